@@ -0,0 +1,251 @@
+// Package actionid parses, canonicalizes and resolves kdeps action IDs.
+//
+// An action ID is either local to the agent declaring it, or qualified with an
+// agent and optionally a version:
+//
+//	fetch                    local
+//	@search/query            qualified, any version
+//	@search/query:1.2.0      qualified, exact version (canonical)
+//	@search/query:^1.2       qualified, version constraint
+//
+// Canonical IDs name an agent, an action and an exact version, which is the form
+// Core.resolveActionID produces through the agent: reader:
+//
+//	id, err := actionid.Canonicalize("fetch", "myAgent", "1.0.0") // @myAgent/fetch:1.0.0
+//
+// Names follow actionStringRegex of Resource.pkl and Workflow.pkl: local IDs
+// and agents are letters, digits and underscores (\w+), and actions within an
+// agent may also contain hyphens ([\w-]+). ValidLocal, ValidAgent and
+// ValidAction check names against it.
+//
+// A Resolver canonicalizes local and qualified IDs against a workflow and the
+// versions of the installed agents.
+package actionid
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kdeps/schema/internal/semver"
+)
+
+var (
+	// ErrInvalid is returned for strings that are not action IDs.
+	ErrInvalid = errors.New("invalid action ID")
+
+	// ErrNotInstalled is returned when resolving an ID of an unknown agent.
+	ErrNotInstalled = errors.New("agent not installed")
+
+	// ErrNoMatchingVersion is returned when no installed version of an agent
+	// satisfies the version of an ID.
+	ErrNoMatchingVersion = errors.New("no matching agent version")
+)
+
+var (
+	localRegex  = regexp.MustCompile(`^\w+$`)
+	agentRegex  = regexp.MustCompile(`^\w+$`)
+	actionRegex = regexp.MustCompile(`^[\w-]+$`)
+)
+
+// ValidLocal reports whether s is a valid local ID.
+func ValidLocal(s string) bool {
+	return localRegex.MatchString(s)
+}
+
+// ValidAgent reports whether s is a valid agent ID.
+func ValidAgent(s string) bool {
+	return agentRegex.MatchString(s)
+}
+
+// ValidAction reports whether s is a valid action ID within an agent.
+func ValidAction(s string) bool {
+	return actionRegex.MatchString(s)
+}
+
+// ID is a parsed action ID.
+type ID struct {
+	// Agent is the agent ID, or empty for a local ID.
+	Agent string
+
+	// Action is the action ID within the agent.
+	Action string
+
+	// Version is an exact version or a version constraint, or empty for any
+	// version. Local IDs have no version.
+	Version string
+}
+
+// Parse parses a local or qualified action ID.
+func Parse(s string) (ID, error) {
+	rest, qualified := strings.CutPrefix(s, "@")
+	if !qualified {
+		if !ValidLocal(s) {
+			return ID{}, fmt.Errorf("%w %q: local IDs may only contain letters, digits and underscores", ErrInvalid, s)
+		}
+		return ID{Action: s}, nil
+	}
+
+	rest, version, versioned := strings.Cut(rest, ":")
+	agent, action, ok := strings.Cut(rest, "/")
+	if !ok || !ValidAgent(agent) || !ValidAction(action) {
+		return ID{}, fmt.Errorf("%w %q: expected @agent/action[:version]", ErrInvalid, s)
+	}
+	if versioned {
+		if version == "" {
+			return ID{}, fmt.Errorf("%w %q: empty version", ErrInvalid, s)
+		}
+		if _, err := semver.ParseConstraint(version); err != nil {
+			return ID{}, fmt.Errorf("%w %q: %w", ErrInvalid, s, err)
+		}
+	}
+	return ID{Agent: agent, Action: action, Version: version}, nil
+}
+
+// MustParse is like Parse but panics on invalid input. It is intended for constants.
+func MustParse(s string) ID {
+	id, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Canonicalize qualifies local with the agent and version of the workflow
+// declaring it. Qualified IDs are returned as parsed.
+func Canonicalize(local, agentID, version string) (ID, error) {
+	id, err := Parse(local)
+	if err != nil || !id.IsLocal() {
+		return id, err
+	}
+	if !ValidAgent(agentID) {
+		return ID{}, fmt.Errorf("%w: invalid agent %q", ErrInvalid, agentID)
+	}
+	if _, err := semver.Parse(version); err != nil {
+		return ID{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	return ID{Agent: agentID, Action: id.Action, Version: version}, nil
+}
+
+// String formats the ID as it is written in PKL.
+func (id ID) String() string {
+	if id.IsLocal() {
+		return id.Action
+	}
+	s := "@" + id.Agent + "/" + id.Action
+	if id.Version != "" {
+		s += ":" + id.Version
+	}
+	return s
+}
+
+// IsLocal reports whether the ID has no agent.
+func (id ID) IsLocal() bool {
+	return id.Agent == ""
+}
+
+// IsCanonical reports whether the ID names an agent and an exact version.
+func (id ID) IsCanonical() bool {
+	if id.IsLocal() || id.Version == "" {
+		return false
+	}
+	c, err := semver.ParseConstraint(id.Version)
+	return err == nil && c.IsExact()
+}
+
+// Matches reports whether version satisfies the version of the ID. IDs without
+// a version match every version.
+func (id ID) Matches(version string) bool {
+	c, err := semver.ParseConstraint(id.Version)
+	return err == nil && c.CheckString(version)
+}
+
+// Equal reports whether id and o name the same action and version. Versions are
+// compared by precedence, so "1.2.0" and "v1.2.0" are equal.
+func (id ID) Equal(o ID) bool {
+	return Compare(id, o) == 0
+}
+
+// Less reports whether id sorts before o.
+func (id ID) Less(o ID) bool {
+	return Compare(id, o) < 0
+}
+
+// Compare orders IDs by agent, with local IDs first, then by action and then by
+// version precedence. It returns -1, 0 or 1.
+func Compare(a, b ID) int {
+	if c := strings.Compare(a.Agent, b.Agent); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Action, b.Action); c != 0 {
+		return c
+	}
+	switch {
+	case a.Version == b.Version:
+		return 0
+	case a.Version == "":
+		return -1
+	case b.Version == "":
+		return 1
+	}
+	return semver.Compare(a.Version, b.Version)
+}
+
+// Resolver resolves action IDs to canonical IDs in the context of a workflow.
+type Resolver struct {
+	// AgentID and Version identify the workflow that local IDs belong to.
+	AgentID string
+	Version string
+
+	// Installed lists the available versions of each agent. The workflow's own
+	// version is always available.
+	Installed map[string][]string
+}
+
+// NewResolver creates a Resolver for the workflow agentID at version.
+func NewResolver(agentID, version string, installed map[string][]string) *Resolver {
+	return &Resolver{AgentID: agentID, Version: version, Installed: installed}
+}
+
+// Resolve parses s and resolves it to a canonical ID. Local IDs belong to the
+// workflow. Qualified IDs resolve to the highest installed version of their
+// agent that satisfies their version; IDs of the workflow's own agent without a
+// version resolve to the workflow's version.
+func (r *Resolver) Resolve(s string) (ID, error) {
+	id, err := Parse(s)
+	if err != nil {
+		return ID{}, err
+	}
+	if id.IsLocal() {
+		return Canonicalize(id.Action, r.AgentID, r.Version)
+	}
+	if id.Agent == r.AgentID && id.Version == "" {
+		id.Version = r.Version
+		return id, nil
+	}
+
+	versions := r.Versions(id.Agent)
+	if len(versions) == 0 {
+		return ID{}, fmt.Errorf("%w: %s", ErrNotInstalled, id)
+	}
+	c, err := semver.ParseConstraint(id.Version)
+	if err != nil {
+		return ID{}, fmt.Errorf("%w %q: %w", ErrInvalid, s, err)
+	}
+	best, ok := c.Best(versions)
+	if !ok {
+		return ID{}, fmt.Errorf("%w: %s (installed: %s)", ErrNoMatchingVersion, id, strings.Join(versions, ", "))
+	}
+	id.Version = best
+	return id, nil
+}
+
+// Versions returns the available versions of an agent.
+func (r *Resolver) Versions(agentID string) []string {
+	versions := append([]string{}, r.Installed[agentID]...)
+	if agentID == r.AgentID && r.Version != "" {
+		versions = append(versions, r.Version)
+	}
+	return versions
+}
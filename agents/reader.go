@@ -0,0 +1,111 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/readeruri"
+)
+
+// Scheme is the URI scheme of the reader.
+const Scheme = "agent"
+
+type config struct {
+	agent   string
+	version string
+}
+
+// Option configures a Reader.
+type Option func(*config)
+
+// WithWorkflow sets the agent and version of the workflow being run, to which
+// local action IDs belong unless their URI sets agent and version.
+func WithWorkflow(agent, version string) Option {
+	return func(c *config) {
+		c.agent = agent
+		c.version = version
+	}
+}
+
+// Reader is the agent: resource reader. It implements the ops of
+// readeruri:
+//
+//	agent:/<actionID>[?agent=<agent>&version=<version>]  the canonical ID, as Registry.Resolve returns it
+//	agent:/?op=list-installed                            JSON array of the installed Agents
+//	agent:/<agent>?op=list[&version=<version>]           JSON array of the canonical IDs of the actions of agent
+//
+// It is safe for concurrent use.
+type Reader struct {
+	registry *Registry
+	agent    string
+	version  string
+}
+
+var _ pkl.ResourceReader = (*Reader)(nil)
+
+// NewReader creates a reader over registry.
+func NewReader(registry *Registry, opts ...Option) *Reader {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Reader{registry: registry, agent: c.agent, version: c.version}
+}
+
+// Registry returns the registry of the reader.
+func (r *Reader) Registry() *Registry {
+	return r.registry
+}
+
+func (r *Reader) Scheme() string {
+	return Scheme
+}
+
+func (r *Reader) IsGlobbable() bool {
+	return false
+}
+
+func (r *Reader) HasHierarchicalUris() bool {
+	return false
+}
+
+func (r *Reader) ListElements(url.URL) ([]pkl.PathElement, error) {
+	return nil, nil
+}
+
+// Read performs the op of uri.
+func (r *Reader) Read(uri url.URL) ([]byte, error) {
+	if uri.Scheme != Scheme {
+		return nil, fmt.Errorf("unsupported scheme %q, expected %q", uri.Scheme, Scheme)
+	}
+	op, err := readeruri.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	switch op := op.(type) {
+	case *readeruri.AgentResolve:
+		agent, version := op.Agent, op.Version
+		if agent == "" {
+			agent, version = r.agent, r.version
+		} else if agent == r.agent && version == "" {
+			version = r.version
+		}
+		id, err := r.registry.Resolve(op.ActionID, agent, version)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(id), nil
+	case *readeruri.AgentListInstalled:
+		return json.Marshal(r.registry.Agents())
+	case *readeruri.AgentList:
+		ids, err := r.registry.List(op.Agent, op.Version)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(ids)
+	default:
+		return nil, fmt.Errorf("unsupported op %s", uri.String())
+	}
+}
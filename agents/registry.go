@@ -0,0 +1,361 @@
+// Package agents indexes the installed agents and implements the agent:
+// resource reader, through which Core.resolveActionID and Agent.resolveActionID
+// canonicalize action IDs.
+//
+// Agents are installed one directory per version, with the workflow and
+// resources of the version:
+//
+//	agents/<name>/<version>/workflow.pkl
+//	agents/<name>/<version>/resources/*.pkl
+//
+// A Registry indexes the action IDs declared (ActionID = "...") by the modules
+// of each version and resolves IDs against them with an actionid.Resolver:
+//
+//	reg, err := agents.LoadDir("/root/.kdeps/agents")
+//	if err != nil {
+//	    return err
+//	}
+//	reg.Resolve("@search/query", "", "")     // @search/query:2.1.0, the highest version declaring query
+//	reg.Resolve("fetch", "myAgent", "1.0.0") // @myAgent/fetch:1.0.0
+//
+//	reader := agents.NewReader(reg, agents.WithWorkflow("myAgent", "1.0.0"))
+//	l, err := loader.New(ctx, loader.WithResourceReaders(reader))
+//
+// IDs that no installed agent declares fail with a *NotFoundError, and local
+// IDs declared by several agents, when resolved without a workflow, with an
+// *AmbiguousError.
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kdeps/schema/actionid"
+	"github.com/kdeps/schema/internal/semver"
+)
+
+// WorkflowFile is the module of an agent version, next to its resources
+// directory.
+const WorkflowFile = "workflow.pkl"
+
+var actionIDRegex = regexp.MustCompile(`(?m)^\s*ActionID\s*=\s*"([^"]+)"`)
+
+// Agent is an installed version of an agent.
+type Agent struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+
+	// Actions are the action IDs declared by the workflow and resources of the
+	// version, sorted.
+	Actions []string `json:"actions"`
+}
+
+// Provides reports whether the version declares action.
+func (a *Agent) Provides(action string) bool {
+	i := sort.SearchStrings(a.Actions, action)
+	return i < len(a.Actions) && a.Actions[i] == action
+}
+
+// ID returns the canonical ID of action in the version.
+func (a *Agent) ID(action string) string {
+	return "@" + a.Name + "/" + action + ":" + a.Version
+}
+
+// NotFoundError is an action or agent ID that no installed agent matches.
+type NotFoundError struct {
+	ID string
+
+	// Agent is the agent of ID, or empty for a local ID resolved without a
+	// workflow.
+	Agent string
+
+	// Installed are the installed versions of Agent, none if it is not
+	// installed.
+	Installed []string
+}
+
+func (e *NotFoundError) Error() string {
+	switch {
+	case e.Agent == "":
+		return fmt.Sprintf("action %q is not declared by any installed agent", e.ID)
+	case len(e.Installed) == 0:
+		return fmt.Sprintf("%s: agent %q is not installed", e.ID, e.Agent)
+	}
+	return fmt.Sprintf("%s: no installed version of agent %q matches (installed: %s)", e.ID, e.Agent, strings.Join(e.Installed, ", "))
+}
+
+// AmbiguousError is a local action ID, resolved without a workflow, that
+// several agents declare.
+type AmbiguousError struct {
+	ID string
+
+	// Candidates are the canonical IDs of the action in the highest version of
+	// each agent declaring it.
+	Candidates []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("action %q is declared by several agents: %s", e.ID, strings.Join(e.Candidates, ", "))
+}
+
+// Registry is the index of the installed agents. It is immutable and safe for
+// concurrent use; load a new one to pick up installs.
+type Registry struct {
+	// agents maps the names of the agents to their versions, sorted by
+	// ascending precedence.
+	agents map[string][]*Agent
+}
+
+// LoadDir indexes the agents installed in dir.
+func LoadDir(dir string) (*Registry, error) {
+	return Load(os.DirFS(dir))
+}
+
+// Load indexes the agents installed at the root of fsys. Entries that are not
+// directories, version directories that are not valid versions and versions
+// without a workflow.pkl are skipped.
+func Load(fsys fs.FS) (*Registry, error) {
+	names, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agents directory: %w", err)
+	}
+	r := &Registry{agents: make(map[string][]*Agent)}
+	for _, name := range names {
+		if !name.IsDir() || !actionid.ValidAgent(name.Name()) {
+			continue
+		}
+		versions, err := fs.ReadDir(fsys, name.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read versions of agent %s: %w", name.Name(), err)
+		}
+		for _, version := range versions {
+			if !version.IsDir() || !semver.IsValid(version.Name()) {
+				continue
+			}
+			a, err := loadAgent(fsys, name.Name(), version.Name())
+			if err != nil {
+				return nil, err
+			}
+			if a != nil {
+				r.agents[a.Name] = append(r.agents[a.Name], a)
+			}
+		}
+		sort.Slice(r.agents[name.Name()], func(i, j int) bool {
+			vs := r.agents[name.Name()]
+			return semver.Compare(vs[i].Version, vs[j].Version) < 0
+		})
+	}
+	return r, nil
+}
+
+// loadAgent indexes the actions of a version, or returns nil if it has no
+// workflow.
+func loadAgent(fsys fs.FS, name, version string) (*Agent, error) {
+	dir := path.Join(name, version)
+	files := []string{path.Join(dir, WorkflowFile)}
+	if _, err := fs.Stat(fsys, files[0]); err != nil {
+		return nil, nil
+	}
+	resources := path.Join(dir, "resources")
+	if _, err := fs.Stat(fsys, resources); err == nil {
+		err := fs.WalkDir(fsys, resources, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && path.Ext(p) == ".pkl" {
+				files = append(files, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read resources of %s: %w", dir, err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	a := &Agent{Name: name, Version: version, Actions: []string{}}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		for _, m := range actionIDRegex.FindAllStringSubmatch(string(data), -1) {
+			action := m[1]
+			// Resources may declare their ID in canonical form.
+			if strings.HasPrefix(action, "@") {
+				id, err := actionid.Parse(action)
+				if err != nil || id.Agent != name {
+					continue
+				}
+				action = id.Action
+			}
+			if actionid.ValidAction(action) && !seen[action] {
+				seen[action] = true
+				a.Actions = append(a.Actions, action)
+			}
+		}
+	}
+	sort.Strings(a.Actions)
+	return a, nil
+}
+
+// Agents returns the installed versions of all agents, sorted by name and
+// version.
+func (r *Registry) Agents() []Agent {
+	all := []Agent{}
+	for _, name := range r.names() {
+		for _, a := range r.agents[name] {
+			all = append(all, *a)
+		}
+	}
+	return all
+}
+
+// Agent returns an installed version of an agent.
+func (r *Registry) Agent(name, version string) (Agent, bool) {
+	for _, a := range r.agents[name] {
+		if semver.Compare(a.Version, version) == 0 {
+			return *a, true
+		}
+	}
+	return Agent{}, false
+}
+
+// Versions returns the installed versions of an agent, sorted by ascending
+// precedence.
+func (r *Registry) Versions(name string) []string {
+	versions := make([]string, 0, len(r.agents[name]))
+	for _, a := range r.agents[name] {
+		versions = append(versions, a.Version)
+	}
+	return versions
+}
+
+// Installed returns the installed versions of each agent, as
+// actionid.NewResolver takes them.
+func (r *Registry) Installed() map[string][]string {
+	installed := make(map[string][]string, len(r.agents))
+	for name := range r.agents {
+		installed[name] = r.Versions(name)
+	}
+	return installed
+}
+
+// List returns the canonical IDs of the actions of an agent at version, or at
+// its highest version if version is empty.
+func (r *Registry) List(name, version string) ([]string, error) {
+	id := "@" + name
+	if version != "" {
+		id += ":" + version
+	}
+	a, err := r.best(id, name, version, "")
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(a.Actions))
+	for _, action := range a.Actions {
+		ids = append(ids, a.ID(action))
+	}
+	return ids, nil
+}
+
+// Resolve returns the canonical form of an action ID, as an actionid.Resolver
+// over the installed versions declaring the action resolves it:
+//
+//	@agent/action[:version]  the highest version matching version that declares action
+//	action                   @agent/action:version, of the workflow agent at version
+//
+// Local IDs belong to the workflow agent and version, and are not checked
+// against its actions, since the workflow being run need not be installed. If
+// version is empty, the highest installed version of agent is used. If agent
+// is empty too, local IDs resolve to the agent declaring them, in its highest
+// version declaring them. Qualified IDs ignore agent and version.
+func (r *Registry) Resolve(id, agent, version string) (string, error) {
+	parsed, err := actionid.Parse(id)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !parsed.IsLocal():
+		agent, version = "", ""
+	case agent == "":
+		return r.search(parsed.Action)
+	case version == "":
+		a, err := r.best("@"+agent+"/"+id, agent, "", "")
+		if err != nil {
+			return "", err
+		}
+		version = a.Version
+	}
+
+	resolved, err := actionid.NewResolver(agent, version, r.declaring(parsed)).Resolve(id)
+	if errors.Is(err, actionid.ErrNotInstalled) || errors.Is(err, actionid.ErrNoMatchingVersion) {
+		return "", &NotFoundError{ID: id, Agent: parsed.Agent, Installed: r.Versions(parsed.Agent)}
+	}
+	if err != nil {
+		return "", err
+	}
+	return resolved.String(), nil
+}
+
+// declaring returns the installed versions of the agent of a qualified ID that
+// declare its action, as actionid.NewResolver takes them.
+func (r *Registry) declaring(id actionid.ID) map[string][]string {
+	if id.IsLocal() {
+		return nil
+	}
+	versions := []string{}
+	for _, a := range r.agents[id.Agent] {
+		if a.Provides(id.Action) {
+			versions = append(versions, a.Version)
+		}
+	}
+	return map[string][]string{id.Agent: versions}
+}
+
+// best returns the highest version of agent matching constraint that declares
+// action, if not empty.
+func (r *Registry) best(id, agent, constraint, action string) (*Agent, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid action ID %q: %w", id, err)
+	}
+	versions := r.agents[agent]
+	for i := len(versions) - 1; i >= 0; i-- {
+		a := versions[i]
+		if c.CheckString(a.Version) && (action == "" || a.Provides(action)) {
+			return a, nil
+		}
+	}
+	return nil, &NotFoundError{ID: id, Agent: agent, Installed: r.Versions(agent)}
+}
+
+// search resolves a local ID against all agents.
+func (r *Registry) search(action string) (string, error) {
+	var candidates []string
+	for _, name := range r.names() {
+		if a, err := r.best(action, name, "", action); err == nil {
+			candidates = append(candidates, a.ID(action))
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return "", &NotFoundError{ID: action}
+	case 1:
+		return candidates[0], nil
+	}
+	return "", &AmbiguousError{ID: action, Candidates: candidates}
+}
+
+// names returns the sorted names of the installed agents.
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.agents))
+	for name := range r.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
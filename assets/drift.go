@@ -0,0 +1,419 @@
+package assets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DriftReport describes differences between the PKL sources in deps/pkl, the
+// copies embedded from assets/pkl, the embedded manifest and the gen/ bindings.
+type DriftReport struct {
+	// SourceOnly lists files present in deps/pkl but not in assets/pkl.
+	SourceOnly []string
+
+	// AssetsOnly lists files present in assets/pkl but not in deps/pkl.
+	AssetsOnly []string
+
+	// Modified lists files whose contents differ between deps/pkl and assets/pkl.
+	Modified []string
+
+	// StaleManifest is true when manifest.json does not describe assets/pkl.
+	StaleManifest bool
+
+	// Generated lists mismatches between PKL class properties in deps/pkl and the gen/ structs.
+	Generated []GeneratedDrift
+}
+
+// GeneratedDrift is a single mismatch between a PKL property and its generated Go field.
+type GeneratedDrift struct {
+	// Module is the PKL module name, e.g. "org.kdeps.pkl.Exec".
+	Module string
+
+	// Class is the PKL class name, or empty for module-level properties.
+	Class string
+
+	// Property is the PKL property name.
+	Property string
+
+	// Problem describes the mismatch.
+	Problem string
+}
+
+func (d GeneratedDrift) String() string {
+	name := d.Module
+	if d.Class != "" {
+		name += "#" + d.Class
+	}
+	if d.Property != "" {
+		name += "." + d.Property
+	}
+	return fmt.Sprintf("%s: %s", name, d.Problem)
+}
+
+// HasDrift reports whether any drift was detected.
+func (r *DriftReport) HasDrift() bool {
+	return len(r.SourceOnly) > 0 || len(r.AssetsOnly) > 0 || len(r.Modified) > 0 ||
+		r.StaleManifest || len(r.Generated) > 0
+}
+
+func (r *DriftReport) String() string {
+	if !r.HasDrift() {
+		return "no drift detected"
+	}
+	var b strings.Builder
+	if len(r.SourceOnly) > 0 {
+		fmt.Fprintf(&b, "only in deps/pkl: %v\n", r.SourceOnly)
+	}
+	if len(r.AssetsOnly) > 0 {
+		fmt.Fprintf(&b, "only in assets/pkl: %v\n", r.AssetsOnly)
+	}
+	if len(r.Modified) > 0 {
+		fmt.Fprintf(&b, "deps/pkl and assets/pkl differ: %v\n", r.Modified)
+	}
+	if r.StaleManifest {
+		fmt.Fprintf(&b, "assets/%s is out of date\n", ManifestFilename)
+	}
+	for _, g := range r.Generated {
+		fmt.Fprintf(&b, "gen: %s\n", g)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// DetectDrift compares deps/pkl, assets/pkl, the embedded manifest and gen/ inside
+// the repository at repoRoot. It is intended for development and CI; deployed
+// binaries should use VerifyIntegrity instead.
+func DetectDrift(repoRoot string) (*DriftReport, error) {
+	depsDir := filepath.Join(repoRoot, "deps", "pkl")
+	assetsDir := filepath.Join(repoRoot, "assets", "pkl")
+
+	source, err := BuildManifest(os.DirFS(depsDir), SchemaVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", depsDir, err)
+	}
+	copied, err := BuildManifest(os.DirFS(assetsDir), SchemaVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", assetsDir, err)
+	}
+
+	report := &DriftReport{}
+	for _, entry := range source.Files {
+		other, ok := copied.Lookup(entry.Name)
+		switch {
+		case !ok:
+			report.SourceOnly = append(report.SourceOnly, entry.Name)
+		case other.SHA256 != entry.SHA256:
+			report.Modified = append(report.Modified, entry.Name)
+		}
+	}
+	for _, entry := range copied.Files {
+		if _, ok := source.Lookup(entry.Name); !ok {
+			report.AssetsOnly = append(report.AssetsOnly, entry.Name)
+		}
+	}
+
+	embedded, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	report.StaleManifest = !manifestEqual(embedded, copied)
+
+	report.Generated, err = detectGeneratedDrift(depsDir, filepath.Join(repoRoot, "gen"))
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+var (
+	goPackageRegex  = regexp.MustCompile(`@go\.Package\s*\{\s*name\s*=\s*"github\.com/kdeps/schema/gen/([\w/]+)"`)
+	pklClassRegex   = regexp.MustCompile(`^\s*(?:(?:open|abstract|external)\s+)*class\s+(\w+)`)
+	pklPropRegex    = regexp.MustCompile(`^\s*((?:(?:hidden|local|fixed|const|abstract|external)\s+)*)([A-Za-z_]\w*)\s*:\s*(.+)$`)
+	pklStringsRegex = regexp.MustCompile(`#"(?:[^"]|"[^#])*"#|"(?:[^"\\]|\\.)*"`)
+)
+
+// pklProperty is a property declared in a PKL module or class.
+type pklProperty struct {
+	class    string
+	name     string
+	nullable bool
+}
+
+// detectGeneratedDrift compares the properties declared in each PKL module with the
+// pkl-tagged fields of the structs registered for it in gen/.
+func detectGeneratedDrift(depsDir, genDir string) ([]GeneratedDrift, error) {
+	files, err := listPKLFiles(os.DirFS(depsDir), ".")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var drift []GeneratedDrift
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(depsDir, file))
+		if err != nil {
+			return nil, err
+		}
+		pkgMatch := goPackageRegex.FindSubmatch(data)
+		modMatch := moduleNameRegex.FindSubmatch(data)
+		if pkgMatch == nil || modMatch == nil {
+			continue
+		}
+		module := string(modMatch[1])
+
+		pkgDir := filepath.Join(genDir, filepath.FromSlash(string(pkgMatch[1])))
+		structs, err := parseGeneratedPackage(pkgDir, module)
+		if err != nil {
+			if os.IsNotExist(err) {
+				drift = append(drift, GeneratedDrift{Module: module, Problem: "generated package " + string(pkgMatch[1]) + " is missing"})
+				continue
+			}
+			return nil, err
+		}
+
+		props := parsePKLProperties(data)
+		seen := make(map[string]map[string]bool)
+		for _, prop := range props {
+			fields, ok := structs[prop.class]
+			if !ok {
+				drift = append(drift, GeneratedDrift{Module: module, Class: prop.class, Problem: "no generated struct"})
+				structs[prop.class] = map[string]goField{}
+				continue
+			}
+			if seen[prop.class] == nil {
+				seen[prop.class] = make(map[string]bool)
+			}
+			seen[prop.class][prop.name] = true
+
+			field, ok := fields[prop.name]
+			switch {
+			case !ok:
+				drift = append(drift, GeneratedDrift{Module: module, Class: prop.class, Property: prop.name, Problem: "missing from generated struct"})
+			case field.nullabilityMismatch(prop.nullable):
+				want := "non-nullable"
+				if prop.nullable {
+					want = "nullable"
+				}
+				drift = append(drift, GeneratedDrift{Module: module, Class: prop.class, Property: prop.name, Problem: "PKL property is " + want + " but generated field type is " + field.typ})
+			}
+		}
+
+		classes := make([]string, 0, len(structs))
+		for class := range structs {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			names := make([]string, 0, len(structs[class]))
+			for name := range structs[class] {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if !seen[class][name] {
+					drift = append(drift, GeneratedDrift{Module: module, Class: class, Property: name, Problem: "not declared in PKL"})
+				}
+			}
+		}
+	}
+	return drift, nil
+}
+
+// parsePKLProperties extracts the non-hidden properties declared at module level or
+// directly inside class bodies. Class properties are keyed by class name; module
+// properties use an empty class.
+func parsePKLProperties(data []byte) []pklProperty {
+	var props []pklProperty
+	depth := 0
+	class := ""
+	classDepth := -1
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		code := pklStringsRegex.ReplaceAllString(line, `""`)
+		if i := strings.Index(code, "//"); i >= 0 {
+			code = code[:i]
+		}
+
+		if m := pklClassRegex.FindStringSubmatch(code); m != nil && depth == 0 {
+			class = m[1]
+			classDepth = depth
+		} else if m := pklPropRegex.FindStringSubmatch(code); m != nil &&
+			m[1] == "" && !isPKLKeyword(m[2]) &&
+			((class == "" && depth == 0) || (class != "" && depth == classDepth+1)) {
+			typ := m[3]
+			if i := strings.Index(typ, " ="); i >= 0 {
+				typ = typ[:i]
+			}
+			props = append(props, pklProperty{
+				class:    class,
+				name:     m[2],
+				nullable: strings.HasSuffix(strings.TrimSpace(typ), "?"),
+			})
+		}
+
+		depth += strings.Count(code, "{") - strings.Count(code, "}")
+		if class != "" && depth <= classDepth {
+			class = ""
+			classDepth = -1
+		}
+	}
+	return props
+}
+
+func isPKLKeyword(word string) bool {
+	switch word {
+	case "function", "let", "import", "extends", "amends", "module", "class", "typealias", "when", "for", "if", "else", "new":
+		return true
+	}
+	return false
+}
+
+// goField is a pkl-tagged struct field in a generated package.
+type goField struct {
+	typ     string
+	pointer bool
+}
+
+// nullabilityMismatch reports whether the Go field type contradicts the PKL nullability.
+// pkl-gen-go renders nullable properties as pointers, except `Any` which becomes `any`.
+// Class-typed properties are pointers even when non-nullable, so the reverse check is
+// limited to primitive and collection types.
+func (f goField) nullabilityMismatch(nullable bool) bool {
+	if f.typ == "any" {
+		return false
+	}
+	if nullable {
+		return !f.pointer
+	}
+	if !f.pointer {
+		return false
+	}
+	elem := strings.TrimPrefix(f.typ, "*")
+	switch elem {
+	case "string", "bool", "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "float64":
+		return true
+	}
+	return strings.HasPrefix(elem, "[]") || strings.HasPrefix(elem, "map[")
+}
+
+// parseGeneratedPackage returns the pkl-tagged fields of the structs registered for
+// module in pkgDir, keyed by PKL class name ("" for the module class).
+func parseGeneratedPackage(pkgDir, module string) (map[string]map[string]goField, error) {
+	entries, err := os.ReadDir(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	structTypes := make(map[string]*ast.StructType)
+	mappings := make(map[string]string) // struct name -> PKL class
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(pkgDir, entry.Name()), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.TypeSpec:
+				if st, ok := node.Type.(*ast.StructType); ok {
+					structTypes[node.Name.Name] = st
+				}
+			case *ast.CallExpr:
+				if class, structName, ok := registerMapping(node, module); ok {
+					mappings[structName] = class
+				}
+			}
+			return true
+		})
+	}
+
+	result := make(map[string]map[string]goField)
+	for structName, class := range mappings {
+		st, ok := structTypes[structName]
+		if !ok {
+			continue
+		}
+		fields := make(map[string]goField)
+		for _, field := range st.Fields.List {
+			if field.Tag == nil {
+				continue
+			}
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				continue
+			}
+			name := reflect.StructTag(tag).Get("pkl")
+			if name == "" {
+				continue
+			}
+			typ := exprString(field.Type)
+			_, pointer := field.Type.(*ast.StarExpr)
+			fields[name] = goField{typ: typ, pointer: pointer}
+		}
+		result[class] = fields
+	}
+	return result, nil
+}
+
+// registerMapping matches pkl.RegisterMapping("<module>[#Class]", Struct{}) calls.
+func registerMapping(call *ast.CallExpr, module string) (class, structName string, ok bool) {
+	sel, isSel := call.Fun.(*ast.SelectorExpr)
+	if !isSel || sel.Sel.Name != "RegisterMapping" || len(call.Args) != 2 {
+		return "", "", false
+	}
+	lit, isLit := call.Args[0].(*ast.BasicLit)
+	comp, isComp := call.Args[1].(*ast.CompositeLit)
+	if !isLit || !isComp {
+		return "", "", false
+	}
+	name, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", "", false
+	}
+	ident, isIdent := comp.Type.(*ast.Ident)
+	if !isIdent {
+		return "", "", false
+	}
+
+	switch {
+	case name == module:
+		return "", ident.Name, true
+	case strings.HasPrefix(name, module+"#"):
+		return strings.TrimPrefix(name, module+"#"), ident.Name, true
+	}
+	return "", "", false
+}
+
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + exprString(e.Elt)
+	case *ast.MapType:
+		return "map[" + exprString(e.Key) + "]" + exprString(e.Value)
+	case *ast.InterfaceType:
+		return "any"
+	}
+	return fmt.Sprintf("%T", expr)
+}
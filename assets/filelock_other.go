@@ -0,0 +1,18 @@
+//go:build !unix
+
+package assets
+
+import "os"
+
+// Advisory file locks are not available on this platform. Extraction into the
+// shared cache still goes through a temporary directory and an atomic rename,
+// but garbage collection never removes other versions because it cannot tell
+// whether they are in use.
+
+func lockExclusive(f *os.File) error { return nil }
+
+func lockShared(f *os.File) error { return nil }
+
+func tryLockExclusive(f *os.File) (bool, error) { return false, nil }
+
+func unlock(f *os.File) error { return nil }
@@ -0,0 +1,45 @@
+//go:build unix
+
+package assets
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockExclusive blocks until an exclusive advisory lock on f is held.
+func lockExclusive(f *os.File) error {
+	return flock(f, syscall.LOCK_EX)
+}
+
+// lockShared blocks until a shared advisory lock on f is held. If f already
+// holds an exclusive lock, it is converted to a shared one; the conversion is
+// not atomic, and another process may take the lock in between.
+func lockShared(f *os.File) error {
+	return flock(f, syscall.LOCK_SH)
+}
+
+// tryLockExclusive acquires an exclusive lock on f without blocking. It returns
+// false if another holder has a lock on the file.
+func tryLockExclusive(f *os.File) (bool, error) {
+	err := flock(f, syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlock releases any lock held on f.
+func unlock(f *os.File) error {
+	return flock(f, syscall.LOCK_UN)
+}
+
+func flock(f *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			return err
+		}
+	}
+}
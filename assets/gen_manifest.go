@@ -0,0 +1,32 @@
+//go:build ignore
+
+// gen_manifest writes manifest.json describing the PKL files in assets/pkl.
+// Run from the assets directory: go run gen_manifest.go
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kdeps/schema/assets"
+)
+
+func main() {
+	manifest, err := assets.BuildManifest(os.DirFS("pkl"), assets.SchemaVersion)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build manifest: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := manifest.MarshalIndent()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode manifest: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(assets.ManifestFilename, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write %s: %v\n", assets.ManifestFilename, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s with %d files\n", assets.ManifestFilename, len(manifest.Files))
+}
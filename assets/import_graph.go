@@ -0,0 +1,482 @@
+package assets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ImportKind classifies the target of an import, extends or amends clause.
+type ImportKind int
+
+const (
+	// ImportLocal is a relative import of another schema file, e.g. "Project.pkl".
+	ImportLocal ImportKind = iota
+	// ImportPackage is a PKL package import, e.g. "package://pkg.pkl-lang.org/...".
+	ImportPackage
+	// ImportStdlib is a PKL standard library import, e.g. "pkl:json".
+	ImportStdlib
+	// ImportExternal is any other absolute URI, e.g. "https:" or "kdeps-schema:".
+	ImportExternal
+)
+
+func (k ImportKind) String() string {
+	switch k {
+	case ImportLocal:
+		return "local"
+	case ImportPackage:
+		return "package"
+	case ImportStdlib:
+		return "stdlib"
+	default:
+		return "external"
+	}
+}
+
+// Import is a single import, extends or amends clause of a module.
+type Import struct {
+	// From is the importing file, e.g. "Resource.pkl".
+	From string
+
+	// URI is the imported URI as written in the source.
+	URI string
+
+	// Kind classifies the URI.
+	Kind ImportKind
+
+	// Clause is "import", "import*", "extends" or "amends".
+	Clause string
+
+	// Alias is the name given with `as`, if any.
+	Alias string
+
+	// Line is the 1-based source line of the clause.
+	Line int
+
+	// Targets are the schema files a local import resolves to. Glob imports
+	// may resolve to several files; missing files resolve to none.
+	Targets []string
+}
+
+// ModuleGraph is the import graph of a set of PKL files.
+type ModuleGraph struct {
+	modules []string
+	imports map[string][]Import
+	deps    map[string][]string
+}
+
+// CycleError is returned when an operation requires an acyclic graph.
+type CycleError struct {
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	parts := make([]string, 0, len(e.Cycles))
+	for _, cycle := range e.Cycles {
+		parts = append(parts, strings.Join(cycle, " -> "))
+	}
+	return fmt.Sprintf("import cycle detected: %s", strings.Join(parts, "; "))
+}
+
+var (
+	importClauseRegex = regexp.MustCompile(`^\s*(import\*|import|extends|amends)\s+"([^"]+)"(?:\s+as\s+(\w+))?`)
+	uriSchemeRegex    = regexp.MustCompile(`^[A-Za-z][\w+.-]*:`)
+)
+
+// ImportGraph returns the import graph of the embedded schema files.
+func ImportGraph() (*ModuleGraph, error) {
+	return BuildImportGraph(schemaFS())
+}
+
+// BuildImportGraph parses the import, extends and amends clauses of the .pkl
+// files at the root of fsys.
+func BuildImportGraph(fsys fs.FS) (*ModuleGraph, error) {
+	files, err := listPKLFiles(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKL files: %w", err)
+	}
+	sort.Strings(files)
+
+	g := &ModuleGraph{
+		modules: files,
+		imports: make(map[string][]Import, len(files)),
+		deps:    make(map[string][]string, len(files)),
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		imports, err := parseImports(fsys, file, data)
+		if err != nil {
+			return nil, err
+		}
+		g.imports[file] = imports
+
+		seen := make(map[string]bool)
+		for _, imp := range imports {
+			for _, target := range imp.Targets {
+				if !seen[target] {
+					seen[target] = true
+					g.deps[file] = append(g.deps[file], target)
+				}
+			}
+		}
+		sort.Strings(g.deps[file])
+	}
+	return g, nil
+}
+
+func parseImports(fsys fs.FS, file string, data []byte) ([]Import, error) {
+	var imports []Import
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		m := importClauseRegex.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		imp := Import{From: file, Clause: m[1], URI: m[2], Alias: m[3], Line: line, Kind: classifyImport(m[2])}
+		if imp.Kind == ImportLocal {
+			target := path.Clean(path.Join(path.Dir(file), imp.URI))
+			if imp.Clause == "import*" {
+				matches, err := fs.Glob(fsys, target)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: invalid glob import %q: %w", file, line, imp.URI, err)
+				}
+				imp.Targets = matches
+			} else if _, err := fs.Stat(fsys, target); err == nil {
+				imp.Targets = []string{target}
+			}
+		}
+		imports = append(imports, imp)
+	}
+	return imports, scanner.Err()
+}
+
+func classifyImport(uri string) ImportKind {
+	switch {
+	case strings.HasPrefix(uri, "pkl:"):
+		return ImportStdlib
+	case strings.HasPrefix(uri, "package://"):
+		return ImportPackage
+	case uriSchemeRegex.MatchString(uri):
+		return ImportExternal
+	}
+	return ImportLocal
+}
+
+// Modules returns the files in the graph in lexical order.
+func (g *ModuleGraph) Modules() []string {
+	return append([]string(nil), g.modules...)
+}
+
+// Imports returns every clause of a module, in source order.
+func (g *ModuleGraph) Imports(module string) []Import {
+	return append([]Import(nil), g.imports[module]...)
+}
+
+// Dependencies returns the schema files a module directly depends on.
+func (g *ModuleGraph) Dependencies(module string) []string {
+	return append([]string(nil), g.deps[module]...)
+}
+
+// Dependents returns the schema files that directly depend on module.
+func (g *ModuleGraph) Dependents(module string) []string {
+	var dependents []string
+	for _, m := range g.modules {
+		for _, dep := range g.deps[m] {
+			if dep == module {
+				dependents = append(dependents, m)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// ImportsOfKind returns the distinct URIs of the given kind imported by any module.
+func (g *ModuleGraph) ImportsOfKind(kind ImportKind) []string {
+	seen := make(map[string]bool)
+	var uris []string
+	for _, m := range g.modules {
+		for _, imp := range g.imports[m] {
+			if imp.Kind == kind && !seen[imp.URI] {
+				seen[imp.URI] = true
+				uris = append(uris, imp.URI)
+			}
+		}
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// Unresolved returns local imports that do not resolve to any schema file.
+func (g *ModuleGraph) Unresolved() []Import {
+	var unresolved []Import
+	for _, m := range g.modules {
+		for _, imp := range g.imports[m] {
+			if imp.Kind == ImportLocal && len(imp.Targets) == 0 {
+				unresolved = append(unresolved, imp)
+			}
+		}
+	}
+	return unresolved
+}
+
+// Cycles returns every import cycle as a path that starts and ends at the same file.
+func (g *ModuleGraph) Cycles() [][]string {
+	return g.cycles(g.modules)
+}
+
+// cycles returns the import cycles among modules, ignoring imports of other
+// modules.
+func (g *ModuleGraph) cycles(modules []string) [][]string {
+	var cycles [][]string
+	for _, scc := range g.stronglyConnected(modules) {
+		members := make(map[string]bool, len(scc))
+		for _, m := range scc {
+			members[m] = true
+		}
+		if len(scc) == 1 && !g.dependsOn(scc[0], scc[0]) {
+			continue
+		}
+		cycles = append(cycles, g.cyclePath(scc[0], members))
+	}
+	return cycles
+}
+
+func (g *ModuleGraph) dependsOn(from, to string) bool {
+	for _, dep := range g.deps[from] {
+		if dep == to {
+			return true
+		}
+	}
+	return false
+}
+
+// cyclePath finds the shortest path from start back to itself within members.
+func (g *ModuleGraph) cyclePath(start string, members map[string]bool) []string {
+	prev := map[string]string{}
+	queue := []string{start}
+	visited := map[string]bool{}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.deps[cur] {
+			if !members[dep] {
+				continue
+			}
+			if dep == start {
+				path := []string{start}
+				for n := cur; n != start; n = prev[n] {
+					path = append([]string{n}, path...)
+				}
+				return append([]string{start}, path...)
+			}
+			if !visited[dep] {
+				visited[dep] = true
+				prev[dep] = cur
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return []string{start}
+}
+
+// stronglyConnected returns the strongly connected components of the subgraph of
+// modules using Tarjan's algorithm.
+func (g *ModuleGraph) stronglyConnected(modules []string) [][]string {
+	include := make(map[string]bool, len(modules))
+	for _, m := range modules {
+		include[m] = true
+	}
+	index := 0
+	indices := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var result [][]string
+
+	var visit func(string)
+	visit = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.deps[v] {
+			if !include[w] {
+				continue
+			}
+			if _, ok := indices[w]; !ok {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], indices[w])
+			}
+		}
+
+		if lowlink[v] == indices[v] {
+			var scc []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sort.Strings(scc)
+			result = append(result, scc)
+		}
+	}
+
+	for _, m := range modules {
+		if _, ok := indices[m]; !ok {
+			visit(m)
+		}
+	}
+	return result
+}
+
+// TopologicalOrder returns the modules ordered so that every module comes after
+// the modules it imports. It returns a *CycleError if the graph has cycles.
+func (g *ModuleGraph) TopologicalOrder() ([]string, error) {
+	return g.topologicalOrder(g.modules)
+}
+
+// topologicalOrder orders modules, failing only on cycles among them.
+func (g *ModuleGraph) topologicalOrder(modules []string) ([]string, error) {
+	if cycles := g.cycles(modules); len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+
+	include := make(map[string]bool, len(modules))
+	for _, m := range modules {
+		include[m] = true
+	}
+
+	remaining := make(map[string]int, len(modules))
+	for _, m := range modules {
+		for _, dep := range g.deps[m] {
+			if include[dep] {
+				remaining[m]++
+			}
+		}
+	}
+
+	var ready []string
+	for _, m := range modules {
+		if remaining[m] == 0 {
+			ready = append(ready, m)
+		}
+	}
+
+	order := make([]string, 0, len(modules))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		m := ready[0]
+		ready = ready[1:]
+		order = append(order, m)
+		for _, dependent := range g.Dependents(m) {
+			if !include[dependent] {
+				continue
+			}
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return order, nil
+}
+
+// Closure returns the entry modules and every schema file they transitively import,
+// in topological order. This is the minimal file set needed to evaluate the entries.
+func (g *ModuleGraph) Closure(entries ...string) ([]string, error) {
+	seen := make(map[string]bool)
+	var walk func(string)
+	walk = func(m string) {
+		if seen[m] {
+			return
+		}
+		seen[m] = true
+		for _, dep := range g.deps[m] {
+			walk(dep)
+		}
+	}
+
+	known := make(map[string]bool, len(g.modules))
+	for _, m := range g.modules {
+		known[m] = true
+	}
+	for _, entry := range entries {
+		if !known[entry] {
+			return nil, fmt.Errorf("module %s not found in import graph", entry)
+		}
+		walk(entry)
+	}
+
+	modules := make([]string, 0, len(seen))
+	for _, m := range g.modules {
+		if seen[m] {
+			modules = append(modules, m)
+		}
+	}
+
+	order, err := g.topologicalOrder(modules)
+	if err != nil {
+		// Cycles do not prevent extraction; fall back to lexical order.
+		return modules, nil
+	}
+	return order, nil
+}
+
+// ExtractPKLSubsetToDir extracts the given entry modules and their transitive local
+// imports to dir. If dir is empty, uses a temporary directory in system tmpdir.
+// Returns the directory path.
+func ExtractPKLSubsetToDir(dir string, entries ...string) (string, error) {
+	graph, err := ImportGraph()
+	if err != nil {
+		return "", err
+	}
+	files, err := graph.Closure(entries...)
+	if err != nil {
+		return "", err
+	}
+
+	if dir == "" {
+		tmpDir := os.TempDir()
+		dir, err = os.MkdirTemp(tmpDir, "pkl_subset_*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory in %s: %w", tmpDir, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	for _, filename := range files {
+		data, err := GetPKLFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		outputPath := filepath.Join(dir, filepath.FromSlash(filename))
+		if err := os.WriteFile(outputPath, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+	}
+	return dir, nil
+}
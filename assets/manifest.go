@@ -0,0 +1,227 @@
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ManifestFilename is the name of the integrity manifest embedded next to the PKL files.
+const ManifestFilename = "manifest.json"
+
+//go:embed manifest.json
+var manifestJSON []byte
+
+var moduleNameRegex = regexp.MustCompile(`(?m)^\s*(?:open\s+|abstract\s+)?module\s+([\w.]+)`)
+
+// Manifest describes the embedded schema files. It is generated by
+// `make manifest` (go run gen_manifest.go) whenever the PKL files change.
+type Manifest struct {
+	// SchemaVersion is the schema release the files belong to.
+	SchemaVersion string `json:"schemaVersion"`
+
+	// Files lists every embedded PKL file, sorted by name.
+	Files []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes a single PKL file.
+type ManifestEntry struct {
+	// Name is the file name, e.g. "Workflow.pkl".
+	Name string `json:"name"`
+
+	// SHA256 is the hex encoded SHA-256 digest of the file contents.
+	SHA256 string `json:"sha256"`
+
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+
+	// Module is the PKL module name declared by the file, e.g. "org.kdeps.pkl.Workflow".
+	Module string `json:"module,omitempty"`
+}
+
+// Lookup returns the manifest entry for a file name.
+func (m *Manifest) Lookup(name string) (ManifestEntry, bool) {
+	for _, entry := range m.Files {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+// Names returns the file names listed in the manifest.
+func (m *Manifest) Names() []string {
+	names := make([]string, 0, len(m.Files))
+	for _, entry := range m.Files {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+// Digest returns a SHA-256 over all entries, identifying the complete file set.
+func (m *Manifest) Digest() string {
+	h := sha256.New()
+	for _, entry := range m.Files {
+		fmt.Fprintf(h, "%s\x00%s\x00", entry.Name, entry.SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MarshalIndent returns the manifest in its canonical on-disk JSON form.
+func (m *Manifest) MarshalIndent() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+var (
+	embeddedManifest     *Manifest
+	embeddedManifestErr  error
+	embeddedManifestOnce sync.Once
+)
+
+// LoadManifest returns the manifest embedded with the PKL files.
+func LoadManifest() (*Manifest, error) {
+	embeddedManifestOnce.Do(func() {
+		var m Manifest
+		if err := json.Unmarshal(manifestJSON, &m); err != nil {
+			embeddedManifestErr = fmt.Errorf("failed to parse embedded manifest: %w", err)
+			return
+		}
+		embeddedManifest = &m
+	})
+	return embeddedManifest, embeddedManifestErr
+}
+
+// BuildManifest computes a manifest for the .pkl files at the root of fsys.
+func BuildManifest(fsys fs.FS, schemaVersion string) (*Manifest, error) {
+	files, err := listPKLFiles(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKL files: %w", err)
+	}
+	sort.Strings(files)
+
+	m := &Manifest{SchemaVersion: schemaVersion, Files: make([]ManifestEntry, 0, len(files))}
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		m.Files = append(m.Files, newManifestEntry(name, data))
+	}
+	return m, nil
+}
+
+func newManifestEntry(name string, data []byte) ManifestEntry {
+	sum := sha256.Sum256(data)
+	entry := ManifestEntry{
+		Name:   name,
+		SHA256: hex.EncodeToString(sum[:]),
+		Size:   int64(len(data)),
+	}
+	if match := moduleNameRegex.FindSubmatch(data); match != nil {
+		entry.Module = string(match[1])
+	}
+	return entry
+}
+
+// IntegrityError reports differences between a set of PKL files and the manifest.
+type IntegrityError struct {
+	// Source identifies the checked file set (e.g. "embedded" or a workspace directory).
+	Source string
+
+	// Missing lists manifest files that are not present.
+	Missing []string
+
+	// Unexpected lists present files that are not in the manifest.
+	Unexpected []string
+
+	// Modified lists files whose size or SHA-256 differ from the manifest.
+	Modified []string
+}
+
+func (e *IntegrityError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing %v", e.Missing))
+	}
+	if len(e.Unexpected) > 0 {
+		parts = append(parts, fmt.Sprintf("unexpected %v", e.Unexpected))
+	}
+	if len(e.Modified) > 0 {
+		parts = append(parts, fmt.Sprintf("modified %v", e.Modified))
+	}
+	return fmt.Sprintf("PKL integrity check failed for %s: %s", e.Source, strings.Join(parts, "; "))
+}
+
+// VerifyFS checks the .pkl files at the root of fsys against the manifest.
+// It returns an *IntegrityError describing every difference, or nil.
+func (m *Manifest) VerifyFS(fsys fs.FS, source string) error {
+	actual, err := BuildManifest(fsys, m.SchemaVersion)
+	if err != nil {
+		return err
+	}
+
+	ierr := &IntegrityError{Source: source}
+	seen := make(map[string]bool, len(actual.Files))
+	for _, got := range actual.Files {
+		seen[got.Name] = true
+		want, ok := m.Lookup(got.Name)
+		switch {
+		case !ok:
+			ierr.Unexpected = append(ierr.Unexpected, got.Name)
+		case want.SHA256 != got.SHA256 || want.Size != got.Size:
+			ierr.Modified = append(ierr.Modified, got.Name)
+		}
+	}
+	for _, want := range m.Files {
+		if !seen[want.Name] {
+			ierr.Missing = append(ierr.Missing, want.Name)
+		}
+	}
+
+	if len(ierr.Missing) == 0 && len(ierr.Unexpected) == 0 && len(ierr.Modified) == 0 {
+		return nil
+	}
+	return ierr
+}
+
+// VerifyIntegrity checks the embedded PKL files against the embedded manifest.
+func VerifyIntegrity() error {
+	m, err := LoadManifest()
+	if err != nil {
+		return err
+	}
+	if m.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("manifest schema version %s does not match embedded schema %s", m.SchemaVersion, SchemaVersion)
+	}
+	return m.VerifyFS(schemaFS(), "embedded")
+}
+
+// VerifyIntegrity checks the extracted workspace files against the embedded manifest,
+// detecting files that were modified, removed or added after extraction.
+func (w *PKLWorkspace) VerifyIntegrity() error {
+	m, err := LoadManifest()
+	if err != nil {
+		return err
+	}
+	return m.VerifyFS(os.DirFS(w.Directory), w.Directory)
+}
+
+// manifestEqual reports whether two manifests describe the same files.
+func manifestEqual(a, b *Manifest) bool {
+	ja, errA := a.MarshalIndent()
+	jb, errB := b.MarshalIndent()
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -0,0 +1,148 @@
+package assets
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// ModuleScheme is the URI scheme served by the embedded schema ModuleReader.
+// Schema modules can be imported directly from the binary, e.g.:
+//
+//	import "kdeps-schema:/Workflow.pkl"
+const ModuleScheme = "kdeps-schema"
+
+// ModuleReader is a pkl.ModuleReader that serves PKL modules from an fs.FS
+// without extracting them to disk. URIs are hierarchical, so relative imports
+// inside the schema (e.g. `import "Project.pkl"` in Workflow.pkl) resolve
+// against the same reader.
+type ModuleReader struct {
+	fsys   fs.FS
+	scheme string
+}
+
+var _ pkl.ModuleReader = (*ModuleReader)(nil)
+
+// NewModuleReader returns a ModuleReader serving the embedded schema files
+// under the ModuleScheme scheme.
+func NewModuleReader() *ModuleReader {
+	return NewFSModuleReader(ModuleScheme, schemaFS())
+}
+
+// NewFSModuleReader returns a ModuleReader serving the files of fsys under the given scheme.
+// The root of fsys maps to the URI path "/".
+func NewFSModuleReader(scheme string, fsys fs.FS) *ModuleReader {
+	return &ModuleReader{fsys: fsys, scheme: scheme}
+}
+
+// Scheme returns the URI scheme handled by this reader.
+func (r *ModuleReader) Scheme() string {
+	return r.scheme
+}
+
+// IsGlobbable reports that modules can be imported with glob patterns (import* "kdeps-schema:/*.pkl").
+func (r *ModuleReader) IsGlobbable() bool {
+	return true
+}
+
+// HasHierarchicalUris reports that URIs are hierarchical so relative imports resolve.
+func (r *ModuleReader) HasHierarchicalUris() bool {
+	return true
+}
+
+// IsLocal reports that modules are served from local (in-process) storage.
+func (r *ModuleReader) IsLocal() bool {
+	return true
+}
+
+// ListElements lists the files and directories below the URI path.
+// It is used by PKL to resolve glob imports.
+func (r *ModuleReader) ListElements(uri url.URL) ([]pkl.PathElement, error) {
+	name, err := r.fsPath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(r.fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", uri.String(), err)
+	}
+
+	elements := make([]pkl.PathElement, 0, len(entries))
+	for _, entry := range entries {
+		elements = append(elements, pkl.NewPathElement(entry.Name(), entry.IsDir()))
+	}
+	return elements, nil
+}
+
+// Read returns the source text of the module at the URI path.
+func (r *ModuleReader) Read(uri url.URL) (string, error) {
+	name, err := r.fsPath(uri)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := fs.ReadFile(r.fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", uri.String(), err)
+	}
+	return string(data), nil
+}
+
+// fsPath converts a reader URI into a path suitable for the underlying fs.FS.
+func (r *ModuleReader) fsPath(uri url.URL) (string, error) {
+	if uri.Scheme != "" && uri.Scheme != r.scheme {
+		return "", fmt.Errorf("unsupported scheme %q, expected %q", uri.Scheme, r.scheme)
+	}
+
+	p := uri.Path
+	if p == "" {
+		p = uri.Opaque
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("invalid module path %q", uri.Path)
+	}
+	return name, nil
+}
+
+// ModuleURI returns the URI of an embedded schema file for use in PKL import statements.
+// Example: assets.ModuleURI("Workflow.pkl") -> "kdeps-schema:/Workflow.pkl"
+func ModuleURI(filename string) string {
+	return fmt.Sprintf("%s:/%s", ModuleScheme, filename)
+}
+
+// NewEvaluator creates a PKL evaluator preconfigured with pkl.PreconfiguredOptions and the
+// embedded schema ModuleReader, so modules can `import "kdeps-schema:/Workflow.pkl"` without
+// extracting the schema to disk. Additional options are applied after the defaults.
+func NewEvaluator(ctx context.Context, opts ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	evalOpts := []func(*pkl.EvaluatorOptions){
+		pkl.PreconfiguredOptions,
+		pkl.WithModuleReader(NewModuleReader()),
+	}
+	evalOpts = append(evalOpts, opts...)
+
+	evaluator, err := pkl.NewEvaluator(ctx, evalOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create evaluator: %w", err)
+	}
+	return evaluator, nil
+}
+
+// schemaFS returns the embedded schema files rooted at the pkl/ directory.
+func schemaFS() fs.FS {
+	sub, err := fs.Sub(PKLFS, "pkl")
+	if err != nil {
+		// fs.Sub only fails for invalid paths; "pkl" is a constant valid path.
+		panic(fmt.Sprintf("assets: failed to open embedded pkl directory: %v", err))
+	}
+	return sub
+}
@@ -0,0 +1,310 @@
+package assets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// OverlayScheme is the URI scheme served by the overlay ModuleReader, e.g.
+// "kdeps-overlay:/workflow.pkl".
+const OverlayScheme = "kdeps-overlay"
+
+// OverlaySchemaDir is the directory Materialize extracts the schema files to,
+// relative to the materialized workspace root.
+const OverlaySchemaDir = ".kdeps-schema"
+
+// OverlayLayer is a named file tree layered over the embedded schema.
+type OverlayLayer struct {
+	// Name identifies the layer in conflict reports, e.g. the directory path.
+	Name string
+
+	// FS holds the layer files. Its root maps to the overlay root.
+	FS fs.FS
+}
+
+// DirLayer returns a layer serving the files of a directory on disk.
+func DirLayer(dir string) OverlayLayer {
+	return OverlayLayer{Name: dir, FS: os.DirFS(dir)}
+}
+
+// FSLayer returns a layer serving the files of an fs.FS, e.g. an embed.FS or fstest.MapFS.
+func FSLayer(name string, fsys fs.FS) OverlayLayer {
+	return OverlayLayer{Name: name, FS: fsys}
+}
+
+// OverlayConflict reports a file that hides another file of the same name.
+type OverlayConflict struct {
+	// Path is the overlay path of the file, e.g. "resources/Resource.pkl".
+	Path string
+
+	// Layer is the name of the layer whose file wins.
+	Layer string
+
+	// Shadows is the name of the layer whose file is hidden, or "schema" when
+	// the file shadows a schema module for relative imports in its directory.
+	Shadows string
+}
+
+func (c OverlayConflict) String() string {
+	return fmt.Sprintf("%s from %s shadows %s", c.Path, c.Layer, c.Shadows)
+}
+
+// OverlayWorkspace layers user files, such as an agent's workflow.pkl and
+// resources/*.pkl, over the embedded schema. Earlier layers take precedence
+// over later ones.
+//
+// Relative imports that do not resolve to a user file but name a schema module
+// (e.g. `import "Resource.pkl"` from resources/fetch.pkl) resolve to the schema,
+// so agent files do not need absolute import paths.
+type OverlayWorkspace struct {
+	layers    []OverlayLayer
+	files     map[string]int
+	paths     []string
+	schema    map[string]bool
+	conflicts []OverlayConflict
+}
+
+// NewOverlayWorkspace indexes the files of the given layers. Files added to a
+// layer afterwards are not visible to the workspace.
+func NewOverlayWorkspace(layers ...OverlayLayer) (*OverlayWorkspace, error) {
+	schemaFiles, err := listPKLFiles(schemaFS(), ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKL files: %w", err)
+	}
+
+	o := &OverlayWorkspace{
+		layers: layers,
+		files:  make(map[string]int),
+		schema: make(map[string]bool, len(schemaFiles)),
+	}
+	for _, name := range schemaFiles {
+		o.schema[name] = true
+	}
+
+	for i, layer := range layers {
+		err := fs.WalkDir(layer.FS, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if winner, ok := o.files[p]; ok {
+				o.conflicts = append(o.conflicts, OverlayConflict{Path: p, Layer: layers[winner].Name, Shadows: layer.Name})
+				return nil
+			}
+			o.files[p] = i
+			o.paths = append(o.paths, p)
+			if strings.HasSuffix(p, ".pkl") && o.schema[path.Base(p)] {
+				o.conflicts = append(o.conflicts, OverlayConflict{Path: p, Layer: layer.Name, Shadows: "schema"})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to index layer %s: %w", layer.Name, err)
+		}
+	}
+	sort.Strings(o.paths)
+	return o, nil
+}
+
+// Files returns the overlay paths of all user files in lexical order.
+func (o *OverlayWorkspace) Files() []string {
+	return append([]string(nil), o.paths...)
+}
+
+// Conflicts returns the files that hide another layer's file or a schema module.
+func (o *OverlayWorkspace) Conflicts() []OverlayConflict {
+	return append([]OverlayConflict(nil), o.conflicts...)
+}
+
+// ReadFile returns the unmodified contents of a user file.
+func (o *OverlayWorkspace) ReadFile(name string) ([]byte, error) {
+	i, ok := o.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.ReadFile(o.layers[i].FS, name)
+}
+
+// ResolveImport resolves an import URI written in the user file from. It returns
+// the overlay path of a user file, the ModuleURI of a schema module, or false
+// if a local import resolves to neither. Non-local URIs are returned unchanged.
+func (o *OverlayWorkspace) ResolveImport(from, uri string) (string, bool) {
+	if classifyImport(uri) != ImportLocal {
+		return uri, true
+	}
+	target := path.Clean(path.Join(path.Dir(from), uri))
+	if _, ok := o.files[target]; ok {
+		return target, true
+	}
+	if base := path.Base(target); o.schema[base] {
+		return ModuleURI(base), true
+	}
+	return "", false
+}
+
+// ReadModule returns the source of a user module with imports of schema modules
+// rewritten to kdeps-schema: URIs, so that every module shares one copy of the schema.
+func (o *OverlayWorkspace) ReadModule(name string) (string, error) {
+	data, err := o.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	rewritten, err := o.rewriteImports(name, data, func(resolved string) string { return resolved })
+	if err != nil {
+		return "", err
+	}
+	return string(rewritten), nil
+}
+
+// rewriteImports rewrites local imports of a user module that resolve to the
+// schema with schemaURI(ModuleURI(name)). Imports of user files and glob imports
+// are left unchanged.
+func (o *OverlayWorkspace) rewriteImports(from string, data []byte, schemaURI func(string) string) ([]byte, error) {
+	if !strings.HasSuffix(from, ".pkl") {
+		return data, nil
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := importClauseRegex.FindStringSubmatchIndex(line); m != nil && line[m[2]:m[3]] != "import*" {
+			uri := line[m[4]:m[5]]
+			if resolved, ok := o.ResolveImport(from, uri); ok && classifyImport(uri) == ImportLocal && strings.HasPrefix(resolved, ModuleScheme+":") {
+				line = line[:m[4]] + schemaURI(resolved) + line[m[5]:]
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", from, err)
+	}
+	return out.Bytes(), nil
+}
+
+// ModuleURI returns the URI of a user file for use with the overlay evaluator.
+// Example: overlay.ModuleURI("workflow.pkl") -> "kdeps-overlay:/workflow.pkl"
+func (o *OverlayWorkspace) ModuleURI(name string) string {
+	return fmt.Sprintf("%s:/%s", OverlayScheme, name)
+}
+
+// ModuleReader returns a pkl.ModuleReader serving the user files under OverlayScheme,
+// with imports of schema modules rewritten as by ReadModule.
+func (o *OverlayWorkspace) ModuleReader() pkl.ModuleReader {
+	return &overlayModuleReader{ModuleReader: NewFSModuleReader(OverlayScheme, nil), overlay: o}
+}
+
+// NewEvaluator creates a PKL evaluator that reads user modules through the overlay
+// and schema modules through the embedded schema reader:
+//
+//	evaluator, _ := overlay.NewEvaluator(ctx)
+//	wf, _ := workflow.Load(ctx, evaluator, pkl.UriSource(overlay.ModuleURI("workflow.pkl")))
+func (o *OverlayWorkspace) NewEvaluator(ctx context.Context, opts ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	return NewEvaluator(ctx, append([]func(*pkl.EvaluatorOptions){pkl.WithModuleReader(o.ModuleReader())}, opts...)...)
+}
+
+// Materialize writes the overlay to dir: the schema files under OverlaySchemaDir and
+// the user files at the root, with imports of schema modules rewritten to relative
+// paths into OverlaySchemaDir. If dir is empty, uses a temporary directory in system
+// tmpdir. Returns the directory path.
+func (o *OverlayWorkspace) Materialize(dir string) (string, error) {
+	if dir == "" {
+		tmpDir := os.TempDir()
+		var err error
+		dir, err = os.MkdirTemp(tmpDir, "pkl_overlay_*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory in %s: %w", tmpDir, err)
+		}
+	}
+
+	if err := extractFS(schemaFS(), filepath.Join(dir, OverlaySchemaDir)); err != nil {
+		return "", err
+	}
+
+	for _, name := range o.paths {
+		data, err := o.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		toRoot := strings.Repeat("../", strings.Count(name, "/"))
+		data, err = o.rewriteImports(name, data, func(resolved string) string {
+			return toRoot + OverlaySchemaDir + "/" + strings.TrimPrefix(resolved, ModuleScheme+":/")
+		})
+		if err != nil {
+			return "", err
+		}
+
+		outputPath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory for %s: %w", outputPath, err)
+		}
+		if err := os.WriteFile(outputPath, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+	}
+	return dir, nil
+}
+
+// overlayModuleReader serves the user files of an OverlayWorkspace.
+type overlayModuleReader struct {
+	*ModuleReader
+	overlay *OverlayWorkspace
+}
+
+// ListElements lists the user files and directories below the URI path.
+func (r *overlayModuleReader) ListElements(uri url.URL) ([]pkl.PathElement, error) {
+	name, err := r.fsPath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	seen := make(map[string]bool)
+	var elements []pkl.PathElement
+	for _, p := range r.overlay.paths {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(p, prefix)
+		child, _, isDir := strings.Cut(rest, "/")
+		if !seen[child] {
+			seen[child] = true
+			elements = append(elements, pkl.NewPathElement(child, isDir))
+		}
+	}
+	if elements == nil {
+		return nil, fmt.Errorf("failed to list %s: %w", uri.String(), fs.ErrNotExist)
+	}
+	return elements, nil
+}
+
+// Read returns the source text of a user module with schema imports rewritten.
+func (r *overlayModuleReader) Read(uri url.URL) (string, error) {
+	name, err := r.fsPath(uri)
+	if err != nil {
+		return "", err
+	}
+	content, err := r.overlay.ReadModule(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", uri.String(), err)
+	}
+	return content, nil
+}
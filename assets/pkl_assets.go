@@ -85,6 +85,59 @@
 //	    // pkl eval test.pkl
 //	}
 //
+// ## In-Process Schema Imports
+//
+// The embedded schema can also be served straight from the binary through a
+// pkl.ModuleReader, which avoids extracting files to a temporary directory:
+//
+//	evaluator, err := assets.NewEvaluator(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	defer evaluator.Close()
+//
+//	source := pkl.TextSource(`
+//	    amends "kdeps-schema:/Workflow.pkl"
+//	    AgentID = "test"
+//	    TargetActionID = "answer"
+//	    Workflows {}
+//	    Settings {}
+//	`)
+//
+// Use assets.ModuleURI("Workflow.pkl") to build import URIs programmatically.
+//
+// ## Import Graph
+//
+// assets.ImportGraph() parses the import, extends and amends clauses of the
+// embedded files. Use it to find the minimal set of files an entry module needs:
+//
+//	graph, _ := assets.ImportGraph()
+//	files, _ := graph.Closure("Workflow.pkl")     // dependencies first
+//	dir, _ := assets.ExtractPKLSubsetToDir("", "Workflow.pkl")
+//
+// ## Shared Workspace Cache
+//
+// SetupSharedPKLWorkspace extracts the schema once per integrity hash under
+// $XDG_CACHE_HOME/kdeps/schema/<hash> and shares the read-only files between
+// tests and processes. Cleanup only releases the reference:
+//
+//	workspace, err := assets.SetupSharedPKLWorkspace()
+//	if err != nil {
+//	    t.Fatalf("Failed to setup shared workspace: %v", err)
+//	}
+//	defer workspace.Cleanup()
+//
+// ## Overlay Workspace for Agent Files
+//
+// NewOverlayWorkspace layers agent files over the schema, so a workflow.pkl
+// can simply `amends "Workflow.pkl"`:
+//
+//	overlay, _ := assets.NewOverlayWorkspace(assets.DirLayer("./my-agent"))
+//	for _, conflict := range overlay.Conflicts() {
+//	    log.Printf("warning: %s", conflict)
+//	}
+//	evaluator, _ := overlay.NewEvaluator(ctx)
+//
 // ## Available PKL Schema Files
 //
 // All files from deps/pkl/ are available:
@@ -289,6 +342,7 @@ func SetupPKLWorkspaceInTmpDir() (*PKLWorkspace, error) {
 type PKLWorkspace struct {
 	Directory string
 	isTemp    bool
+	shared    *sharedRef
 }
 
 // GetTmpDir returns the system temporary directory path.
@@ -339,8 +393,12 @@ func (w *PKLWorkspace) ListFiles() ([]string, error) {
 }
 
 // Cleanup removes the workspace directory if it was created as a temporary directory.
+// For a shared workspace it releases the reference and leaves the cached files in place.
 // Call this in defer or when done with the workspace.
 func (w *PKLWorkspace) Cleanup() error {
+	if w.shared != nil {
+		return releaseShared(w.shared)
+	}
 	if w.isTemp {
 		return os.RemoveAll(w.Directory)
 	}
@@ -372,31 +430,14 @@ func ListPKLFiles() ([]string, error) {
 	return files, err
 }
 
-// ValidatePKLFiles checks that all expected PKL files are present in the embedded filesystem.
+// ValidatePKLFiles checks that all PKL files listed in the embedded manifest are present
+// in the embedded filesystem. Use VerifyIntegrity to also check file contents.
 func ValidatePKLFiles() error {
-	expectedFiles := []string{
-		"APIServer.pkl",
-		"APIServerRequest.pkl",
-		"APIServerResponse.pkl",
-		"Data.pkl",
-		"Docker.pkl",
-		"Document.pkl",
-		"Exec.pkl",
-		"HTTP.pkl",
-		"Item.pkl",
-		"Kdeps.pkl",
-		"LLM.pkl",
-		"Memory.pkl",
-		"Project.pkl",
-		"Python.pkl",
-		"Resource.pkl",
-		"Session.pkl",
-		"Skip.pkl",
-		"Tool.pkl",
-		"Utils.pkl",
-		"WebServer.pkl",
-		"Workflow.pkl",
+	manifest, err := LoadManifest()
+	if err != nil {
+		return err
 	}
+	expectedFiles := manifest.Names()
 
 	availableFiles, err := ListPKLFiles()
 	if err != nil {
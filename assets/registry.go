@@ -0,0 +1,339 @@
+package assets
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kdeps/schema/internal/semver"
+)
+
+// SchemaVersion is the version of the schema embedded in PKLFS.
+const SchemaVersion = "0.5.0"
+
+// SchemaPackageURI is the base URI under which the schema is published as a PKL package.
+const SchemaPackageURI = "package://schema.kdeps.com/core"
+
+// VersionsFS holds older schema releases embedded side by side, one directory per
+// version (e.g. versions/0.4.3/*.pkl). Use `make snapshot-pkl-version VERSION=x.y.z`
+// to add a release.
+//
+//go:embed versions
+var VersionsFS embed.FS
+
+// ErrVersionNotFound is returned when no registered schema version matches a request.
+var ErrVersionNotFound = errors.New("schema version not found")
+
+var minPklVersionRegex = regexp.MustCompile(`minPklVersion\s*=\s*"([^"]+)"`)
+
+// SchemaRelease is a single registered schema version and its files.
+type SchemaRelease struct {
+	// Version is the semantic version of the schema, without a leading "v".
+	Version string
+
+	// MinPklVersion is the highest minPklVersion declared by any module of this release.
+	MinPklVersion string
+
+	fsys fs.FS
+}
+
+// FS returns the release's PKL files rooted at the schema directory.
+func (s *SchemaRelease) FS() fs.FS {
+	return s.fsys
+}
+
+// GetPKLFile reads a specific PKL file of this release.
+func (s *SchemaRelease) GetPKLFile(filename string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, filename)
+}
+
+// ListPKLFiles returns the PKL files of this release.
+func (s *SchemaRelease) ListPKLFiles() ([]string, error) {
+	return listPKLFiles(s.fsys, ".")
+}
+
+// ModuleReader returns a kdeps-schema: ModuleReader serving this release.
+func (s *SchemaRelease) ModuleReader() *ModuleReader {
+	return NewFSModuleReader(ModuleScheme, s.fsys)
+}
+
+// ExtractAllPKLFilesToDir extracts the release's PKL files to dir.
+// If dir is empty, uses a temporary directory in system tmpdir. Returns the directory path.
+func (s *SchemaRelease) ExtractAllPKLFilesToDir(dir string) (string, error) {
+	if dir == "" {
+		tmpDir := os.TempDir()
+		var err error
+		dir, err = os.MkdirTemp(tmpDir, fmt.Sprintf("pkl_extracted_%s_*", s.Version))
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory in %s: %w", tmpDir, err)
+		}
+	}
+	if err := extractFS(s.fsys, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// SetupPKLWorkspace extracts the release to dir and returns a PKLWorkspace for it.
+// If dir is empty, creates a temporary directory in system tmpdir.
+func (s *SchemaRelease) SetupPKLWorkspace(dir string) (*PKLWorkspace, error) {
+	extractedDir, err := s.ExtractAllPKLFilesToDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &PKLWorkspace{
+		Directory: extractedDir,
+		isTemp:    dir == "",
+	}, nil
+}
+
+// Registry holds several schema releases side by side and selects one by
+// version, version constraint, PKL version or package URI.
+type Registry struct {
+	mu       sync.RWMutex
+	releases map[string]*SchemaRelease
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{releases: make(map[string]*SchemaRelease)}
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryErr  error
+	defaultRegistryOnce sync.Once
+)
+
+// DefaultRegistry returns the registry of all schema releases embedded in this
+// package: the current schema (SchemaVersion) and every release under VersionsFS.
+func DefaultRegistry() (*Registry, error) {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry, defaultRegistryErr = newEmbeddedRegistry()
+	})
+	return defaultRegistry, defaultRegistryErr
+}
+
+func newEmbeddedRegistry() (*Registry, error) {
+	r := NewRegistry()
+	if _, err := r.Register(SchemaVersion, schemaFS()); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(VersionsFS, "versions")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedded schema versions: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		sub, err := fs.Sub(VersionsFS, "versions/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open schema version %s: %w", entry.Name(), err)
+		}
+		if _, err := r.Register(entry.Name(), sub); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a schema release served from fsys, whose root contains the PKL files.
+// Registering an already registered version replaces it.
+func (r *Registry) Register(version string, fsys fs.FS) (*SchemaRelease, error) {
+	v, err := semver.Parse(version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register schema: %w", err)
+	}
+
+	minPkl, err := scanMinPklVersion(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register schema %s: %w", version, err)
+	}
+
+	release := &SchemaRelease{
+		Version:       v.String(),
+		MinPklVersion: minPkl,
+		fsys:          fsys,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releases[release.Version] = release
+	return release, nil
+}
+
+// Versions returns the registered versions in ascending order.
+func (r *Registry) Versions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]string, 0, len(r.releases))
+	for v := range r.releases {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// Get returns the release with exactly the given version ("0.4.5" or "v0.4.5").
+func (r *Registry) Get(version string) (*SchemaRelease, error) {
+	v, err := semver.Parse(version)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	release, ok := r.releases[v.String()]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, version)
+	}
+	return release, nil
+}
+
+// Latest returns the release with the highest version.
+func (r *Registry) Latest() (*SchemaRelease, error) {
+	return r.Resolve("")
+}
+
+// Resolve returns the highest release satisfying a version constraint such as
+// "0.4.5", "^0.4", "~0.4.3", ">=0.4.0" or "0.4.x". An empty constraint matches any version.
+func (r *Registry) Resolve(constraint string) (*SchemaRelease, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	best, ok := c.Best(r.Versions())
+	if !ok {
+		return nil, fmt.Errorf("%w: no release satisfies %q", ErrVersionNotFound, constraint)
+	}
+	return r.Get(best)
+}
+
+// ForPklVersion returns the highest release that can be evaluated by the given
+// PKL version, i.e. whose MinPklVersion is not newer than pklVersion.
+func (r *Registry) ForPklVersion(pklVersion string) (*SchemaRelease, error) {
+	pv, err := semver.Parse(pklVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := r.Versions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		release, err := r.Get(versions[i])
+		if err != nil {
+			return nil, err
+		}
+		if release.MinPklVersion == "" {
+			return release, nil
+		}
+		if mv, err := semver.Parse(release.MinPklVersion); err == nil && mv.Compare(pv) <= 0 {
+			return release, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no release supports PKL %s", ErrVersionNotFound, pklVersion)
+}
+
+// ForPackageURI returns the release referenced by a schema package URI as used in agent
+// PklProject dependencies and amends clauses, e.g.
+// "package://schema.kdeps.com/core@0.4.5#/Workflow.pkl".
+func (r *Registry) ForPackageURI(uri string) (*SchemaRelease, error) {
+	version, err := PackageURIVersion(uri)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(version)
+}
+
+// PackageURIVersion extracts the version from a schema package URI
+// ("package://schema.kdeps.com/core@0.4.5#/Workflow.pkl" -> "0.4.5").
+func PackageURIVersion(uri string) (string, error) {
+	rest, ok := strings.CutPrefix(uri, SchemaPackageURI+"@")
+	if !ok {
+		return "", fmt.Errorf("not a schema package URI: %s", uri)
+	}
+	if i := strings.IndexAny(rest, "#/"); i >= 0 {
+		rest = rest[:i]
+	}
+	if !semver.IsValid(rest) {
+		return "", fmt.Errorf("invalid version in package URI %s", uri)
+	}
+	return rest, nil
+}
+
+// scanMinPklVersion returns the highest minPklVersion declared in the PKL files of fsys.
+func scanMinPklVersion(fsys fs.FS) (string, error) {
+	files, err := listPKLFiles(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+
+	highest := ""
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return "", err
+		}
+		m := minPklVersionRegex.FindSubmatch(data)
+		if m == nil {
+			continue
+		}
+		if v := string(m[1]); highest == "" || semver.Compare(v, highest) > 0 {
+			highest = v
+		}
+	}
+	return highest, nil
+}
+
+// listPKLFiles returns the names of the .pkl files directly inside dir.
+func listPKLFiles(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".pkl") {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// extractFS writes the .pkl files at the root of fsys into dir.
+func extractFS(fsys fs.FS, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	files, err := listPKLFiles(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("failed to list PKL files: %w", err)
+	}
+
+	for _, filename := range files {
+		data, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+
+		outputPath := filepath.Join(dir, filename)
+		if err := os.WriteFile(outputPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+	}
+	return nil
+}
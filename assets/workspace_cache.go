@@ -0,0 +1,312 @@
+package assets
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SchemaCacheEnv overrides the directory used for shared PKL workspaces.
+const SchemaCacheEnv = "KDEPS_SCHEMA_CACHE_DIR"
+
+// sharedEntry is the in-process state of one cached schema version. The shared
+// lock on lockFile is held while refs > 0, which keeps other processes from
+// garbage collecting the directory.
+type sharedEntry struct {
+	dir      string
+	lockFile *os.File
+	refs     int
+}
+
+// sharedRef is the handle a PKLWorkspace holds on a sharedEntry.
+type sharedRef struct {
+	key     string
+	release sync.Once
+}
+
+var (
+	sharedMu      sync.Mutex
+	sharedEntries = make(map[string]*sharedEntry)
+)
+
+// SchemaCacheDir returns the root directory of the shared workspace cache:
+// $KDEPS_SCHEMA_CACHE_DIR if set, otherwise $XDG_CACHE_HOME/kdeps/schema, falling
+// back to the user cache directory of the platform.
+func SchemaCacheDir() (string, error) {
+	if dir := os.Getenv(SchemaCacheEnv); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "kdeps", "schema"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(dir, "kdeps", "schema"), nil
+}
+
+// SetupSharedPKLWorkspace returns a read-only workspace in the shared cache,
+// keyed by the integrity hash of the embedded schema. The files are extracted
+// once and reused by every process with the same schema; Cleanup releases the
+// reference instead of deleting the files.
+//
+// Stale versions in the cache that no process holds are garbage collected.
+func SetupSharedPKLWorkspace() (*PKLWorkspace, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	root, err := SchemaCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	key := manifest.Digest()
+	w, err := acquireShared(root, key, manifest, schemaFS())
+	if err != nil {
+		return nil, err
+	}
+
+	// Garbage collection is best effort; a failure must not fail the caller.
+	_, _ = GCSchemaCache()
+	return w, nil
+}
+
+// IsShared returns true if the workspace lives in the shared cache.
+func (w *PKLWorkspace) IsShared() bool {
+	return w.shared != nil
+}
+
+func acquireShared(root, key string, manifest *Manifest, fsys fs.FS) (*PKLWorkspace, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	cacheKey := filepath.Join(root, key)
+	if entry, ok := sharedEntries[cacheKey]; ok {
+		entry.refs++
+		return &PKLWorkspace{Directory: entry.dir, shared: &sharedRef{key: cacheKey}}, nil
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %w", root, err)
+	}
+
+	dir := cacheKey
+	for {
+		lockFile, err := openLockedFile(cacheKey + ".lock")
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ensureExtracted(dir, manifest, fsys); err != nil {
+			unlock(lockFile)
+			lockFile.Close()
+			return nil, err
+		}
+
+		if err := lockShared(lockFile); err != nil {
+			unlock(lockFile)
+			lockFile.Close()
+			return nil, fmt.Errorf("failed to downgrade lock on %s: %w", lockFile.Name(), err)
+		}
+
+		// The downgrade is not atomic, so garbage collection may have taken the
+		// lock in between. It removes the lock file along with the directory,
+		// so a lock file still in place means the directory is intact.
+		if isCurrent(lockFile) {
+			sharedEntries[cacheKey] = &sharedEntry{dir: dir, lockFile: lockFile, refs: 1}
+			return &PKLWorkspace{Directory: dir, shared: &sharedRef{key: cacheKey}}, nil
+		}
+		unlock(lockFile)
+		lockFile.Close()
+	}
+}
+
+// openLockedFile opens path and takes an exclusive lock on it. If the file was
+// removed by garbage collection while waiting for the lock, it retries with the
+// new file so that every holder locks the same inode.
+func openLockedFile(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lock file %s: %w", path, err)
+		}
+		if err := lockExclusive(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		if isCurrent(f) {
+			return f, nil
+		}
+		unlock(f)
+		f.Close()
+	}
+}
+
+// isCurrent reports whether the open lock file f is still the file at its path.
+func isCurrent(f *os.File) bool {
+	opened, errOpened := f.Stat()
+	current, errCurrent := os.Stat(f.Name())
+	return errOpened == nil && errCurrent == nil && os.SameFile(opened, current)
+}
+
+// ensureExtracted makes dir contain exactly the files in the manifest. The
+// caller must hold the exclusive lock for dir.
+func ensureExtracted(dir string, manifest *Manifest, fsys fs.FS) error {
+	if _, err := os.Stat(dir); err == nil {
+		if manifest.VerifyFS(os.DirFS(dir), dir) == nil {
+			return nil
+		}
+		if err := removeReadOnly(dir); err != nil {
+			return fmt.Errorf("failed to remove corrupt cache entry %s: %w", dir, err)
+		}
+	}
+
+	tmpDir := dir + ".tmp"
+	if err := removeReadOnly(tmpDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", tmpDir, err)
+	}
+	if err := extractFS(fsys, tmpDir); err != nil {
+		removeReadOnly(tmpDir)
+		return err
+	}
+	if err := manifest.VerifyFS(os.DirFS(tmpDir), tmpDir); err != nil {
+		removeReadOnly(tmpDir)
+		return err
+	}
+	if err := setReadOnly(tmpDir); err != nil {
+		removeReadOnly(tmpDir)
+		return fmt.Errorf("failed to make %s read-only: %w", tmpDir, err)
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		removeReadOnly(tmpDir)
+		return fmt.Errorf("failed to move %s into place: %w", dir, err)
+	}
+	return nil
+}
+
+// releaseShared drops one reference. When the last reference in this process is
+// released, the shared lock is dropped so the entry can be garbage collected
+// once it becomes stale.
+func releaseShared(ref *sharedRef) error {
+	var err error
+	ref.release.Do(func() {
+		sharedMu.Lock()
+		defer sharedMu.Unlock()
+
+		entry, ok := sharedEntries[ref.key]
+		if !ok {
+			return
+		}
+		entry.refs--
+		if entry.refs > 0 {
+			return
+		}
+		delete(sharedEntries, ref.key)
+		if uerr := unlock(entry.lockFile); uerr != nil {
+			err = fmt.Errorf("failed to unlock %s: %w", entry.lockFile.Name(), uerr)
+		}
+		entry.lockFile.Close()
+	})
+	return err
+}
+
+// GCSchemaCache removes cached schema versions other than the embedded one that
+// are not held by any process. It returns the removed integrity hashes.
+func GCSchemaCache() ([]string, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	root, err := SchemaCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return gcSharedCache(root, manifest.Digest())
+}
+
+func gcSharedCache(root, keep string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory %s: %w", root, err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		key := entry.Name()
+		if !entry.IsDir() || key == keep || strings.Contains(key, ".") {
+			continue
+		}
+
+		sharedMu.Lock()
+		_, held := sharedEntries[filepath.Join(root, key)]
+		sharedMu.Unlock()
+		if held {
+			continue
+		}
+
+		ok, err := removeIfUnused(filepath.Join(root, key))
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, key)
+		}
+	}
+	return removed, nil
+}
+
+func removeIfUnused(dir string) (bool, error) {
+	lockPath := dir + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open lock file %s: %w", lockPath, err)
+	}
+	defer f.Close()
+
+	ok, err := tryLockExclusive(f)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer unlock(f)
+
+	if err := removeReadOnly(dir); err != nil {
+		return false, fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+	removeReadOnly(dir + ".tmp")
+	// Remove the lock file while still holding it; waiters notice the new inode and retry.
+	os.Remove(lockPath)
+	return true, nil
+}
+
+func setReadOnly(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Chmod(filepath.Join(dir, entry.Name()), 0444); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(dir, 0555)
+}
+
+// removeReadOnly removes a directory created by setReadOnly.
+func removeReadOnly(dir string) error {
+	if _, err := os.Lstat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,391 @@
+package resource
+
+import (
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	apiserverresponse "github.com/kdeps/schema/gen/api_server_response"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/http"
+	"github.com/kdeps/schema/gen/llm"
+	"github.com/kdeps/schema/gen/python"
+	gen "github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/internal/deepcopy"
+	"github.com/kdeps/schema/validate"
+)
+
+// defaultTimeout is the TimeoutDuration default of the action classes.
+var defaultTimeout = pkl.Duration{Value: 60, Unit: pkl.Second}
+
+// Action builds the Run block of a resource.
+type Action struct {
+	a *gen.ResourceAction
+}
+
+// NewAction starts an empty Run block.
+func NewAction() *Action {
+	return &Action{a: &gen.ResourceAction{}}
+}
+
+// Chat sets the chat action.
+func (a *Action) Chat(chat *ChatAction) *Action {
+	a.a.Chat = chat.c
+	return a
+}
+
+// Exec sets the exec action.
+func (a *Action) Exec(exec *ExecAction) *Action {
+	a.a.Exec = exec.e
+	return a
+}
+
+// Python sets the python action.
+func (a *Action) Python(python *PythonAction) *Action {
+	a.a.Python = python.p
+	return a
+}
+
+// HTTP sets the HTTP client action.
+func (a *Action) HTTP(client *HTTPAction) *Action {
+	a.a.HTTPClient = client.h
+	return a
+}
+
+// Response sets the API response.
+func (a *Action) Response(response *ResponseAction) *Action {
+	a.a.APIResponse = ptr[apiserverresponse.APIServerResponse](response.r)
+	return a
+}
+
+// Expr sets the PKL expressions block.
+func (a *Action) Expr(expr *pkl.Object) *Action {
+	a.a.Expr = expr
+	return a
+}
+
+// SkipIf adds skip conditions.
+func (a *Action) SkipIf(conditions ...any) *Action {
+	if a.a.SkipCondition == nil {
+		a.a.SkipCondition = &[]any{}
+	}
+	*a.a.SkipCondition = append(*a.a.SkipCondition, conditions...)
+	return a
+}
+
+// Preflight sets the check performed before the action runs.
+func (a *Action) Preflight(check *Check) *Action {
+	a.a.PreflightCheck = check.c
+	return a
+}
+
+// Postflight sets the check performed after the action runs.
+func (a *Action) Postflight(check *Check) *Action {
+	a.a.PostflightCheck = check.c
+	return a
+}
+
+// AllowedHeaders adds HTTP headers the action accepts.
+func (a *Action) AllowedHeaders(headers ...string) *Action {
+	a.a.AllowedHeaders = appendList(a.a.AllowedHeaders, headers)
+	return a
+}
+
+// AllowedParams adds HTTP params the action accepts.
+func (a *Action) AllowedParams(params ...string) *Action {
+	a.a.AllowedParams = appendList(a.a.AllowedParams, params)
+	return a
+}
+
+// RestrictToHTTPMethods adds HTTP methods the action is restricted to.
+func (a *Action) RestrictToHTTPMethods(methods ...string) *Action {
+	a.a.RestrictToHTTPMethods = appendList(a.a.RestrictToHTTPMethods, methods)
+	return a
+}
+
+// RestrictToRoutes adds API routes the action is restricted to.
+func (a *Action) RestrictToRoutes(routes ...string) *Action {
+	a.a.RestrictToRoutes = appendList(a.a.RestrictToRoutes, routes)
+	return a
+}
+
+// Build validates the Run block and returns it. Violations are returned as
+// validate.Errors. The Run block is a copy, unaffected by later calls on the
+// builder.
+func (a *Action) Build() (*gen.ResourceAction, error) {
+	action := deepcopy.Copy(*a.a)
+	if err := validate.ResourceAction(&action).Err(); err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// ChatAction builds an LLM chat.
+type ChatAction struct {
+	c *llm.ResourceChat
+}
+
+// Chat starts a chat with a prompt. Model defaults to "llama3.2",
+// JSONResponse to false and TimeoutDuration to 60.s.
+func Chat(prompt string) *ChatAction {
+	return &ChatAction{c: &llm.ResourceChat{
+		Model:           ptr("llama3.2"),
+		Prompt:          &prompt,
+		JSONResponse:    ptr(false),
+		TimeoutDuration: ptr(defaultTimeout),
+	}}
+}
+
+// Model sets the model.
+func (c *ChatAction) Model(model string) *ChatAction {
+	c.c.Model = &model
+	return c
+}
+
+// Role sets the role of the prompt.
+func (c *ChatAction) Role(role string) *ChatAction {
+	c.c.Role = &role
+	return c
+}
+
+// Description sets the description of the chat.
+func (c *ChatAction) Description(description string) *ChatAction {
+	c.c.Description = &description
+	return c
+}
+
+// Files adds files passed to the model.
+func (c *ChatAction) Files(files ...string) *ChatAction {
+	c.c.Files = appendList(c.c.Files, files)
+	return c
+}
+
+// JSONResponse requests a JSON response with the given keys.
+func (c *ChatAction) JSONResponse(keys ...string) *ChatAction {
+	c.c.JSONResponse = ptr(true)
+	if len(keys) > 0 {
+		c.c.JSONResponseKeys = appendList(c.c.JSONResponseKeys, keys)
+	}
+	return c
+}
+
+// Scenario adds messages to the conversation.
+func (c *ChatAction) Scenario(messages ...*llm.MultiChat) *ChatAction {
+	if c.c.Scenario == nil {
+		c.c.Scenario = &[]*llm.MultiChat{}
+	}
+	*c.c.Scenario = append(*c.c.Scenario, messages...)
+	return c
+}
+
+// Tools adds tools the model may call.
+func (c *ChatAction) Tools(tools ...*llm.Tool) *ChatAction {
+	if c.c.Tools == nil {
+		c.c.Tools = &[]*llm.Tool{}
+	}
+	*c.c.Tools = append(*c.c.Tools, tools...)
+	return c
+}
+
+// Timeout sets the timeout of the chat.
+func (c *ChatAction) Timeout(d time.Duration) *ChatAction {
+	c.c.TimeoutDuration = duration(d)
+	return c
+}
+
+// ExecAction builds a shell command.
+type ExecAction struct {
+	e *exec.ResourceExec
+}
+
+// Exec starts a shell command. ExitCode defaults to 0 and TimeoutDuration to 60.s.
+func Exec(command string) *ExecAction {
+	return &ExecAction{e: &exec.ResourceExec{
+		Command:         command,
+		ExitCode:        ptr(0),
+		TimeoutDuration: ptr(defaultTimeout),
+	}}
+}
+
+// Env sets an environment variable of the command.
+func (e *ExecAction) Env(name, value string) *ExecAction {
+	e.e.Env = setEntry(e.e.Env, name, value)
+	return e
+}
+
+// Timeout sets the timeout of the command.
+func (e *ExecAction) Timeout(d time.Duration) *ExecAction {
+	e.e.TimeoutDuration = duration(d)
+	return e
+}
+
+// PythonAction builds a python script.
+type PythonAction struct {
+	p *python.ResourcePython
+}
+
+// Python starts a python script. ExitCode defaults to 0 and TimeoutDuration to 60.s.
+func Python(script string) *PythonAction {
+	return &PythonAction{p: &python.ResourcePython{
+		Script:          script,
+		ExitCode:        ptr(0),
+		TimeoutDuration: ptr(defaultTimeout),
+	}}
+}
+
+// Env sets an environment variable of the script.
+func (p *PythonAction) Env(name, value string) *PythonAction {
+	p.p.Env = setEntry(p.p.Env, name, value)
+	return p
+}
+
+// Environment sets the Anaconda environment the script runs in.
+func (p *PythonAction) Environment(name string) *PythonAction {
+	p.p.PythonEnvironment = &name
+	return p
+}
+
+// Timeout sets the timeout of the script.
+func (p *PythonAction) Timeout(d time.Duration) *PythonAction {
+	p.p.TimeoutDuration = duration(d)
+	return p
+}
+
+// HTTPAction builds an HTTP client request.
+type HTTPAction struct {
+	h *http.ResourceHTTPClient
+}
+
+// HTTP starts a request. TimeoutDuration defaults to 60.s.
+func HTTP(method, url string) *HTTPAction {
+	return &HTTPAction{h: &http.ResourceHTTPClient{
+		Method:          method,
+		Url:             url,
+		TimeoutDuration: ptr(defaultTimeout),
+	}}
+}
+
+// Header sets a request header.
+func (h *HTTPAction) Header(name, value string) *HTTPAction {
+	h.h.Headers = setEntry(h.h.Headers, name, value)
+	return h
+}
+
+// Param sets a query parameter.
+func (h *HTTPAction) Param(name, value string) *HTTPAction {
+	h.h.Params = setEntry(h.h.Params, name, value)
+	return h
+}
+
+// Data adds request body entries.
+func (h *HTTPAction) Data(data ...string) *HTTPAction {
+	h.h.Data = appendList(h.h.Data, data)
+	return h
+}
+
+// Timeout sets the timeout of the request.
+func (h *HTTPAction) Timeout(d time.Duration) *HTTPAction {
+	h.h.TimeoutDuration = duration(d)
+	return h
+}
+
+// ResponseAction builds an API server response.
+type ResponseAction struct {
+	r *apiserverresponse.APIServerResponseImpl
+}
+
+// Response starts a response with data. Success defaults to true.
+func Response(data ...any) *ResponseAction {
+	if data == nil {
+		data = []any{}
+	}
+	return &ResponseAction{r: &apiserverresponse.APIServerResponseImpl{
+		Success:  ptr(true),
+		Response: &apiserverresponse.APIServerResponseBlock{Data: data},
+	}}
+}
+
+// Success sets whether the request succeeded.
+func (r *ResponseAction) Success(success bool) *ResponseAction {
+	r.r.Success = &success
+	return r
+}
+
+// Error adds an error to the response.
+func (r *ResponseAction) Error(code int, message string) *ResponseAction {
+	if r.r.Errors == nil {
+		r.r.Errors = &[]*apiserverresponse.APIServerErrorsBlock{}
+	}
+	*r.r.Errors = append(*r.r.Errors, &apiserverresponse.APIServerErrorsBlock{Code: code, Message: message})
+	return r
+}
+
+// Header sets a response header.
+func (r *ResponseAction) Header(name, value string) *ResponseAction {
+	meta := r.meta()
+	meta.Headers = setEntry(meta.Headers, name, value)
+	return r
+}
+
+// Property sets a response meta property.
+func (r *ResponseAction) Property(name, value string) *ResponseAction {
+	meta := r.meta()
+	meta.Properties = setEntry(meta.Properties, name, value)
+	return r
+}
+
+func (r *ResponseAction) meta() *apiserverresponse.APIServerResponseMetaBlock {
+	if r.r.Meta == nil {
+		r.r.Meta = &apiserverresponse.APIServerResponseMetaBlock{}
+	}
+	return r.r.Meta
+}
+
+// Check builds a preflight or postflight validation check.
+type Check struct {
+	c *gen.ValidationCheck
+}
+
+// NewCheck starts a check of validation conditions. Retry defaults to false and
+// RetryTimes to 3.
+func NewCheck(validations ...any) *Check {
+	if validations == nil {
+		validations = []any{}
+	}
+	return &Check{c: &gen.ValidationCheck{
+		Validations: &validations,
+		Retry:       ptr(false),
+		RetryTimes:  ptr(3),
+	}}
+}
+
+// Error sets the error returned when the check fails.
+func (c *Check) Error(code int, message string) *Check {
+	c.c.Error = &gen.APIError{Code: &code, Message: &message}
+	return c
+}
+
+// Retry retries the action up to times times when the check fails.
+func (c *Check) Retry(times int) *Check {
+	c.c.Retry = ptr(true)
+	c.c.RetryTimes = &times
+	return c
+}
+
+func setEntry(m *map[string]string, key, value string) *map[string]string {
+	if m == nil {
+		m = &map[string]string{}
+	}
+	(*m)[key] = value
+	return m
+}
+
+// duration converts d to a PKL duration in the largest of s, ms, us and ns that
+// represents it exactly.
+func duration(d time.Duration) *pkl.Duration {
+	for _, unit := range []pkl.DurationUnit{pkl.Second, pkl.Millisecond, pkl.Microsecond} {
+		if d%time.Duration(unit) == 0 {
+			return &pkl.Duration{Value: float64(d / time.Duration(unit)), Unit: unit}
+		}
+	}
+	return &pkl.Duration{Value: float64(d), Unit: pkl.Nanosecond}
+}
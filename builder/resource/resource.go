@@ -0,0 +1,145 @@
+// Package resource builds resource.ResourceImpl values and their actions in Go
+// with the defaults of the PKL schema, and validates them when built:
+//
+//	res, err := resource.New("fetch").
+//	    Requires("auth").
+//	    HTTP(resource.HTTP("GET", "https://api.example.com").Header("Accept", "application/json")).
+//	    Build()
+//
+//	res, err := resource.New("answer").
+//	    Requires("fetch").
+//	    Chat(resource.Chat("Summarize the response").Model("llama3.3").Timeout(2 * time.Minute)).
+//	    Build()
+//
+// Actions start with the defaults of their classes, e.g. Model = "llama3.2" and
+// TimeoutDuration = 60.s for chats and RetryTimes = 3 for validation checks, so
+// a built resource equals the one PKL evaluates from the same properties.
+package resource
+
+import (
+	"github.com/apple/pkl-go/pkl"
+	gen "github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/internal/deepcopy"
+	"github.com/kdeps/schema/validate"
+)
+
+// Builder builds a resource.
+type Builder struct {
+	r gen.ResourceImpl
+}
+
+// New starts a resource with an empty Run block.
+func New(actionID string) *Builder {
+	return &Builder{r: gen.ResourceImpl{
+		ActionID: actionID,
+		Run:      &gen.ResourceAction{},
+	}}
+}
+
+// Name sets the name of the resource.
+func (b *Builder) Name(name string) *Builder {
+	b.r.Name = &name
+	return b
+}
+
+// Description sets the description of the resource.
+func (b *Builder) Description(description string) *Builder {
+	b.r.Description = &description
+	return b
+}
+
+// Category sets the category of the resource.
+func (b *Builder) Category(category string) *Builder {
+	b.r.Category = &category
+	return b
+}
+
+// Requires adds dependencies of the resource.
+func (b *Builder) Requires(actionIDs ...string) *Builder {
+	b.r.Requires = appendList(b.r.Requires, actionIDs)
+	return b
+}
+
+// Items adds items the action is run for.
+func (b *Builder) Items(items ...string) *Builder {
+	b.r.Items = appendList(b.r.Items, items)
+	return b
+}
+
+// Run replaces the Run block.
+func (b *Builder) Run(action *Action) *Builder {
+	b.r.Run = action.a
+	return b
+}
+
+// Chat sets the chat action of the Run block.
+func (b *Builder) Chat(chat *ChatAction) *Builder {
+	b.action().Chat(chat)
+	return b
+}
+
+// Exec sets the exec action of the Run block.
+func (b *Builder) Exec(exec *ExecAction) *Builder {
+	b.action().Exec(exec)
+	return b
+}
+
+// Python sets the python action of the Run block.
+func (b *Builder) Python(python *PythonAction) *Builder {
+	b.action().Python(python)
+	return b
+}
+
+// HTTP sets the HTTP client action of the Run block.
+func (b *Builder) HTTP(client *HTTPAction) *Builder {
+	b.action().HTTP(client)
+	return b
+}
+
+// Response sets the API response of the Run block.
+func (b *Builder) Response(response *ResponseAction) *Builder {
+	b.action().Response(response)
+	return b
+}
+
+// Expr sets the PKL expressions block of the Run block.
+func (b *Builder) Expr(expr *pkl.Object) *Builder {
+	b.action().Expr(expr)
+	return b
+}
+
+// SkipIf adds skip conditions to the Run block.
+func (b *Builder) SkipIf(conditions ...any) *Builder {
+	b.action().SkipIf(conditions...)
+	return b
+}
+
+func (b *Builder) action() *Action {
+	if b.r.Run == nil {
+		b.r.Run = &gen.ResourceAction{}
+	}
+	return &Action{a: b.r.Run}
+}
+
+// Build validates the resource against the constraints of Resource.pkl and the
+// action modules, and returns it. Violations are returned as validate.Errors.
+// The resource is a copy, unaffected by later calls on the builder.
+func (b *Builder) Build() (*gen.ResourceImpl, error) {
+	r := deepcopy.Copy(b.r)
+	if err := validate.Resource(&r).Err(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func appendList(list *[]string, values []string) *[]string {
+	if list == nil {
+		list = &[]string{}
+	}
+	*list = append(*list, values...)
+	return list
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,155 @@
+// Package workflow builds workflow.WorkflowImpl values in Go with the defaults
+// of Workflow.pkl, and validates them when built:
+//
+//	wf, err := workflow.New("myAgent").
+//	    Version("1.2.0").
+//	    Target("answer").
+//	    Workflows("@search/query:1.0.0").
+//	    Environment(buildenv.Prod).
+//	    Build()
+//
+// A built workflow equals the one PKL evaluates from the same properties.
+package workflow
+
+import (
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/project/buildenv"
+	gen "github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/internal/deepcopy"
+	"github.com/kdeps/schema/validate"
+)
+
+// Builder builds a workflow.
+type Builder struct {
+	w gen.WorkflowImpl
+}
+
+// New starts a workflow for an agent. Version defaults to "1.0.0" and Settings
+// to the defaults of Project.Settings.
+func New(agentID string) *Builder {
+	return &Builder{w: gen.WorkflowImpl{
+		AgentID:   agentID,
+		Version:   "1.0.0",
+		Workflows: []string{},
+		Settings:  DefaultSettings(),
+	}}
+}
+
+// DefaultSettings returns Project.Settings with its declared defaults.
+func DefaultSettings() *project.Settings {
+	env := buildenv.Dev
+	return &project.Settings{
+		APIServerMode: ptr(false),
+		WebServerMode: ptr(false),
+		RateLimitMax:  ptr(5),
+		Environment:   &env,
+	}
+}
+
+// Description sets the description of the agent.
+func (b *Builder) Description(description string) *Builder {
+	b.w.Description = &description
+	return b
+}
+
+// Website sets the website of the agent.
+func (b *Builder) Website(url string) *Builder {
+	b.w.Website = &url
+	return b
+}
+
+// Authors adds authors of the agent.
+func (b *Builder) Authors(authors ...string) *Builder {
+	b.w.Authors = appendList(b.w.Authors, authors)
+	return b
+}
+
+// Documentation sets the documentation URL of the agent.
+func (b *Builder) Documentation(url string) *Builder {
+	b.w.Documentation = &url
+	return b
+}
+
+// Repository sets the source repository URL of the agent.
+func (b *Builder) Repository(url string) *Builder {
+	b.w.Repository = &url
+	return b
+}
+
+// HeroImage sets the hero image of the agent.
+func (b *Builder) HeroImage(path string) *Builder {
+	b.w.HeroImage = &path
+	return b
+}
+
+// AgentIcon sets the icon of the agent.
+func (b *Builder) AgentIcon(path string) *Builder {
+	b.w.AgentIcon = &path
+	return b
+}
+
+// Version sets the semantic version of the agent.
+func (b *Builder) Version(version string) *Builder {
+	b.w.Version = version
+	return b
+}
+
+// Target sets the action ID the workflow terminates on.
+func (b *Builder) Target(actionID string) *Builder {
+	b.w.TargetActionID = actionID
+	return b
+}
+
+// Workflows adds external workflows, e.g. "@agent/action:1.0.0".
+func (b *Builder) Workflows(workflows ...string) *Builder {
+	b.w.Workflows = append(b.w.Workflows, workflows...)
+	return b
+}
+
+// Settings replaces the project settings.
+func (b *Builder) Settings(settings *project.Settings) *Builder {
+	b.w.Settings = settings
+	return b
+}
+
+// Environment sets the build environment of the project settings.
+func (b *Builder) Environment(env buildenv.BuildEnv) *Builder {
+	b.settings().Environment = &env
+	return b
+}
+
+// RateLimit sets the maximum rate limit of the project settings.
+func (b *Builder) RateLimit(max int) *Builder {
+	b.settings().RateLimitMax = &max
+	return b
+}
+
+func (b *Builder) settings() *project.Settings {
+	if b.w.Settings == nil {
+		b.w.Settings = DefaultSettings()
+	}
+	return b.w.Settings
+}
+
+// Build validates the workflow against the constraints of Workflow.pkl and
+// returns it. Violations are returned as validate.Errors. The workflow is a
+// copy, unaffected by later calls on the builder.
+func (b *Builder) Build() (*gen.WorkflowImpl, error) {
+	w := deepcopy.Copy(b.w)
+	if err := validate.Workflow(&w).Err(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func appendList(list *[]string, values []string) *[]string {
+	if list == nil {
+		list = &[]string{}
+	}
+	*list = append(*list, values...)
+	return list
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
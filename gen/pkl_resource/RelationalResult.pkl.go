@@ -11,4 +11,7 @@ type RelationalResult struct {
 	Query string `pkl:"query"`
 
 	Ttl string `pkl:"ttl"`
+
+	// The cursor of the next page of a query, unset on the last page
+	NextCursor *string `pkl:"nextCursor"`
 }
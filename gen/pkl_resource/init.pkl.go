@@ -9,4 +9,11 @@ func init() {
 	pkl.RegisterMapping("org.kdeps.pkl.PklResource#ProjectionCondition", ProjectionCondition{})
 	pkl.RegisterMapping("org.kdeps.pkl.PklResource#JoinCondition", JoinCondition{})
 	pkl.RegisterMapping("org.kdeps.pkl.PklResource#RelationalResult", RelationalResult{})
+	pkl.RegisterMapping("org.kdeps.pkl.PklResource#QueryCondition", QueryCondition{})
+	pkl.RegisterMapping("org.kdeps.pkl.PklResource#OrderBy", OrderBy{})
+	pkl.RegisterMapping("org.kdeps.pkl.PklResource#Query", Query{})
+	pkl.RegisterMapping("org.kdeps.pkl.PklResource#AggregateCondition", AggregateCondition{})
+	pkl.RegisterMapping("org.kdeps.pkl.PklResource#AggregateResult", AggregateResult{})
+	pkl.RegisterMapping("org.kdeps.pkl.PklResource#CacheOpStats", CacheOpStats{})
+	pkl.RegisterMapping("org.kdeps.pkl.PklResource#CacheStats", CacheStats{})
 }
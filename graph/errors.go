@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CycleError is a dependency cycle.
+type CycleError struct {
+	// Path starts and ends with the same action ID.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Path, " -> ")
+}
+
+// MissingDependencyError is a dependency without a resource.
+type MissingDependencyError struct {
+	// ActionID is the resource with the dependency, or empty if Dependency is
+	// the workflow target.
+	ActionID   string
+	Dependency string
+}
+
+func (e *MissingDependencyError) Error() string {
+	if e.ActionID == "" {
+		return fmt.Sprintf("target action %q has no resource", e.Dependency)
+	}
+	return fmt.Sprintf("resource %q requires %q, which has no resource", e.ActionID, e.Dependency)
+}
+
+// DuplicateError is an action ID declared by more than one resource.
+type DuplicateError struct {
+	ActionID string
+	Count    int
+
+	// Files are the resource modules declaring ActionID, for resources loaded
+	// from a directory.
+	Files []string
+}
+
+func (e *DuplicateError) Error() string {
+	msg := fmt.Sprintf("action %q is declared by %d resources", e.ActionID, e.Count)
+	if len(e.Files) > 0 {
+		msg += ": " + strings.Join(e.Files, ", ")
+	}
+	return msg
+}
+
+// UnreachableError is a resource the workflow target does not depend on.
+type UnreachableError struct {
+	ActionID string
+	Target   string
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("resource %q is not reachable from target %q", e.ActionID, e.Target)
+}
+
+// Errors collects the problems of a graph.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the individual errors for errors.Is and errors.As.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Err returns nil if there are no problems, or the Errors otherwise.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,387 @@
+// Package graph builds the execution graph of an agent: the DAG defined by the
+// Requires of its resources, rooted at the TargetActionID of its workflow.
+//
+//	g, err := graph.Load(ctx, l, os.DirFS("agents/myAgent"))
+//	if err != nil {
+//	    return err
+//	}
+//	if err := g.Validate(); err != nil {
+//	    return err // cycles, missing dependencies, duplicates, unreachable resources
+//	}
+//	layers, err := g.Layers()
+//
+// Dependencies are local action IDs such as "fetch", or qualified action IDs
+// such as "@myAgent/fetch:1.0.0". Qualified IDs of the agent itself resolve to
+// its resources; qualified IDs of other agents are external dependencies and
+// are not part of the graph.
+package graph
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kdeps/schema/actionid"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/loader"
+)
+
+// WorkflowFile and ResourcesDir locate the modules of an agent directory.
+const (
+	WorkflowFile = "workflow.pkl"
+	ResourcesDir = "resources"
+)
+
+// Node is a resource in the graph.
+type Node struct {
+	// ActionID is the local action ID of the resource.
+	ActionID string
+
+	// File is the path of the resource module, if loaded from a directory.
+	File string
+
+	Resource resource.Resource
+
+	// Requires are the local action IDs the resource depends on, in declaration
+	// order and without repetitions. They may include action IDs that have no
+	// resource.
+	Requires []string
+
+	// External are the dependencies on actions of other agents.
+	External []string
+}
+
+// Graph is the resource graph of an agent.
+type Graph struct {
+	AgentID string
+	Version string
+	Target  string
+
+	nodes      map[string]*Node
+	duplicates []*DuplicateError
+}
+
+// New builds the graph of a workflow and its resources.
+func New(wf workflow.Workflow, resources ...resource.Resource) *Graph {
+	g := newGraph(wf)
+	for _, r := range resources {
+		g.add("", r)
+	}
+	return g
+}
+
+func newGraph(wf workflow.Workflow) *Graph {
+	return &Graph{
+		AgentID: wf.GetAgentID(),
+		Version: wf.GetVersion(),
+		Target:  wf.GetTargetActionID(),
+		nodes:   make(map[string]*Node),
+	}
+}
+
+// Load evaluates workflow.pkl and resources/*.pkl of an agent directory with l
+// and builds their graph.
+func Load(ctx context.Context, l *loader.Loader, fsys fs.FS) (*Graph, error) {
+	wf, err := loader.LoadFromFS[workflow.WorkflowImpl](ctx, l, fsys, WorkflowFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", WorkflowFile, err)
+	}
+	files, err := fs.Glob(fsys, path.Join(ResourcesDir, "*.pkl"))
+	if err != nil {
+		return nil, err
+	}
+
+	g := newGraph(wf)
+	for _, file := range files {
+		r, err := loader.LoadFromFS[resource.ResourceImpl](ctx, l, fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", file, err)
+		}
+		g.add(file, r)
+	}
+	return g, nil
+}
+
+func (g *Graph) add(file string, r resource.Resource) {
+	id := g.local(r.GetActionID())
+	if existing, ok := g.nodes[id]; ok {
+		var dup *DuplicateError
+		for _, d := range g.duplicates {
+			if d.ActionID == id {
+				dup = d
+			}
+		}
+		if dup == nil {
+			dup = &DuplicateError{ActionID: id, Count: 1}
+			if existing.File != "" {
+				dup.Files = append(dup.Files, existing.File)
+			}
+			g.duplicates = append(g.duplicates, dup)
+		}
+		dup.Count++
+		if file != "" {
+			dup.Files = append(dup.Files, file)
+		}
+		return
+	}
+
+	n := &Node{ActionID: id, File: file, Resource: r}
+	if requires := r.GetRequires(); requires != nil {
+		for _, dep := range *requires {
+			switch local := g.local(dep); {
+			case strings.HasPrefix(local, "@"):
+				n.External = append(n.External, dep)
+			case !slices.Contains(n.Requires, local):
+				n.Requires = append(n.Requires, local)
+			}
+		}
+	}
+	g.nodes[id] = n
+}
+
+// local resolves a qualified action ID of the agent, @agent/action[:version]
+// with a version matching the workflow's, to its local action ID. Other action
+// IDs are returned unchanged.
+func (g *Graph) local(actionID string) string {
+	id, err := actionid.Parse(actionID)
+	if err != nil || id.IsLocal() || id.Agent != g.AgentID || !id.Matches(g.Version) {
+		return actionID
+	}
+	return id.Action
+}
+
+// Node returns the resource with a local action ID, or nil.
+func (g *Graph) Node(actionID string) *Node {
+	return g.nodes[g.local(actionID)]
+}
+
+// Nodes returns all resources sorted by action ID.
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, id := range g.ids() {
+		nodes = append(nodes, g.nodes[id])
+	}
+	return nodes
+}
+
+func (g *Graph) ids() []string {
+	ids := make([]string, 0, len(g.nodes))
+	for id := range g.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Dependents returns the action IDs of the resources that require actionID.
+func (g *Graph) Dependents(actionID string) []string {
+	id := g.local(actionID)
+	var dependents []string
+	for _, n := range g.Nodes() {
+		for _, dep := range n.Requires {
+			if dep == id {
+				dependents = append(dependents, n.ActionID)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// Cycles returns the dependency cycles of the graph. Each path starts and ends
+// with the same action ID, e.g. [a b c a] for a requiring b requiring c
+// requiring a.
+func (g *Graph) Cycles() []*CycleError {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(g.nodes))
+	var stack []string
+	var cycles []*CycleError
+
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, dep := range g.nodes[id].Requires {
+			if _, ok := g.nodes[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != dep {
+					start--
+				}
+				path := append(append([]string{}, stack[start:]...), dep)
+				cycles = append(cycles, &CycleError{Path: path})
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+	}
+	for _, id := range g.ids() {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return cycles
+}
+
+// Missing returns the dependencies, and the workflow target, that have no resource.
+func (g *Graph) Missing() []*MissingDependencyError {
+	var missing []*MissingDependencyError
+	if _, ok := g.nodes[g.local(g.Target)]; !ok && g.Target != "" {
+		missing = append(missing, &MissingDependencyError{Dependency: g.Target})
+	}
+	for _, n := range g.Nodes() {
+		for _, dep := range n.Requires {
+			if _, ok := g.nodes[dep]; !ok {
+				missing = append(missing, &MissingDependencyError{ActionID: n.ActionID, Dependency: dep})
+			}
+		}
+	}
+	return missing
+}
+
+// Duplicates returns the action IDs declared by more than one resource. Only
+// the first resource with an action ID is part of the graph.
+func (g *Graph) Duplicates() []*DuplicateError {
+	return g.duplicates
+}
+
+// Reachable returns the action IDs of the resources the target depends on,
+// including the target itself, sorted.
+func (g *Graph) Reachable() []string {
+	seen := g.reachable()
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (g *Graph) reachable() map[string]bool {
+	seen := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		if _, ok := g.nodes[id]; !ok || seen[id] {
+			return
+		}
+		seen[id] = true
+		for _, dep := range g.nodes[id].Requires {
+			visit(dep)
+		}
+	}
+	visit(g.local(g.Target))
+	return seen
+}
+
+// Unreachable returns the resources the target does not depend on.
+func (g *Graph) Unreachable() []*UnreachableError {
+	seen := g.reachable()
+	var unreachable []*UnreachableError
+	for _, id := range g.ids() {
+		if !seen[id] {
+			unreachable = append(unreachable, &UnreachableError{ActionID: id, Target: g.Target})
+		}
+	}
+	return unreachable
+}
+
+// Validate reports every cycle, missing dependency, duplicate action ID and
+// unreachable resource of the graph as Errors, or returns nil.
+func (g *Graph) Validate() error {
+	var errs Errors
+	for _, e := range g.Duplicates() {
+		errs = append(errs, e)
+	}
+	for _, e := range g.Missing() {
+		errs = append(errs, e)
+	}
+	for _, e := range g.Cycles() {
+		errs = append(errs, e)
+	}
+	for _, e := range g.Unreachable() {
+		errs = append(errs, e)
+	}
+	return errs.Err()
+}
+
+// Layers returns the resources the target depends on in execution order, grouped
+// into layers whose resources depend only on earlier layers and can run
+// concurrently. Each layer is sorted. Dependencies without a resource are
+// ignored; Layers fails with a CycleError if the target depends on a cycle.
+func (g *Graph) Layers() ([][]string, error) {
+	seen := g.reachable()
+	pending := make(map[string]int, len(seen))
+	for id := range seen {
+		for _, dep := range g.nodes[id].Requires {
+			if seen[dep] {
+				pending[id]++
+			}
+		}
+	}
+
+	var layers [][]string
+	var layer []string
+	for id := range seen {
+		if pending[id] == 0 {
+			layer = append(layer, id)
+		}
+	}
+	for len(layer) > 0 {
+		sort.Strings(layer)
+		layers = append(layers, layer)
+		var next []string
+		for _, id := range layer {
+			for _, dependent := range g.Dependents(id) {
+				if !seen[dependent] {
+					continue
+				}
+				if pending[dependent]--; pending[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		layer = next
+	}
+
+	for _, layer := range layers {
+		for _, id := range layer {
+			delete(pending, id)
+		}
+	}
+	if len(pending) > 0 {
+		for _, cycle := range g.Cycles() {
+			if seen[cycle.Path[0]] {
+				return nil, cycle
+			}
+		}
+	}
+	return layers, nil
+}
+
+// Order returns the resources the target depends on in a topological execution
+// order: every resource comes after its dependencies and the target comes last.
+func (g *Graph) Order() ([]string, error) {
+	layers, err := g.Layers()
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	for _, layer := range layers {
+		order = append(order, layer...)
+	}
+	return order, nil
+}
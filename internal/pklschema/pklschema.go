@@ -0,0 +1,440 @@
+// Package pklschema reads the declarations of the PKL schema modules that the
+// Go types in gen/ are generated from: documentation, property types and
+// defaults, typealias enums and the regexes behind constraint lambdas.
+//
+// It is a line-based scanner for the style the schema is written in, not a PKL
+// parser, and is shared by the packages that work with generated types.
+package pklschema
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/kdeps/schema/assets"
+	apiserverresponse "github.com/kdeps/schema/gen/api_server_response"
+)
+
+var (
+	goPackageRegex  = regexp.MustCompile(`@go\.Package\s*\{\s*name\s*=\s*"([^"]+)"`)
+	moduleRegex     = regexp.MustCompile(`^\s*(?:(?:open|abstract)\s+)?module\s+([\w.]+)`)
+	classRegex      = regexp.MustCompile(`^\s*(?:(?:open|abstract|external)\s+)*class\s+(\w+)`)
+	propRegex       = regexp.MustCompile(`^\s*([A-Za-z_]\w*)\s*:\s*(.+)$`)
+	typealiasRegex  = regexp.MustCompile(`^\s*typealias\s+(\w+)\s*=\s*(.+)$`)
+	importRegex     = regexp.MustCompile(`^\s*import\s+"([^"]+)"(?:\s+as\s+(\w+))?`)
+	hiddenRegex     = regexp.MustCompile(`^\s*hidden\s+(\w+)\s*=\s*Regex\(#"(.*)"#\)`)
+	lambdaRegex     = regexp.MustCompile(`^\s*hidden\s+(\w+)\s*=\s*\(\w+\)\s*->(.*)$`)
+	functionRegex   = regexp.MustCompile(`^\s*(?:(?:local|abstract|external)\s+)*function\s+(\w+)\s*\(`)
+	matchesRegex    = regexp.MustCompile(`\.matches\((\w+)\)`)
+	callRegex       = regexp.MustCompile(`^\s*(?:(\w+)\.)?(\w+)\(\w+\)\s*$`)
+	stringsRegex    = regexp.MustCompile(`#"(?:[^"]|"[^#])*"#|"(?:[^"\\]|\\.)*"`)
+	aliasValueRegex = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+// Schema is a set of parsed modules.
+type Schema struct {
+	Modules  map[string]*Module // by file name
+	Packages map[string]*Module // by Go import path
+}
+
+// Module is one PKL module that declares a Go package.
+type Module struct {
+	File      string
+	Name      string // last segment of the module name, e.g. Project
+	GoPackage string
+	Classes   map[string]*Class   // the module body is keyed by ""
+	Aliases   map[string][]string // string-literal typealias unions
+	Imports   map[string]string   // import name -> URI
+	Functions map[string]string   // module function -> regex property it matches against
+}
+
+// Class is a class body, or the module body for the "" class.
+type Class struct {
+	Name    string
+	Doc     string
+	Props   map[string]*Property
+	Regexes map[string]string // hidden Regex property -> pattern
+	Checks  map[string]Check  // hidden lambda -> what it checks
+}
+
+// Check is the body of a constraint lambda: either str.matches(Regex) or a call
+// of a (possibly imported) module function.
+type Check struct {
+	Regex    string
+	Alias    string
+	Function string
+}
+
+// Property is a declared, non-hidden property.
+type Property struct {
+	Name    string
+	Doc     string
+	Type    *Type
+	Default string // source of the default expression, if declared
+}
+
+// Type is a parsed property type such as Mapping<String(isValidEnv), String>?.
+type Type struct {
+	Name       string
+	Args       []*Type
+	Constraint string
+	Nullable   bool
+}
+
+// Arg returns the i-th type argument of t, or nil.
+func (t *Type) Arg(i int) *Type {
+	if t == nil || i >= len(t.Args) {
+		return nil
+	}
+	return t.Args[i]
+}
+
+// implementations maps the interfaces pkl-gen-go generates for open modules to
+// their structs, for the open modules used as property types.
+var implementations = map[reflect.Type]reflect.Type{
+	reflect.TypeOf((*apiserverresponse.APIServerResponse)(nil)).Elem(): reflect.TypeOf(apiserverresponse.APIServerResponseImpl{}),
+}
+
+// Implementation returns the struct generated for an open module's interface.
+func Implementation(t reflect.Type) (reflect.Type, bool) {
+	impl, ok := implementations[t]
+	return impl, ok
+}
+
+var (
+	embedded     *Schema
+	embeddedErr  error
+	embeddedOnce sync.Once
+)
+
+// Embedded returns the parsed embedded schema (assets/pkl).
+func Embedded() (*Schema, error) {
+	embeddedOnce.Do(func() {
+		fsys, err := fs.Sub(assets.PKLFS, "pkl")
+		if err != nil {
+			embeddedErr = err
+			return
+		}
+		embedded, embeddedErr = Parse(fsys)
+	})
+	return embedded, embeddedErr
+}
+
+// Parse parses every .pkl file at the root of fsys that declares a Go package.
+func Parse(fsys fs.FS) (*Schema, error) {
+	files, err := fs.Glob(fsys, "*.pkl")
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Modules: make(map[string]*Module), Packages: make(map[string]*Module)}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		m := ParseModule(file, data)
+		if m.GoPackage != "" {
+			s.Modules[file] = m
+			s.Packages[m.GoPackage] = m
+		}
+	}
+	return s, nil
+}
+
+// Lookup finds the module and class (or module body) a generated struct was
+// generated from. pkl-gen-go names module structs <Module> or <Module>Impl.
+func (s *Schema) Lookup(t reflect.Type) (*Module, *Class, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("%s is not a struct", t)
+	}
+	m, ok := s.Packages[t.PkgPath()]
+	if !ok {
+		return nil, nil, fmt.Errorf("no PKL module generates package %s", t.PkgPath())
+	}
+	if t.Name() == m.Name || t.Name() == m.Name+"Impl" {
+		return m, m.Classes[""], nil
+	}
+	class, ok := m.Classes[t.Name()]
+	if !ok {
+		return nil, nil, fmt.Errorf("class %s not found in %s", t.Name(), m.File)
+	}
+	return m, class, nil
+}
+
+// Enum returns the values of the typealias a named string type was generated
+// from. pkl-gen-go puts each typealias in a subpackage of its module's package.
+func (s *Schema) Enum(t reflect.Type) []string {
+	if t.PkgPath() == "" {
+		return nil
+	}
+	m, ok := s.Packages[path.Dir(t.PkgPath())]
+	if !ok {
+		return nil
+	}
+	return m.Aliases[t.Name()]
+}
+
+// Regex resolves a constraint such as isValidName, declared in class of m, to
+// the PKL regex it matches against. Constraints that are not regex checks
+// resolve to "".
+func (s *Schema) Regex(m *Module, class *Class, constraint string) string {
+	if constraint == "" {
+		return ""
+	}
+	c, ok := class.Checks[constraint]
+	if !ok {
+		c, ok = m.Classes[""].Checks[constraint]
+	}
+	if !ok {
+		return ""
+	}
+
+	if c.Regex != "" {
+		if re, ok := class.Regexes[c.Regex]; ok {
+			return re
+		}
+		return m.Classes[""].Regexes[c.Regex]
+	}
+
+	target := m
+	if c.Alias != "" {
+		if target, ok = s.Modules[m.Imports[c.Alias]]; !ok {
+			return ""
+		}
+	}
+	return target.Classes[""].Regexes[target.Functions[c.Function]]
+}
+
+// ParseModule scans one module.
+func ParseModule(file string, data []byte) *Module {
+	m := &Module{
+		File:      file,
+		Name:      strings.TrimSuffix(file, ".pkl"),
+		Classes:   map[string]*Class{"": newClass("")},
+		Aliases:   make(map[string][]string),
+		Imports:   make(map[string]string),
+		Functions: make(map[string]string),
+	}
+	if match := goPackageRegex.FindSubmatch(data); match != nil {
+		m.GoPackage = string(match[1])
+	}
+
+	var doc []string
+	depth := 0
+	class := m.Classes[""]
+	classDepth := -1
+	function := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		code := stringsRegex.ReplaceAllString(line, `""`)
+		if i := strings.Index(code, "//"); i >= 0 {
+			code = code[:i]
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "///"):
+			doc = append(doc, strings.TrimPrefix(strings.TrimPrefix(trimmed, "///"), " "))
+			continue
+		case strings.HasPrefix(trimmed, "@"):
+			// Annotations sit between a doc comment and its declaration.
+			depth += strings.Count(code, "{") - strings.Count(code, "}")
+			continue
+		case trimmed == "":
+			function = ""
+			continue
+		}
+
+		inBody := (class.Name == "" && depth == 0) || (class.Name != "" && depth == classDepth+1)
+		if match := moduleRegex.FindStringSubmatch(code); match != nil && depth == 0 {
+			m.Name = match[1][strings.LastIndex(match[1], ".")+1:]
+			m.Classes[""].Doc = strings.Join(doc, "\n")
+		} else if match := classRegex.FindStringSubmatch(code); match != nil && depth == 0 {
+			class = newClass(match[1])
+			class.Doc = strings.Join(doc, "\n")
+			classDepth = depth
+			m.Classes[class.Name] = class
+		} else if match := importRegex.FindStringSubmatch(line); match != nil && depth == 0 {
+			alias := match[2]
+			if alias == "" {
+				alias = strings.TrimSuffix(path.Base(match[1]), ".pkl")
+			}
+			m.Imports[alias] = match[1]
+		} else if match := typealiasRegex.FindStringSubmatch(line); match != nil && depth == 0 {
+			m.Aliases[match[1]] = parseAliasValues(match[2])
+		} else if match := hiddenRegex.FindStringSubmatch(line); match != nil && inBody {
+			class.Regexes[match[1]] = match[2]
+		} else if match := lambdaRegex.FindStringSubmatch(code); match != nil && inBody {
+			class.Checks[match[1]] = parseCheck(match[2])
+		} else if match := functionRegex.FindStringSubmatch(code); match != nil && depth == 0 {
+			function = match[1]
+		} else if match := propRegex.FindStringSubmatch(line); match != nil && inBody && !strings.HasPrefix(trimmed, "hidden") {
+			typ, def := splitDefault(match[2])
+			class.Props[match[1]] = &Property{
+				Name:    match[1],
+				Doc:     strings.Join(doc, "\n"),
+				Type:    ParseType(typ),
+				Default: def,
+			}
+		}
+		if function != "" {
+			if match := matchesRegex.FindStringSubmatch(code); match != nil {
+				m.Functions[function] = match[1]
+				function = ""
+			}
+		}
+		doc = nil
+
+		depth += strings.Count(code, "{") - strings.Count(code, "}")
+		if class.Name != "" && depth <= classDepth {
+			class = m.Classes[""]
+			classDepth = -1
+		}
+	}
+	return m
+}
+
+func newClass(name string) *Class {
+	return &Class{
+		Name:    name,
+		Props:   make(map[string]*Property),
+		Regexes: make(map[string]string),
+		Checks:  make(map[string]Check),
+	}
+}
+
+func parseAliasValues(union string) []string {
+	var values []string
+	for _, member := range strings.Split(union, "|") {
+		match := aliasValueRegex.FindStringSubmatch(strings.TrimSpace(member))
+		if match == nil {
+			return nil
+		}
+		values = append(values, match[1])
+	}
+	return values
+}
+
+func parseCheck(body string) Check {
+	if match := matchesRegex.FindStringSubmatch(body); match != nil {
+		return Check{Regex: match[1]}
+	}
+	if match := callRegex.FindStringSubmatch(body); match != nil {
+		return Check{Alias: match[1], Function: match[2]}
+	}
+	return Check{}
+}
+
+// splitDefault splits `Type = default` at the first `=` outside of brackets and strings.
+func splitDefault(decl string) (typ, def string) {
+	depth := 0
+	inString := false
+	for i := 0; i < len(decl); i++ {
+		switch c := decl[i]; {
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '<' || c == '(':
+			depth++
+		case c == '>' || c == ')':
+			depth--
+		case c == '=' && depth == 0:
+			return strings.TrimSpace(decl[:i]), strings.TrimSpace(decl[i+1:])
+		}
+	}
+	return strings.TrimSpace(decl), ""
+}
+
+// ParseType parses a property type. Union types are not parsed and yield a Type
+// with only the first member's name.
+func ParseType(s string) *Type {
+	p := &typeParser{s: s}
+	t := p.parse()
+	if t == nil {
+		return &Type{}
+	}
+	return t
+}
+
+type typeParser struct {
+	s   string
+	pos int
+}
+
+func (p *typeParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *typeParser) parse() *Type {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (isIdentByte(p.s[p.pos]) || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	if start == p.pos {
+		return nil
+	}
+	t := &Type{Name: p.s[start:p.pos]}
+	if i := strings.LastIndex(t.Name, "."); i >= 0 {
+		t.Name = t.Name[i+1:]
+	}
+
+	if p.peek('<') {
+		p.pos++
+		for {
+			arg := p.parse()
+			if arg == nil {
+				return t
+			}
+			t.Args = append(t.Args, arg)
+			p.skipSpace()
+			if !p.peek(',') {
+				break
+			}
+			p.pos++
+		}
+		if p.peek('>') {
+			p.pos++
+		}
+	}
+	if p.peek('(') {
+		depth := 0
+		start := p.pos + 1
+		for ; p.pos < len(p.s); p.pos++ {
+			if p.s[p.pos] == '(' {
+				depth++
+			} else if p.s[p.pos] == ')' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		t.Constraint = strings.TrimSpace(p.s[start:min(p.pos, len(p.s))])
+		p.pos++
+	}
+	if p.peek('?') {
+		p.pos++
+		t.Nullable = true
+	}
+	return t
+}
+
+func (p *typeParser) peek(c byte) bool {
+	p.skipSpace()
+	return p.pos < len(p.s) && p.s[p.pos] == c
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
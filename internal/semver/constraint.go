@@ -0,0 +1,196 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a set of version comparisons that must all hold.
+type Constraint struct {
+	raw    string
+	bounds []bound
+}
+
+type bound struct {
+	op string
+	v  Version
+}
+
+// ParseConstraint parses a version constraint. Supported forms, which may be
+// combined with spaces or commas (all must match):
+//
+//	""  "*"  "latest"   any version
+//	"1.2.3"  "=1.2.3"   exact version
+//	"1.2"  "1.x"        any version with the given prefix
+//	"^1.2.3"            compatible with 1.2.3 (< 2.0.0; < 0.3.0 for 0.2.x)
+//	"~1.2.3"            patch updates only (< 1.3.0)
+//	">1"  ">=1.2"  "<2"  "<=1.2.3"  "!=1.0.0"
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+	fields := strings.FieldsFunc(c.raw, func(r rune) bool { return r == ' ' || r == ',' })
+	for _, field := range fields {
+		bounds, err := parseBound(field)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		c.bounds = append(c.bounds, bounds...)
+	}
+	return c, nil
+}
+
+// String returns the constraint as it was written.
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// IsExact reports whether the constraint pins a single full version.
+func (c Constraint) IsExact() bool {
+	return len(c.bounds) == 1 && c.bounds[0].op == "="
+}
+
+// Check reports whether v satisfies the constraint.
+func (c Constraint) Check(v Version) bool {
+	for _, b := range c.bounds {
+		cmp := v.Compare(b.v)
+		var ok bool
+		switch b.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckString parses s and reports whether it satisfies the constraint.
+func (c Constraint) CheckString(s string) bool {
+	v, err := Parse(s)
+	if err != nil {
+		return false
+	}
+	return c.Check(v)
+}
+
+// Best returns the highest version in candidates satisfying the constraint.
+func (c Constraint) Best(candidates []string) (string, bool) {
+	var best string
+	var bestV Version
+	found := false
+	for _, candidate := range candidates {
+		v, err := Parse(candidate)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if !found || bestV.Less(v) {
+			best, bestV, found = candidate, v, true
+		}
+	}
+	return best, found
+}
+
+func parseBound(field string) ([]bound, error) {
+	if field == "*" || field == "latest" {
+		return nil, nil
+	}
+
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if !strings.HasPrefix(field, op) {
+			continue
+		}
+		rest := strings.TrimPrefix(field, op)
+		v, parts, err := parsePartial(rest)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "^":
+			return []bound{{">=", v}, {"<", caretUpper(v, parts)}}, nil
+		case "~":
+			return []bound{{">=", v}, {"<", tildeUpper(v, parts)}}, nil
+		case "=":
+			if parts < 3 {
+				return prefixBounds(v, parts), nil
+			}
+		}
+		return []bound{{op, v}}, nil
+	}
+
+	v, parts, err := parsePartial(field)
+	if err != nil {
+		return nil, err
+	}
+	if parts < 3 {
+		return prefixBounds(v, parts), nil
+	}
+	return []bound{{"=", v}}, nil
+}
+
+// parsePartial parses a version that may be partial or use x/* wildcards,
+// returning the number of components that were given explicitly.
+func parsePartial(s string) (Version, int, error) {
+	s = strings.TrimPrefix(s, "v")
+	core := s
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	parts := strings.Split(core, ".")
+	explicit := 0
+	for _, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			break
+		}
+		explicit++
+	}
+	if explicit == 0 {
+		return Version{}, 0, nil
+	}
+	if explicit < len(parts) {
+		s = strings.Join(parts[:explicit], ".")
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return Version{}, 0, err
+	}
+	return v, explicit, nil
+}
+
+func prefixBounds(v Version, parts int) []bound {
+	switch parts {
+	case 0:
+		return nil
+	case 1:
+		return []bound{{">=", v}, {"<", Version{Major: v.Major + 1}}}
+	default:
+		return []bound{{">=", v}, {"<", Version{Major: v.Major, Minor: v.Minor + 1}}}
+	}
+}
+
+func caretUpper(v Version, parts int) Version {
+	switch {
+	case v.Major > 0 || parts == 1:
+		return Version{Major: v.Major + 1}
+	case v.Minor > 0 || parts == 2:
+		return Version{Minor: v.Minor + 1}
+	default:
+		return Version{Patch: v.Patch + 1}
+	}
+}
+
+func tildeUpper(v Version, parts int) Version {
+	if parts == 1 {
+		return Version{Major: v.Major + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor + 1}
+}
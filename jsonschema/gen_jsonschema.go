@@ -0,0 +1,23 @@
+//go:build ignore
+
+// gen_jsonschema writes the JSON Schemas of the root schema types to schemas/.
+// Run from the jsonschema directory: go run gen_jsonschema.go
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kdeps/schema/jsonschema"
+)
+
+func main() {
+	written, err := jsonschema.WriteDir("schemas")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write JSON schemas: %v\n", err)
+		os.Exit(1)
+	}
+	for _, file := range written {
+		fmt.Printf("Wrote %s\n", file)
+	}
+}
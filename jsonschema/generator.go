@@ -0,0 +1,440 @@
+package jsonschema
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apple/pkl-go/pkl"
+	apiserver "github.com/kdeps/schema/gen/api_server"
+	apiserverresponse "github.com/kdeps/schema/gen/api_server_response"
+	"github.com/kdeps/schema/gen/docker"
+	"github.com/kdeps/schema/gen/kdeps"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/resource"
+	webserver "github.com/kdeps/schema/gen/web_server"
+	"github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/internal/pklschema"
+	"github.com/kdeps/schema/pklres"
+)
+
+const dataSizePattern = `^\d+(\.\d+)?\.(b|kb|kib|mb|mib|gb|gib|tb|tib|pb|pib)$`
+
+var durationRegex = regexp.MustCompile(pklres.DurationPattern)
+
+var (
+	durationType = reflect.TypeOf(pkl.Duration{})
+	dataSizeType = reflect.TypeOf(pkl.DataSize{})
+	objectType   = reflect.TypeOf(pkl.Object{})
+)
+
+// Roots are the types whose schemas are written by WriteDir.
+var Roots = []reflect.Type{
+	reflect.TypeOf(workflow.WorkflowImpl{}),
+	reflect.TypeOf(resource.ResourceImpl{}),
+	reflect.TypeOf(resource.ResourceAction{}),
+	reflect.TypeOf(project.Settings{}),
+	reflect.TypeOf(docker.DockerSettings{}),
+	reflect.TypeOf(apiserver.APIServerSettings{}),
+	reflect.TypeOf(apiserverresponse.APIServerResponseImpl{}),
+	reflect.TypeOf(webserver.WebServerSettings{}),
+	reflect.TypeOf(kdeps.Kdeps{}),
+}
+
+// Generator derives JSON Schemas from generated types and the PKL modules they
+// were generated from.
+type Generator struct {
+	schema *pklschema.Schema
+}
+
+var (
+	defaultGenerator     *Generator
+	defaultGeneratorErr  error
+	defaultGeneratorOnce sync.Once
+)
+
+// NewGenerator creates a Generator for the embedded PKL schema.
+func NewGenerator() (*Generator, error) {
+	schema, err := pklschema.Embedded()
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{schema: schema}, nil
+}
+
+// NewGeneratorFromFS creates a Generator for the PKL modules at the root of fsys.
+func NewGeneratorFromFS(fsys fs.FS) (*Generator, error) {
+	schema, err := pklschema.Parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{schema: schema}, nil
+}
+
+func getDefaultGenerator() (*Generator, error) {
+	defaultGeneratorOnce.Do(func() {
+		defaultGenerator, defaultGeneratorErr = NewGenerator()
+	})
+	return defaultGenerator, defaultGeneratorErr
+}
+
+// For returns the schema of T, a generated struct such as workflow.WorkflowImpl,
+// using the embedded PKL schema.
+func For[T any]() (*Schema, error) {
+	g, err := getDefaultGenerator()
+	if err != nil {
+		return nil, err
+	}
+	return g.Generate(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// All returns the schemas of Roots keyed by name.
+func All() (map[string]*Schema, error) {
+	g, err := getDefaultGenerator()
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]*Schema, len(Roots))
+	for _, t := range Roots {
+		s, err := g.Generate(t)
+		if err != nil {
+			return nil, err
+		}
+		schemas[s.Title] = s
+	}
+	return schemas, nil
+}
+
+// Filename returns the file name WriteDir uses for a schema name.
+func Filename(name string) string {
+	return name + ".schema.json"
+}
+
+// WriteDir writes the schemas of Roots to dir and returns the written paths.
+func WriteDir(dir string) ([]string, error) {
+	schemas, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	var written []string
+	for name, s := range schemas {
+		data, err := s.MarshalIndent()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		file := filepath.Join(dir, Filename(name))
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		written = append(written, file)
+	}
+	sort.Strings(written)
+	return written, nil
+}
+
+// Name returns the schema name of a generated struct: the PKL module name for
+// module types (Workflow) and Module.Class for classes (Project.Settings).
+func (g *Generator) Name(t reflect.Type) (string, error) {
+	m, class, err := g.schema.Lookup(t)
+	if err != nil {
+		return "", err
+	}
+	if class.Name == "" {
+		return m.Name, nil
+	}
+	return m.Name + "." + class.Name, nil
+}
+
+// Generate returns the schema of the generated struct t. Classes it references
+// are included under $defs.
+func (g *Generator) Generate(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	name, err := g.Name(t)
+	if err != nil {
+		return nil, err
+	}
+	gen := &generation{g: g, root: t, defs: make(map[string]*Schema)}
+	s := gen.classSchema(t)
+	if gen.err != nil {
+		return nil, gen.err
+	}
+	s.Schema = Draft
+	s.ID = Filename(name)
+	s.Title = name
+	if len(gen.defs) > 0 {
+		s.Defs = gen.defs
+	}
+	return s, nil
+}
+
+// generation is the state of generating one root schema.
+type generation struct {
+	g    *Generator
+	root reflect.Type
+	defs map[string]*Schema
+	err  error
+}
+
+func (gen *generation) fail(err error) *Schema {
+	if gen.err == nil {
+		gen.err = err
+	}
+	return &Schema{}
+}
+
+func (gen *generation) ref(t reflect.Type) *Schema {
+	if t == gen.root {
+		return &Schema{Ref: "#"}
+	}
+	name, err := gen.g.Name(t)
+	if err != nil {
+		return gen.fail(err)
+	}
+	if _, ok := gen.defs[name]; !ok {
+		gen.defs[name] = &Schema{}
+		gen.defs[name] = gen.classSchema(t)
+	}
+	return &Schema{Ref: "#/$defs/" + name}
+}
+
+func (gen *generation) classSchema(t reflect.Type) *Schema {
+	m, class, err := gen.g.schema.Lookup(t)
+	if err != nil {
+		return gen.fail(err)
+	}
+	s := &Schema{
+		Type:                 "object",
+		Description:          class.Doc,
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+	gen.addFields(s, t, m, class)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (gen *generation) addFields(s *Schema, t reflect.Type, m *pklschema.Module, class *pklschema.Class) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			// Embedded parent classes declare their properties in their own module.
+			pm, pc, err := gen.g.schema.Lookup(f.Type)
+			if err != nil {
+				gen.fail(err)
+				continue
+			}
+			gen.addFields(s, f.Type, pm, pc)
+			continue
+		}
+		name, ok := f.Tag.Lookup("pkl")
+		if !ok || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		prop := class.Props[name]
+		var typ *pklschema.Type
+		nullable := f.Type.Kind() == reflect.Pointer
+		if prop != nil {
+			typ = prop.Type
+			nullable = typ.Nullable
+		}
+
+		ps := gen.typeSchema(f.Type, typ, m, class)
+		if nullable {
+			ps = allowNull(ps)
+		}
+		if prop != nil {
+			ps.Description = prop.Doc
+			if def, ok := defaultValue(prop.Default); ok {
+				ps.Default = def
+			}
+		}
+		s.Properties[name] = ps
+		if !nullable && (prop == nil || prop.Default == "") && !hasImplicitDefault(f.Type) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// defaultValue converts a literal default into its JSON value. Durations are kept
+// in their PKL notation, e.g. "60.s". Other expressions have no JSON default.
+func defaultValue(literal string) (any, bool) {
+	switch {
+	case literal == "" || literal == "null":
+		return nil, false
+	case literal == "true" || literal == "false":
+		return literal == "true", true
+	case strings.HasPrefix(literal, `"`):
+		s, err := strconv.Unquote(literal)
+		return s, err == nil
+	case durationRegex.MatchString(literal):
+		return literal, true
+	}
+	if i, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		return i, true
+	}
+	if f, err := strconv.ParseFloat(literal, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
+
+// hasImplicitDefault reports whether PKL supplies a default for a non-nullable
+// property of this type without one being declared: an empty Listing or Mapping,
+// or a new instance of a class.
+func hasImplicitDefault(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		return true
+	case reflect.Struct:
+		return t != durationType && t != dataSizeType
+	}
+	return false
+}
+
+// typeSchema maps a Go field type, with the PKL type it was generated from when
+// known, to a schema.
+func (gen *generation) typeSchema(t reflect.Type, typ *pklschema.Type, m *pklschema.Module, class *pklschema.Class) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case durationType:
+		return &Schema{Type: "string", Pattern: pklres.DurationPattern}
+	case dataSizeType:
+		return &Schema{Type: "string", Pattern: dataSizePattern}
+	case objectType:
+		return &Schema{Type: "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		s := &Schema{Type: "string"}
+		if values := gen.g.schema.Enum(t); values != nil {
+			for _, v := range values {
+				s.Enum = append(s.Enum, v)
+			}
+		}
+		if typ != nil {
+			s.Pattern = ecmaPattern(gen.g.schema.Regex(m, class, typ.Constraint))
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := t.Bits()
+		lo, hi := int64(-1)<<(bits-1), uint64(1)<<(bits-1)-1
+		return &Schema{Type: "integer", Minimum: &lo, Maximum: &hi}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		lo, hi := int64(0), uint64(math.MaxUint64)>>(64-t.Bits())
+		return &Schema{Type: "integer", Minimum: &lo, Maximum: &hi}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: gen.typeSchema(t.Elem(), typ.Arg(0), m, class)}
+	case reflect.Map:
+		s := &Schema{Type: "object", AdditionalProperties: gen.typeSchema(t.Elem(), typ.Arg(1), m, class)}
+		if key := typ.Arg(0); key != nil {
+			if pattern := ecmaPattern(gen.g.schema.Regex(m, class, key.Constraint)); pattern != "" {
+				s.PropertyNames = &Schema{Pattern: pattern}
+			}
+		}
+		return s
+	case reflect.Struct:
+		return gen.ref(t)
+	case reflect.Interface:
+		if impl, ok := pklschema.Implementation(t); ok {
+			return gen.ref(impl)
+		}
+	}
+	return &Schema{}
+}
+
+// allowNull extends s to also accept null.
+func allowNull(s *Schema) *Schema {
+	switch typ := s.Type.(type) {
+	case string:
+		s.Type = []string{typ, "null"}
+		if s.Enum != nil {
+			s.Enum = append(s.Enum, nil)
+		}
+		return s
+	case nil:
+		if s.Ref != "" {
+			return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+		}
+	}
+	return s
+}
+
+// ecmaPattern converts a PKL (Java) regex to an ECMA-262 pattern with the same
+// meaning under String.matches, which requires the whole string to match. Scoped
+// case-insensitive groups (?i:...) are expanded into character classes.
+func ecmaPattern(re string) string {
+	if re == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("^(?:")
+	fold := strings.HasPrefix(re, "(?i)")
+	if fold {
+		re = strings.TrimPrefix(re, "(?i)")
+	}
+	depth, foldDepth := 0, -1
+	inClass := false
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+		switch {
+		case c == '\\' && i+1 < len(re):
+			b.WriteByte(c)
+			b.WriteByte(re[i+1])
+			i++
+			continue
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case strings.HasPrefix(re[i:], "(?i:"):
+			if foldDepth < 0 {
+				foldDepth = depth
+			}
+			depth++
+			b.WriteString("(?:")
+			i += len("(?i:") - 1
+			continue
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == foldDepth {
+				foldDepth = -1
+			}
+		case fold || foldDepth >= 0:
+			if lower, upper := strings.ToLower(string(c)), strings.ToUpper(string(c)); lower != upper {
+				b.WriteString("[" + upper + lower + "]")
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	b.WriteString(")$")
+	return b.String()
+}
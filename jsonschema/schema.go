@@ -0,0 +1,61 @@
+// Package jsonschema exports the kdeps PKL schema as JSON Schema (draft 2020-12)
+// for editors and tooling that cannot read PKL.
+//
+// Schemas are derived from the generated types in gen/ and their pkl struct tags,
+// and annotated from the embedded PKL modules:
+//
+//   - doc comments become "description"
+//   - literal defaults (PortNum = 3000, TimeoutDuration = 60.s) become "default"
+//   - typealias unions (BuildEnv, GPU, RunMode, WebServerType) become "enum"
+//   - regex constraints (String(isValidName)) become "pattern"
+//   - non-nullable properties without a default become "required"
+//
+// Durations are represented in their PKL notation, e.g. "60.s".
+//
+//	s, err := jsonschema.For[workflow.WorkflowImpl]()
+//	data, err := s.MarshalIndent()
+//
+// The schemas of all root types are written to jsonschema/schemas by `make jsonschema`.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Draft is the JSON Schema dialect of generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document or subschema.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *uint64            `json:"maximum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// MarshalIndent encodes the schema as indented JSON with a trailing newline.
+func (s *Schema) MarshalIndent() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,311 @@
+// Package loader evaluates PKL modules into the generated schema types while
+// reusing a pool of configured evaluators.
+//
+// The generated LoadFromPath functions start a new evaluator, and with it a new
+// pkl process, for every call. A Loader starts the evaluators once, with the
+// embedded schema (kdeps-schema:) and any kdeps resource readers registered,
+// and shares them across loads:
+//
+//	l, err := loader.New(ctx, loader.WithResourceReaders(agentReader, pklresReader))
+//	if err != nil {
+//	    return err
+//	}
+//	defer l.Close()
+//
+//	wf, err := loader.LoadFromPath[workflow.WorkflowImpl](ctx, l, "workflow.pkl")
+//	res, err := loader.LoadFromFS[resource.ResourceImpl](ctx, l, agentFS, "resources/fetch.pkl")
+//
+// Generated Load functions can also be used directly through Do:
+//
+//	err = l.Do(ctx, func(ev pkl.Evaluator) error {
+//	    wf, err = workflow.Load(ctx, ev, pkl.FileSource("workflow.pkl"))
+//	    return err
+//	})
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"sync"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+)
+
+// ErrClosed is returned when loading through a closed Loader.
+var ErrClosed = errors.New("loader is closed")
+
+// EvaluatorFunc creates an evaluator from options.
+type EvaluatorFunc func(ctx context.Context, opts ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error)
+
+type config struct {
+	poolSize     int
+	evalOpts     []func(*pkl.EvaluatorOptions)
+	newEvaluator EvaluatorFunc
+}
+
+// Option configures a Loader.
+type Option func(*config)
+
+// WithPoolSize sets the maximum number of evaluators, i.e. the number of loads that
+// run in parallel. Evaluators are started on demand. Defaults to runtime.NumCPU().
+func WithPoolSize(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.poolSize = n
+		}
+	}
+}
+
+// WithResourceReaders registers resource readers (agent:, pklres:, session:, ...)
+// with every evaluator.
+func WithResourceReaders(readers ...pkl.ResourceReader) Option {
+	return func(c *config) {
+		for _, reader := range readers {
+			c.evalOpts = append(c.evalOpts, pkl.WithResourceReader(reader))
+		}
+	}
+}
+
+// WithModuleReaders registers additional module readers with every evaluator.
+func WithModuleReaders(readers ...pkl.ModuleReader) Option {
+	return func(c *config) {
+		for _, reader := range readers {
+			c.evalOpts = append(c.evalOpts, pkl.WithModuleReader(reader))
+		}
+	}
+}
+
+// WithEvaluatorOptions applies further evaluator options after the defaults.
+func WithEvaluatorOptions(opts ...func(*pkl.EvaluatorOptions)) Option {
+	return func(c *config) {
+		c.evalOpts = append(c.evalOpts, opts...)
+	}
+}
+
+// WithEvaluatorFunc replaces how evaluators are created, e.g. to use a project
+// evaluator. By default evaluators are created by one shared pkl.EvaluatorManager.
+func WithEvaluatorFunc(fn EvaluatorFunc) Option {
+	return func(c *config) {
+		c.newEvaluator = fn
+	}
+}
+
+// Loader evaluates modules with a pool of evaluators. It is safe for concurrent use.
+type Loader struct {
+	opts         []func(*pkl.EvaluatorOptions)
+	newEvaluator EvaluatorFunc
+	manager      pkl.EvaluatorManager
+	mounts       *mountReader
+
+	idle  chan pkl.Evaluator
+	slots chan struct{}
+
+	mu     sync.Mutex
+	all    map[pkl.Evaluator]bool
+	closed bool
+}
+
+// New creates a Loader. The first evaluator is started immediately so that
+// configuration errors are reported here rather than on the first load.
+func New(ctx context.Context, opts ...Option) (*Loader, error) {
+	cfg := config{poolSize: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	l := &Loader{
+		mounts: newMountReader(),
+		idle:   make(chan pkl.Evaluator, cfg.poolSize),
+		slots:  make(chan struct{}, cfg.poolSize),
+		all:    make(map[pkl.Evaluator]bool),
+	}
+	l.opts = append([]func(*pkl.EvaluatorOptions){
+		pkl.PreconfiguredOptions,
+		pkl.WithModuleReader(assets.NewModuleReader()),
+		pkl.WithModuleReader(l.mounts),
+	}, cfg.evalOpts...)
+
+	l.newEvaluator = cfg.newEvaluator
+	if l.newEvaluator == nil {
+		l.manager = pkl.NewEvaluatorManager()
+		l.newEvaluator = l.manager.NewEvaluator
+	}
+
+	ev, err := l.acquire(ctx)
+	if err != nil {
+		// The manager's pkl process never started, and pkl-go panics closing
+		// a manager without a process.
+		return nil, err
+	}
+	l.release(ev)
+	return l, nil
+}
+
+// Do runs fn with an evaluator from the pool. The evaluator must not be used
+// after fn returns.
+func (l *Loader) Do(ctx context.Context, fn func(pkl.Evaluator) error) error {
+	ev, err := l.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer l.release(ev)
+	return fn(ev)
+}
+
+// Evaluate evaluates source into out, a pointer to a generated struct.
+func (l *Loader) Evaluate(ctx context.Context, source *pkl.ModuleSource, out any) error {
+	return l.Do(ctx, func(ev pkl.Evaluator) error {
+		return ev.EvaluateModule(ctx, source, out)
+	})
+}
+
+// Size returns the number of evaluators currently started.
+func (l *Loader) Size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.all)
+}
+
+// Close closes every evaluator. Evaluators in use are closed when they are returned.
+func (l *Loader) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	var errs []error
+drain:
+	for {
+		select {
+		case ev := <-l.idle:
+			errs = append(errs, l.discard(ev))
+		default:
+			break drain
+		}
+	}
+	if l.manager != nil {
+		errs = append(errs, l.manager.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Loader) acquire(ctx context.Context) (pkl.Evaluator, error) {
+	for {
+		if l.isClosed() {
+			return nil, ErrClosed
+		}
+
+		select {
+		case ev := <-l.idle:
+			if ev.Closed() {
+				l.discard(ev)
+				continue
+			}
+			return ev, nil
+		default:
+		}
+
+		select {
+		case ev := <-l.idle:
+			if ev.Closed() {
+				l.discard(ev)
+				continue
+			}
+			return ev, nil
+		case l.slots <- struct{}{}:
+			ev, err := l.newEvaluator(ctx, l.opts...)
+			if err != nil {
+				<-l.slots
+				return nil, fmt.Errorf("failed to create evaluator: %w", err)
+			}
+			l.mu.Lock()
+			l.all[ev] = true
+			l.mu.Unlock()
+			return ev, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *Loader) release(ev pkl.Evaluator) {
+	if l.isClosed() || ev.Closed() {
+		l.discard(ev)
+		return
+	}
+	l.idle <- ev
+	// Close may have drained the pool between the check and the send.
+	if l.isClosed() {
+		select {
+		case ev := <-l.idle:
+			l.discard(ev)
+		default:
+		}
+	}
+}
+
+func (l *Loader) discard(ev pkl.Evaluator) error {
+	l.mu.Lock()
+	known := l.all[ev]
+	delete(l.all, ev)
+	l.mu.Unlock()
+	if !known {
+		return nil
+	}
+	<-l.slots
+	if ev.Closed() {
+		return nil
+	}
+	return ev.Close()
+}
+
+func (l *Loader) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+// Load evaluates source into a new T, a generated struct such as workflow.WorkflowImpl.
+func Load[T any](ctx context.Context, l *Loader, source *pkl.ModuleSource) (*T, error) {
+	var ret T
+	if err := l.Evaluate(ctx, source, &ret); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// LoadFromPath evaluates the module file at path into a new T.
+func LoadFromPath[T any](ctx context.Context, l *Loader, path string) (*T, error) {
+	return Load[T](ctx, l, pkl.FileSource(path))
+}
+
+// LoadFromText evaluates module source text into a new T. The text has no base
+// URI, so imports must be absolute, e.g. `amends "kdeps-schema:/Workflow.pkl"`.
+func LoadFromText[T any](ctx context.Context, l *Loader, text string) (*T, error) {
+	return Load[T](ctx, l, pkl.TextSource(text))
+}
+
+// LoadFromBytes evaluates module source into a new T, like LoadFromText.
+func LoadFromBytes[T any](ctx context.Context, l *Loader, data []byte) (*T, error) {
+	return LoadFromText[T](ctx, l, string(data))
+}
+
+// LoadFromFS evaluates the module name of fsys into a new T. Relative imports
+// resolve within fsys, and the schema is available as kdeps-schema:/<file>.
+// Evaluators cache the modules of fsys for the life of the loader, so changes
+// to its files made after a load are not seen by later loads of it.
+func LoadFromFS[T any](ctx context.Context, l *Loader, fsys fs.FS, name string) (*T, error) {
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("invalid module path %q", name)
+	}
+	uri, unmount := l.mounts.mount(fsys, name)
+	defer unmount()
+	return Load[T](ctx, l, pkl.UriSource(uri))
+}
@@ -0,0 +1,145 @@
+package loader
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// MountScheme is the URI scheme under which LoadFromFS serves file systems.
+const MountScheme = "kdeps-fs"
+
+// mountReader serves the file systems passed to LoadFromFS under an id
+// (kdeps-fs:/<id>/<path>). Each fs.FS value keeps its id, and stays mounted, for
+// the life of the loader, so that the module caches of pooled evaluators hold
+// its modules once rather than once per load. The trade-off is that an
+// evaluator returns the modules it cached from an earlier load of an equal
+// value: a file system whose files change between loads, such as an os.DirFS
+// edited in place, needs a new Loader to be reloaded. Values that are neither
+// comparable nor maps are mounted under a fresh id per load, and unmounted after.
+type mountReader struct {
+	mu     sync.RWMutex
+	next   uint64
+	mounts map[string]fs.FS
+	ids    map[any]string
+}
+
+var _ pkl.ModuleReader = (*mountReader)(nil)
+
+func newMountReader() *mountReader {
+	return &mountReader{mounts: make(map[string]fs.FS), ids: make(map[any]string)}
+}
+
+// mountKey identifies a map by its address, which stays valid as the map stays
+// mounted.
+type mountKey struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// mount registers fsys and returns the URI of name within it and a function that
+// releases the mount.
+func (r *mountReader) mount(fsys fs.FS, name string) (string, func()) {
+	var key any
+	switch v := reflect.ValueOf(fsys); {
+	case v.Comparable():
+		key = fsys
+	case v.Kind() == reflect.Map:
+		key = mountKey{typ: v.Type(), ptr: v.Pointer()}
+	}
+
+	r.mu.Lock()
+	id, ok := r.ids[key]
+	if !ok {
+		r.next++
+		id = strconv.FormatUint(r.next, 10)
+		r.mounts[id] = fsys
+		if key != nil {
+			r.ids[key] = id
+		}
+	}
+	r.mu.Unlock()
+
+	uri := url.URL{Scheme: MountScheme, Path: "/" + id + "/" + name}
+	if key != nil {
+		return uri.String(), func() {}
+	}
+	return uri.String(), func() {
+		r.mu.Lock()
+		delete(r.mounts, id)
+		r.mu.Unlock()
+	}
+}
+
+func (r *mountReader) Scheme() string {
+	return MountScheme
+}
+
+func (r *mountReader) IsGlobbable() bool {
+	return true
+}
+
+func (r *mountReader) HasHierarchicalUris() bool {
+	return true
+}
+
+func (r *mountReader) IsLocal() bool {
+	return true
+}
+
+func (r *mountReader) ListElements(uri url.URL) ([]pkl.PathElement, error) {
+	fsys, name, err := r.resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", uri.String(), err)
+	}
+	elements := make([]pkl.PathElement, 0, len(entries))
+	for _, entry := range entries {
+		elements = append(elements, pkl.NewPathElement(entry.Name(), entry.IsDir()))
+	}
+	return elements, nil
+}
+
+func (r *mountReader) Read(uri url.URL) (string, error) {
+	fsys, name, err := r.resolve(uri)
+	if err != nil {
+		return "", err
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", uri.String(), err)
+	}
+	return string(data), nil
+}
+
+// resolve splits kdeps-fs:/<id>/<path> into the mounted file system and path.
+func (r *mountReader) resolve(uri url.URL) (fs.FS, string, error) {
+	if uri.Scheme != MountScheme {
+		return nil, "", fmt.Errorf("unsupported scheme %q, expected %q", uri.Scheme, MountScheme)
+	}
+	id, name, _ := strings.Cut(strings.TrimPrefix(path.Clean("/"+uri.Path), "/"), "/")
+	if name == "" {
+		name = "."
+	}
+
+	r.mu.RLock()
+	fsys, ok := r.mounts[id]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("no file system mounted for %s", uri.String())
+	}
+	if !fs.ValidPath(name) {
+		return nil, "", fmt.Errorf("invalid module path %q", uri.Path)
+	}
+	return fsys, name, nil
+}
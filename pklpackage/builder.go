@@ -0,0 +1,237 @@
+package pklpackage
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kdeps/schema/assets"
+)
+
+// zipModTime is the modification time of every zip entry, so that builds are
+// reproducible and checksums are stable.
+var zipModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Package is a built PKL package.
+type Package struct {
+	// URI is the versioned package URI.
+	URI URI
+
+	// Metadata describes the package. PackageZipChecksums matches Zip.
+	Metadata Metadata
+
+	// Zip is the package archive.
+	Zip []byte
+}
+
+// BuildOptions configures Build. Fields mirror the `package` block of PklProject.
+type BuildOptions struct {
+	// BaseURI is the package URI without version, e.g. "package://schema.kdeps.com/core".
+	BaseURI string
+
+	// Version is the package version.
+	Version string
+
+	// PackageZipURL is the download URL of the zip. It may use %s for the version.
+	PackageZipURL string
+
+	// Dependencies are recorded in the metadata as-is.
+	Dependencies map[string]Dependency
+
+	// Exclude are path.Match patterns of files, relative to the package root,
+	// left out of the zip, like `exclude` in PklProject.
+	Exclude []string
+
+	SourceCode          string
+	SourceCodeURLScheme string
+	Documentation       string
+	License             string
+	Authors             []string
+	Description         string
+}
+
+// CoreOptions returns the build options of deps/pkl/PklProject for a schema version.
+func CoreOptions(version string) BuildOptions {
+	return BuildOptions{
+		BaseURI:             assets.SchemaPackageURI,
+		Version:             version,
+		PackageZipURL:       fmt.Sprintf("https://github.com/kdeps/schema/releases/download/v%s/core@%s.zip", version, version),
+		SourceCode:          "https://github.com/kdeps/schema",
+		SourceCodeURLScheme: fmt.Sprintf("https://github.com/kdeps/schema/blob/v%s%%{path}#L%%{line}-L%%{endLine}", version),
+		Documentation:       "https://schema.kdeps.com",
+		License:             "Apache-2.0",
+		Authors:             []string{"Joel Bryan Juliano <joelbryan.juliano@gmail.com>"},
+		Description:         "Core PKL modules for KDEPS",
+	}
+}
+
+// BuildCore builds the `core` package from the embedded schema at assets.SchemaVersion.
+func BuildCore() (*Package, error) {
+	return Build(assets.PKLFS, "pkl", CoreOptions(assets.SchemaVersion))
+}
+
+// BuildRelease builds the `core` package of a registered schema release.
+func BuildRelease(release *assets.SchemaRelease) (*Package, error) {
+	return Build(release.FS(), ".", CoreOptions(release.Version))
+}
+
+// Build packages the .pkl files in dir of fsys. The zip is deterministic: entries
+// are sorted and carry a fixed modification time.
+func Build(fsys fs.FS, dir string, opts BuildOptions) (*Package, error) {
+	uri, err := ParseURI(fmt.Sprintf("%s@%s", opts.BaseURI, opts.Version))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := buildZip(fsys, dir, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	dependencies := opts.Dependencies
+	if dependencies == nil {
+		dependencies = map[string]Dependency{}
+	}
+	return &Package{
+		URI: uri,
+		Metadata: Metadata{
+			Name:                uri.Name(),
+			PackageURI:          uri.Package(),
+			Version:             opts.Version,
+			PackageZipURL:       opts.PackageZipURL,
+			PackageZipChecksums: Checksums{SHA256: sha256Hex(data)},
+			Dependencies:        dependencies,
+			SourceCode:          opts.SourceCode,
+			SourceCodeURLScheme: opts.SourceCodeURLScheme,
+			Documentation:       opts.Documentation,
+			License:             opts.License,
+			Authors:             opts.Authors,
+			Description:         opts.Description,
+		},
+		Zip: data,
+	}, nil
+}
+
+func buildZip(fsys fs.FS, dir string, exclude []string) ([]byte, error) {
+	var files []string
+	err := fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(p) == ".pkl" && !excluded(relPath(dir, p), exclude) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKL files in %s: %w", dir, err)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		name := relPath(dir, file)
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: zipModTime})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to zip: %w", name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to write %s to zip: %w", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish zip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// relPath returns file relative to dir.
+func relPath(dir, file string) string {
+	if dir == "." {
+		return file
+	}
+	return file[len(dir)+1:]
+}
+
+// excluded reports whether name matches one of patterns. A pattern ending in
+// "/**" also matches every file below the directory.
+func excluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok && strings.HasPrefix(name, prefix+"/") {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MetadataJSON returns the metadata in the form served at the package URI.
+func (p *Package) MetadataJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(p.Metadata, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata for %s: %w", p.URI.Package(), err)
+	}
+	return data, nil
+}
+
+// MetadataChecksum returns the SHA-256 of MetadataJSON, as recorded by dependents
+// in their PklProject.deps.json.
+func (p *Package) MetadataChecksum() (string, error) {
+	data, err := p.MetadataJSON()
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(data), nil
+}
+
+// WriteTo writes the package like `pkl project package` does:
+// <dir>/<name>@<version>/<name>@<version>{,.sha256,.zip,.zip.sha256}.
+// It returns the directory the files were written to.
+func (p *Package) WriteTo(dir string) (string, error) {
+	metadata, err := p.MetadataJSON()
+	if err != nil {
+		return "", err
+	}
+
+	base := p.URI.baseName()
+	outDir := filepath.Join(dir, base)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", outDir, err)
+	}
+
+	files := map[string][]byte{
+		base:                 metadata,
+		base + ".sha256":     []byte(sha256Hex(metadata)),
+		base + ".zip":        p.Zip,
+		base + ".zip.sha256": []byte(p.Metadata.PackageZipChecksums.SHA256),
+	}
+	for name, data := range files {
+		path := filepath.Join(outDir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return outDir, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,179 @@
+package pklpackage
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+)
+
+// VendoredFS holds the third-party packages imported by the schema, in the PKL
+// cache layout (vendored/package-2/<authority><path>@<version>/<name>@<version>.{json,zip}).
+// It is populated by `make vendor-pkl-packages`.
+//
+//go:embed vendored
+var VendoredFS embed.FS
+
+// Vendored returns the packages in VendoredFS. Each zip is verified against the
+// checksum in its metadata.
+func Vendored() ([]*Package, error) {
+	root, err := fs.Sub(VendoredFS, "vendored")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vendored packages: %w", err)
+	}
+	return ReadCache(root)
+}
+
+// ReadCache returns the packages stored in a PKL cache directory tree.
+func ReadCache(fsys fs.FS) ([]*Package, error) {
+	var packages []*Package
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == "." && os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".json" || !strings.HasPrefix(p, "package-2/") {
+			return nil
+		}
+		pkg, err := readCachedPackage(fsys, p)
+		if err != nil {
+			return err
+		}
+		packages = append(packages, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].URI.Package() < packages[j].URI.Package()
+	})
+	return packages, nil
+}
+
+func readCachedPackage(fsys fs.FS, metadataPath string) (*Package, error) {
+	data, err := fs.ReadFile(fsys, metadataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", metadataPath, err)
+	}
+	var metadata Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", metadataPath, err)
+	}
+	uri, err := ParseURI(metadata.PackageURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata %s: %w", metadataPath, err)
+	}
+
+	zipPath := strings.TrimSuffix(metadataPath, ".json") + ".zip"
+	zipData, err := fs.ReadFile(fsys, zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", zipPath, err)
+	}
+	if sum := sha256Hex(zipData); sum != metadata.PackageZipChecksums.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", zipPath, metadata.PackageZipChecksums.SHA256, sum)
+	}
+	return &Package{URI: uri, Metadata: metadata, Zip: zipData}, nil
+}
+
+// WriteCache stores packages in a PKL cache directory, the directory passed to
+// the evaluator as CacheDir. PKL then resolves the packages without fetching them.
+func WriteCache(dir string, packages ...*Package) error {
+	for _, p := range packages {
+		metadata, err := p.MetadataJSON()
+		if err != nil {
+			return err
+		}
+
+		pkgDir := filepath.Join(dir, filepath.FromSlash(p.URI.CacheDir()))
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", pkgDir, err)
+		}
+		base := filepath.Join(pkgDir, p.URI.baseName())
+		if err := os.WriteFile(base+".json", metadata, 0644); err != nil {
+			return fmt.Errorf("failed to write %s.json: %w", base, err)
+		}
+		if err := os.WriteFile(base+".zip", p.Zip, 0644); err != nil {
+			return fmt.Errorf("failed to write %s.zip: %w", base, err)
+		}
+	}
+	return nil
+}
+
+// SetupOfflineCache writes the core package built from the embedded schema and
+// the vendored third-party packages to a PKL cache directory. Imported packages
+// that are not vendored are not written; see MissingVendored. If dir is empty,
+// uses a temporary directory in system tmpdir. Returns the directory path.
+func SetupOfflineCache(dir string) (string, error) {
+	if dir == "" {
+		tmpDir := os.TempDir()
+		var err error
+		dir, err = os.MkdirTemp(tmpDir, "pkl_cache_*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory in %s: %w", tmpDir, err)
+		}
+	}
+
+	core, err := BuildCore()
+	if err != nil {
+		return "", err
+	}
+	vendored, err := Vendored()
+	if err != nil {
+		return "", err
+	}
+	if err := WriteCache(dir, append([]*Package{core}, vendored...)...); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// MissingVendored returns the package URIs imported by the embedded schema that
+// are neither the core package nor vendored.
+func MissingVendored() ([]string, error) {
+	graph, err := assets.ImportGraph()
+	if err != nil {
+		return nil, err
+	}
+	vendored, err := Vendored()
+	if err != nil {
+		return nil, err
+	}
+
+	have := map[string]bool{assets.SchemaPackageURI: true}
+	for _, p := range vendored {
+		have[p.URI.Package()] = true
+	}
+
+	var missing []string
+	for _, imported := range graph.ImportsOfKind(assets.ImportPackage) {
+		uri, err := ParseURI(imported)
+		if err != nil {
+			return nil, err
+		}
+		pkg := uri.Package()
+		if !have[pkg] && !have["package://"+uri.Authority+uri.Path] {
+			have[pkg] = true
+			missing = append(missing, pkg)
+		}
+	}
+	return missing, nil
+}
+
+// WithCacheDir sets the PKL package cache directory of an evaluator, e.g. to the
+// directory returned by SetupOfflineCache.
+func WithCacheDir(dir string) func(*pkl.EvaluatorOptions) {
+	return func(opts *pkl.EvaluatorOptions) {
+		opts.CacheDir = dir
+	}
+}
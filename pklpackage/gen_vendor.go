@@ -0,0 +1,145 @@
+//go:build ignore
+
+// gen_vendor downloads the third-party packages imported by the schema into
+// vendored/ in the PKL cache layout. Packages published from the sources of a
+// Go module this module requires are built from the module cache when the
+// download fails, so they can be vendored offline. Run from the pklpackage
+// directory: go run gen_vendor.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kdeps/schema/pklpackage"
+)
+
+func main() {
+	missing, err := pklpackage.MissingVendored()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list missing packages: %v\n", err)
+		os.Exit(1)
+	}
+	if len(missing) == 0 {
+		fmt.Println("All imported packages are vendored")
+		return
+	}
+
+	failed := false
+	for _, uri := range missing {
+		pkg, err := fetch(uri)
+		if err != nil {
+			var buildErr error
+			if pkg, buildErr = build(uri); buildErr != nil {
+				fmt.Fprintf(os.Stderr, "failed to vendor %s: %v; %v\n", uri, err, buildErr)
+				failed = true
+				continue
+			}
+			fmt.Printf("Built %s from module sources (%v)\n", uri, err)
+		}
+		if err := pklpackage.WriteCache("vendored", pkg); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write %s: %v\n", uri, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Vendored %s\n", uri)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// moduleSource is a package published from a directory of a Go module.
+type moduleSource struct {
+	module string
+	dir    string
+	opts   func(version string) pklpackage.BuildOptions
+}
+
+// moduleSources maps package base URIs to their sources. The options mirror the
+// PklProject of each package.
+var moduleSources = map[string]moduleSource{
+	"package://pkg.pkl-lang.org/pkl-go/pkl.golang": {
+		module: "github.com/apple/pkl-go",
+		dir:    "codegen/src",
+		opts: func(version string) pklpackage.BuildOptions {
+			return pklpackage.BuildOptions{
+				BaseURI:             "package://pkg.pkl-lang.org/pkl-go/pkl.golang",
+				Version:             version,
+				PackageZipURL:       fmt.Sprintf("https://github.com/apple/pkl-go/releases/download/pkl.golang@%s/pkl.golang@%s.zip", version, version),
+				SourceCode:          "https://github.com/apple/pkl-go",
+				SourceCodeURLScheme: fmt.Sprintf("https://github.com/apple/pkl-go/tree/v%s/codegen/src%%{path}#L%%{line}-L%%{endLine}", version),
+				License:             "Apache-2.0",
+				Authors:             []string{"The Pkl Authors <pkl-oss@group.apple.com>"},
+				Description:         "Pkl bindings for the Go programming language",
+				Exclude:             []string{"tests", "tests/**"},
+			}
+		},
+	},
+}
+
+// build packages uri from the module cache. The module must be required at the
+// version of the package.
+func build(uri string) (*pklpackage.Package, error) {
+	parsed, err := pklpackage.ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	src, ok := moduleSources["package://"+parsed.Authority+parsed.Path]
+	if !ok {
+		return nil, fmt.Errorf("no module sources for %s", uri)
+	}
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}} {{.Version}}", src.module).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate module %s: %w", src.module, err)
+	}
+	dir, version, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+	if strings.TrimPrefix(version, "v") != parsed.Version {
+		return nil, fmt.Errorf("module %s is required at %s, not %s", src.module, version, parsed.Version)
+	}
+	return pklpackage.Build(os.DirFS(dir), src.dir, src.opts(parsed.Version))
+}
+
+func fetch(uri string) (*pklpackage.Package, error) {
+	parsed, err := pklpackage.ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := get(parsed.MetadataURL())
+	if err != nil {
+		return nil, err
+	}
+	var metadata pklpackage.Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	zipData, err := get(metadata.PackageZipURL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(zipData)
+	if got := hex.EncodeToString(sum[:]); got != metadata.PackageZipChecksums.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", metadata.PackageZipURL, metadata.PackageZipChecksums.SHA256, got)
+	}
+	return &pklpackage.Package{URI: parsed, Metadata: metadata, Zip: zipData}, nil
+}
+
+func get(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,128 @@
+// Package pklpackage builds and serves PKL packages so that the kdeps schema
+// can be evaluated without fetching it from schema.kdeps.com.
+//
+// The `core` package published from deps/pkl/PklProject is normally fetched from
+// https://schema.kdeps.com and GitHub releases, and the schema itself imports
+// third-party packages from pkg.pkl-lang.org. This package provides:
+//
+//   - Build, which produces the package zip and metadata JSON (with checksums)
+//     from the embedded schema, exactly like `pkl project package`;
+//   - Server, a local httptest server that serves packages by URI;
+//   - a vendored cache of the third-party packages and WriteCache, which lays
+//     packages out in the PKL cache directory format for an evaluator's CacheDir.
+//
+// `make vendor-pkl-packages` vendors the imported packages, building
+// pkl.golang from the pkl-go module sources when pkg.pkl-lang.org is not
+// reachable. MissingVendored lists the packages that are still missing, which
+// evaluation downloads unless they are already in the cache.
+//
+// # Local Evaluation
+//
+//	cacheDir, err := pklpackage.SetupOfflineCache("")
+//	if err != nil {
+//	    return err
+//	}
+//	evaluator, err := assets.NewEvaluator(ctx, pklpackage.WithCacheDir(cacheDir))
+package pklpackage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Checksums holds the digests PKL uses to verify package downloads.
+type Checksums struct {
+	SHA256 string `json:"sha256"`
+}
+
+// Dependency is a dependency entry of package metadata.
+type Dependency struct {
+	URI       string     `json:"uri"`
+	Checksums *Checksums `json:"checksums,omitempty"`
+}
+
+// Metadata is the package metadata JSON served at a package URI. Its format
+// matches the metadata written by `pkl project package`.
+type Metadata struct {
+	Name                string                `json:"name"`
+	PackageURI          string                `json:"packageUri"`
+	Version             string                `json:"version"`
+	PackageZipURL       string                `json:"packageZipUrl"`
+	PackageZipChecksums Checksums             `json:"packageZipChecksums"`
+	Dependencies        map[string]Dependency `json:"dependencies"`
+	SourceCode          string                `json:"sourceCode,omitempty"`
+	SourceCodeURLScheme string                `json:"sourceCodeUrlScheme,omitempty"`
+	Documentation       string                `json:"documentation,omitempty"`
+	License             string                `json:"license,omitempty"`
+	Authors             []string              `json:"authors,omitempty"`
+	IssueTracker        string                `json:"issueTracker,omitempty"`
+	Description         string                `json:"description,omitempty"`
+}
+
+// URI is a parsed package URI, e.g.
+// "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.10.0#/go.pkl".
+type URI struct {
+	// Authority is the host (and optional port), e.g. "pkg.pkl-lang.org".
+	Authority string
+
+	// Path is the package path without version, e.g. "/pkl-go/pkl.golang".
+	Path string
+
+	// Version is the package version, e.g. "0.10.0".
+	Version string
+
+	// Fragment is the module path inside the package, e.g. "/go.pkl".
+	Fragment string
+}
+
+// ParseURI parses a versioned package URI.
+func ParseURI(uri string) (URI, error) {
+	rest, ok := strings.CutPrefix(uri, "package://")
+	if !ok {
+		return URI{}, fmt.Errorf("invalid package URI %q: expected package:// scheme", uri)
+	}
+	rest, fragment, _ := strings.Cut(rest, "#")
+	authority, p, ok := strings.Cut(rest, "/")
+	if !ok || authority == "" {
+		return URI{}, fmt.Errorf("invalid package URI %q: missing path", uri)
+	}
+	at := strings.LastIndex(p, "@")
+	if at <= 0 || at == len(p)-1 {
+		return URI{}, fmt.Errorf("invalid package URI %q: missing version", uri)
+	}
+	return URI{Authority: authority, Path: "/" + p[:at], Version: p[at+1:], Fragment: fragment}, nil
+}
+
+// Name returns the last path segment, e.g. "pkl.golang".
+func (u URI) Name() string {
+	return u.Path[strings.LastIndex(u.Path, "/")+1:]
+}
+
+// Package returns the URI without fragment, e.g. "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.10.0".
+func (u URI) Package() string {
+	return fmt.Sprintf("package://%s%s@%s", u.Authority, u.Path, u.Version)
+}
+
+// String returns the URI including the fragment, if any.
+func (u URI) String() string {
+	if u.Fragment == "" {
+		return u.Package()
+	}
+	return u.Package() + "#" + u.Fragment
+}
+
+// MetadataURL returns the HTTPS URL PKL fetches the package metadata from.
+func (u URI) MetadataURL() string {
+	return fmt.Sprintf("https://%s%s@%s", u.Authority, u.Path, u.Version)
+}
+
+// CacheDir returns the directory of the package inside a PKL cache directory,
+// relative to the cache root, using the package-2 layout.
+func (u URI) CacheDir() string {
+	return fmt.Sprintf("package-2/%s%s@%s", u.Authority, u.Path, u.Version)
+}
+
+// baseName returns "<name>@<version>", the base file name of the zip and metadata.
+func (u URI) baseName() string {
+	return fmt.Sprintf("%s@%s", u.Name(), u.Version)
+}
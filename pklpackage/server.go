@@ -0,0 +1,113 @@
+package pklpackage
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Handler serves package metadata and zips over HTTP. Packages are served under
+// their authority, so one handler can stand in for several package hosts:
+//
+//	GET /<authority><path>@<version>      -> metadata JSON
+//	GET /<authority><path>@<version>.zip  -> package zip
+//
+// The packageZipUrl of served metadata points back at the handler.
+type Handler struct {
+	mu       sync.RWMutex
+	packages map[string]*Package
+}
+
+// NewHandler returns a Handler serving the given packages.
+func NewHandler(packages ...*Package) *Handler {
+	h := &Handler{packages: make(map[string]*Package)}
+	for _, p := range packages {
+		h.Add(p)
+	}
+	return h
+}
+
+// Add serves another package, replacing any package with the same URI.
+func (h *Handler) Add(p *Package) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.packages[servePath(p.URI)] = p
+}
+
+func servePath(u URI) string {
+	return "/" + u.Authority + u.Path + "@" + u.Version
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, isZip := strings.CutSuffix(r.URL.Path, ".zip")
+	h.mu.RLock()
+	p, ok := h.packages[key]
+	h.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if isZip {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(p.Zip)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	served := *p
+	served.Metadata.PackageZipURL = fmt.Sprintf("%s://%s%s.zip", scheme, r.Host, key)
+	data, err := served.MetadataJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Server is a local package server for tests and offline environments.
+type Server struct {
+	*httptest.Server
+	handler *Handler
+}
+
+// NewServer starts a TLS package server serving the given packages. Close it when done.
+func NewServer(packages ...*Package) *Server {
+	handler := NewHandler(packages...)
+	return &Server{Server: httptest.NewTLSServer(handler), handler: handler}
+}
+
+// Add serves another package.
+func (s *Server) Add(p *Package) {
+	s.handler.Add(p)
+}
+
+// MetadataURL returns the URL the server serves the metadata of a package at.
+func (s *Server) MetadataURL(u URI) string {
+	return s.URL + servePath(u)
+}
+
+// Rewrites returns HTTP rewrite rules that redirect every served package host to
+// this server, in the form accepted by PKL's --http-rewrite option.
+func (s *Server) Rewrites() map[string]string {
+	s.handler.mu.RLock()
+	defer s.handler.mu.RUnlock()
+
+	rewrites := make(map[string]string)
+	for _, p := range s.handler.packages {
+		rewrites["https://"+p.URI.Authority+"/"] = s.URL + "/" + p.URI.Authority + "/"
+	}
+	return rewrites
+}
@@ -0,0 +1,406 @@
+// Package pklrender renders generated Go types back to PKL source.
+//
+// A module type such as workflow.WorkflowImpl or resource.ResourceImpl renders
+// as a module that amends its schema module:
+//
+//	amends "kdeps-schema:/Resource.pkl"
+//
+//	ActionID = "answer"
+//	Run {
+//	  Chat {
+//	    Model = "llama3.2"
+//	    TimeoutDuration = 60.s
+//	  }
+//	}
+//
+// A class type such as project.Settings renders as a module that imports its
+// schema module and sets output.value, so it loads with EvaluateOutputValue.
+//
+// Loading the rendered source yields a struct equal to the rendered one. Nil
+// pointers are omitted, except where the PKL property declares a default, which
+// is overridden with null.
+package pklrender
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/internal/pklschema"
+)
+
+const indentUnit = "  "
+
+var (
+	durationType = reflect.TypeOf(pkl.Duration{})
+	dataSizeType = reflect.TypeOf(pkl.DataSize{})
+	objectType   = reflect.TypeOf(pkl.Object{})
+)
+
+type config struct {
+	schemaURI func(file string) string
+}
+
+// Option configures rendering.
+type Option func(*config)
+
+// WithSchemaURI sets how schema modules are referenced. The default is
+// assets.ModuleURI, e.g. "kdeps-schema:/Workflow.pkl".
+func WithSchemaURI(fn func(file string) string) Option {
+	return func(c *config) {
+		c.schemaURI = fn
+	}
+}
+
+// WithSchemaPackage references schema modules in a published package, e.g.
+// "package://schema.kdeps.com/core@0.3.0" gives
+// "package://schema.kdeps.com/core@0.3.0#/Workflow.pkl".
+func WithSchemaPackage(packageURI string) Option {
+	return WithSchemaURI(func(file string) string {
+		return packageURI + "#/" + file
+	})
+}
+
+// Render renders v, a generated struct or a pointer to one, as a PKL module.
+func Render(v any, opts ...Option) ([]byte, error) {
+	cfg := config{schemaURI: assets.ModuleURI}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	schema, err := pklschema.Embedded()
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("cannot render nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	m, class, err := schema.Lookup(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{schema: schema}
+	if class.Name == "" {
+		fmt.Fprintf(&r.buf, "amends %s\n\n", quote(cfg.schemaURI(m.File)))
+		r.fields(0, rv, class)
+	} else {
+		fmt.Fprintf(&r.buf, "import %s\n\n", quote(cfg.schemaURI(m.File)))
+		r.buf.WriteString("output {\n")
+		r.block(1, "value = new "+m.Name+"."+class.Name, rv, false)
+		r.buf.WriteString("}\n")
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.buf.Bytes(), nil
+}
+
+type renderer struct {
+	schema *pklschema.Schema
+	buf    bytes.Buffer
+	err    error
+}
+
+func (r *renderer) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *renderer) line(depth int, s string) {
+	r.buf.WriteString(strings.Repeat(indentUnit, depth))
+	r.buf.WriteString(s)
+	r.buf.WriteByte('\n')
+}
+
+// fields renders the pkl-tagged fields of a generated struct.
+func (r *renderer) fields(depth int, v reflect.Value, class *pklschema.Class) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			_, parent, err := r.schema.Lookup(f.Type)
+			if err != nil {
+				r.fail(err)
+				continue
+			}
+			r.fields(depth, v.Field(i), parent)
+			continue
+		}
+		name, ok := f.Tag.Lookup("pkl")
+		if !ok || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer && fv.IsNil() {
+			if prop := class.Props[name]; prop != nil && prop.Default != "" && prop.Default != "null" {
+				r.line(depth, identifier(name)+" = null")
+			}
+			continue
+		}
+		r.member(depth, identifier(name), fv, false)
+	}
+}
+
+// member renders `name = value`, or `name { ... }` for objects and collections.
+// In dynamic contexts nested values are created with an explicit type.
+func (r *renderer) member(depth int, name string, v reflect.Value, dynamic bool) {
+	v = deref(v)
+	if !v.IsValid() {
+		r.line(depth, name+" = null")
+		return
+	}
+	if isBlock(v) {
+		if dynamic {
+			name += " = new " + dynamicType(v)
+		}
+		r.block(depth, name, v, dynamic)
+		return
+	}
+	r.line(depth, name+" = "+r.scalar(depth, v))
+}
+
+// element renders a Listing element.
+func (r *renderer) element(depth int, v reflect.Value, dynamic bool) {
+	v = deref(v)
+	switch {
+	case !v.IsValid():
+		r.line(depth, "null")
+	case isBlock(v) && dynamic:
+		r.block(depth, "new "+dynamicType(v), v, dynamic)
+	case isBlock(v):
+		r.block(depth, "new", v, dynamic)
+	default:
+		r.line(depth, r.scalar(depth, v))
+	}
+}
+
+// block renders `header { ... }` for a struct, object, slice or map.
+func (r *renderer) block(depth int, header string, v reflect.Value, dynamic bool) {
+	start := r.buf.Len()
+	r.line(depth, header+" {")
+	bodyStart := r.buf.Len()
+
+	switch {
+	case v.Type() == objectType:
+		r.object(depth+1, v.Interface().(pkl.Object))
+	case v.Kind() == reflect.Struct:
+		_, class, err := r.schema.Lookup(v.Type())
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		r.fields(depth+1, v, class)
+	case v.Kind() == reflect.Slice:
+		dynamic = v.Type().Elem().Kind() == reflect.Interface
+		for i := 0; i < v.Len(); i++ {
+			r.element(depth+1, v.Index(i), dynamic)
+		}
+	case v.Kind() == reflect.Map:
+		dynamic = v.Type().Elem().Kind() == reflect.Interface
+		for _, key := range sortedKeys(v) {
+			r.member(depth+1, "["+r.scalar(depth+1, deref(key))+"]", v.MapIndex(key), dynamic)
+		}
+	}
+
+	if r.buf.Len() == bodyStart {
+		r.buf.Truncate(start)
+		r.line(depth, header+" {}")
+		return
+	}
+	r.line(depth, "}")
+}
+
+// object renders the members of a Dynamic object.
+func (r *renderer) object(depth int, obj pkl.Object) {
+	names := make([]string, 0, len(obj.Properties))
+	for name := range obj.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.member(depth, identifier(name), reflect.ValueOf(obj.Properties[name]), true)
+	}
+	entries := reflect.ValueOf(obj.Entries)
+	for _, key := range sortedKeys(entries) {
+		r.member(depth, "["+r.scalar(depth, deref(key))+"]", entries.MapIndex(key), true)
+	}
+	for _, elem := range obj.Elements {
+		r.element(depth, reflect.ValueOf(elem), true)
+	}
+}
+
+// scalar renders a value that is not an object or collection.
+func (r *renderer) scalar(depth int, v reflect.Value) string {
+	switch v.Type() {
+	case durationType:
+		d := v.Interface().(pkl.Duration)
+		return number(d.Value) + "." + d.Unit.String()
+	case dataSizeType:
+		d := v.Interface().(pkl.DataSize)
+		return number(d.Value) + "." + d.Unit.String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return stringLiteral(v.String(), depth)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return floatLiteral(v.Float())
+	}
+	r.fail(fmt.Errorf("cannot render value of type %s", v.Type()))
+	return "null"
+}
+
+func deref(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isBlock(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct:
+		return v.Type() != durationType && v.Type() != dataSizeType
+	case reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// dynamicType is the type to instantiate for a value without a declared type.
+func dynamicType(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		return "Listing"
+	case reflect.Map:
+		return "Mapping"
+	}
+	return "Dynamic"
+}
+
+func sortedKeys(m reflect.Value) []reflect.Value {
+	if !m.IsValid() || m.IsNil() {
+		return nil
+	}
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+func number(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func floatLiteral(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+// stringLiteral renders s as a PKL string, using a multi-line string when s
+// contains newlines.
+func stringLiteral(s string, depth int) string {
+	if !strings.Contains(s, "\n") {
+		return quote(s)
+	}
+	indent := strings.Repeat(indentUnit, depth+1)
+	var b strings.Builder
+	b.WriteString(`"""`)
+	for _, line := range strings.Split(s, "\n") {
+		b.WriteString("\n")
+		b.WriteString(indent)
+		b.WriteString(escape(line))
+	}
+	b.WriteString("\n" + indent + `"""`)
+	return b.String()
+}
+
+func quote(s string) string {
+	return `"` + escape(s) + `"`
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\u{%x}`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+var keywords = map[string]bool{
+	"abstract": true, "amends": true, "as": true, "case": true, "class": true, "const": true,
+	"delete": true, "else": true, "extends": true, "external": true, "false": true, "fixed": true,
+	"for": true, "function": true, "hidden": true, "if": true, "import": true, "in": true, "is": true,
+	"let": true, "local": true, "module": true, "new": true, "nothing": true, "null": true,
+	"open": true, "out": true, "outer": true, "override": true, "protected": true, "read": true,
+	"record": true, "super": true, "switch": true, "this": true, "throw": true, "trace": true,
+	"true": true, "typealias": true, "unknown": true, "vararg": true, "when": true,
+}
+
+// identifier quotes a property name with backticks when it is not a plain identifier.
+func identifier(name string) string {
+	plain := name != "" && !keywords[name]
+	for i, c := range name {
+		if !(c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			plain = false
+		}
+	}
+	if plain {
+		return name
+	}
+	return "`" + name + "`"
+}
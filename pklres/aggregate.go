@@ -0,0 +1,253 @@
+package pklres
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pklresource "github.com/kdeps/schema/gen/pkl_resource"
+)
+
+// Aggregate is an aggregation, the JSON form of the params of op=aggregate: the
+// rows matching Where are grouped on equal values of the GroupBy fields, and
+// each group is folded into one row by the Aggregations.
+type Aggregate struct {
+	Where        *pklresource.QueryCondition       `json:"where,omitempty"`
+	GroupBy      []string                          `json:"groupBy"`
+	Aggregations []*pklresource.AggregateCondition `json:"aggregations"`
+}
+
+// group is the rows of a group of an aggregation and the values of its groupBy fields.
+type group struct {
+	values []any
+	rows   []Row
+}
+
+// Aggregate returns a row per group of a, sorted by the values of the groupBy
+// fields. Rows without a groupBy field are grouped on a null value, which sorts
+// last. Without groupBy fields every matching row is in a single group, which
+// exists even when no row matches.
+//
+// The aggregates skip null and missing values, except "count" without a field,
+// which counts the rows of the group. "sum" and "avg" require numbers or
+// durations, "min" and "max" compare as in conditions, and "distinct" returns
+// the distinct values sorted.
+func (t *Table) Aggregate(a Aggregate) (Result, error) {
+	match, err := compile(a.Where)
+	if err != nil {
+		return Result{}, err
+	}
+	names, err := aggregateNames(a)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, r := range t.filter(a.Where, match) {
+		values := make([]any, len(a.GroupBy))
+		keys := make([]string, len(a.GroupBy))
+		for i, f := range a.GroupBy {
+			values[i] = r.Data[f]
+			if values[i] != nil {
+				keys[i] = typed(values[i]).key()
+			}
+		}
+		key := strings.Join(keys, "\x00")
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{values: values}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, r)
+	}
+	if len(a.GroupBy) == 0 && len(groups) == 0 {
+		groups = append(groups, &group{})
+	}
+
+	orderBy := make([]*pklresource.OrderBy, len(a.GroupBy))
+	for i, f := range a.GroupBy {
+		orderBy[i] = &pklresource.OrderBy{Field: f}
+	}
+	rows := make([]Row, 0, len(groups))
+	for _, g := range groups {
+		data := make(map[string]any, len(a.GroupBy)+len(a.Aggregations))
+		for i, f := range a.GroupBy {
+			data[f] = g.values[i]
+		}
+		for i, c := range a.Aggregations {
+			v, err := aggregateGroup(c, g.rows)
+			if err != nil {
+				return Result{}, err
+			}
+			data[names[i]] = v
+		}
+		rows = append(rows, Row{Data: data})
+	}
+	slices.SortFunc(rows, func(x, y Row) int {
+		return compareRows(orderBy, x, sortValues(orderBy, y), rowKey(y))
+	})
+
+	selected := make([]string, 0, len(a.GroupBy)+len(a.Aggregations))
+	selected = append(selected, a.GroupBy...)
+	for i, c := range a.Aggregations {
+		field := "*"
+		if c.Field != nil {
+			field = *c.Field
+		}
+		selected = append(selected, fmt.Sprintf("%s(%s) AS %s", c.Operator, field, names[i]))
+	}
+	query := "SELECT " + strings.Join(selected, ", ") + " FROM " + t.name + whereClause(a.Where)
+	if len(a.GroupBy) > 0 {
+		query += " GROUP BY " + strings.Join(a.GroupBy, ", ")
+	}
+	return Result{Rows: rows, Columns: append(slices.Clone(a.GroupBy), names...), Query: query}, nil
+}
+
+// aggregateNames validates the aggregations of a and returns their names.
+func aggregateNames(a Aggregate) ([]string, error) {
+	if len(a.Aggregations) == 0 {
+		return nil, fmt.Errorf("aggregation without aggregations")
+	}
+	seen := make(map[string]bool)
+	for _, f := range a.GroupBy {
+		if f == "" {
+			return nil, fmt.Errorf("groupBy with an empty field")
+		}
+		if seen[f] {
+			return nil, fmt.Errorf("duplicate groupBy field %q", f)
+		}
+		seen[f] = true
+	}
+
+	names := make([]string, len(a.Aggregations))
+	for i, c := range a.Aggregations {
+		if c == nil {
+			return nil, fmt.Errorf("aggregation without operator")
+		}
+		if _, ok := aggregators[c.Operator]; !ok {
+			return nil, fmt.Errorf("unsupported aggregate %q", c.Operator)
+		}
+		if c.Field != nil && *c.Field == "" {
+			return nil, fmt.Errorf("aggregate %q with an empty field", c.Operator)
+		}
+		if c.Field == nil && c.Operator != "count" {
+			return nil, fmt.Errorf("aggregate %q requires a field", c.Operator)
+		}
+		switch {
+		case c.Alias != nil && *c.Alias != "":
+			names[i] = *c.Alias
+		case c.Field != nil:
+			names[i] = c.Operator + "_" + *c.Field
+		default:
+			names[i] = c.Operator
+		}
+		if seen[names[i]] {
+			return nil, fmt.Errorf("duplicate column %q in aggregation", names[i])
+		}
+		seen[names[i]] = true
+	}
+	return names, nil
+}
+
+// aggregateGroup computes the aggregate c over the rows of a group.
+func aggregateGroup(c *pklresource.AggregateCondition, rows []Row) (any, error) {
+	if c.Field == nil {
+		return len(rows), nil
+	}
+	var values []any
+	for _, r := range rows {
+		if v := r.Data[*c.Field]; v != nil {
+			values = append(values, v)
+		}
+	}
+	v, err := aggregators[c.Operator](values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute %s(%s): %w", c.Operator, *c.Field, err)
+	}
+	return v, nil
+}
+
+// aggregators maps aggregate operators to functions folding the non-null
+// values of a field in a group.
+var aggregators = map[string]func(values []any) (any, error){
+	"count": func(values []any) (any, error) {
+		return len(values), nil
+	},
+	"sum": func(values []any) (any, error) {
+		total, err := sum(values)
+		if err != nil {
+			return nil, err
+		}
+		return total.result(), nil
+	},
+	"avg": func(values []any) (any, error) {
+		if len(values) == 0 {
+			return nil, nil
+		}
+		total, err := sum(values)
+		if err != nil {
+			return nil, err
+		}
+		total.num /= float64(len(values))
+		return total.result(), nil
+	},
+	"min": func(values []any) (any, error) {
+		return extreme(values, -1), nil
+	},
+	"max": func(values []any) (any, error) {
+		return extreme(values, 1), nil
+	},
+	"distinct": func(values []any) (any, error) {
+		seen := make(map[string]bool)
+		distinct := []any{}
+		for _, v := range values {
+			if k := typed(v).key(); !seen[k] {
+				seen[k] = true
+				distinct = append(distinct, v)
+			}
+		}
+		slices.SortStableFunc(distinct, compare)
+		return distinct, nil
+	},
+}
+
+// sum adds values, which must all be numbers or all durations. The sum of no
+// values is the number 0.
+func sum(values []any) (value, error) {
+	total := value{kind: kindNumber}
+	for i, v := range values {
+		t := typed(v)
+		if t.kind == kindText {
+			return value{}, fmt.Errorf("value %s is neither a number nor a duration", text(v))
+		}
+		if i > 0 && t.kind != total.kind {
+			return value{}, fmt.Errorf("cannot add %s to %s: numbers and durations are mixed", text(v), text(values[0]))
+		}
+		total.kind = t.kind
+		total.num += t.num
+	}
+	return total, nil
+}
+
+// result returns a number or duration as a JSON value: a number, or a Go
+// duration string such as "1m30s".
+func (v value) result() any {
+	if v.kind == kindNumber {
+		return v.num
+	}
+	return v.String()
+}
+
+// extreme returns the least value when sign is -1 and the greatest when sign is
+// 1, or nil when there are no values.
+func extreme(values []any, sign int) any {
+	var best any
+	for _, v := range values {
+		if best == nil || compare(v, best)*sign > 0 {
+			best = v
+		}
+	}
+	return best
+}
@@ -0,0 +1,335 @@
+package pklres
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is the time queries stay cached unless changed with WithCacheTTL
+// or op=setCacheTTL.
+const DefaultCacheTTL = 5 * time.Minute
+
+// DefaultCacheMaxEntries and DefaultCacheMaxBytes bound the cache of a Reader
+// created without WithCache or WithCacheLimits.
+const (
+	DefaultCacheMaxEntries = 1024
+	DefaultCacheMaxBytes   = 64 << 20
+)
+
+// CacheKey identifies a cached query result: the graph it was computed for, the
+// query type ("select", "project", "join", "query" or "aggregate") and the
+// normalized JSON of its collection and condition.
+type CacheKey struct {
+	GraphID string
+	Op      string
+	Params  string
+}
+
+// size is the number of bytes the key takes in a cache.
+func (k CacheKey) size() int64 {
+	return int64(len(k.GraphID) + len(k.Op) + len(k.Params))
+}
+
+// newCacheKey returns the key of a query on collection with condition. The
+// condition is normalized, so that JSON differing only in whitespace or the
+// order of object fields shares a key.
+func newCacheKey(graphID, op, collection string, condition []byte) CacheKey {
+	var params any = string(condition)
+	d := json.NewDecoder(bytes.NewReader(condition))
+	d.UseNumber()
+	var v any
+	if d.Decode(&v) == nil {
+		params = v
+	}
+	data, err := json.Marshal(map[string]any{"collection": collection, "condition": params})
+	if err != nil {
+		data = []byte(collection + "\x00" + string(condition))
+	}
+	return CacheKey{GraphID: graphID, Op: op, Params: string(data)}
+}
+
+// Cache caches the JSON results of the relational queries of readers. A cache
+// may be shared by the readers of several graphs.
+//
+// Results are tagged with the collections they read. A cache must not return a
+// result after one of its collections is invalidated, nor cache a result
+// computed at a generation older than the last invalidation, because it may
+// have read the collection before it changed.
+//
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the cached result of key. On a miss it returns the current
+	// generation, to pass to Put.
+	Get(key CacheKey) (result []byte, generation uint64, ok bool)
+
+	// Put caches the result of key, computed at generation in latency from the
+	// collections of key.GraphID. Put records the latency even when it does
+	// not cache the result.
+	Put(key CacheKey, generation uint64, result []byte, latency time.Duration, collections ...string)
+
+	// Invalidate drops the results that read a collection of a graph.
+	Invalidate(graphID, collection string)
+
+	// Clear drops the results of a graph.
+	Clear(graphID string)
+
+	// SetTTL sets the time results stay cached. A TTL of zero disables caching.
+	SetTTL(ttl time.Duration)
+
+	// TTL returns the time results stay cached.
+	TTL() time.Duration
+
+	// Stats returns the statistics of the cache.
+	Stats() CacheStats
+}
+
+// CacheStats are the statistics returned by op=getCacheStats, the JSON form of
+// PklResource.CacheStats.
+type CacheStats struct {
+	Entries    int   `json:"entries"`
+	Bytes      int64 `json:"bytes"`
+	MaxEntries int   `json:"maxEntries"`
+	MaxBytes   int64 `json:"maxBytes"`
+
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+
+	// Evictions counts the results dropped to stay within the bounds,
+	// Expirations the results dropped after the TTL and Invalidations the
+	// results dropped because a collection they read changed.
+	Evictions     uint64 `json:"evictions"`
+	Expirations   uint64 `json:"expirations"`
+	Invalidations uint64 `json:"invalidations"`
+
+	TTL string `json:"ttl"`
+
+	// Ops are the statistics of each query type.
+	Ops map[string]CacheOpStats `json:"ops"`
+}
+
+// CacheOpStats are the statistics of a query type. The latencies are those of
+// computing results on misses.
+type CacheOpStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	AvgLatency string `json:"avgLatency"`
+	MaxLatency string `json:"maxLatency"`
+}
+
+type opStats struct {
+	hits, misses uint64
+	computed     uint64
+	total, max   time.Duration
+}
+
+type cacheEntry struct {
+	key         CacheKey
+	result      []byte
+	collections []string
+	expires     time.Time
+	size        int64
+}
+
+// LRUCache is a Cache bounded by a number of entries and bytes, evicting the
+// least recently used results first. Results also expire after the TTL.
+type LRUCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+	now        func() time.Time
+
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[CacheKey]*list.Element
+	readers map[[2]string]map[CacheKey]bool // keys by graph ID and collection
+	bytes   int64
+
+	generation                            uint64
+	hits, misses                          uint64
+	evictions, expirations, invalidations uint64
+	ops                                   map[string]*opStats
+}
+
+var _ Cache = (*LRUCache)(nil)
+
+// NewLRUCache creates an LRUCache with a TTL, holding at most maxEntries
+// results and maxBytes bytes of keys and results. A bound of zero or less is
+// no bound.
+func NewLRUCache(ttl time.Duration, maxEntries int, maxBytes int64) *LRUCache {
+	return &LRUCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		now:        time.Now,
+		lru:        list.New(),
+		entries:    make(map[CacheKey]*list.Element),
+		readers:    make(map[[2]string]map[CacheKey]bool),
+		ops:        make(map[string]*opStats),
+	}
+}
+
+func (c *LRUCache) op(name string) *opStats {
+	s, ok := c.ops[name]
+	if !ok {
+		s = &opStats{}
+		c.ops[name] = s
+	}
+	return s
+}
+
+// Get implements Cache.
+func (c *LRUCache) Get(key CacheKey) ([]byte, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok && !c.now().Before(el.Value.(*cacheEntry).expires) {
+		c.remove(el)
+		c.expirations++
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		c.op(key.Op).misses++
+		return nil, c.generation, false
+	}
+	c.hits++
+	c.op(key.Op).hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).result, c.generation, true
+}
+
+// Put implements Cache.
+func (c *LRUCache) Put(key CacheKey, generation uint64, result []byte, latency time.Duration, collections ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.op(key.Op)
+	s.computed++
+	s.total += latency
+	s.max = max(s.max, latency)
+
+	size := key.size() + int64(len(result))
+	if c.ttl <= 0 || generation != c.generation || (c.maxBytes > 0 && size > c.maxBytes) {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	e := &cacheEntry{key: key, result: result, collections: collections, expires: c.now().Add(c.ttl), size: size}
+	c.entries[key] = c.lru.PushFront(e)
+	c.bytes += size
+	for _, name := range collections {
+		scope := [2]string{key.GraphID, name}
+		if c.readers[scope] == nil {
+			c.readers[scope] = make(map[CacheKey]bool)
+		}
+		c.readers[scope][key] = true
+	}
+	for (c.maxEntries > 0 && len(c.entries) > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.lru.Back())
+		c.evictions++
+	}
+}
+
+// remove drops the entry of el. It must be called with the lock held.
+func (c *LRUCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+	for _, name := range e.collections {
+		scope := [2]string{e.key.GraphID, name}
+		delete(c.readers[scope], e.key)
+		if len(c.readers[scope]) == 0 {
+			delete(c.readers, scope)
+		}
+	}
+}
+
+// Invalidate implements Cache.
+func (c *LRUCache) Invalidate(graphID, collection string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key := range c.readers[[2]string{graphID, collection}] {
+		c.remove(c.entries[key])
+		c.invalidations++
+	}
+}
+
+// Clear implements Cache.
+func (c *LRUCache) Clear(graphID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, el := range c.entries {
+		if key.GraphID == graphID {
+			c.remove(el)
+		}
+	}
+}
+
+// SetTTL implements Cache. Cached results expire no later than the new TTL
+// from now, so a TTL of zero drops them all.
+func (c *LRUCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	expires := c.now().Add(ttl)
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*cacheEntry)
+		if ttl <= 0 {
+			c.remove(el)
+			c.expirations++
+		} else if e.expires.After(expires) {
+			e.expires = expires
+		}
+		el = next
+	}
+}
+
+// TTL implements Cache.
+func (c *LRUCache) TTL() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttl
+}
+
+// Stats implements Cache. Expired results are dropped first.
+func (c *LRUCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if !now.Before(el.Value.(*cacheEntry).expires) {
+			c.remove(el)
+			c.expirations++
+		}
+		el = next
+	}
+
+	ops := make(map[string]CacheOpStats, len(c.ops))
+	for name, s := range c.ops {
+		var avg time.Duration
+		if s.computed > 0 {
+			avg = s.total / time.Duration(s.computed)
+		}
+		ops[name] = CacheOpStats{Hits: s.hits, Misses: s.misses, AvgLatency: avg.String(), MaxLatency: s.max.String()}
+	}
+	return CacheStats{
+		Entries:       len(c.entries),
+		Bytes:         c.bytes,
+		MaxEntries:    c.maxEntries,
+		MaxBytes:      c.maxBytes,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		Expirations:   c.expirations,
+		Invalidations: c.invalidations,
+		TTL:           c.ttl.String(),
+		Ops:           ops,
+	}
+}
@@ -0,0 +1,196 @@
+package pklres
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	pklresource "github.com/kdeps/schema/gen/pkl_resource"
+)
+
+// predicate is a compiled query condition.
+type predicate func(Row) bool
+
+// compile validates c and compiles it to a predicate. Leaves other than
+// "isNull" do not match rows without their field.
+func compile(c *pklresource.QueryCondition) (predicate, error) {
+	if c == nil {
+		return func(Row) bool { return true }, nil
+	}
+
+	switch c.Operator {
+	case "and", "or", "not":
+		if c.Field != nil {
+			return nil, fmt.Errorf("operator %q does not take a field", c.Operator)
+		}
+		if c.Operator == "not" && len(c.Conditions) != 1 {
+			return nil, fmt.Errorf("operator \"not\" requires exactly one condition, got %d", len(c.Conditions))
+		}
+		children := make([]predicate, len(c.Conditions))
+		for i, child := range c.Conditions {
+			match, err := compile(child)
+			if err != nil {
+				return nil, err
+			}
+			children[i] = match
+		}
+		return combine(c.Operator, children), nil
+	}
+
+	if c.Field == nil || *c.Field == "" {
+		return nil, fmt.Errorf("operator %q requires a field", c.Operator)
+	}
+	if len(c.Conditions) > 0 {
+		return nil, fmt.Errorf("operator %q does not take conditions", c.Operator)
+	}
+	test, err := leaf(c.Operator, c.Value)
+	if err != nil {
+		return nil, err
+	}
+	field := *c.Field
+	if c.Operator == "isNull" {
+		return func(r Row) bool { return test(r.Data[field]) }, nil
+	}
+	return func(r Row) bool {
+		v, ok := r.Data[field]
+		return ok && test(v)
+	}, nil
+}
+
+func combine(op string, children []predicate) predicate {
+	switch op {
+	case "and":
+		return func(r Row) bool {
+			for _, child := range children {
+				if !child(r) {
+					return false
+				}
+			}
+			return true
+		}
+	case "or":
+		return func(r Row) bool {
+			for _, child := range children {
+				if child(r) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return func(r Row) bool {
+		return !children[0](r)
+	}
+}
+
+// leaf returns the test of a field value against value by op.
+func leaf(op string, value any) (func(any) bool, error) {
+	if test, ok := operators[op]; ok {
+		if _, ok := value.([]any); op == "in" && !ok {
+			return nil, fmt.Errorf("operator \"in\" requires a list value, got %s", text(value))
+		}
+		return func(v any) bool { return test(v, value) }, nil
+	}
+
+	switch op {
+	case "regex":
+		re, err := regexp.Compile(text(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", text(value), err)
+		}
+		return func(v any) bool { return re.MatchString(text(v)) }, nil
+	case "startsWith":
+		prefix := text(value)
+		return func(v any) bool { return strings.HasPrefix(text(v), prefix) }, nil
+	case "between":
+		bounds, ok := value.([]any)
+		if !ok || len(bounds) != 2 {
+			return nil, fmt.Errorf("operator \"between\" requires a list of two bounds, got %s", text(value))
+		}
+		lo, hi := typed(bounds[0]), typed(bounds[1])
+		return func(v any) bool {
+			t := typed(v)
+			return t.compare(lo) >= 0 && t.compare(hi) <= 0
+		}, nil
+	case "isNull":
+		want := true
+		if value != nil {
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("operator \"isNull\" requires a Boolean value, got %s", text(value))
+			}
+			want = b
+		}
+		return func(v any) bool { return (v == nil) == want }, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+// describe formats c as in the query of a result. Nested "and" and "or"
+// conditions are parenthesized.
+func describe(c *pklresource.QueryCondition) string {
+	switch c.Operator {
+	case "and", "or":
+		parts := make([]string, len(c.Conditions))
+		for i, child := range c.Conditions {
+			parts[i] = describe(child)
+			if child.Operator == "and" || child.Operator == "or" {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+		return strings.Join(parts, " "+strings.ToUpper(c.Operator)+" ")
+	case "not":
+		return "NOT (" + describe(c.Conditions[0]) + ")"
+	case "isNull":
+		if c.Value == false {
+			return *c.Field + " isNull false"
+		}
+		return *c.Field + " isNull"
+	}
+	return fmt.Sprintf("%s %s %s", *c.Field, c.Operator, text(c.Value))
+}
+
+// whereClause formats c as the WHERE clause of the query of a result, or returns
+// "" when c matches every row.
+func whereClause(c *pklresource.QueryCondition) string {
+	if c == nil || (c.Operator == "and" && len(c.Conditions) == 0) {
+		return ""
+	}
+	return " WHERE " + describe(c)
+}
+
+// candidates returns the positions of the rows of t that can match c, in order,
+// and whether an index narrowed them. "eq" and "in" leaves on indexed fields are
+// looked up; "and" uses the smallest lookup of its conditions.
+func (t *Table) candidates(c *pklresource.QueryCondition) ([]int, bool) {
+	if c == nil {
+		return nil, false
+	}
+	switch c.Operator {
+	case "and":
+		var best []int
+		found := false
+		for _, child := range c.Conditions {
+			if positions, ok := t.candidates(child); ok && (!found || len(positions) < len(best)) {
+				best, found = positions, true
+			}
+		}
+		return best, found
+	case "eq", "in":
+		index, ok := t.indexes[*c.Field]
+		if !ok {
+			return nil, false
+		}
+		if c.Operator == "eq" {
+			return index[typed(c.Value).key()], true
+		}
+		var positions []int
+		for _, v := range c.Value.([]any) {
+			positions = append(positions, index[typed(v).key()]...)
+		}
+		slices.Sort(positions)
+		return slices.Compact(positions), true
+	}
+	return nil, false
+}
@@ -0,0 +1,93 @@
+package pklres
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	pklresource "github.com/kdeps/schema/gen/pkl_resource"
+)
+
+// cursor is the position of a row in the ordering of a query: the values of
+// its orderBy fields and its key, which breaks ties. Pages continue after the
+// cursor, so they stay consistent while rows are added or removed.
+type cursor struct {
+	Fields []string `json:"f"`
+	Values []any    `json:"v"`
+	Key    string   `json:"k"`
+}
+
+// cursorFields describes orderBy, with descending fields prefixed by "-".
+func cursorFields(orderBy []*pklresource.OrderBy) []string {
+	fields := make([]string, len(orderBy))
+	for i, o := range orderBy {
+		fields[i] = o.Field
+		if o.Descending {
+			fields[i] = "-" + o.Field
+		}
+	}
+	return fields
+}
+
+func encodeCursor(orderBy []*pklresource.OrderBy, r Row) string {
+	data, _ := json.Marshal(cursor{Fields: cursorFields(orderBy), Values: sortValues(orderBy, r), Key: rowKey(r)})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, orderBy []*pklresource.OrderBy) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return cursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	if want := cursorFields(orderBy); !slices.Equal(c.Fields, want) || len(c.Values) != len(want) {
+		return cursor{}, fmt.Errorf("cursor ordered by [%s] does not match the query ordered by [%s]",
+			strings.Join(c.Fields, ", "), strings.Join(want, ", "))
+	}
+	return c, nil
+}
+
+// sortValues returns the values of the orderBy fields of r, nil for missing fields.
+func sortValues(orderBy []*pklresource.OrderBy, r Row) []any {
+	values := make([]any, len(orderBy))
+	for i, o := range orderBy {
+		values[i] = r.Data[o.Field]
+	}
+	return values
+}
+
+func rowKey(r Row) string {
+	key, _ := r.Data["key"].(string)
+	return key
+}
+
+// compareRows compares r to the row with the orderBy values and key. Null and
+// missing values sort last in either direction.
+func compareRows(orderBy []*pklresource.OrderBy, r Row, values []any, key string) int {
+	for i, o := range orderBy {
+		a, b := r.Data[o.Field], values[i]
+		var c int
+		switch {
+		case a == nil && b == nil:
+			continue
+		case a == nil:
+			return 1
+		case b == nil:
+			return -1
+		default:
+			c = compare(a, b)
+		}
+		if o.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(rowKey(r), key)
+}
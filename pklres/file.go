@@ -0,0 +1,126 @@
+package pklres
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileFormatVersion is the version of the FileStorage file format.
+const fileFormatVersion = 1
+
+type fileContents struct {
+	Version int                                     `json:"version"`
+	Graphs  map[string]map[string]map[string]string `json:"graphs"`
+}
+
+// FileStorage is a Storage that keeps values in memory and writes them to a
+// JSON file after every change. The file is replaced atomically, so it always
+// holds a consistent state.
+type FileStorage struct {
+	MemoryStorage
+	path string
+}
+
+var (
+	_ Storage = (*FileStorage)(nil)
+	_ Watcher = (*FileStorage)(nil)
+)
+
+// NewFileStorage opens the store at path. A missing or empty file is an empty store.
+func NewFileStorage(path string) (*FileStorage, error) {
+	s := &FileStorage{MemoryStorage: *NewMemoryStorage(), path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read pklres store %s: %w", path, err)
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var contents fileContents
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil, fmt.Errorf("failed to parse pklres store %s: %w", path, err)
+	}
+	if contents.Version != fileFormatVersion {
+		return nil, fmt.Errorf("unsupported pklres store version %d in %s", contents.Version, path)
+	}
+	if contents.Graphs != nil {
+		s.graphs = contents.Graphs
+	}
+	return s, nil
+}
+
+// Path returns the path of the store file.
+func (s *FileStorage) Path() string {
+	return s.path
+}
+
+// Set implements Storage.
+func (s *FileStorage) Set(graphID, collection, key, value string) error {
+	return s.SetMany(graphID, collection, map[string]string{key: value})
+}
+
+// SetMany implements Storage. The values are written to the file at once.
+func (s *FileStorage) SetMany(graphID, collection string, entries map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commit(s.setMany(graphID, collection, entries))
+}
+
+// Delete implements Storage.
+func (s *FileStorage) Delete(graphID, collection, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.lookup(graphID, collection, key)
+	if !s.delete(graphID, collection, key) {
+		return false, nil
+	}
+	return true, s.commit([]Event{change(EventDelete, graphID, collection, key, old, nil)})
+}
+
+// Clear removes all values of a graph.
+func (s *FileStorage) Clear(graphID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commit(s.clear(graphID))
+}
+
+// commit saves the changes of events and publishes them, or reverts them if
+// the store cannot be written. It must be called with the lock held.
+func (s *FileStorage) commit(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		s.undo(events)
+		return err
+	}
+	s.hub.publish(events...)
+	return nil
+}
+
+// save writes the store. It must be called with the lock held.
+func (s *FileStorage) save() error {
+	data, err := json.Marshal(fileContents{Version: fileFormatVersion, Graphs: s.graphs})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write pklres store %s: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write pklres store %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pklres store %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write pklres store %s: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package pklres
+
+import (
+	"fmt"
+	"strings"
+
+	pklresource "github.com/kdeps/schema/gen/pkl_resource"
+)
+
+// Condition is a selection condition, the JSON form of PklResource.SelectionCondition.
+type Condition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    any    `json:"value"`
+}
+
+// NewCondition converts an evaluated PklResource.SelectionCondition.
+func NewCondition(c pklresource.SelectionCondition) Condition {
+	return Condition{Field: c.Field, Operator: c.Operator, Value: objectValue(c.Value)}
+}
+
+// String formats the condition as in the query of a result.
+func (c Condition) String() string {
+	return fmt.Sprintf("%s %s %s", c.Field, c.Operator, text(c.Value))
+}
+
+// Row is a row of a relational result.
+type Row struct {
+	Data map[string]any `json:"data"`
+}
+
+// Result is a relational result, the JSON form of PklResource.RelationalResult.
+type Result struct {
+	Rows    []Row    `json:"rows"`
+	Columns []string `json:"columns"`
+	Query   string   `json:"query"`
+	TTL     string   `json:"ttl"`
+
+	// NextCursor continues a query after the last row of the result.
+	NextCursor *string `json:"nextCursor,omitempty"`
+}
+
+// operators maps operator names to predicates over a field value and a
+// condition value.
+var operators = map[string]func(field, value any) bool{
+	"eq":  func(f, v any) bool { return compare(f, v) == 0 },
+	"ne":  func(f, v any) bool { return compare(f, v) != 0 },
+	"gt":  func(f, v any) bool { return compare(f, v) > 0 },
+	"lt":  func(f, v any) bool { return compare(f, v) < 0 },
+	"gte": func(f, v any) bool { return compare(f, v) >= 0 },
+	"lte": func(f, v any) bool { return compare(f, v) <= 0 },
+	"contains": func(f, v any) bool {
+		if list, ok := f.([]any); ok {
+			return contains(list, v)
+		}
+		return strings.Contains(text(f), text(v))
+	},
+	"in": func(f, v any) bool {
+		list, ok := v.([]any)
+		return ok && contains(list, f)
+	},
+}
+
+func contains(list []any, v any) bool {
+	for _, e := range list {
+		if compare(e, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// joinType returns whether unmatched left and right rows are kept by a join.
+func joinType(t string) (keepLeft, keepRight bool, err error) {
+	switch t {
+	case "", "inner":
+		return false, false, nil
+	case "left":
+		return true, false, nil
+	case "right":
+		return false, true, nil
+	case "full":
+		return true, true, nil
+	}
+	return false, false, fmt.Errorf("unsupported join type %q", t)
+}
+
+func joined(j pklresource.JoinCondition, left, right *Row) Row {
+	data := make(map[string]any)
+	if left != nil {
+		for f, v := range left.Data {
+			data[j.LeftCollection+"."+f] = v
+		}
+	}
+	if right != nil {
+		for f, v := range right.Data {
+			data[j.RightCollection+"."+f] = v
+		}
+	}
+	return Row{Data: data}
+}
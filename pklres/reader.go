@@ -0,0 +1,625 @@
+// Package pklres implements the pklres: resource reader that the kdeps PKL
+// modules (Core.pkl, PklResource.pkl, Data.pkl, APIServerRequest.pkl, ...) use as
+// their key-value store.
+//
+// Values are stored per graph (one execution of an agent), collection (an action
+// ID) and key. The reader implements the protocol of Core.pkl:
+//
+//	pklres://?op=get&collection=<id>&key=<key>              value, or "" if missing
+//	pklres://?op=set&collection=<id>&key=<key>&value=<v>    the stored value
+//	pklres://?op=setMany&collection=<id>&values=<json>      the stored values, as a JSON object
+//	pklres://?op=list&collection=<id>                       JSON array of keys
+//	pklres://?op=delete&collection=<id>&key=<key>           "true" if the key existed
+//	pklres://?op=waitFor&collection=<id>&key=<key>[&timeout=<t>]  the value, once the key is set
+//	pklres://?op=relationalSelect&collection=<id>&conditions=<json>
+//	pklres://?op=relationalProject&collection=<id>&condition=<json>
+//	pklres://?op=relationalJoin&condition=<json>
+//	pklres://?op=relationalQuery&collection=<id>&query=<json>
+//	pklres://?op=aggregate&collection=<id>&aggregations=<json>[&groupBy=<json>][&where=<json>]
+//	pklres://?op=queryWithCache&queryType=<select|project|join|query|aggregate>&params=<json>
+//	pklres://?op=clearCache
+//	pklres://?op=setCacheTTL&ttl=<seconds>
+//	pklres://?op=getCacheStats
+//
+// Relational ops return a PklResource.RelationalResult as JSON, and op=aggregate a
+// PklResource.AggregateResult. Their results are cached, keyed by graph, query
+// type and normalized params, until the TTL expires, they are evicted, or a
+// collection they read is changed. The cache is pluggable (WithCache); the
+// default is an LRUCache. op=getCacheStats returns a PklResource.CacheStats.
+//
+// Writes through the reader invalidate at once. When the storage implements
+// Watcher, the reader also follows its events until Close, so that writes
+// bypassing it (Storage methods, records, Snapshot.Restore, MemoryStorage.Clear
+// or other readers) invalidate too, once their event is delivered. Other
+// storages only see writes through the reader invalidate.
+//
+// The relational engine can also be used directly: a Table holds a collection as
+// rows with hash indexes on selected fields, and Select, Project and Join
+// evaluate the conditions of PklResource.pkl with typed comparisons. Aggregate
+// groups rows and computes count, sum, avg, min, max and distinct per group.
+//
+// op=waitFor blocks until the key exists or the timeout (seconds, or a duration
+// such as "30.s", default DefaultWaitTimeout) expires. It requires a storage
+// implementing Watcher, through which Go code can also follow changes.
+//
+// TakeSnapshot captures the values of a graph, which WriteNDJSON and WriteTar
+// export and ReadSnapshot and Restore load into another store to replay a run.
+//
+// The storage is pluggable; MemoryStorage and FileStorage are provided:
+//
+//	store, err := pklres.NewFileStorage("pklres.json")
+//	if err != nil {
+//	    return err
+//	}
+//	reader := pklres.NewReader(store, pklres.WithGraphID(graphID))
+//	l, err := loader.New(ctx, loader.WithResourceReaders(reader))
+package pklres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	pklresource "github.com/kdeps/schema/gen/pkl_resource"
+	"github.com/kdeps/schema/readeruri"
+)
+
+// Scheme is the URI scheme of the reader.
+const Scheme = "pklres"
+
+// DefaultGraphID is the graph ID of readers created without WithGraphID.
+const DefaultGraphID = "default"
+
+// DefaultWaitTimeout is the timeout of op=waitFor without a timeout parameter.
+const DefaultWaitTimeout = time.Minute
+
+type config struct {
+	graphID         string
+	cache           Cache
+	cacheTTL        time.Duration
+	cacheTTLSet     bool
+	cacheMaxEntries int
+	cacheMaxBytes   int64
+	indexes         []string
+}
+
+// Option configures a Reader.
+type Option func(*config)
+
+// WithGraphID sets the graph whose values the reader reads and writes.
+func WithGraphID(graphID string) Option {
+	return func(c *config) {
+		c.graphID = graphID
+	}
+}
+
+// WithCacheTTL sets the initial time relational query results stay cached. A TTL
+// of zero disables caching. Defaults to DefaultCacheTTL.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.cacheTTL = ttl
+		c.cacheTTLSet = true
+	}
+}
+
+// WithCacheLimits bounds the default cache to maxEntries results and maxBytes
+// bytes. A bound of zero or less is no bound. Defaults to DefaultCacheMaxEntries
+// and DefaultCacheMaxBytes.
+func WithCacheLimits(maxEntries int, maxBytes int64) Option {
+	return func(c *config) {
+		c.cacheMaxEntries = maxEntries
+		c.cacheMaxBytes = maxBytes
+	}
+}
+
+// WithCache sets the cache of relational query results, which may be shared
+// with the readers of other graphs. Its TTL is only changed by WithCacheTTL.
+func WithCache(cache Cache) Option {
+	return func(c *config) {
+		c.cache = cache
+	}
+}
+
+// WithIndexes sets the fields that relational queries look up through an index.
+// Defaults to DefaultIndexes.
+func WithIndexes(fields ...string) Option {
+	return func(c *config) {
+		c.indexes = fields
+	}
+}
+
+// Reader is the pklres: resource reader. It is safe for concurrent use.
+type Reader struct {
+	storage Storage
+	graphID string
+	cache   Cache
+	indexes []string
+
+	mu     sync.Mutex
+	tables map[string]cachedTable
+
+	// stop ends the watch of the storage, if it implements Watcher.
+	stop context.CancelFunc
+}
+
+// cachedTable is a table kept by a reader until it expires with the cache TTL.
+type cachedTable struct {
+	table   *Table
+	expires time.Time
+}
+
+var _ pkl.ResourceReader = (*Reader)(nil)
+
+// NewReader creates a reader over storage.
+func NewReader(storage Storage, opts ...Option) *Reader {
+	c := config{
+		graphID:         DefaultGraphID,
+		cacheTTL:        DefaultCacheTTL,
+		cacheMaxEntries: DefaultCacheMaxEntries,
+		cacheMaxBytes:   DefaultCacheMaxBytes,
+		indexes:         DefaultIndexes,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.cache == nil {
+		c.cache = NewLRUCache(c.cacheTTL, c.cacheMaxEntries, c.cacheMaxBytes)
+	} else if c.cacheTTLSet {
+		c.cache.SetTTL(c.cacheTTL)
+	}
+	r := &Reader{
+		storage: storage,
+		graphID: c.graphID,
+		cache:   c.cache,
+		indexes: c.indexes,
+		tables:  make(map[string]cachedTable),
+	}
+	if w, ok := storage.(Watcher); ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		r.stop = cancel
+		go r.watch(w.Watch(ctx, "", ""))
+	}
+	return r
+}
+
+// watch invalidates the collections of the graph changed by events, including
+// by writes that bypass the reader.
+func (r *Reader) watch(events <-chan Event) {
+	for e := range events {
+		if e.GraphID == r.graphID {
+			r.invalidate(e.Collection)
+		}
+	}
+}
+
+// Close stops the reader from following the changes of its storage. The reader
+// remains usable, but only writes through it invalidate from then on.
+func (r *Reader) Close() error {
+	if r.stop != nil {
+		r.stop()
+	}
+	return nil
+}
+
+// GraphID returns the graph the reader reads and writes.
+func (r *Reader) GraphID() string {
+	return r.graphID
+}
+
+// Storage returns the storage of the reader.
+func (r *Reader) Storage() Storage {
+	return r.storage
+}
+
+func (r *Reader) Scheme() string {
+	return Scheme
+}
+
+func (r *Reader) IsGlobbable() bool {
+	return false
+}
+
+func (r *Reader) HasHierarchicalUris() bool {
+	return false
+}
+
+func (r *Reader) ListElements(url.URL) ([]pkl.PathElement, error) {
+	return nil, nil
+}
+
+// Read performs the op of uri.
+func (r *Reader) Read(uri url.URL) ([]byte, error) {
+	if uri.Scheme != Scheme {
+		return nil, fmt.Errorf("unsupported scheme %q, expected %q", uri.Scheme, Scheme)
+	}
+	op, err := readeruri.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	switch op := op.(type) {
+	case *readeruri.PklresGet:
+		return r.get(op)
+	case *readeruri.PklresSet:
+		return r.set(op)
+	case *readeruri.PklresSetMany:
+		return r.setMany(op)
+	case *readeruri.PklresList:
+		return r.list(op)
+	case *readeruri.PklresDelete:
+		return r.delete(op)
+	case *readeruri.PklresWaitFor:
+		return r.waitFor(op)
+	case *readeruri.PklresRelationalSelect:
+		return r.run("select", op.Collection, []byte(op.Conditions))
+	case *readeruri.PklresRelationalProject:
+		return r.run("project", op.Collection, []byte(op.Condition))
+	case *readeruri.PklresRelationalJoin:
+		return r.run("join", "", []byte(op.Condition))
+	case *readeruri.PklresRelationalQuery:
+		return r.run("query", op.Collection, []byte(op.Query))
+	case *readeruri.PklresAggregate:
+		return r.aggregate(op)
+	case *readeruri.PklresQueryWithCache:
+		return r.queryWithCache(op)
+	case *readeruri.PklresClearCache:
+		r.clearTables()
+		r.cache.Clear(r.graphID)
+		return []byte("cache cleared"), nil
+	case *readeruri.PklresSetCacheTTL:
+		return r.setCacheTTL(op)
+	case *readeruri.PklresGetCacheStats:
+		return json.Marshal(r.cache.Stats())
+	default:
+		return nil, fmt.Errorf("unsupported op %s", uri.String())
+	}
+}
+
+func (r *Reader) get(op *readeruri.PklresGet) ([]byte, error) {
+	value, _, err := r.storage.Get(r.graphID, op.Collection, op.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s/%s: %w", op.Collection, op.Key, err)
+	}
+	return []byte(value), nil
+}
+
+func (r *Reader) set(op *readeruri.PklresSet) ([]byte, error) {
+	if err := r.storage.Set(r.graphID, op.Collection, op.Key, op.Value); err != nil {
+		return nil, fmt.Errorf("failed to set %s/%s: %w", op.Collection, op.Key, err)
+	}
+	r.invalidate(op.Collection)
+	return []byte(op.Value), nil
+}
+
+// setMany stores the values of a JSON object atomically. String values are
+// stored as is and other values as JSON, like nested data written with op=set.
+func (r *Reader) setMany(op *readeruri.PklresSetMany) ([]byte, error) {
+	var values map[string]any
+	if err := json.Unmarshal([]byte(op.Values), &values); err != nil {
+		return nil, fmt.Errorf("invalid values: expected a JSON object: %w", err)
+	}
+	entries := make(map[string]string, len(values))
+	for k, v := range values {
+		if v == nil {
+			return nil, fmt.Errorf("invalid values: value of key %q is null", k)
+		}
+		entries[k] = text(v)
+	}
+	if len(entries) > 0 {
+		if err := r.storage.SetMany(r.graphID, op.Collection, entries); err != nil {
+			return nil, fmt.Errorf("failed to set %d keys of %s: %w", len(entries), op.Collection, err)
+		}
+		r.invalidate(op.Collection)
+	}
+	return json.Marshal(entries)
+}
+
+func (r *Reader) delete(op *readeruri.PklresDelete) ([]byte, error) {
+	existed, err := r.storage.Delete(r.graphID, op.Collection, op.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete %s/%s: %w", op.Collection, op.Key, err)
+	}
+	if existed {
+		r.invalidate(op.Collection)
+	}
+	return []byte(strconv.FormatBool(existed)), nil
+}
+
+func (r *Reader) waitFor(op *readeruri.PklresWaitFor) ([]byte, error) {
+	collection, key := op.Collection, op.Key
+	timeout := DefaultWaitTimeout
+	if op.Timeout != "" {
+		var err error
+		if timeout, err = parseTimeout(op.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	w, ok := r.storage.(Watcher)
+	if !ok {
+		return nil, fmt.Errorf("op=waitFor requires a storage implementing Watcher, got %T", r.storage)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// Watch before reading the value, so that a set in between is not missed.
+	events := w.Watch(ctx, collection, key)
+	value, ok, err := r.storage.Get(r.graphID, collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s/%s: %w", collection, key, err)
+	}
+	if ok {
+		return []byte(value), nil
+	}
+	for e := range events {
+		if e.GraphID == r.graphID && e.Key == key && e.NewValue != nil {
+			return []byte(*e.NewValue), nil
+		}
+	}
+	return nil, fmt.Errorf("timed out after %s waiting for %s/%s", timeout, collection, key)
+}
+
+// parseTimeout parses a number of seconds or a PKL or Go duration.
+func parseTimeout(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	if d, err := ParseDuration(s); err == nil && d.GoDuration() >= 0 {
+		return d.GoDuration(), nil
+	}
+	return 0, fmt.Errorf("invalid timeout %q: expected a number of seconds or a duration", s)
+}
+
+func (r *Reader) list(op *readeruri.PklresList) ([]byte, error) {
+	entries, err := r.storage.Entries(r.graphID, op.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", op.Collection, err)
+	}
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return json.Marshal(keys)
+}
+
+func (r *Reader) setCacheTTL(op *readeruri.PklresSetCacheTTL) ([]byte, error) {
+	seconds, err := strconv.Atoi(op.TTL)
+	if err != nil || seconds < 0 {
+		return nil, fmt.Errorf("invalid ttl %q: expected a number of seconds", op.TTL)
+	}
+	ttl := time.Duration(seconds) * time.Second
+	r.clearTables()
+	r.cache.SetTTL(ttl)
+	return []byte(ttl.String()), nil
+}
+
+// aggregate runs op=aggregate, whose groupBy, aggregations and where are JSON
+// params. groupBy and where are optional.
+func (r *Reader) aggregate(op *readeruri.PklresAggregate) ([]byte, error) {
+	var a Aggregate
+	if err := json.Unmarshal([]byte(op.Aggregations), &a.Aggregations); err != nil {
+		return nil, fmt.Errorf("invalid aggregations: %w", err)
+	}
+	if op.GroupBy != "" {
+		if err := json.Unmarshal([]byte(op.GroupBy), &a.GroupBy); err != nil {
+			return nil, fmt.Errorf("invalid groupBy: %w", err)
+		}
+	}
+	if op.Where != "" {
+		if err := json.Unmarshal([]byte(op.Where), &a.Where); err != nil {
+			return nil, fmt.Errorf("invalid where: %w", err)
+		}
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return r.run("aggregate", op.Collection, data)
+}
+
+// queryParams are the params of op=queryWithCache.
+type queryParams struct {
+	Collection    string          `json:"collection"`
+	CollectionKey string          `json:"collectionKey"`
+	Conditions    json.RawMessage `json:"conditions"`
+	Condition     json.RawMessage `json:"condition"`
+	Query         json.RawMessage `json:"query"`
+}
+
+func (r *Reader) queryWithCache(op *readeruri.PklresQueryWithCache) ([]byte, error) {
+	queryType, data := op.QueryType, op.Params
+	var p queryParams
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	collection := p.Collection
+	if collection == "" {
+		collection = p.CollectionKey
+	}
+
+	// Conditions may be nested or, for project, join, query and aggregate, be the
+	// params themselves.
+	condition := []byte(data)
+	switch {
+	case queryType == "select":
+		condition = p.Conditions
+	case queryType == "query":
+		if p.Query != nil {
+			condition = p.Query
+		}
+	case p.Condition != nil:
+		condition = p.Condition
+	}
+	return r.run(queryType, collection, condition)
+}
+
+// run runs a select, project, join, query or aggregate query, using the cache.
+func (r *Reader) run(queryType, collection string, condition []byte) ([]byte, error) {
+	switch queryType {
+	case "select", "project", "query", "aggregate", "join":
+	default:
+		return nil, fmt.Errorf("unsupported query type %q", queryType)
+	}
+	key := newCacheKey(r.graphID, queryType, collection, condition)
+	result, generation, ok := r.cache.Get(key)
+	if ok {
+		return result, nil
+	}
+
+	start := time.Now()
+
+	var res Result
+	var read []string
+	var err error
+	switch queryType {
+	case "select":
+		res, err = r.selectQuery(collection, condition)
+		read = []string{collection}
+	case "project":
+		res, err = r.projectQuery(collection, condition)
+		read = []string{collection}
+	case "query":
+		res, err = r.queryQuery(collection, condition)
+		read = []string{collection}
+	case "aggregate":
+		res, err = r.aggregateQuery(collection, condition)
+		read = []string{collection}
+	case "join":
+		var j pklresource.JoinCondition
+		res, j, err = r.joinQuery(condition)
+		read = []string{j.LeftCollection, j.RightCollection}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res.TTL = r.cache.TTL().String()
+	result, err = json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.Put(key, generation, result, time.Since(start), read...)
+	return result, nil
+}
+
+func (r *Reader) selectQuery(collection string, data []byte) (Result, error) {
+	var conditions []Condition
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &conditions); err != nil {
+			return Result{}, fmt.Errorf("invalid selection conditions: %w", err)
+		}
+	}
+	t, err := r.Table(collection)
+	if err != nil {
+		return Result{}, err
+	}
+	return t.Select(conditions...)
+}
+
+func (r *Reader) queryQuery(collection string, data []byte) (Result, error) {
+	var q pklresource.Query
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &q); err != nil {
+			return Result{}, fmt.Errorf("invalid query: %w", err)
+		}
+	}
+	t, err := r.Table(collection)
+	if err != nil {
+		return Result{}, err
+	}
+	return t.Query(q)
+}
+
+func (r *Reader) aggregateQuery(collection string, data []byte) (Result, error) {
+	var a Aggregate
+	if err := json.Unmarshal(data, &a); err != nil {
+		return Result{}, fmt.Errorf("invalid aggregation: %w", err)
+	}
+	t, err := r.Table(collection)
+	if err != nil {
+		return Result{}, err
+	}
+	return t.Aggregate(a)
+}
+
+func (r *Reader) projectQuery(collection string, data []byte) (Result, error) {
+	var p pklresource.ProjectionCondition
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &p); err != nil {
+			return Result{}, fmt.Errorf("invalid projection condition: %w", err)
+		}
+	}
+	t, err := r.Table(collection)
+	if err != nil {
+		return Result{}, err
+	}
+	return t.Project(p), nil
+}
+
+func (r *Reader) joinQuery(data []byte) (Result, pklresource.JoinCondition, error) {
+	var j pklresource.JoinCondition
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Result{}, j, fmt.Errorf("invalid join condition: %w", err)
+	}
+	if j.LeftCollection == "" || j.RightCollection == "" {
+		return Result{}, j, fmt.Errorf("join condition without leftCollection or rightCollection")
+	}
+	left, err := r.Table(j.LeftCollection)
+	if err != nil {
+		return Result{}, j, err
+	}
+	right, err := r.Table(j.RightCollection)
+	if err != nil {
+		return Result{}, j, err
+	}
+	res, err := Join(left, right, j)
+	return res, j, err
+}
+
+// Table returns collection as an indexed table. Like query results, tables are
+// kept until the cache TTL expires, the cache is cleared or the collection is
+// set or deleted through the reader; a TTL of zero rebuilds them on each call.
+func (r *Reader) Table(collection string) (*Table, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection")
+	}
+	ttl := r.cache.TTL()
+	now := time.Now()
+	// Tables are built under the lock so that a set invalidating a collection
+	// never races with building its table from the previous entries.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tables[collection]; ok && ttl > 0 && now.Before(t.expires) {
+		return t.table, nil
+	}
+	delete(r.tables, collection)
+	entries, err := r.storage.Entries(r.graphID, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", collection, err)
+	}
+	t := NewTable(collection, entries, r.indexes...)
+	if ttl > 0 {
+		r.tables[collection] = cachedTable{table: t, expires: now.Add(ttl)}
+	}
+	return t, nil
+}
+
+// clearTables drops the tables of all collections.
+func (r *Reader) clearTables() {
+	r.mu.Lock()
+	clear(r.tables)
+	r.mu.Unlock()
+}
+
+// invalidate drops the table and cached query results of collection.
+func (r *Reader) invalidate(collection string) {
+	r.mu.Lock()
+	delete(r.tables, collection)
+	r.mu.Unlock()
+	r.cache.Invalidate(r.graphID, collection)
+}
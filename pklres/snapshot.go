@@ -0,0 +1,305 @@
+package pklres
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot formats written by
+// WriteNDJSON and WriteTar.
+const SnapshotVersion = 1
+
+// Snapshot is the values of a graph at a point in time: every collection,
+// including the request data (method, path, headers, params, files, ...) that
+// the API server stores under the request ID and the "current" collection.
+//
+// Snapshots reproduce a run elsewhere: write one with WriteNDJSON or WriteTar,
+// read it back with ReadSnapshot and Restore it into a fresh store, from which
+// a Reader returns the recorded values.
+type Snapshot struct {
+	Version     int
+	GraphID     string
+	Time        time.Time
+	Collections map[string]map[string]string
+}
+
+// snapshotter is implemented by storages that read all collections of a graph
+// at once, such as MemoryStorage and FileStorage.
+type snapshotter interface {
+	snapshot(graphID string) map[string]map[string]string
+}
+
+func (s *MemoryStorage) snapshot(graphID string) map[string]map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collections := make(map[string]map[string]string, len(s.graphs[graphID]))
+	for name, entries := range s.graphs[graphID] {
+		copied := make(map[string]string, len(entries))
+		for k, v := range entries {
+			copied[k] = v
+		}
+		collections[name] = copied
+	}
+	return collections
+}
+
+// TakeSnapshot reads the values of a graph. The snapshot is consistent across
+// collections for MemoryStorage and FileStorage, and per collection for other
+// storages.
+func TakeSnapshot(s Storage, graphID string) (*Snapshot, error) {
+	snap := &Snapshot{Version: SnapshotVersion, GraphID: graphID, Time: time.Now().UTC()}
+	if ss, ok := s.(snapshotter); ok {
+		snap.Collections = ss.snapshot(graphID)
+		return snap, nil
+	}
+	names, err := s.Collections(graphID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the collections of graph %s: %w", graphID, err)
+	}
+	snap.Collections = make(map[string]map[string]string, len(names))
+	for _, name := range names {
+		entries, err := s.Entries(graphID, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		snap.Collections[name] = entries
+	}
+	return snap, nil
+}
+
+// Restore makes the values of graphID those of the snapshot: collections are
+// written with SetMany and keys missing from the snapshot are deleted. An
+// empty graphID restores the graph of the snapshot.
+func (snap *Snapshot) Restore(s Storage, graphID string) error {
+	if graphID == "" {
+		graphID = snap.GraphID
+	}
+	names, err := s.Collections(graphID)
+	if err != nil {
+		return fmt.Errorf("failed to list the collections of graph %s: %w", graphID, err)
+	}
+	for _, name := range names {
+		entries, err := s.Entries(graphID, name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		for k := range entries {
+			if _, ok := snap.Collections[name][k]; ok {
+				continue
+			}
+			if _, err := s.Delete(graphID, name, k); err != nil {
+				return fmt.Errorf("failed to delete %s/%s: %w", name, k, err)
+			}
+		}
+	}
+	for _, name := range snap.names() {
+		if err := s.SetMany(graphID, name, snap.Collections[name]); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// names returns the sorted names of the collections of the snapshot.
+func (snap *Snapshot) names() []string {
+	names := make([]string, 0, len(snap.Collections))
+	for name := range snap.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// snapshotHeader is the first line of an NDJSON snapshot and the manifest of a
+// tar snapshot.
+type snapshotHeader struct {
+	Type    string    `json:"type,omitempty"`
+	Version int       `json:"version"`
+	GraphID string    `json:"graphID"`
+	Time    time.Time `json:"time"`
+}
+
+// snapshotEntry is a line of an NDJSON snapshot after the header.
+type snapshotEntry struct {
+	Type       string `json:"type"`
+	Collection string `json:"collection"`
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+}
+
+func (snap *Snapshot) header() snapshotHeader {
+	return snapshotHeader{Version: SnapshotVersion, GraphID: snap.GraphID, Time: snap.Time}
+}
+
+func (h snapshotHeader) check() error {
+	if h.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported pklres snapshot version %d", h.Version)
+	}
+	return nil
+}
+
+// WriteNDJSON writes the snapshot as newline-delimited JSON: a header line
+// with the version, graph ID and time, then a line per key, sorted by
+// collection and key.
+func (snap *Snapshot) WriteNDJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	h := snap.header()
+	h.Type = "header"
+	if err := enc.Encode(h); err != nil {
+		return err
+	}
+	for _, name := range snap.names() {
+		entries := snap.Collections[name]
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := enc.Encode(snapshotEntry{Type: "entry", Collection: name, Key: k, Value: entries[k]}); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// tarManifest is the name of the manifest of a tar snapshot. Collections are
+// stored as JSON objects in collections/, named by their path-escaped name.
+const tarManifest = "manifest.json"
+
+// WriteTar writes the snapshot as a tar archive of a manifest.json with the
+// version, graph ID and time, and a collections/<name>.json object per
+// collection.
+func (snap *Snapshot) WriteTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	add := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: snap.Time, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+	if err := add(tarManifest, snap.header()); err != nil {
+		return err
+	}
+	for _, name := range snap.names() {
+		if err := add(path.Join("collections", url.PathEscape(name)+".json"), snap.Collections[name]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ReadSnapshot reads a snapshot written by WriteNDJSON or WriteTar.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pklres snapshot: %w", err)
+	}
+	if first[0] == '{' {
+		return readNDJSON(br)
+	}
+	return readTar(br)
+}
+
+func readNDJSON(r io.Reader) (*Snapshot, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	var snap *Snapshot
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if snap == nil {
+			var h snapshotHeader
+			if err := json.Unmarshal(data, &h); err != nil || h.Type != "header" {
+				return nil, fmt.Errorf("invalid pklres snapshot: line %d is not a header", line)
+			}
+			if err := h.check(); err != nil {
+				return nil, err
+			}
+			snap = &Snapshot{Version: h.Version, GraphID: h.GraphID, Time: h.Time, Collections: make(map[string]map[string]string)}
+			continue
+		}
+		var e snapshotEntry
+		if err := json.Unmarshal(data, &e); err != nil || e.Type != "entry" {
+			return nil, fmt.Errorf("invalid pklres snapshot: line %d is not an entry", line)
+		}
+		if snap.Collections[e.Collection] == nil {
+			snap.Collections[e.Collection] = make(map[string]string)
+		}
+		snap.Collections[e.Collection][e.Key] = e.Value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pklres snapshot: %w", err)
+	}
+	if snap == nil {
+		return nil, errors.New("invalid pklres snapshot: missing header")
+	}
+	return snap, nil
+}
+
+func readTar(r io.Reader) (*Snapshot, error) {
+	tr := tar.NewReader(r)
+	var header *snapshotHeader
+	collections := make(map[string]map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pklres snapshot: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of pklres snapshot: %w", hdr.Name, err)
+		}
+		switch dir, file := path.Split(hdr.Name); {
+		case hdr.Name == tarManifest:
+			header = &snapshotHeader{}
+			if err := json.Unmarshal(data, header); err != nil {
+				return nil, fmt.Errorf("invalid manifest of pklres snapshot: %w", err)
+			}
+			if err := header.check(); err != nil {
+				return nil, err
+			}
+		case dir == "collections/" && strings.HasSuffix(file, ".json"):
+			name, err := url.PathUnescape(strings.TrimSuffix(file, ".json"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid collection file %s in pklres snapshot", hdr.Name)
+			}
+			var entries map[string]string
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return nil, fmt.Errorf("invalid collection file %s in pklres snapshot: %w", hdr.Name, err)
+			}
+			collections[name] = entries
+		}
+	}
+	if header == nil {
+		return nil, fmt.Errorf("invalid pklres snapshot: missing %s", tarManifest)
+	}
+	return &Snapshot{Version: header.Version, GraphID: header.GraphID, Time: header.Time, Collections: collections}, nil
+}
@@ -0,0 +1,198 @@
+package pklres
+
+import (
+	"sort"
+	"sync"
+)
+
+// Storage stores the string values of the pklres key-value store. Values are
+// scoped by graph ID, then collection (an action ID), then key.
+//
+// Implementations must be safe for concurrent use, and changes must be atomic
+// per collection: Get and Entries never observe part of a SetMany.
+type Storage interface {
+	// Get returns the value of a key and whether it exists.
+	Get(graphID, collection, key string) (string, bool, error)
+
+	// Set stores the value of a key.
+	Set(graphID, collection, key, value string) error
+
+	// SetMany stores the values of several keys of a collection at once. On
+	// error none of them is stored.
+	SetMany(graphID, collection string, entries map[string]string) error
+
+	// Delete removes a key and reports whether it existed.
+	Delete(graphID, collection, key string) (bool, error)
+
+	// Entries returns all keys and values of a collection.
+	Entries(graphID, collection string) (map[string]string, error)
+
+	// Collections returns the names of the non-empty collections of a graph, sorted.
+	Collections(graphID string) ([]string, error)
+}
+
+// MemoryStorage is a Storage that keeps values in memory. It publishes its
+// changes to watchers.
+type MemoryStorage struct {
+	mu     sync.RWMutex
+	graphs map[string]map[string]map[string]string
+	hub    hub
+}
+
+var (
+	_ Storage = (*MemoryStorage)(nil)
+	_ Watcher = (*MemoryStorage)(nil)
+)
+
+// NewMemoryStorage creates an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{graphs: make(map[string]map[string]map[string]string)}
+}
+
+// Get implements Storage.
+func (s *MemoryStorage) Get(graphID, collection, key string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.graphs[graphID][collection][key]
+	return value, ok, nil
+}
+
+// Set implements Storage.
+func (s *MemoryStorage) Set(graphID, collection, key, value string) error {
+	return s.SetMany(graphID, collection, map[string]string{key: value})
+}
+
+// SetMany implements Storage.
+func (s *MemoryStorage) SetMany(graphID, collection string, entries map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hub.publish(s.setMany(graphID, collection, entries)...)
+	return nil
+}
+
+// setMany stores entries and returns the events of the changes, sorted by key.
+// It must be called with the lock held.
+func (s *MemoryStorage) setMany(graphID, collection string, entries map[string]string) []Event {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	events := make([]Event, 0, len(keys))
+	for _, k := range keys {
+		value := entries[k]
+		events = append(events, change(EventSet, graphID, collection, k, s.lookup(graphID, collection, k), &value))
+		s.set(graphID, collection, k, value)
+	}
+	return events
+}
+
+// undo reverts the changes of events, in reverse order. It must be called with
+// the lock held.
+func (s *MemoryStorage) undo(events []Event) {
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if e.OldValue != nil {
+			s.set(e.GraphID, e.Collection, e.Key, *e.OldValue)
+		} else {
+			s.delete(e.GraphID, e.Collection, e.Key)
+		}
+	}
+}
+
+func (s *MemoryStorage) set(graphID, collection, key, value string) {
+	collections, ok := s.graphs[graphID]
+	if !ok {
+		collections = make(map[string]map[string]string)
+		s.graphs[graphID] = collections
+	}
+	entries, ok := collections[collection]
+	if !ok {
+		entries = make(map[string]string)
+		collections[collection] = entries
+	}
+	entries[key] = value
+}
+
+// Delete implements Storage.
+func (s *MemoryStorage) Delete(graphID, collection, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.lookup(graphID, collection, key)
+	if !s.delete(graphID, collection, key) {
+		return false, nil
+	}
+	s.hub.publish(change(EventDelete, graphID, collection, key, old, nil))
+	return true, nil
+}
+
+// Clear removes all values of a graph.
+func (s *MemoryStorage) Clear(graphID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hub.publish(s.clear(graphID)...)
+	return nil
+}
+
+// clear removes the values of a graph and returns the events of the changes,
+// sorted by collection and key. It must be called with the lock held.
+func (s *MemoryStorage) clear(graphID string) []Event {
+	var events []Event
+	names := make([]string, 0, len(s.graphs[graphID]))
+	for name := range s.graphs[graphID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entries := s.graphs[graphID][name]
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			old := entries[k]
+			events = append(events, change(EventClear, graphID, name, k, &old, nil))
+		}
+	}
+	delete(s.graphs, graphID)
+	return events
+}
+
+func (s *MemoryStorage) delete(graphID, collection, key string) bool {
+	entries, ok := s.graphs[graphID][collection]
+	if !ok {
+		return false
+	}
+	if _, ok := entries[key]; !ok {
+		return false
+	}
+	delete(entries, key)
+	if len(entries) == 0 {
+		delete(s.graphs[graphID], collection)
+	}
+	return true
+}
+
+// Entries implements Storage.
+func (s *MemoryStorage) Entries(graphID, collection string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := make(map[string]string, len(s.graphs[graphID][collection]))
+	for k, v := range s.graphs[graphID][collection] {
+		entries[k] = v
+	}
+	return entries, nil
+}
+
+// Collections implements Storage.
+func (s *MemoryStorage) Collections(graphID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.graphs[graphID]))
+	for name := range s.graphs[graphID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,285 @@
+package pklres
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	pklresource "github.com/kdeps/schema/gen/pkl_resource"
+)
+
+// DefaultIndexes are the fields indexed by tables of a Reader created without
+// WithIndexes: the fields that the Data, LLM and Exec modules filter on most.
+var DefaultIndexes = []string{"key", "model", "role", "exitCode"}
+
+// Table is a collection as rows, sorted by key. Each row has the fields "key"
+// and "value"; values that are JSON objects also contribute their fields.
+//
+// Indexed fields are looked up by hash for "eq" and "in" conditions instead of
+// scanning every row. A Table is immutable and safe for concurrent use.
+type Table struct {
+	name    string
+	rows    []Row
+	indexes map[string]map[string][]int
+}
+
+// NewTable creates a table named name from the entries of a collection, with
+// indexes on the fields indexed.
+func NewTable(name string, entries map[string]string, indexed ...string) *Table {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	t := &Table{name: name, rows: make([]Row, 0, len(keys)), indexes: make(map[string]map[string][]int)}
+	for _, k := range keys {
+		data := make(map[string]any)
+		var fields map[string]any
+		if json.Unmarshal([]byte(entries[k]), &fields) == nil {
+			for f, v := range fields {
+				data[f] = v
+			}
+		}
+		data["key"] = k
+		data["value"] = entries[k]
+		t.rows = append(t.rows, Row{Data: data})
+	}
+	for _, field := range indexed {
+		t.indexes[field] = hashIndex(t.rows, field)
+	}
+	return t
+}
+
+// hashIndex maps the comparison keys of field to the positions of the rows having them.
+func hashIndex(rows []Row, field string) map[string][]int {
+	index := make(map[string][]int)
+	for i, r := range rows {
+		if v, ok := r.Data[field]; ok {
+			k := typed(v).key()
+			index[k] = append(index[k], i)
+		}
+	}
+	return index
+}
+
+// Name returns the name of the table.
+func (t *Table) Name() string {
+	return t.name
+}
+
+// Rows returns the rows of the table.
+func (t *Table) Rows() []Row {
+	return t.rows
+}
+
+// Indexed reports whether field is indexed.
+func (t *Table) Indexed(field string) bool {
+	_, ok := t.indexes[field]
+	return ok
+}
+
+// Select returns the rows matching all conditions. Rows without a field of a
+// condition do not match it.
+func (t *Table) Select(conditions ...Condition) (Result, error) {
+	where := &pklresource.QueryCondition{Operator: "and"}
+	for _, c := range conditions {
+		field := c.Field
+		where.Conditions = append(where.Conditions, &pklresource.QueryCondition{Operator: c.Operator, Field: &field, Value: c.Value})
+	}
+	return t.Query(pklresource.Query{Where: where})
+}
+
+// Query returns the rows matching the condition of q, ordered and paginated.
+// The result has a NextCursor when rows remain after its page.
+func (t *Table) Query(q pklresource.Query) (Result, error) {
+	match, err := compile(q.Where)
+	if err != nil {
+		return Result{}, err
+	}
+	if q.Offset < 0 {
+		return Result{}, fmt.Errorf("invalid offset %d", q.Offset)
+	}
+	if q.Limit != nil && *q.Limit <= 0 {
+		return Result{}, fmt.Errorf("invalid limit %d", *q.Limit)
+	}
+	for _, o := range q.OrderBy {
+		if o == nil || o.Field == "" {
+			return Result{}, fmt.Errorf("orderBy without field")
+		}
+	}
+
+	selected := t.filter(q.Where, match)
+
+	if len(q.OrderBy) > 0 {
+		slices.SortStableFunc(selected, func(a, b Row) int {
+			return compareRows(q.OrderBy, a, sortValues(q.OrderBy, b), rowKey(b))
+		})
+	}
+	if q.Cursor != nil {
+		c, err := decodeCursor(*q.Cursor, q.OrderBy)
+		if err != nil {
+			return Result{}, err
+		}
+		start, _ := slices.BinarySearchFunc(selected, c, func(r Row, c cursor) int {
+			if compareRows(q.OrderBy, r, c.Values, c.Key) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		selected = selected[start:]
+	}
+	selected = selected[min(q.Offset, len(selected)):]
+
+	var next *string
+	if q.Limit != nil && *q.Limit < len(selected) {
+		selected = selected[:*q.Limit]
+		last := selected[len(selected)-1]
+		c := encodeCursor(q.OrderBy, last)
+		next = &c
+	}
+
+	query := "SELECT * FROM " + t.name + whereClause(q.Where)
+	for i, o := range q.OrderBy {
+		if i == 0 {
+			query += " ORDER BY "
+		} else {
+			query += ", "
+		}
+		query += o.Field
+		if o.Descending {
+			query += " DESC"
+		}
+	}
+	if q.Limit != nil {
+		query += fmt.Sprintf(" LIMIT %d", *q.Limit)
+	}
+	if q.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", q.Offset)
+	}
+	return Result{Rows: selected, Columns: columns(selected), Query: query, NextCursor: next}, nil
+}
+
+// filter returns the rows matching where, compiled to match, in order.
+func (t *Table) filter(where *pklresource.QueryCondition, match predicate) []Row {
+	selected := []Row{}
+	positions, indexed := t.candidates(where)
+	if !indexed {
+		positions = make([]int, len(t.rows))
+		for i := range positions {
+			positions[i] = i
+		}
+	}
+	for _, i := range positions {
+		if match(t.rows[i]) {
+			selected = append(selected, t.rows[i])
+		}
+	}
+	return selected
+}
+
+// Project keeps the columns of p in each row, or all but the excluded ones when
+// p has no columns.
+func (t *Table) Project(p pklresource.ProjectionCondition) Result {
+	exclude := make(map[string]bool, len(p.Exclude))
+	for _, f := range p.Exclude {
+		exclude[f] = true
+	}
+
+	projected := make([]Row, 0, len(t.rows))
+	for _, r := range t.rows {
+		data := make(map[string]any)
+		if len(p.Columns) > 0 {
+			for _, f := range p.Columns {
+				if v, ok := r.Data[f]; ok && !exclude[f] {
+					data[f] = v
+				}
+			}
+		} else {
+			for f, v := range r.Data {
+				if !exclude[f] {
+					data[f] = v
+				}
+			}
+		}
+		projected = append(projected, Row{Data: data})
+	}
+
+	query := "SELECT * FROM " + t.name
+	if len(p.Columns) > 0 {
+		query = "SELECT " + strings.Join(p.Columns, ", ") + " FROM " + t.name
+	}
+	if len(p.Exclude) > 0 {
+		query += " EXCLUDE " + strings.Join(p.Exclude, ", ")
+	}
+	return Result{Rows: projected, Columns: columns(projected), Query: query}
+}
+
+// Join joins left and right on equal values of the key fields of j, which
+// compare as in "eq" conditions. The right table is hashed on its key, using its
+// index when there is one. The fields of joined rows are prefixed with the name
+// of their collection and a dot.
+func Join(left, right *Table, j pklresource.JoinCondition) (Result, error) {
+	if j.LeftKey == "" || j.RightKey == "" {
+		return Result{}, fmt.Errorf("join condition without leftKey or rightKey")
+	}
+	keepLeft, keepRight, err := joinType(j.JoinType)
+	if err != nil {
+		return Result{}, err
+	}
+	j.LeftCollection, j.RightCollection = left.name, right.name
+
+	index, ok := right.indexes[j.RightKey]
+	if !ok {
+		index = hashIndex(right.rows, j.RightKey)
+	}
+
+	rows := []Row{}
+	matchedRight := make([]bool, len(right.rows))
+	for _, l := range left.rows {
+		var matches []int
+		if v, ok := l.Data[j.LeftKey]; ok {
+			matches = index[typed(v).key()]
+		}
+		for _, i := range matches {
+			matchedRight[i] = true
+			rows = append(rows, joined(j, &l, &right.rows[i]))
+		}
+		if len(matches) == 0 && keepLeft {
+			rows = append(rows, joined(j, &l, nil))
+		}
+	}
+	if keepRight {
+		for i := range right.rows {
+			if !matchedRight[i] {
+				rows = append(rows, joined(j, nil, &right.rows[i]))
+			}
+		}
+	}
+
+	kind := j.JoinType
+	if kind == "" {
+		kind = "inner"
+	}
+	query := fmt.Sprintf("SELECT * FROM %s %s JOIN %s ON %s.%s = %s.%s",
+		left.name, strings.ToUpper(kind), right.name, left.name, j.LeftKey, right.name, j.RightKey)
+	return Result{Rows: rows, Columns: columns(rows), Query: query}, nil
+}
+
+// columns returns the sorted names of all fields of rows.
+func columns(rows []Row) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, r := range rows {
+		for f := range r.Data {
+			if !seen[f] {
+				seen[f] = true
+				names = append(names, f)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
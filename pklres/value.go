@@ -0,0 +1,177 @@
+package pklres
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+)
+
+// kind is the comparison type of a value.
+type kind int
+
+const (
+	kindText kind = iota
+	kindNumber
+	kindDuration
+)
+
+// value is a field or condition value typed for comparison. Numbers and numeric
+// strings compare as numbers, durations ("1.5.min" in PKL, "90s" in Go, or
+// pkl.Duration) as durations, and everything else as text.
+type value struct {
+	kind kind
+	num  float64
+	text string
+}
+
+// DurationPattern matches PKL duration literals such as "60.s" or "-1.5.min".
+const DurationPattern = `^(-?\d+(?:\.\d+)?)\.(ns|us|ms|s|min|h|d)$`
+
+var durationRegex = regexp.MustCompile(DurationPattern)
+
+// typed types v for comparison.
+func typed(v any) value {
+	switch x := v.(type) {
+	case float64:
+		return value{kind: kindNumber, num: x}
+	case int:
+		return value{kind: kindNumber, num: float64(x)}
+	case int64:
+		return value{kind: kindNumber, num: float64(x)}
+	case time.Duration:
+		return value{kind: kindDuration, num: float64(x)}
+	case pkl.Duration:
+		return value{kind: kindDuration, num: float64(x.GoDuration())}
+	case *pkl.Duration:
+		if x != nil {
+			return value{kind: kindDuration, num: float64(x.GoDuration())}
+		}
+	case string:
+		if f, err := strconv.ParseFloat(x, 64); err == nil {
+			return value{kind: kindNumber, num: f}
+		}
+		if d, err := ParseDuration(x); err == nil {
+			return value{kind: kindDuration, num: float64(d.GoDuration())}
+		}
+	}
+	return value{kind: kindText, text: text(v)}
+}
+
+// ParseDuration parses a PKL duration literal such as "60.s", in its unit, or a
+// Go duration such as "1m30s", in seconds.
+func ParseDuration(s string) (pkl.Duration, error) {
+	if m := durationRegex.FindStringSubmatch(s); m != nil {
+		f, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return pkl.Duration{}, err
+		}
+		unit, err := pkl.ToDurationUnit(m[2])
+		if err != nil {
+			return pkl.Duration{}, err
+		}
+		return pkl.Duration{Value: f, Unit: unit}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return pkl.Duration{}, fmt.Errorf("expected a duration such as 60.s")
+	}
+	return pkl.Duration{Value: d.Seconds(), Unit: pkl.Second}, nil
+}
+
+// String formats v as text in its comparison type.
+func (v value) String() string {
+	switch v.kind {
+	case kindNumber:
+		return strconv.FormatFloat(v.num, 'g', -1, 64)
+	case kindDuration:
+		return time.Duration(v.num).String()
+	}
+	return v.text
+}
+
+// key returns a string that is equal for two values exactly when they compare
+// equal. It keys indexes and hash joins.
+func (v value) key() string {
+	return strconv.Itoa(int(v.kind)) + ":" + v.String()
+}
+
+// compare compares a and b in their comparison type when they share one and as
+// text otherwise. It returns -1, 0 or 1.
+func compare(a, b any) int {
+	return typed(a).compare(typed(b))
+}
+
+func (v value) compare(o value) int {
+	if v.kind != o.kind || v.kind == kindText {
+		return strings.Compare(v.String(), o.String())
+	}
+	switch {
+	case v.num < o.num:
+		return -1
+	case v.num > o.num:
+		return 1
+	}
+	return 0
+}
+
+// text formats a JSON value as text. Strings are returned as is.
+func text(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// objectValue converts a PKL Dynamic to a JSON-like value: a list for elements,
+// a map for properties or entries, and the object itself otherwise.
+func objectValue(o *pkl.Object) any {
+	switch {
+	case o == nil:
+		return nil
+	case len(o.Elements) > 0:
+		list := make([]any, len(o.Elements))
+		for i, e := range o.Elements {
+			list[i] = plainValue(e)
+		}
+		return list
+	case len(o.Properties) > 0:
+		m := make(map[string]any, len(o.Properties))
+		for k, e := range o.Properties {
+			m[k] = plainValue(e)
+		}
+		return m
+	case len(o.Entries) > 0:
+		m := make(map[string]any, len(o.Entries))
+		for k, e := range o.Entries {
+			m[fmt.Sprint(k)] = plainValue(e)
+		}
+		return m
+	}
+	return []any{}
+}
+
+func plainValue(v any) any {
+	switch x := v.(type) {
+	case *pkl.Object:
+		return objectValue(x)
+	case pkl.Object:
+		return objectValue(&x)
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	}
+	return v
+}
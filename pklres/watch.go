@@ -0,0 +1,155 @@
+package pklres
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of change of an Event.
+type EventType string
+
+const (
+	// EventSet is a key set by Set or SetMany.
+	EventSet EventType = "set"
+	// EventDelete is a key removed by Delete.
+	EventDelete EventType = "delete"
+	// EventClear is a key removed by Clear.
+	EventClear EventType = "clear"
+)
+
+// Event is a change of a key of a storage.
+type Event struct {
+	Type       EventType
+	GraphID    string
+	Collection string
+	Key        string
+
+	// OldValue is the value before the change, nil if the key did not exist.
+	OldValue *string
+	// NewValue is the value after the change, nil if the key was removed.
+	NewValue *string
+
+	Time time.Time
+}
+
+// Watcher is implemented by storages that publish their changes, such as
+// MemoryStorage and FileStorage.
+type Watcher interface {
+	// Watch returns the changes of the keys of collection starting with
+	// keyPrefix, in all graphs, until ctx is done. An empty collection
+	// watches all collections.
+	Watch(ctx context.Context, collection, keyPrefix string) <-chan Event
+}
+
+// watcher queues the events of a Watch call. Events are queued without bound,
+// so that a slow receiver neither blocks writers nor misses changes.
+type watcher struct {
+	collection string
+	prefix     string
+
+	mu     sync.Mutex
+	queue  []Event
+	signal chan struct{}
+}
+
+func (w *watcher) matches(e Event) bool {
+	return (w.collection == "" || w.collection == e.Collection) && strings.HasPrefix(e.Key, w.prefix)
+}
+
+// hub dispatches the events of a storage to its watchers.
+type hub struct {
+	mu       sync.Mutex
+	watchers map[*watcher]bool
+}
+
+// watch registers a watcher and returns its channel, closed when ctx is done.
+func (h *hub) watch(ctx context.Context, collection, keyPrefix string) <-chan Event {
+	w := &watcher{collection: collection, prefix: keyPrefix, signal: make(chan struct{}, 1)}
+	h.mu.Lock()
+	if h.watchers == nil {
+		h.watchers = make(map[*watcher]bool)
+	}
+	h.watchers[w] = true
+	h.mu.Unlock()
+
+	out := make(chan Event)
+	go func() {
+		defer func() {
+			h.mu.Lock()
+			delete(h.watchers, w)
+			h.mu.Unlock()
+			close(out)
+		}()
+		for {
+			w.mu.Lock()
+			if len(w.queue) == 0 {
+				w.mu.Unlock()
+				select {
+				case <-w.signal:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			e := w.queue[0]
+			w.queue = w.queue[1:]
+			w.mu.Unlock()
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// publish queues events for the watchers they match. Storages publish while
+// holding their lock, so watchers receive changes in the order they happened.
+func (h *hub) publish(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		queued := false
+		w.mu.Lock()
+		for _, e := range events {
+			if w.matches(e) {
+				e.Time = now
+				w.queue = append(w.queue, e)
+				queued = true
+			}
+		}
+		w.mu.Unlock()
+		if queued {
+			select {
+			case w.signal <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// change returns the event of changing a key from old to value.
+func change(t EventType, graphID, collection, key string, old, value *string) Event {
+	return Event{Type: t, GraphID: graphID, Collection: collection, Key: key, OldValue: old, NewValue: value}
+}
+
+// lookup returns a pointer to the value of a key, or nil if it does not exist.
+// It must be called with the lock held.
+func (s *MemoryStorage) lookup(graphID, collection, key string) *string {
+	if v, ok := s.graphs[graphID][collection][key]; ok {
+		return &v
+	}
+	return nil
+}
+
+// Watch implements Watcher.
+func (s *MemoryStorage) Watch(ctx context.Context, collection, keyPrefix string) <-chan Event {
+	return s.hub.watch(ctx, collection, keyPrefix)
+}
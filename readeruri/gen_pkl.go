@@ -0,0 +1,28 @@
+//go:build ignore
+
+// gen_pkl writes the URI builders of ReaderURI.pkl to ../deps/pkl.
+// Run from the readeruri directory: go run gen_pkl.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kdeps/schema/readeruri"
+)
+
+func main() {
+	var buf bytes.Buffer
+	if err := readeruri.WritePKL(&buf); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate %s: %v\n", readeruri.PKLModule, err)
+		os.Exit(1)
+	}
+	path := filepath.Join("..", "deps", "pkl", readeruri.PKLModule)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s\n", path)
+}
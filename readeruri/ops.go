@@ -0,0 +1,290 @@
+package readeruri
+
+import "net/url"
+
+// schemes defines the readers and their ops, in the order of ReaderURI.pkl.
+var schemes = []*scheme{
+	{name: "pklres", authority: true, ops: []*spec{
+		{op: "get", since: 1, doc: `returns the value of a key, or "" if it is missing`, new: func() Op { return new(PklresGet) }},
+		{op: "set", since: 1, doc: "stores the value of a key and returns it", new: func() Op { return new(PklresSet) }},
+		{op: "setMany", since: 2, doc: "stores the values of a JSON object at once and returns them", new: func() Op { return new(PklresSetMany) }},
+		{op: "list", since: 1, doc: "returns the keys of a collection as a JSON array", new: func() Op { return new(PklresList) }},
+		{op: "delete", since: 2, doc: `removes a key and returns "true" if it existed`, new: func() Op { return new(PklresDelete) }},
+		{op: "waitFor", since: 2, doc: "returns the value of a key once it is set, waiting at most timeout (seconds or a duration)", new: func() Op { return new(PklresWaitFor) }},
+		{op: "relationalSelect", since: 1, doc: "returns the rows of a collection matching JSON conditions", new: func() Op { return new(PklresRelationalSelect) }},
+		{op: "relationalProject", since: 1, doc: "returns the rows of a collection projected by a JSON condition", new: func() Op { return new(PklresRelationalProject) }},
+		{op: "relationalJoin", since: 1, doc: "joins two collections on a JSON condition", new: func() Op { return new(PklresRelationalJoin) }},
+		{op: "relationalQuery", since: 2, doc: "runs a JSON query on a collection", new: func() Op { return new(PklresRelationalQuery) }},
+		{op: "aggregate", since: 2, doc: "groups the rows of a collection and aggregates each group", new: func() Op { return new(PklresAggregate) }},
+		{op: "queryWithCache", since: 1, doc: "runs a query of a type on JSON params through the query cache", new: func() Op { return new(PklresQueryWithCache) }},
+		{op: "clearCache", since: 1, doc: "clears the query cache of the graph", new: func() Op { return new(PklresClearCache) }},
+		{op: "setCacheTTL", since: 1, doc: "sets the time, in seconds, queries stay cached", new: func() Op { return new(PklresSetCacheTTL) }},
+		{op: "getCacheStats", since: 1, doc: "returns the statistics of the query cache as JSON", new: func() Op { return new(PklresGetCacheStats) }},
+	}},
+	{name: "session", ops: []*spec{
+		{op: "get", implicit: true, since: 1, doc: "returns the session record of an ID", new: func() Op { return new(SessionGet) }},
+		{op: "set", since: 1, doc: "stores the session record of an ID", new: func() Op { return new(SessionSet) }},
+		{op: "delete", since: 1, doc: "deletes the session record of an ID", new: func() Op { return new(SessionDelete) }},
+		{op: "clear", path: "_", since: 1, doc: "deletes all session records", new: func() Op { return new(SessionClear) }},
+	}},
+	{name: "memory", ops: []*spec{
+		{op: "get", implicit: true, since: 1, doc: "returns the memory record of an ID", new: func() Op { return new(MemoryGet) }},
+		{op: "set", since: 1, doc: "stores the memory record of an ID", new: func() Op { return new(MemorySet) }},
+		{op: "delete", since: 1, doc: "deletes the memory record of an ID", new: func() Op { return new(MemoryDelete) }},
+		{op: "clear", path: "_", since: 1, doc: "deletes all memory records", new: func() Op { return new(MemoryClear) }},
+	}},
+	{name: "item", ops: []*spec{
+		{op: "current", path: "_", since: 1, doc: "returns the item of the current iteration", new: func() Op { return new(ItemCurrent) }},
+		{op: "prev", path: "_", since: 1, doc: "returns the item of the previous iteration", new: func() Op { return new(ItemPrev) }},
+		{op: "next", path: "_", since: 1, doc: "returns the item of the next iteration", new: func() Op { return new(ItemNext) }},
+		{op: "values", since: 1, doc: "returns the results of the iterations of a resource as JSON", new: func() Op { return new(ItemValues) }},
+	}},
+	{name: "tool", ops: []*spec{
+		{op: "get", implicit: true, since: 1, doc: "returns the output of the last run of a tool", new: func() Op { return new(ToolGet) }},
+		{op: "run", since: 1, doc: "runs a tool script with params and returns its output", new: func() Op { return new(ToolRun) }},
+		{op: "history", since: 1, doc: "returns the outputs of the runs of a tool", new: func() Op { return new(ToolHistory) }},
+	}},
+	{name: "agent", ops: []*spec{
+		{op: "resolve", implicit: true, since: 1, doc: "returns the canonical form of an action ID, of the current agent unless agent and version are set", new: func() Op { return new(AgentResolve) }},
+		{op: "list-installed", since: 1, doc: "returns the installed agents and their versions as JSON", new: func() Op { return new(AgentListInstalled) }},
+		{op: "list", since: 1, doc: "returns the action IDs of an agent as JSON, of its newest version unless version is set", new: func() Op { return new(AgentList) }},
+	}},
+}
+
+// PklresGet is pklres://?op=get.
+type PklresGet struct {
+	Collection string `uri:"collection"`
+	Key        string `uri:"key"`
+}
+
+// PklresSet is pklres://?op=set.
+type PklresSet struct {
+	Collection string `uri:"collection"`
+	Key        string `uri:"key"`
+	Value      string `uri:"value"`
+}
+
+// PklresSetMany is pklres://?op=setMany. Values is a JSON object.
+type PklresSetMany struct {
+	Collection string `uri:"collection"`
+	Values     string `uri:"values"`
+}
+
+// PklresList is pklres://?op=list.
+type PklresList struct {
+	Collection string `uri:"collection"`
+}
+
+// PklresDelete is pklres://?op=delete.
+type PklresDelete struct {
+	Collection string `uri:"collection"`
+	Key        string `uri:"key"`
+}
+
+// PklresWaitFor is pklres://?op=waitFor.
+type PklresWaitFor struct {
+	Collection string `uri:"collection"`
+	Key        string `uri:"key"`
+	Timeout    string `uri:"timeout,opt"`
+}
+
+// PklresRelationalSelect is pklres://?op=relationalSelect.
+type PklresRelationalSelect struct {
+	Collection string `uri:"collection"`
+	Conditions string `uri:"conditions"`
+}
+
+// PklresRelationalProject is pklres://?op=relationalProject.
+type PklresRelationalProject struct {
+	Collection string `uri:"collection"`
+	Condition  string `uri:"condition"`
+}
+
+// PklresRelationalJoin is pklres://?op=relationalJoin.
+type PklresRelationalJoin struct {
+	Condition string `uri:"condition"`
+}
+
+// PklresRelationalQuery is pklres://?op=relationalQuery.
+type PklresRelationalQuery struct {
+	Collection string `uri:"collection"`
+	Query      string `uri:"query"`
+}
+
+// PklresAggregate is pklres://?op=aggregate.
+type PklresAggregate struct {
+	Collection   string `uri:"collection"`
+	GroupBy      string `uri:"groupBy,opt"`
+	Aggregations string `uri:"aggregations"`
+	Where        string `uri:"where,opt"`
+}
+
+// PklresQueryWithCache is pklres://?op=queryWithCache.
+type PklresQueryWithCache struct {
+	QueryType string `uri:"queryType"`
+	Params    string `uri:"params"`
+}
+
+// PklresClearCache is pklres://?op=clearCache.
+type PklresClearCache struct{}
+
+// PklresSetCacheTTL is pklres://?op=setCacheTTL.
+type PklresSetCacheTTL struct {
+	TTL string `uri:"ttl"`
+}
+
+// PklresGetCacheStats is pklres://?op=getCacheStats.
+type PklresGetCacheStats struct{}
+
+// SessionGet is session:/<id>.
+type SessionGet struct {
+	ID string `uri:"id,path"`
+}
+
+// SessionSet is session:/<id>?op=set.
+type SessionSet struct {
+	ID    string `uri:"id,path"`
+	Value string `uri:"value"`
+}
+
+// SessionDelete is session:/<id>?op=delete.
+type SessionDelete struct {
+	ID string `uri:"id,path"`
+}
+
+// SessionClear is session:/_?op=clear.
+type SessionClear struct{}
+
+// MemoryGet is memory:/<id>.
+type MemoryGet struct {
+	ID string `uri:"id,path"`
+}
+
+// MemorySet is memory:/<id>?op=set.
+type MemorySet struct {
+	ID    string `uri:"id,path"`
+	Value string `uri:"value"`
+}
+
+// MemoryDelete is memory:/<id>?op=delete.
+type MemoryDelete struct {
+	ID string `uri:"id,path"`
+}
+
+// MemoryClear is memory:/_?op=clear.
+type MemoryClear struct{}
+
+// ItemCurrent is item:/_?op=current.
+type ItemCurrent struct{}
+
+// ItemPrev is item:/_?op=prev.
+type ItemPrev struct{}
+
+// ItemNext is item:/_?op=next.
+type ItemNext struct{}
+
+// ItemValues is item:/<id>?op=values.
+type ItemValues struct {
+	ID string `uri:"id,path"`
+}
+
+// ToolGet is tool:/<id>.
+type ToolGet struct {
+	ID string `uri:"id,path"`
+}
+
+// ToolRun is tool:/<id>?op=run.
+type ToolRun struct {
+	ID     string `uri:"id,path"`
+	Script string `uri:"script"`
+	Params string `uri:"params"`
+}
+
+// ToolHistory is tool:/<id>?op=history.
+type ToolHistory struct {
+	ID string `uri:"id,path"`
+}
+
+// AgentResolve is agent:/<actionID>.
+type AgentResolve struct {
+	ActionID string `uri:"actionID,path"`
+	Agent    string `uri:"agent,opt"`
+	Version  string `uri:"version,opt"`
+}
+
+// AgentListInstalled is agent:/?op=list-installed.
+type AgentListInstalled struct{}
+
+// AgentList is agent:/<agent>?op=list. The agent may be repeated in the
+// agent param, as kdeps' agent reader accepts.
+type AgentList struct {
+	Agent   string `uri:"agent,path,query"`
+	Version string `uri:"version,opt"`
+}
+
+func (o PklresGet) Encode() string                        { return encode(&o) }
+func (o *PklresGet) Decode(u url.URL) error               { return decode(u, o) }
+func (o PklresSet) Encode() string                        { return encode(&o) }
+func (o *PklresSet) Decode(u url.URL) error               { return decode(u, o) }
+func (o PklresSetMany) Encode() string                    { return encode(&o) }
+func (o *PklresSetMany) Decode(u url.URL) error           { return decode(u, o) }
+func (o PklresList) Encode() string                       { return encode(&o) }
+func (o *PklresList) Decode(u url.URL) error              { return decode(u, o) }
+func (o PklresDelete) Encode() string                     { return encode(&o) }
+func (o *PklresDelete) Decode(u url.URL) error            { return decode(u, o) }
+func (o PklresWaitFor) Encode() string                    { return encode(&o) }
+func (o *PklresWaitFor) Decode(u url.URL) error           { return decode(u, o) }
+func (o PklresRelationalSelect) Encode() string           { return encode(&o) }
+func (o *PklresRelationalSelect) Decode(u url.URL) error  { return decode(u, o) }
+func (o PklresRelationalProject) Encode() string          { return encode(&o) }
+func (o *PklresRelationalProject) Decode(u url.URL) error { return decode(u, o) }
+func (o PklresRelationalJoin) Encode() string             { return encode(&o) }
+func (o *PklresRelationalJoin) Decode(u url.URL) error    { return decode(u, o) }
+func (o PklresRelationalQuery) Encode() string            { return encode(&o) }
+func (o *PklresRelationalQuery) Decode(u url.URL) error   { return decode(u, o) }
+func (o PklresAggregate) Encode() string                  { return encode(&o) }
+func (o *PklresAggregate) Decode(u url.URL) error         { return decode(u, o) }
+func (o PklresQueryWithCache) Encode() string             { return encode(&o) }
+func (o *PklresQueryWithCache) Decode(u url.URL) error    { return decode(u, o) }
+func (o PklresClearCache) Encode() string                 { return encode(&o) }
+func (o *PklresClearCache) Decode(u url.URL) error        { return decode(u, o) }
+func (o PklresSetCacheTTL) Encode() string                { return encode(&o) }
+func (o *PklresSetCacheTTL) Decode(u url.URL) error       { return decode(u, o) }
+func (o PklresGetCacheStats) Encode() string              { return encode(&o) }
+func (o *PklresGetCacheStats) Decode(u url.URL) error     { return decode(u, o) }
+func (o SessionGet) Encode() string                       { return encode(&o) }
+func (o *SessionGet) Decode(u url.URL) error              { return decode(u, o) }
+func (o SessionSet) Encode() string                       { return encode(&o) }
+func (o *SessionSet) Decode(u url.URL) error              { return decode(u, o) }
+func (o SessionDelete) Encode() string                    { return encode(&o) }
+func (o *SessionDelete) Decode(u url.URL) error           { return decode(u, o) }
+func (o SessionClear) Encode() string                     { return encode(&o) }
+func (o *SessionClear) Decode(u url.URL) error            { return decode(u, o) }
+func (o MemoryGet) Encode() string                        { return encode(&o) }
+func (o *MemoryGet) Decode(u url.URL) error               { return decode(u, o) }
+func (o MemorySet) Encode() string                        { return encode(&o) }
+func (o *MemorySet) Decode(u url.URL) error               { return decode(u, o) }
+func (o MemoryDelete) Encode() string                     { return encode(&o) }
+func (o *MemoryDelete) Decode(u url.URL) error            { return decode(u, o) }
+func (o MemoryClear) Encode() string                      { return encode(&o) }
+func (o *MemoryClear) Decode(u url.URL) error             { return decode(u, o) }
+func (o ItemCurrent) Encode() string                      { return encode(&o) }
+func (o *ItemCurrent) Decode(u url.URL) error             { return decode(u, o) }
+func (o ItemPrev) Encode() string                         { return encode(&o) }
+func (o *ItemPrev) Decode(u url.URL) error                { return decode(u, o) }
+func (o ItemNext) Encode() string                         { return encode(&o) }
+func (o *ItemNext) Decode(u url.URL) error                { return decode(u, o) }
+func (o ItemValues) Encode() string                       { return encode(&o) }
+func (o *ItemValues) Decode(u url.URL) error              { return decode(u, o) }
+func (o ToolGet) Encode() string                          { return encode(&o) }
+func (o *ToolGet) Decode(u url.URL) error                 { return decode(u, o) }
+func (o ToolRun) Encode() string                          { return encode(&o) }
+func (o *ToolRun) Decode(u url.URL) error                 { return decode(u, o) }
+func (o ToolHistory) Encode() string                      { return encode(&o) }
+func (o *ToolHistory) Decode(u url.URL) error             { return decode(u, o) }
+func (o AgentResolve) Encode() string                     { return encode(&o) }
+func (o *AgentResolve) Decode(u url.URL) error            { return decode(u, o) }
+func (o AgentListInstalled) Encode() string               { return encode(&o) }
+func (o *AgentListInstalled) Decode(u url.URL) error      { return decode(u, o) }
+func (o AgentList) Encode() string                        { return encode(&o) }
+func (o *AgentList) Decode(u url.URL) error               { return decode(u, o) }
@@ -0,0 +1,119 @@
+package readeruri
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// PKLModule is the name of the PKL module written by WritePKL.
+const PKLModule = "ReaderURI.pkl"
+
+const pklHeader = `/// URI builders for the kdeps resource readers
+///
+/// Each function returns the URI of an op of a reader (pklres:, session:, memory:, item:, tool: and agent:),
+/// with its params percent-encoded. Null and empty optional params are omitted.
+///
+/// Generated by the readeruri Go package, which decodes these URIs: cd readeruri && go run gen_pkl.go. DO NOT EDIT.
+@ModuleInfo { minPklVersion = "0.28.2" }
+
+@go.Package { name = "github.com/kdeps/schema/gen/reader_uri" }
+
+open module org.kdeps.pkl.ReaderURI
+
+import "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.10.0#/go.pkl"
+import "package://pkg.pkl-lang.org/pkl-pantry/pkl.experimental.uri@1.0.3#/URI.pkl"
+
+/// The query of a URI from its params, skipping null params
+local function joinQuery(params: List<String?>): String =
+  let (present = params.filterNonNull())
+  if (present.isEmpty) "" else "?" + present.join("&")
+
+/// The param [name] with an optional [value], or null if [value] is null or empty
+local function optionalParam(name: String, value: String?): String? =
+  if (value != null && value != "") "\(name)=\(URI.encodeComponent(value))" else null
+`
+
+// WritePKL writes ReaderURI.pkl: a function per op, named after its scheme
+// and op (pklresGet, sessionSet, agentListInstalled, ...), taking its params
+// in the order of its struct and returning the URI Encode returns.
+func WritePKL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(pklHeader)
+	for _, sc := range schemes {
+		for _, s := range sc.ops {
+			bw.WriteString("\n" + s.pklFunction())
+		}
+	}
+	return bw.Flush()
+}
+
+// pklName returns the name of the PKL function of s.
+func (s *spec) pklName() string {
+	var b strings.Builder
+	b.WriteString(s.scheme.name)
+	for _, part := range strings.Split(s.op, "-") {
+		r := []rune(part)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+	return b.String()
+}
+
+// pklFunction returns the PKL function of s. Ops with optional params build
+// their query with the joinQuery and optionalParam helpers of the module.
+func (s *spec) pklFunction() string {
+	var params, query []string
+	prefix := s.scheme.name + ":"
+	if s.scheme.authority {
+		prefix += "//"
+	} else {
+		prefix += "/" + s.path
+	}
+	if !s.implicit {
+		query = append(query, "op="+s.op)
+	}
+	optional := false
+	for _, f := range s.fields {
+		switch {
+		case f.path:
+			params = append(params, f.name+": String")
+			prefix += fmt.Sprintf(`\(URI.encodeComponent(%s))`, f.name)
+		case f.optional:
+			optional = true
+			params = append(params, f.name+": String?")
+			query = append(query, fmt.Sprintf(`optionalParam("%s", %s)`, f.name, f.name))
+		default:
+			params = append(params, f.name+": String")
+			query = append(query, fmt.Sprintf(`%s=\(URI.encodeComponent(%s))`, f.name, f.name))
+		}
+	}
+
+	var body string
+	switch {
+	case optional:
+		for i, q := range query {
+			if !strings.HasPrefix(q, "optionalParam(") {
+				query[i] = `"` + q + `"`
+			}
+		}
+		body = fmt.Sprintf(`"%s" + joinQuery(List(%s))`, prefix, strings.Join(query, ", "))
+	case len(query) > 0:
+		body = `"` + prefix + "?" + strings.Join(query, "&") + `"`
+	default:
+		body = `"` + prefix + `"`
+	}
+
+	var b strings.Builder
+	if s.implicit {
+		fmt.Fprintf(&b, "/// Builds a %s: URI without op, which %s.\n", s.scheme.name, s.doc)
+	} else {
+		fmt.Fprintf(&b, "/// Builds a %s: URI with op=%s, which %s.\n", s.scheme.name, s.op, s.doc)
+	}
+	if s.since > MinVersion {
+		fmt.Fprintf(&b, "///\n/// Requires version %d of the reader protocol.\n", s.since)
+	}
+	fmt.Fprintf(&b, "function %s(%s): String =\n  %s\n", s.pklName(), strings.Join(params, ", "), body)
+	return b.String()
+}
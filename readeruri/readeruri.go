@@ -0,0 +1,334 @@
+// Package readeruri encodes and decodes the URIs of the kdeps resource readers:
+// pklres:, session:, memory:, item:, tool: and agent:.
+//
+// Each operation of a reader is a struct whose fields are the params of its URI:
+//
+//	uri := readeruri.PklresGet{Collection: "@myAgent/fetch:1.0.0", Key: "url"}.Encode()
+//	// pklres://?op=get&collection=%40myAgent%2Ffetch%3A1.0.0&key=url
+//
+//	op, err := readeruri.Parse(u) // *readeruri.PklresGet
+//
+// Decoding is strict: the op must match, required params must be present,
+// unknown and repeated params are rejected. Values are percent-encoded as
+// URI.encodeComponent does, so that keys and IDs may hold any character.
+//
+// The builders of ReaderURI.pkl, through which the PKL modules build these
+// URIs, are generated from the same definitions by WritePKL, so that both
+// sides agree on every op and param:
+//
+//	cd readeruri && go run gen_pkl.go
+//
+// # Versions
+//
+// Ops are added to the protocol in versions. A URI may name the version it
+// was built for with the v param, which readers check: URIs naming a version
+// outside MinVersion to Version, or an op newer than their version, are
+// rejected with ErrUnsupportedVersion. URIs without v are not checked.
+// Negotiate picks the version to use with a reader and EncodeVersion builds
+// URIs naming it.
+package readeruri
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MinVersion and Version are the oldest and newest versions of the protocol.
+// Version 2 added op=setMany, op=delete, op=waitFor, op=relationalQuery and
+// op=aggregate to pklres.
+const (
+	MinVersion = 1
+	Version    = 2
+)
+
+var (
+	// ErrUnknownScheme is returned for URIs of another reader.
+	ErrUnknownScheme = errors.New("unknown reader scheme")
+
+	// ErrUnknownOp is returned for URIs naming an op the reader does not have,
+	// or another op than the one decoded.
+	ErrUnknownOp = errors.New("unknown reader op")
+
+	// ErrMissingParam is returned for URIs without a required param.
+	ErrMissingParam = errors.New("missing param")
+
+	// ErrUnknownParam is returned for URIs with a param the op does not have.
+	ErrUnknownParam = errors.New("unknown param")
+
+	// ErrRepeatedParam is returned for URIs with a param given twice.
+	ErrRepeatedParam = errors.New("repeated param")
+
+	// ErrConflictingParam is returned for URIs repeating their path in a
+	// param with another value.
+	ErrConflictingParam = errors.New("conflicting param")
+
+	// ErrUnsupportedVersion is returned for versions outside MinVersion to
+	// Version, and for ops newer than the version of a URI.
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+)
+
+// Op is an operation of a reader.
+type Op interface {
+	// Encode returns the URI of the operation, as the builders of
+	// ReaderURI.pkl do.
+	Encode() string
+
+	// Decode sets the operation from its URI.
+	Decode(u url.URL) error
+}
+
+// Parse decodes the URI of any op of any reader.
+func Parse(u url.URL) (Op, error) {
+	sc := schemeNamed(u.Scheme)
+	if sc == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownScheme, u.Scheme)
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query of %s: URI: %w", sc.name, err)
+	}
+	name := q.Get("op")
+	for _, s := range sc.ops {
+		if (s.implicit && name == "") || (!s.implicit && name == s.op) {
+			o := s.new()
+			if err := o.Decode(u); err != nil {
+				return nil, err
+			}
+			return o, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q for %s:", ErrUnknownOp, name, sc.name)
+}
+
+// Negotiate returns the newest version spoken by both this package and a
+// reader speaking versions lo to hi.
+func Negotiate(lo, hi int) (int, error) {
+	if lo > hi || lo > Version || hi < MinVersion {
+		return 0, fmt.Errorf("%w: reader speaks %d to %d, expected %d to %d", ErrUnsupportedVersion, lo, hi, MinVersion, Version)
+	}
+	return min(hi, Version), nil
+}
+
+// EncodeVersion returns the URI of o naming version v, which must have o.
+func EncodeVersion(o Op, v int) (string, error) {
+	s := specOf(o)
+	if err := s.supports(v); err != nil {
+		return "", err
+	}
+	uri := o.Encode()
+	if strings.Contains(uri, "?") {
+		return uri + "&v=" + strconv.Itoa(v), nil
+	}
+	return uri + "?v=" + strconv.Itoa(v), nil
+}
+
+// scheme is a reader and its ops.
+type scheme struct {
+	name string
+	// authority is set for schemes whose URIs have an empty authority and no
+	// path, such as pklres://?op=get.
+	authority bool
+	ops       []*spec
+}
+
+// spec defines an op of a reader. The params of the op are the fields of the
+// struct returned by new, tagged with their name:
+//
+//	Key     string `uri:"key"`               required param
+//	Timeout string `uri:"timeout,opt"`       optional param, omitted when empty
+//	ID      string `uri:"id,path"`           the path of the URI
+//	Agent   string `uri:"agent,path,query"`  the path, which may be repeated in the agent param
+type spec struct {
+	scheme *scheme
+	op     string
+	// implicit is set for the default op of a scheme, whose URIs have no op
+	// param, such as session:/<id>.
+	implicit bool
+	// path is the path of ops without a path field, such as "_" for
+	// session:/_?op=clear.
+	path string
+	// since is the version of the protocol that added the op.
+	since int
+	// doc describes what the op does, for ReaderURI.pkl.
+	doc string
+	new func() Op
+
+	fields []field
+}
+
+type field struct {
+	index    int
+	name     string
+	path     bool
+	optional bool
+	// query is set for path fields that may also be given as a param, which
+	// must then equal the path.
+	query bool
+}
+
+func (s *spec) String() string {
+	if s.implicit {
+		return s.scheme.name + ": " + s.op
+	}
+	return s.scheme.name + ": op=" + s.op
+}
+
+func (s *spec) supports(v int) error {
+	if v < MinVersion || v > Version {
+		return fmt.Errorf("%w %d, expected %d to %d", ErrUnsupportedVersion, v, MinVersion, Version)
+	}
+	if s.since > v {
+		return fmt.Errorf("%w %d: %s requires version %d", ErrUnsupportedVersion, v, s, s.since)
+	}
+	return nil
+}
+
+var specs = make(map[reflect.Type]*spec)
+
+func init() {
+	for _, sc := range schemes {
+		for _, s := range sc.ops {
+			s.scheme = sc
+			t := reflect.TypeOf(s.new()).Elem()
+			for i := 0; i < t.NumField(); i++ {
+				tag := strings.Split(t.Field(i).Tag.Get("uri"), ",")
+				f := field{index: i, name: tag[0]}
+				for _, opt := range tag[1:] {
+					f.path = f.path || opt == "path"
+					f.optional = f.optional || opt == "opt"
+					f.query = f.query || opt == "query"
+				}
+				s.fields = append(s.fields, f)
+			}
+			specs[t] = s
+		}
+	}
+}
+
+func schemeNamed(name string) *scheme {
+	i := slices.IndexFunc(schemes, func(sc *scheme) bool { return sc.name == name })
+	if i < 0 {
+		return nil
+	}
+	return schemes[i]
+}
+
+// specOf returns the spec of an op, given as a struct or a pointer to one.
+func specOf(o any) *spec {
+	return specs[reflect.Indirect(reflect.ValueOf(o)).Type()]
+}
+
+// encode returns the URI of o.
+func encode(o any) string {
+	s := specOf(o)
+	v := reflect.Indirect(reflect.ValueOf(o))
+	var b strings.Builder
+	b.WriteString(s.scheme.name + ":")
+	if s.scheme.authority {
+		b.WriteString("//")
+	} else {
+		path := s.path
+		for _, f := range s.fields {
+			if f.path {
+				path = encodeComponent(v.Field(f.index).String())
+			}
+		}
+		b.WriteString("/" + path)
+	}
+	var params []string
+	if !s.implicit {
+		params = append(params, "op="+s.op)
+	}
+	for _, f := range s.fields {
+		value := v.Field(f.index).String()
+		if f.path || (f.optional && value == "") {
+			continue
+		}
+		params = append(params, f.name+"="+encodeComponent(value))
+	}
+	if len(params) > 0 {
+		b.WriteString("?" + strings.Join(params, "&"))
+	}
+	return b.String()
+}
+
+// decode sets the fields of o from u.
+func decode(u url.URL, o any) error {
+	s := specOf(o)
+	if u.Scheme != s.scheme.name {
+		return fmt.Errorf("%w %q, expected %q", ErrUnknownScheme, u.Scheme, s.scheme.name)
+	}
+	if u.Opaque != "" || u.Host != "" {
+		return fmt.Errorf("invalid URI for %s: %s", s, u.String())
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return fmt.Errorf("invalid query for %s: %w", s, err)
+	}
+	for name, values := range q {
+		if len(values) > 1 {
+			return fmt.Errorf("%w %q for %s", ErrRepeatedParam, name, s)
+		}
+		if name != "op" && name != "v" && !slices.ContainsFunc(s.fields, func(f field) bool { return f.name == name && (!f.path || f.query) }) {
+			return fmt.Errorf("%w %q for %s", ErrUnknownParam, name, s)
+		}
+	}
+	if op := q.Get("op"); (s.implicit && q.Has("op")) || (!s.implicit && op != s.op) {
+		return fmt.Errorf("%w %q, expected %s", ErrUnknownOp, op, s)
+	}
+	if q.Has("v") {
+		v, err := strconv.Atoi(q.Get("v"))
+		if err != nil {
+			return fmt.Errorf("%w %q", ErrUnsupportedVersion, q.Get("v"))
+		}
+		if err := s.supports(v); err != nil {
+			return err
+		}
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	hasPath := slices.ContainsFunc(s.fields, func(f field) bool { return f.path })
+	if !hasPath && path != "" && path != s.path {
+		return fmt.Errorf("unexpected path %q for %s", path, s)
+	}
+	v := reflect.ValueOf(o).Elem()
+	for _, f := range s.fields {
+		var value string
+		switch {
+		case f.path:
+			if path == "" {
+				return fmt.Errorf("%w %s for %s", ErrMissingParam, f.name, s)
+			}
+			if f.query && q.Has(f.name) && q.Get(f.name) != path {
+				return fmt.Errorf("%w %q for %s: %q differs from the path %q", ErrConflictingParam, f.name, s, q.Get(f.name), path)
+			}
+			value = path
+		case q.Has(f.name):
+			value = q.Get(f.name)
+		case !f.optional:
+			return fmt.Errorf("%w %q for %s", ErrMissingParam, f.name, s)
+		}
+		v.Field(f.index).SetString(value)
+	}
+	return nil
+}
+
+// encodeComponent percent-encodes s as URI.encodeComponent and JavaScript's
+// encodeURIComponent do: all bytes but letters, digits and -_.!~*'().
+func encodeComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.!~*'()", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package records
+
+import (
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/pklres"
+)
+
+// ReadExec reads the command of actionID, as Exec.resource does.
+func ReadExec(s pklres.Storage, graphID, actionID string) (*exec.ResourceExec, error) {
+	d, err := read(s, graphID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	return decodeExec(d, actionID)
+}
+
+// DecodeExec decodes the values of a command.
+func DecodeExec(entries map[string]string) (*exec.ResourceExec, error) {
+	return decodeExec(&decoder{entries: entries}, "exec")
+}
+
+func decodeExec(d *decoder, name string) (*exec.ResourceExec, error) {
+	x := &exec.ResourceExec{
+		Env:             decodeJSON(d, "env", &map[string]string{}),
+		Command:         d.stringOr("command", ""),
+		Stderr:          d.string("stderr"),
+		Stdout:          d.string("stdout"),
+		ExitCode:        d.int("exitCode", 0),
+		File:            d.string("file"),
+		ItemValues:      decodeJSON(d, "itemValues", &[]string{}),
+		Timestamp:       d.duration("timestamp", nil),
+		TimeoutDuration: d.duration("timeoutDuration", &DefaultTimeout),
+	}
+	if d.err != nil {
+		return nil, wrap(name, d.err)
+	}
+	return x, nil
+}
+
+// WriteExec writes the non-nil fields of x into the collection of actionID.
+func WriteExec(s pklres.Storage, graphID, actionID string, x *exec.ResourceExec) error {
+	entries, err := EncodeExec(x)
+	if err != nil {
+		return err
+	}
+	return write(s, graphID, actionID, entries)
+}
+
+// EncodeExec encodes the command and the non-nil fields of x into values.
+func EncodeExec(x *exec.ResourceExec) (map[string]string, error) {
+	e := newEncoder()
+	encodeJSON(&e, "env", x.Env)
+	e.string("command", &x.Command)
+	e.string("stderr", x.Stderr)
+	e.string("stdout", x.Stdout)
+	e.int("exitCode", x.ExitCode)
+	e.string("file", x.File)
+	encodeJSON(&e, "itemValues", x.ItemValues)
+	e.duration("timestamp", x.Timestamp)
+	e.duration("timeoutDuration", x.TimeoutDuration)
+	return e.entries, e.err
+}
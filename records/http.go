@@ -0,0 +1,69 @@
+package records
+
+import (
+	"github.com/kdeps/schema/gen/http"
+	"github.com/kdeps/schema/pklres"
+)
+
+// ReadHTTP reads the HTTP client of actionID, as HTTP.resource does. The
+// response is a JSON object with Body and Headers.
+func ReadHTTP(s pklres.Storage, graphID, actionID string) (*http.ResourceHTTPClient, error) {
+	d, err := read(s, graphID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	return decodeHTTP(d, actionID)
+}
+
+// DecodeHTTP decodes the values of an HTTP client.
+func DecodeHTTP(entries map[string]string) (*http.ResourceHTTPClient, error) {
+	return decodeHTTP(&decoder{entries: entries}, "http")
+}
+
+func decodeHTTP(d *decoder, name string) (*http.ResourceHTTPClient, error) {
+	h := &http.ResourceHTTPClient{
+		Method:          d.stringOr("method", DefaultMethod),
+		Url:             d.stringOr("url", ""),
+		Data:            decodeJSON(d, "data", &[]string{}),
+		Headers:         decodeJSON(d, "headers", &map[string]string{}),
+		Params:          decodeJSON(d, "params", &map[string]string{}),
+		File:            d.string("file"),
+		ItemValues:      decodeJSON(d, "itemValues", &[]string{}),
+		Timestamp:       d.duration("timestamp", nil),
+		TimeoutDuration: d.duration("timeoutDuration", &DefaultTimeout),
+	}
+	if response := decodeJSON[*http.ResponseBlock](d, "response", nil); response != nil {
+		h.Response = *response
+	}
+	if d.err != nil {
+		return nil, wrap(name, d.err)
+	}
+	return h, nil
+}
+
+// WriteHTTP writes the non-nil fields of h into the collection of actionID.
+func WriteHTTP(s pklres.Storage, graphID, actionID string, h *http.ResourceHTTPClient) error {
+	entries, err := EncodeHTTP(h)
+	if err != nil {
+		return err
+	}
+	return write(s, graphID, actionID, entries)
+}
+
+// EncodeHTTP encodes the method, URL and non-nil fields of h into values.
+func EncodeHTTP(h *http.ResourceHTTPClient) (map[string]string, error) {
+	e := newEncoder()
+	e.string("method", &h.Method)
+	e.string("url", &h.Url)
+	encodeJSON(&e, "data", h.Data)
+	encodeJSON(&e, "headers", h.Headers)
+	encodeJSON(&e, "params", h.Params)
+	if h.Response != nil {
+		encodeJSON(&e, "response", h.Response)
+	}
+	e.string("file", h.File)
+	encodeJSON(&e, "itemValues", h.ItemValues)
+	e.duration("timestamp", h.Timestamp)
+	e.duration("timeoutDuration", h.TimeoutDuration)
+	return e.entries, e.err
+}
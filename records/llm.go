@@ -0,0 +1,73 @@
+package records
+
+import (
+	"github.com/kdeps/schema/gen/llm"
+	"github.com/kdeps/schema/pklres"
+)
+
+// ReadChat reads the chat of actionID, as LLM.resource does.
+func ReadChat(s pklres.Storage, graphID, actionID string) (*llm.ResourceChat, error) {
+	d, err := read(s, graphID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	return decodeChat(d, actionID)
+}
+
+// DecodeChat decodes the values of a chat.
+func DecodeChat(entries map[string]string) (*llm.ResourceChat, error) {
+	return decodeChat(&decoder{entries: entries}, "chat")
+}
+
+func decodeChat(d *decoder, name string) (*llm.ResourceChat, error) {
+	model := d.stringOr("model", DefaultModel)
+	c := &llm.ResourceChat{
+		Model:            &model,
+		Role:             d.string("role"),
+		Prompt:           d.string("prompt"),
+		Response:         d.string("response"),
+		File:             d.string("file"),
+		JSONResponse:     d.bool("jsonResponse", false),
+		JSONResponseKeys: decodeJSON[[]string](d, "jsonResponseKeys", nil),
+		TimeoutDuration:  d.duration("timeoutDuration", &DefaultTimeout),
+		Timestamp:        d.duration("timestamp", nil),
+		Scenario:         decodeJSON[[]*llm.MultiChat](d, "scenario", nil),
+		Tools:            decodeJSON[[]*llm.Tool](d, "tools", nil),
+		Files:            decodeJSON[[]string](d, "files", nil),
+		Description:      d.string("description"),
+		ItemValues:       decodeJSON[[]string](d, "itemValues", nil),
+	}
+	if d.err != nil {
+		return nil, wrap(name, d.err)
+	}
+	return c, nil
+}
+
+// WriteChat writes the non-nil fields of c into the collection of actionID.
+func WriteChat(s pklres.Storage, graphID, actionID string, c *llm.ResourceChat) error {
+	entries, err := EncodeChat(c)
+	if err != nil {
+		return err
+	}
+	return write(s, graphID, actionID, entries)
+}
+
+// EncodeChat encodes the non-nil fields of c into values.
+func EncodeChat(c *llm.ResourceChat) (map[string]string, error) {
+	e := newEncoder()
+	e.string("model", c.Model)
+	e.string("role", c.Role)
+	e.string("prompt", c.Prompt)
+	e.string("response", c.Response)
+	e.string("file", c.File)
+	e.bool("jsonResponse", c.JSONResponse)
+	encodeJSON(&e, "jsonResponseKeys", c.JSONResponseKeys)
+	e.duration("timeoutDuration", c.TimeoutDuration)
+	e.duration("timestamp", c.Timestamp)
+	encodeJSON(&e, "scenario", c.Scenario)
+	encodeJSON(&e, "tools", c.Tools)
+	encodeJSON(&e, "files", c.Files)
+	e.string("description", c.Description)
+	encodeJSON(&e, "itemValues", c.ItemValues)
+	return e.entries, e.err
+}
@@ -0,0 +1,64 @@
+package records
+
+import (
+	"github.com/kdeps/schema/gen/python"
+	"github.com/kdeps/schema/pklres"
+)
+
+// ReadPython reads the script of actionID, as Python.resource does.
+func ReadPython(s pklres.Storage, graphID, actionID string) (*python.ResourcePython, error) {
+	d, err := read(s, graphID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	return decodePython(d, actionID)
+}
+
+// DecodePython decodes the values of a script.
+func DecodePython(entries map[string]string) (*python.ResourcePython, error) {
+	return decodePython(&decoder{entries: entries}, "python")
+}
+
+func decodePython(d *decoder, name string) (*python.ResourcePython, error) {
+	p := &python.ResourcePython{
+		Env:               decodeJSON(d, "env", &map[string]string{}),
+		PythonEnvironment: d.string("pythonEnvironment"),
+		Script:            d.stringOr("script", ""),
+		Stderr:            d.string("stderr"),
+		Stdout:            d.string("stdout"),
+		ExitCode:          d.int("exitCode", 0),
+		File:              d.string("file"),
+		ItemValues:        decodeJSON(d, "itemValues", &[]string{}),
+		Timestamp:         d.duration("timestamp", nil),
+		TimeoutDuration:   d.duration("timeoutDuration", &DefaultTimeout),
+	}
+	if d.err != nil {
+		return nil, wrap(name, d.err)
+	}
+	return p, nil
+}
+
+// WritePython writes the non-nil fields of p into the collection of actionID.
+func WritePython(s pklres.Storage, graphID, actionID string, p *python.ResourcePython) error {
+	entries, err := EncodePython(p)
+	if err != nil {
+		return err
+	}
+	return write(s, graphID, actionID, entries)
+}
+
+// EncodePython encodes the script and the non-nil fields of p into values.
+func EncodePython(p *python.ResourcePython) (map[string]string, error) {
+	e := newEncoder()
+	encodeJSON(&e, "env", p.Env)
+	e.string("pythonEnvironment", p.PythonEnvironment)
+	e.string("script", &p.Script)
+	e.string("stderr", p.Stderr)
+	e.string("stdout", p.Stdout)
+	e.int("exitCode", p.ExitCode)
+	e.string("file", p.File)
+	encodeJSON(&e, "itemValues", p.ItemValues)
+	e.duration("timestamp", p.Timestamp)
+	e.duration("timeoutDuration", p.TimeoutDuration)
+	return e.entries, e.err
+}
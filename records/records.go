@@ -0,0 +1,216 @@
+// Package records decodes the values that actions store in pklres into the
+// generated resource classes, as LLM.resource, Exec.resource, Python.resource
+// and HTTP.resource do in PKL, and encodes resources back into keys.
+//
+// The values of an action are the keys of the collection named by its
+// canonical action ID:
+//
+//	chat, err := records.ReadChat(storage, graphID, "@myAgent/llm:1.0.0")
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Println(*chat.Model) // "llama3.2" unless the action stored a model
+//
+//	err = records.WriteExec(storage, graphID, "@myAgent/exec:1.0.0", &exec.ResourceExec{...})
+//
+// Missing keys, and keys holding "" or "null", take the defaults of the PKL
+// functions: Model "llama3.2", JSONResponse false, Method "GET", ExitCode 0
+// and TimeoutDuration 60.s. Lists, mappings and objects such as scenario,
+// tools, jsonResponseKeys, itemValues and env are stored as JSON, durations as
+// PKL literals such as "60.s". Unlike PKL, which falls back to the default,
+// decoding fails on malformed values.
+//
+// Writing encodes the non-nil fields of a resource and stores them at once with
+// SetMany. The keys of nil fields are left unchanged.
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/pklres"
+)
+
+// DefaultModel is the Model of chats that did not store one.
+const DefaultModel = "llama3.2"
+
+// DefaultMethod is the Method of HTTP clients that did not store one.
+const DefaultMethod = "GET"
+
+// DefaultTimeout is the TimeoutDuration of resources that did not store one.
+var DefaultTimeout = pkl.Duration{Value: 60, Unit: pkl.Second}
+
+// read returns the decoder of the values of actionID.
+func read(s pklres.Storage, graphID, actionID string) (*decoder, error) {
+	entries, err := s.Entries(graphID, actionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", actionID, err)
+	}
+	return &decoder{entries: entries}, nil
+}
+
+// write stores entries in the collection of actionID.
+func write(s pklres.Storage, graphID, actionID string, entries map[string]string) error {
+	if err := s.SetMany(graphID, actionID, entries); err != nil {
+		return fmt.Errorf("failed to write %s: %w", actionID, err)
+	}
+	return nil
+}
+
+// wrap reports a decoding error of the values of name.
+func wrap(name string, err error) error {
+	return fmt.Errorf("failed to decode %s: %w", name, err)
+}
+
+// decoder decodes the values of a collection, keeping the first error.
+type decoder struct {
+	entries map[string]string
+	err     error
+}
+
+// value returns the value of key, or "" and false if it is missing, empty or
+// "null", as common.safeGetValue does.
+func (d *decoder) value(key string) (string, bool) {
+	v := d.entries[key]
+	if v == "" || v == "null" {
+		return "", false
+	}
+	return v, true
+}
+
+func (d *decoder) fail(key, value string, err error) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+}
+
+// string returns the value of key, or nil if it is missing.
+func (d *decoder) string(key string) *string {
+	if v, ok := d.value(key); ok {
+		return &v
+	}
+	return nil
+}
+
+// stringOr returns the value of key, or def if it is missing.
+func (d *decoder) stringOr(key, def string) string {
+	if v, ok := d.value(key); ok {
+		return v
+	}
+	return def
+}
+
+// bool returns the value of key as a boolean, or def if it is missing.
+func (d *decoder) bool(key string, def bool) *bool {
+	v, ok := d.value(key)
+	if !ok {
+		return &def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		d.fail(key, v, err)
+	}
+	return &b
+}
+
+// int returns the value of key as an integer, or def if it is missing.
+func (d *decoder) int(key string, def int) *int {
+	v, ok := d.value(key)
+	if !ok {
+		return &def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		d.fail(key, v, err)
+	}
+	return &i
+}
+
+// duration returns the value of key as a duration, or def if it is missing.
+func (d *decoder) duration(key string, def *pkl.Duration) *pkl.Duration {
+	v, ok := d.value(key)
+	if !ok {
+		if def == nil {
+			return nil
+		}
+		copied := *def
+		return &copied
+	}
+	dur, err := pklres.ParseDuration(v)
+	if err != nil {
+		d.fail(key, v, err)
+		return nil
+	}
+	return &dur
+}
+
+// decodeJSON returns the JSON value of key, or def if it is missing.
+func decodeJSON[T any](d *decoder, key string, def *T) *T {
+	v, ok := d.value(key)
+	if !ok {
+		return def
+	}
+	var decoded T
+	if err := json.Unmarshal([]byte(v), &decoded); err != nil {
+		d.fail(key, v, err)
+	}
+	return &decoded
+}
+
+// encoder encodes the fields of a resource into values, keeping the first
+// error.
+type encoder struct {
+	entries map[string]string
+	err     error
+}
+
+func newEncoder() encoder {
+	return encoder{entries: make(map[string]string)}
+}
+
+func (e *encoder) string(key string, v *string) {
+	if v != nil {
+		e.entries[key] = *v
+	}
+}
+
+func (e *encoder) bool(key string, v *bool) {
+	if v != nil {
+		e.entries[key] = strconv.FormatBool(*v)
+	}
+}
+
+func (e *encoder) int(key string, v *int) {
+	if v != nil {
+		e.entries[key] = strconv.Itoa(*v)
+	}
+}
+
+func (e *encoder) duration(key string, v *pkl.Duration) {
+	if v != nil {
+		e.entries[key] = formatDuration(*v)
+	}
+}
+
+// encodeJSON stores v as JSON under key.
+func encodeJSON[T any](e *encoder, key string, v *T) {
+	if v == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		if e.err == nil {
+			e.err = fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+		return
+	}
+	e.entries[key] = string(data)
+}
+
+// formatDuration formats d as a PKL duration literal, which String.toDuration
+// parses.
+func formatDuration(d pkl.Duration) string {
+	return strconv.FormatFloat(d.Value, 'f', -1, 64) + "." + d.Unit.String()
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/kdeps/schema/actionid"
+)
+
+// TestActionIDParse tests parsing and formatting of local and qualified IDs
+func TestActionIDParse(t *testing.T) {
+	cases := []struct {
+		input string
+		want  actionid.ID
+	}{
+		{"fetch", actionid.ID{Action: "fetch"}},
+		{"@search/query", actionid.ID{Agent: "search", Action: "query"}},
+		{"@search/query:1.2.0", actionid.ID{Agent: "search", Action: "query", Version: "1.2.0"}},
+		{"@my_agent/run-query:^1.2", actionid.ID{Agent: "my_agent", Action: "run-query", Version: "^1.2"}},
+		{"@search/query:>=1.0,<2", actionid.ID{Agent: "search", Action: "query", Version: ">=1.0,<2"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			id, err := actionid.Parse(tc.input)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			if id != tc.want {
+				t.Errorf("Expected %+v, got %+v", tc.want, id)
+			}
+			if id.String() != tc.input {
+				t.Errorf("Expected %s to format as itself, got %s", tc.input, id)
+			}
+		})
+	}
+
+	for _, input := range []string{"", "my-action", "@my-agent/query", "@search", "@search/", "@/query", "@search/query:", "@search/query:^a.b", "@a/b/c"} {
+		if _, err := actionid.Parse(input); !errors.Is(err, actionid.ErrInvalid) {
+			t.Errorf("Expected ErrInvalid for %q, got %v", input, err)
+		}
+	}
+	if !actionid.ValidAction("run-query") || actionid.ValidLocal("run-query") || actionid.ValidAgent("my-agent") {
+		t.Error("Expected hyphens to be valid in actions only, as in Resource.pkl")
+	}
+}
+
+// TestActionIDCanonicalize tests qualification and canonical form checks
+func TestActionIDCanonicalize(t *testing.T) {
+	id, err := actionid.Canonicalize("fetch", "myAgent", "1.0.0")
+	if err != nil {
+		t.Fatalf("Failed to canonicalize: %v", err)
+	}
+	if id.String() != "@myAgent/fetch:1.0.0" || !id.IsCanonical() {
+		t.Errorf("Unexpected canonical ID %s", id)
+	}
+
+	qualified, err := actionid.Canonicalize("@search/query:^1", "myAgent", "1.0.0")
+	if err != nil || qualified.Agent != "search" || qualified.IsCanonical() {
+		t.Errorf("Qualified IDs should be returned as parsed, got %s %v", qualified, err)
+	}
+	if _, err := actionid.Canonicalize("fetch", "myAgent", "latest"); !errors.Is(err, actionid.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for an invalid version, got %v", err)
+	}
+}
+
+// TestActionIDOrdering tests equality and ordering
+func TestActionIDOrdering(t *testing.T) {
+	if !actionid.MustParse("@a/x:1.2.0").Equal(actionid.MustParse("@a/x:v1.2.0")) {
+		t.Error("Versions should be compared by precedence")
+	}
+
+	ids := []actionid.ID{
+		actionid.MustParse("@b/x:1.10.0"),
+		actionid.MustParse("@b/x:1.9.0"),
+		actionid.MustParse("@a/y"),
+		actionid.MustParse("@b/x"),
+		actionid.MustParse("fetch"),
+		actionid.MustParse("@a/x:2.0.0"),
+	}
+	slices.SortFunc(ids, actionid.Compare)
+	var got []string
+	for _, id := range ids {
+		got = append(got, id.String())
+	}
+	want := []string{"fetch", "@a/x:2.0.0", "@a/y", "@b/x", "@b/x:1.9.0", "@b/x:1.10.0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected order %v, got %v", want, got)
+	}
+}
+
+// TestActionIDResolver tests resolution against a workflow and installed agents
+func TestActionIDResolver(t *testing.T) {
+	r := actionid.NewResolver("myAgent", "1.0.0", map[string][]string{
+		"search":  {"1.1.0", "1.2.5", "1.10.0", "2.0.0"},
+		"myAgent": {"0.9.0"},
+	})
+
+	cases := map[string]string{
+		"fetch":                 "@myAgent/fetch:1.0.0",
+		"@myAgent/fetch":        "@myAgent/fetch:1.0.0",
+		"@myAgent/fetch:0.9":    "@myAgent/fetch:0.9.0",
+		"@search/query":         "@search/query:2.0.0",
+		"@search/query:^1.2":    "@search/query:1.10.0",
+		"@search/query:~1.2":    "@search/query:1.2.5",
+		"@search/query:1.1.0":   "@search/query:1.1.0",
+		"@search/query:<2,>1.2": "@search/query:1.10.0",
+	}
+	for input, want := range cases {
+		id, err := r.Resolve(input)
+		if err != nil {
+			t.Errorf("Failed to resolve %s: %v", input, err)
+			continue
+		}
+		if id.String() != want || !id.IsCanonical() {
+			t.Errorf("Expected %s to resolve to %s, got %s", input, want, id)
+		}
+	}
+
+	if _, err := r.Resolve("@search/query:^3"); !errors.Is(err, actionid.ErrNoMatchingVersion) {
+		t.Errorf("Expected ErrNoMatchingVersion, got %v", err)
+	}
+	if _, err := r.Resolve("@other/query"); !errors.Is(err, actionid.ErrNotInstalled) {
+		t.Errorf("Expected ErrNotInstalled, got %v", err)
+	}
+	if _, err := r.Resolve("not-valid"); !errors.Is(err, actionid.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid, got %v", err)
+	}
+}
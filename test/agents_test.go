@@ -0,0 +1,182 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kdeps/schema/agents"
+	"github.com/kdeps/schema/readeruri"
+)
+
+// installAgents writes agents/<name>/<version> directories declaring actions
+// in their workflow.pkl and resources, and returns the agents directory.
+func installAgents(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("agent1/1.0.0/workflow.pkl", "amends \"package://schema.kdeps.com/core@0.4.6#/Workflow.pkl\"\n\nAgentID = \"agent1\"\nTargetActionID = \"action2\"\n")
+	write("agent1/1.0.0/resources/action1.pkl", "ActionID = \"action1\"\n")
+	write("agent1/1.0.0/resources/action2.pkl", "ActionID = \"@agent1/action2:1.0.0\"\n")
+	write("agent1/2.0.0/workflow.pkl", "ActionID = \"action1\"\nActionID = \"action3\"\n")
+	write("agent1/10.0.0-beta/workflow.pkl", "ActionID = \"action3\"\n")
+	write("agent2/1.0.0/workflow.pkl", "ActionID = \"action1\"\n")
+	write("agent2/1.0.0/resources/nested/fetch-data.pkl", "  ActionID = \"fetch-data\"\n")
+	write("agent3/1.0.0/resources/orphan.pkl", "ActionID = \"orphan\"\n")
+	write("agent3/latest/workflow.pkl", "ActionID = \"orphan\"\n")
+	write("README.md", "agents\n")
+	return dir
+}
+
+// TestAgentsRegistry tests indexing installed agents and resolving action IDs
+func TestAgentsRegistry(t *testing.T) {
+	reg, err := agents.LoadDir(installAgents(t))
+	if err != nil {
+		t.Fatalf("Failed to load agents: %v", err)
+	}
+
+	if got, want := reg.Versions("agent1"), []string{"1.0.0", "2.0.0", "10.0.0-beta"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected versions %v, got %v", want, got)
+	}
+	if got := reg.Versions("agent3"); len(got) != 0 {
+		t.Errorf("Expected agent3 without workflow to be skipped, got %v", got)
+	}
+	a, ok := reg.Agent("agent1", "1.0.0")
+	if !ok || !reflect.DeepEqual(a.Actions, []string{"action1", "action2"}) {
+		t.Errorf("Expected agent1:1.0.0 to declare action1 and action2, got %v", a.Actions)
+	}
+	a, _ = reg.Agent("agent2", "1.0.0")
+	if !reflect.DeepEqual(a.Actions, []string{"action1", "fetch-data"}) {
+		t.Errorf("Expected agent2:1.0.0 to declare action1 and fetch-data, got %v", a.Actions)
+	}
+
+	resolved := []struct {
+		id, agent, version, want string
+	}{
+		{"@agent1/action1", "", "", "@agent1/action1:2.0.0"},
+		{"@agent1/action2", "", "", "@agent1/action2:1.0.0"},
+		{"@agent1/action1:1.0.0", "", "", "@agent1/action1:1.0.0"},
+		{"@agent1/action3:<3", "", "", "@agent1/action3:2.0.0"},
+		{"@agent2/fetch-data", "", "", "@agent2/fetch-data:1.0.0"},
+		{"action2", "", "", "@agent1/action2:1.0.0"},
+		{"action9", "myAgent", "1.2.0", "@myAgent/action9:1.2.0"},
+		{"action1", "agent2", "", "@agent2/action1:1.0.0"},
+	}
+	for _, tc := range resolved {
+		got, err := reg.Resolve(tc.id, tc.agent, tc.version)
+		if err != nil {
+			t.Errorf("Failed to resolve %s: %v", tc.id, err)
+		} else if got != tc.want {
+			t.Errorf("Expected %s to resolve to %s, got %s", tc.id, tc.want, got)
+		}
+	}
+
+	_, err = reg.Resolve("action1", "", "")
+	var ambiguous *agents.AmbiguousError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("Expected an AmbiguousError, got %v", err)
+	}
+	if want := []string{"@agent1/action1:2.0.0", "@agent2/action1:1.0.0"}; !reflect.DeepEqual(ambiguous.Candidates, want) {
+		t.Errorf("Expected candidates %v, got %v", want, ambiguous.Candidates)
+	}
+
+	var notFound *agents.NotFoundError
+	for id, want := range map[string]agents.NotFoundError{
+		"missing":            {ID: "missing"},
+		"@agent9/action1":    {ID: "@agent9/action1", Agent: "agent9", Installed: []string{}},
+		"@agent2/action3":    {ID: "@agent2/action3", Agent: "agent2", Installed: []string{"1.0.0"}},
+		"@agent1/action2:^2": {ID: "@agent1/action2:^2", Agent: "agent1", Installed: []string{"1.0.0", "2.0.0", "10.0.0-beta"}},
+	} {
+		_, err := reg.Resolve(id, "", "")
+		if !errors.As(err, &notFound) {
+			t.Errorf("Expected a NotFoundError for %s, got %v", id, err)
+		} else if !reflect.DeepEqual(*notFound, want) {
+			t.Errorf("Expected %+v for %s, got %+v", want, id, *notFound)
+		}
+	}
+	// Hyphens are allowed in actions of qualified IDs only, as in Resource.pkl,
+	// and agent IDs without an action are not action IDs.
+	for _, id := range []string{"@agent1/bad action", "fetch-data", "@my-agent/action1", "@agent1", "@agent1:^1"} {
+		if _, err := reg.Resolve(id, "", ""); err == nil {
+			t.Errorf("Expected an error for the invalid action ID %q", id)
+		}
+	}
+}
+
+// TestAgentsReader tests the agent: resource reader
+func TestAgentsReader(t *testing.T) {
+	reg, err := agents.LoadDir(installAgents(t))
+	if err != nil {
+		t.Fatalf("Failed to load agents: %v", err)
+	}
+	reader := agents.NewReader(reg, agents.WithWorkflow("agent1", "1.0.0"))
+
+	read := func(uri string) (string, error) {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", uri, err)
+		}
+		result, err := reader.Read(*u)
+		return string(result), err
+	}
+
+	for uri, want := range map[string]string{
+		"agent:/action1":                                   "@agent1/action1:1.0.0",
+		"agent:/action1?agent=agent2":                      "@agent2/action1:1.0.0",
+		"agent:/action1?agent=agent1":                      "@agent1/action1:1.0.0",
+		"agent:/action1?agent=agent1&version=2.0.0":        "@agent1/action1:2.0.0",
+		"agent:/%40agent1%2Faction1":                       "@agent1/action1:2.0.0",
+		"agent:/a?op=list&version=1.0.0":                   "",
+		"agent:/agent1?op=list&version=1.0.0":              `["@agent1/action1:1.0.0","@agent1/action2:1.0.0"]`,
+		"agent:/agent2?op=list":                            `["@agent2/action1:1.0.0","@agent2/fetch-data:1.0.0"]`,
+		"agent:/agent1?op=list&agent=agent1&version=1.0.0": `["@agent1/action1:1.0.0","@agent1/action2:1.0.0"]`,
+	} {
+		got, err := read(uri)
+		if want == "" {
+			var notFound *agents.NotFoundError
+			if !errors.As(err, &notFound) {
+				t.Errorf("Expected a NotFoundError for %s, got %v", uri, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed to read %s: %v", uri, err)
+		} else if got != want {
+			t.Errorf("Expected %s for %s, got %s", want, uri, got)
+		}
+	}
+
+	result, err := read("agent:/?op=list-installed")
+	if err != nil {
+		t.Fatalf("Failed to list installed agents: %v", err)
+	}
+	var installed []agents.Agent
+	if err := json.Unmarshal([]byte(result), &installed); err != nil {
+		t.Fatalf("Failed to unmarshal agents: %v", err)
+	}
+	if len(installed) != 4 || installed[0].Name != "agent1" || installed[3].Name != "agent2" {
+		t.Errorf("Expected agent1 (3 versions) and agent2, got %+v", installed)
+	}
+
+	if _, err := read("agent:/agent1?op=list&agent=agent2"); !errors.Is(err, readeruri.ErrConflictingParam) {
+		t.Errorf("Expected %v for an agent param differing from the path, got %v", readeruri.ErrConflictingParam, err)
+	}
+	if _, err := read("agent:/action1?op=resolve"); err == nil {
+		t.Errorf("Expected an error for an unknown op")
+	}
+	if _, err := agents.NewReader(reg).Read(url.URL{Scheme: "agent", Path: "/action1"}); err == nil {
+		t.Errorf("Expected an AmbiguousError without a workflow")
+	}
+}
@@ -51,8 +51,8 @@ func TestPKLFileEmbedding(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to list PKL files: %v", err)
 		}
-		if len(files) != 23 {
-			t.Errorf("Expected 23 PKL files, got %d", len(files))
+		if len(files) != 28 {
+			t.Errorf("Expected 28 PKL files, got %d", len(files))
 		}
 
 		// Check for key files
@@ -188,8 +188,8 @@ func TestPKLWorkspace(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to list workspace files: %v", err)
 		}
-		if len(files) != 23 {
-			t.Errorf("Expected 23 files in workspace, got %d", len(files))
+		if len(files) != 28 {
+			t.Errorf("Expected 28 files in workspace, got %d", len(files))
 		}
 	})
 
@@ -310,8 +310,8 @@ func TestExtractAllPKLFiles(t *testing.T) {
 				pklCount++
 			}
 		}
-		if pklCount != 23 {
-			t.Errorf("Expected 23 PKL files, found %d", pklCount)
+		if pklCount != 28 {
+			t.Errorf("Expected 28 PKL files, found %d", pklCount)
 		}
 	})
 
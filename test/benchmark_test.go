@@ -5,12 +5,11 @@ import (
 	"net/url"
 	"os"
 	"testing"
-
-	"github.com/kdeps/kdeps/pkg/pklres"
 )
 
 // BenchmarkPKLFileEvaluation benchmarks PKL file evaluation performance
 func BenchmarkPKLFileEvaluation(b *testing.B) {
+	RequirePkl(b)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		b.Fatalf("Failed to create evaluator: %v", err)
@@ -92,16 +91,13 @@ func BenchmarkRealPklresReader(b *testing.B) {
 	defer os.Remove(tempDB.Name())
 	tempDB.Close()
 
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		b.Fatalf("Failed to initialize pklres reader: %v", err)
-	}
+	pklresReader := NewTestPklresReader(b, tempDB.Name())
 
 	// Benchmark set operations
 	b.Run("Real_Pklres_Set", func(b *testing.B) {
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			uri, _ := url.Parse(fmt.Sprintf("pklres:/bench-id-%d?op=set&type=exec&key=command&value=echo%%20hello%%20%d", i, i))
+			uri, _ := url.Parse(fmt.Sprintf("pklres://?op=set&collection=bench-id-%d&key=command&value=echo%%20hello%%20%d", i, i))
 			_, err := pklresReader.Read(*uri)
 			if err != nil {
 				b.Fatalf("Failed to set pklres resource: %v", err)
@@ -113,7 +109,7 @@ func BenchmarkRealPklresReader(b *testing.B) {
 	b.Run("Real_Pklres_Get", func(b *testing.B) {
 		// Pre-populate some data
 		for i := 0; i < 100; i++ {
-			uri, _ := url.Parse(fmt.Sprintf("pklres:/bench-id-%d?op=set&type=exec&key=command&value=echo%%20hello%%20%d", i, i))
+			uri, _ := url.Parse(fmt.Sprintf("pklres://?op=set&collection=bench-id-%d&key=command&value=echo%%20hello%%20%d", i, i))
 			_, err := pklresReader.Read(*uri)
 			if err != nil {
 				b.Fatalf("Failed to set pklres resource: %v", err)
@@ -122,7 +118,7 @@ func BenchmarkRealPklresReader(b *testing.B) {
 
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			uri, _ := url.Parse(fmt.Sprintf("pklres:/bench-id-%d?op=get&type=exec&key=command", i%100))
+			uri, _ := url.Parse(fmt.Sprintf("pklres://?op=get&collection=bench-id-%d&key=command", i%100))
 			_, err := pklresReader.Read(*uri)
 			if err != nil {
 				b.Fatalf("Failed to get pklres resource: %v", err)
@@ -134,7 +130,7 @@ func BenchmarkRealPklresReader(b *testing.B) {
 	b.Run("Real_Pklres_List", func(b *testing.B) {
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			uri, _ := url.Parse("pklres:/?op=list&type=exec")
+			uri, _ := url.Parse("pklres://?op=list&collection=bench-id-0")
 			_, err := pklresReader.Read(*uri)
 			if err != nil {
 				b.Fatalf("Failed to list pklres resources: %v", err)
@@ -152,10 +148,7 @@ func BenchmarkConcurrentOperations(b *testing.B) {
 	defer os.Remove(tempDB.Name())
 	tempDB.Close()
 
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		b.Fatalf("Failed to initialize pklres reader: %v", err)
-	}
+	pklresReader := NewTestPklresReader(b, tempDB.Name())
 
 	// Benchmark concurrent set operations
 	b.Run("Concurrent_Set", func(b *testing.B) {
@@ -163,7 +156,7 @@ func BenchmarkConcurrentOperations(b *testing.B) {
 		b.RunParallel(func(pb *testing.PB) {
 			i := 0
 			for pb.Next() {
-				uri, _ := url.Parse(fmt.Sprintf("pklres:/concurrent-id-%d?op=set&type=exec&key=command&value=echo%%20hello%%20%d", i, i))
+				uri, _ := url.Parse(fmt.Sprintf("pklres://?op=set&collection=concurrent-id-%d&key=command&value=echo%%20hello%%20%d", i, i))
 				_, err := pklresReader.Read(*uri)
 				if err != nil {
 					b.Fatalf("Failed to set pklres resource: %v", err)
@@ -177,7 +170,7 @@ func BenchmarkConcurrentOperations(b *testing.B) {
 	b.Run("Concurrent_Get", func(b *testing.B) {
 		// Pre-populate data
 		for i := 0; i < 1000; i++ {
-			uri, _ := url.Parse(fmt.Sprintf("pklres:/concurrent-get-id-%d?op=set&type=exec&key=command&value=echo%%20hello%%20%d", i, i))
+			uri, _ := url.Parse(fmt.Sprintf("pklres://?op=set&collection=concurrent-get-id-%d&key=command&value=echo%%20hello%%20%d", i, i))
 			_, err := pklresReader.Read(*uri)
 			if err != nil {
 				b.Fatalf("Failed to set pklres resource: %v", err)
@@ -188,7 +181,7 @@ func BenchmarkConcurrentOperations(b *testing.B) {
 		b.RunParallel(func(pb *testing.PB) {
 			i := 0
 			for pb.Next() {
-				uri, _ := url.Parse(fmt.Sprintf("pklres:/concurrent-get-id-%d?op=get&type=exec&key=command", i%1000))
+				uri, _ := url.Parse(fmt.Sprintf("pklres://?op=get&collection=concurrent-get-id-%d&key=command", i%1000))
 				_, err := pklresReader.Read(*uri)
 				if err != nil {
 					b.Fatalf("Failed to get pklres resource: %v", err)
@@ -202,6 +195,7 @@ func BenchmarkConcurrentOperations(b *testing.B) {
 // BenchmarkPKLEvaluatorCreation benchmarks evaluator creation performance
 func BenchmarkPKLEvaluatorCreation(b *testing.B) {
 	b.Run("Mock_Readers", func(b *testing.B) {
+		RequirePkl(b)
 		for i := 0; i < b.N; i++ {
 			evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 			if err != nil {
@@ -212,6 +206,7 @@ func BenchmarkPKLEvaluatorCreation(b *testing.B) {
 	})
 
 	b.Run("Real_Readers", func(b *testing.B) {
+		RequirePkl(b)
 		for i := 0; i < b.N; i++ {
 			tempDB, err := os.CreateTemp("", "pklres-bench-*.db")
 			if err != nil {
@@ -220,10 +215,7 @@ func BenchmarkPKLEvaluatorCreation(b *testing.B) {
 			tempDB.Close()
 			defer os.Remove(tempDB.Name())
 
-			pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-			if err != nil {
-				b.Fatalf("Failed to initialize pklres reader: %v", err)
-			}
+			pklresReader := NewTestPklresReader(b, tempDB.Name())
 
 			evaluator, err := NewTestEvaluator(&AgentResourceReader{}, pklresReader)
 			if err != nil {
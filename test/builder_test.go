@@ -0,0 +1,315 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+	resourcebuilder "github.com/kdeps/schema/builder/resource"
+	workflowbuilder "github.com/kdeps/schema/builder/workflow"
+	"github.com/kdeps/schema/gen/project/buildenv"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/jsonschema"
+	"github.com/kdeps/schema/validate"
+)
+
+// TestWorkflowBuilder tests workflow defaults, setters and validation
+func TestWorkflowBuilder(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		wf, err := workflowbuilder.New("myAgent").Target("answer").Build()
+		if err != nil {
+			t.Fatalf("Failed to build workflow: %v", err)
+		}
+		if wf.Version != "1.0.0" || wf.Workflows == nil {
+			t.Errorf("Unexpected defaults %+v", wf)
+		}
+		if *wf.Settings.Environment != buildenv.Dev || *wf.Settings.RateLimitMax != 5 {
+			t.Errorf("Unexpected default settings %+v", wf.Settings)
+		}
+	})
+
+	t.Run("Setters", func(t *testing.T) {
+		b := workflowbuilder.New("myAgent").
+			Version("1.2.0").
+			Target("answer").
+			Authors("a", "b").
+			Workflows("@search/query:1.0.0").
+			Environment(buildenv.Prod)
+		wf, err := b.Build()
+		if err != nil {
+			t.Fatalf("Failed to build workflow: %v", err)
+		}
+		if wf.Version != "1.2.0" || wf.TargetActionID != "answer" || len(*wf.Authors) != 2 {
+			t.Errorf("Unexpected workflow %+v", wf)
+		}
+		if *wf.Settings.Environment != buildenv.Prod {
+			t.Errorf("Expected prod environment, got %s", *wf.Settings.Environment)
+		}
+
+		b.Workflows("@other/action:1.0.0")
+		if !reflect.DeepEqual(wf.Workflows, []string{"@search/query:1.0.0"}) {
+			t.Errorf("Built workflow should not change with the builder, got %v", wf.Workflows)
+		}
+	})
+
+	t.Run("Reuse", func(t *testing.T) {
+		b := workflowbuilder.New("myAgent").Target("answer").Authors("a")
+		w1, err := b.Build()
+		if err != nil {
+			t.Fatalf("Failed to build workflow: %v", err)
+		}
+		b.Authors("b").Environment(buildenv.Prod).RateLimit(10)
+		if _, err := b.Build(); err != nil {
+			t.Fatalf("Failed to rebuild workflow: %v", err)
+		}
+		if !reflect.DeepEqual(*w1.Authors, []string{"a"}) {
+			t.Errorf("Expected authors [a], got %v", *w1.Authors)
+		}
+		if *w1.Settings.Environment != buildenv.Dev || *w1.Settings.RateLimitMax != 5 {
+			t.Errorf("Expected the default settings, got %+v", w1.Settings)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := workflowbuilder.New("my-agent").Version("v1").Target("answer").Build()
+		var errs validate.Errors
+		if !errors.As(err, &errs) {
+			t.Fatalf("Expected validate.Errors, got %v", err)
+		}
+		if !reflect.DeepEqual(errs.Paths(), []string{"AgentID", "Version"}) {
+			t.Errorf("Unexpected error paths %v", errs.Paths())
+		}
+	})
+}
+
+// TestResourceBuilder tests action defaults, setters and validation
+func TestResourceBuilder(t *testing.T) {
+	t.Run("Chat", func(t *testing.T) {
+		res, err := resourcebuilder.New("answer").
+			Requires("fetch").
+			Chat(resourcebuilder.Chat("Summarize").Timeout(2 * time.Minute).JSONResponse("summary")).
+			SkipIf(false).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build resource: %v", err)
+		}
+		chat := res.Run.Chat
+		if *chat.Model != "llama3.2" || *chat.Prompt != "Summarize" || !*chat.JSONResponse {
+			t.Errorf("Unexpected chat %+v", chat)
+		}
+		if *chat.TimeoutDuration != (pkl.Duration{Value: 120, Unit: pkl.Second}) {
+			t.Errorf("Expected 120.s timeout, got %v", *chat.TimeoutDuration)
+		}
+		if !reflect.DeepEqual(*res.Requires, []string{"fetch"}) || len(*res.Run.SkipCondition) != 1 {
+			t.Errorf("Unexpected resource %+v", res)
+		}
+	})
+
+	t.Run("Actions", func(t *testing.T) {
+		action, err := resourcebuilder.NewAction().
+			HTTP(resourcebuilder.HTTP("GET", "https://example.com").Header("Accept", "application/json").Timeout(1500 * time.Millisecond)).
+			Exec(resourcebuilder.Exec("ls").Env("HOME", "/root")).
+			Python(resourcebuilder.Python("print(1)").Environment("base")).
+			Response(resourcebuilder.Response("ok").Error(500, "failed").Success(false)).
+			Preflight(resourcebuilder.NewCheck(true).Retry(5)).
+			Postflight(resourcebuilder.NewCheck(true)).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build action: %v", err)
+		}
+		if *action.HTTPClient.TimeoutDuration != (pkl.Duration{Value: 1500, Unit: pkl.Millisecond}) {
+			t.Errorf("Expected 1500.ms timeout, got %v", *action.HTTPClient.TimeoutDuration)
+		}
+		if (*action.HTTPClient.Headers)["Accept"] != "application/json" || (*action.Exec.Env)["HOME"] != "/root" {
+			t.Errorf("Unexpected headers or env %+v %+v", action.HTTPClient, action.Exec)
+		}
+		if *action.PreflightCheck.RetryTimes != 5 || *action.PostflightCheck.RetryTimes != 3 || *action.PostflightCheck.Retry {
+			t.Errorf("Unexpected checks %+v %+v", action.PreflightCheck, action.PostflightCheck)
+		}
+		if (*action.APIResponse).GetSuccess() == nil || *(*action.APIResponse).GetSuccess() {
+			t.Error("Expected an unsuccessful API response")
+		}
+	})
+
+	t.Run("Reuse", func(t *testing.T) {
+		b := resourcebuilder.New("answer").Requires("a").Items("x").HTTP(resourcebuilder.HTTP("GET", "https://example.com"))
+		r1, err := b.Build()
+		if err != nil {
+			t.Fatalf("Failed to build resource: %v", err)
+		}
+		b.Requires("b").Items("y").SkipIf(true).Exec(resourcebuilder.Exec("ls"))
+		if _, err := b.Build(); err != nil {
+			t.Fatalf("Failed to rebuild resource: %v", err)
+		}
+		if !reflect.DeepEqual(*r1.Requires, []string{"a"}) || !reflect.DeepEqual(*r1.Items, []string{"x"}) {
+			t.Errorf("Expected requires [a] and items [x], got %v and %v", *r1.Requires, *r1.Items)
+		}
+		if r1.Run.SkipCondition != nil || r1.Run.Exec != nil {
+			t.Errorf("Expected the first Run block, got %+v", r1.Run)
+		}
+
+		action := resourcebuilder.NewAction().AllowedHeaders("Accept")
+		a1, err := action.Build()
+		if err != nil {
+			t.Fatalf("Failed to build action: %v", err)
+		}
+		action.AllowedHeaders("Authorization")
+		if !reflect.DeepEqual(*a1.AllowedHeaders, []string{"Accept"}) {
+			t.Errorf("Expected allowed headers [Accept], got %v", *a1.AllowedHeaders)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := resourcebuilder.New("answer").
+			Requires("bad dep").
+			HTTP(resourcebuilder.HTTP("FETCH", "https://example.com")).
+			Exec(resourcebuilder.Exec("ls").Env("1BAD", "x")).
+			Build()
+		var errs validate.Errors
+		if !errors.As(err, &errs) {
+			t.Fatalf("Expected validate.Errors, got %v", err)
+		}
+		want := []string{"Requires[0]", `Run.Exec.Env["1BAD"]`, "Run.HTTPClient.Method"}
+		if !reflect.DeepEqual(errs.Paths(), want) {
+			t.Errorf("Expected error paths %v, got %v", want, errs.Paths())
+		}
+	})
+}
+
+// TestBuilderDefaults tests that builders set every literal default of the PKL schema
+func TestBuilderDefaults(t *testing.T) {
+	wf, err := workflowbuilder.New("myAgent").Target("answer").Build()
+	if err != nil {
+		t.Fatalf("Failed to build workflow: %v", err)
+	}
+	action, err := resourcebuilder.NewAction().
+		Chat(resourcebuilder.Chat("p")).
+		Exec(resourcebuilder.Exec("ls")).
+		Python(resourcebuilder.Python("print(1)")).
+		HTTP(resourcebuilder.HTTP("GET", "https://example.com")).
+		Response(resourcebuilder.Response()).
+		Preflight(resourcebuilder.NewCheck()).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build action: %v", err)
+	}
+
+	g, err := jsonschema.NewGenerator()
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	for name, v := range map[string]any{
+		"Workflow":          wf,
+		"Settings":          wf.Settings,
+		"ResourceChat":      action.Chat,
+		"ResourceExec":      action.Exec,
+		"ResourcePython":    action.Python,
+		"ResourceHTTP":      action.HTTPClient,
+		"APIServerResponse": *action.APIResponse,
+		"ValidationCheck":   action.PreflightCheck,
+	} {
+		t.Run(name, func(t *testing.T) {
+			value := reflect.ValueOf(v).Elem()
+			s, err := g.Generate(value.Type())
+			if err != nil {
+				t.Fatalf("Failed to generate schema: %v", err)
+			}
+			for prop, ps := range s.Properties {
+				if ps.Default == nil {
+					continue
+				}
+				got := defaultOf(value.FieldByName(prop))
+				if got != fmt.Sprint(ps.Default) {
+					t.Errorf("%s defaults to %v in PKL, builder sets %s", prop, ps.Default, got)
+				}
+			}
+		})
+	}
+}
+
+func defaultOf(field reflect.Value) string {
+	for field.Kind() == reflect.Pointer && !field.IsNil() {
+		field = field.Elem()
+	}
+	switch v := field.Interface().(type) {
+	case pkl.Duration:
+		return fmt.Sprintf("%g.%s", v.Value, v.Unit)
+	default:
+		if field.Kind() == reflect.Pointer {
+			return "<nil>"
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// TestBuilderEvaluationParity tests that built values equal the ones PKL evaluates
+func TestBuilderEvaluationParity(t *testing.T) {
+	ctx := context.Background()
+	evaluator, err := assets.NewEvaluator(ctx)
+	if err != nil {
+		t.Skipf("PKL evaluator not available: %v", err)
+	}
+	defer evaluator.Close()
+
+	load := func(t *testing.T, source string, out any) {
+		if err := evaluator.EvaluateModule(ctx, pkl.TextSource(source), out); err != nil {
+			if strings.Contains(err.Error(), "package://") {
+				t.Skipf("Package dependencies not resolvable offline: %v", err)
+			}
+			t.Fatalf("Failed to evaluate: %v", err)
+		}
+	}
+
+	t.Run("Workflow", func(t *testing.T) {
+		var want workflow.WorkflowImpl
+		load(t, `
+amends "kdeps-schema:/Workflow.pkl"
+
+AgentID = "myAgent"
+TargetActionID = "answer"
+Workflows { "@search/query:1.0.0" }
+`, &want)
+		got, err := workflowbuilder.New("myAgent").Target("answer").Workflows("@search/query:1.0.0").Build()
+		if err != nil {
+			t.Fatalf("Failed to build workflow: %v", err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("Built workflow differs from PKL:\ngot  %+v\nwant %+v", *got, want)
+		}
+	})
+
+	t.Run("Resource", func(t *testing.T) {
+		var want resource.ResourceImpl
+		load(t, `
+amends "kdeps-schema:/Resource.pkl"
+
+ActionID = "answer"
+Requires { "fetch" }
+Run {
+  Chat { Prompt = "Summarize" }
+  Exec { Command = "ls" }
+  PreflightCheck { Validations { true } }
+}
+`, &want)
+		got, err := resourcebuilder.New("answer").
+			Requires("fetch").
+			Run(resourcebuilder.NewAction().
+				Chat(resourcebuilder.Chat("Summarize")).
+				Exec(resourcebuilder.Exec("ls")).
+				Preflight(resourcebuilder.NewCheck(true))).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build resource: %v", err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("Built resource differs from PKL:\ngot  %+v\nwant %+v", *got.Run, *want.Run)
+		}
+	})
+}
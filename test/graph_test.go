@@ -0,0 +1,201 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	resourcebuilder "github.com/kdeps/schema/builder/resource"
+	workflowbuilder "github.com/kdeps/schema/builder/workflow"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/graph"
+	"github.com/kdeps/schema/loader"
+)
+
+// graphResources builds resources from action IDs and their dependencies.
+func graphResources(t *testing.T, deps map[string][]string) []resource.Resource {
+	var resources []resource.Resource
+	for id, requires := range deps {
+		r, err := resourcebuilder.New(id).Requires(requires...).Build()
+		if err != nil {
+			t.Fatalf("Failed to build resource %s: %v", id, err)
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+func newTestGraph(t *testing.T, target string, deps map[string][]string) *graph.Graph {
+	wf, err := workflowbuilder.New("myAgent").Target(target).Build()
+	if err != nil {
+		t.Fatalf("Failed to build workflow: %v", err)
+	}
+	return graph.New(wf, graphResources(t, deps)...)
+}
+
+// TestGraphOrder tests topological order and layers
+func TestGraphOrder(t *testing.T) {
+	g := newTestGraph(t, "answer", map[string][]string{
+		"auth":   nil,
+		"config": nil,
+		"fetch":  {"auth", "@myAgent/config:1.0.0"},
+		"search": {"auth", "@search/query:1.0.0"},
+		"answer": {"fetch", "search", "fetch"},
+	})
+	if err := g.Validate(); err != nil {
+		t.Fatalf("Expected a valid graph: %v", err)
+	}
+
+	layers, err := g.Layers()
+	if err != nil {
+		t.Fatalf("Failed to compute layers: %v", err)
+	}
+	want := [][]string{{"auth", "config"}, {"fetch", "search"}, {"answer"}}
+	if !reflect.DeepEqual(layers, want) {
+		t.Errorf("Expected layers %v, got %v", want, layers)
+	}
+	order, err := g.Order()
+	if err != nil {
+		t.Fatalf("Failed to compute order: %v", err)
+	}
+	if !reflect.DeepEqual(order, []string{"auth", "config", "fetch", "search", "answer"}) {
+		t.Errorf("Unexpected order %v", order)
+	}
+
+	if n := g.Node("@myAgent/search"); n == nil || !reflect.DeepEqual(n.External, []string{"@search/query:1.0.0"}) {
+		t.Errorf("Expected search to have an external dependency, got %+v", n)
+	}
+	if deps := g.Node("answer").Requires; !reflect.DeepEqual(deps, []string{"fetch", "search"}) {
+		t.Errorf("Expected repeated dependencies once, got %v", deps)
+	}
+	if dependents := g.Dependents("auth"); !reflect.DeepEqual(dependents, []string{"fetch", "search"}) {
+		t.Errorf("Unexpected dependents of auth %v", dependents)
+	}
+}
+
+// TestGraphProblems tests cycle, missing, duplicate and reachability analysis
+func TestGraphProblems(t *testing.T) {
+	t.Run("Cycle", func(t *testing.T) {
+		g := newTestGraph(t, "answer", map[string][]string{
+			"a":      {"b"},
+			"b":      {"c"},
+			"c":      {"a"},
+			"answer": {"a"},
+		})
+		cycles := g.Cycles()
+		if len(cycles) != 1 || !reflect.DeepEqual(cycles[0].Path, []string{"a", "b", "c", "a"}) {
+			t.Fatalf("Expected cycle a -> b -> c -> a, got %v", cycles)
+		}
+		if _, err := g.Layers(); !errors.As(err, new(*graph.CycleError)) {
+			t.Errorf("Expected a CycleError from Layers, got %v", err)
+		}
+		if _, err := g.Order(); err == nil {
+			t.Error("Expected an error from Order")
+		}
+	})
+
+	t.Run("UnreachableCycle", func(t *testing.T) {
+		g := newTestGraph(t, "answer", map[string][]string{
+			"a":      {"b"},
+			"b":      {"a"},
+			"answer": nil,
+		})
+		if _, err := g.Layers(); err != nil {
+			t.Errorf("A cycle the target does not depend on should not prevent ordering: %v", err)
+		}
+		var unreachable []string
+		for _, e := range g.Unreachable() {
+			unreachable = append(unreachable, e.ActionID)
+		}
+		if !reflect.DeepEqual(unreachable, []string{"a", "b"}) {
+			t.Errorf("Expected a and b unreachable, got %v", unreachable)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		g := newTestGraph(t, "answer", map[string][]string{
+			"fetch": {"auth"},
+		})
+		var got []string
+		for _, e := range g.Missing() {
+			got = append(got, e.Error())
+		}
+		want := []string{
+			`target action "answer" has no resource`,
+			`resource "fetch" requires "auth", which has no resource`,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		wf, err := workflowbuilder.New("myAgent").Target("answer").Build()
+		if err != nil {
+			t.Fatalf("Failed to build workflow: %v", err)
+		}
+		resources := graphResources(t, map[string][]string{"answer": nil})
+		g := graph.New(wf, append(resources, resources[0], resources[0])...)
+		dups := g.Duplicates()
+		if len(dups) != 1 || dups[0].ActionID != "answer" || dups[0].Count != 3 {
+			t.Errorf("Expected answer declared 3 times, got %v", dups)
+		}
+
+		err = g.Validate()
+		var errs graph.Errors
+		if !errors.As(err, &errs) || len(errs) != 1 || !errors.As(err, new(*graph.DuplicateError)) {
+			t.Errorf("Expected a single DuplicateError, got %v", err)
+		}
+	})
+}
+
+// TestGraphLoad tests loading an agent directory through a loader
+func TestGraphLoad(t *testing.T) {
+	ctx := context.Background()
+	wf, err := workflowbuilder.New("myAgent").Target("answer").Build()
+	if err != nil {
+		t.Fatalf("Failed to build workflow: %v", err)
+	}
+	answer, err := resourcebuilder.New("answer").Requires("fetch").Build()
+	if err != nil {
+		t.Fatalf("Failed to build resource: %v", err)
+	}
+	fetch, err := resourcebuilder.New("fetch").Build()
+	if err != nil {
+		t.Fatalf("Failed to build resource: %v", err)
+	}
+	values := map[string]any{"workflow": wf, "answer": answer, "fetch": fetch}
+
+	factory := &fakeEvaluatorFactory{values: values}
+	l, err := loader.New(ctx, loader.WithEvaluatorFunc(factory.New))
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+	defer l.Close()
+
+	fsys := fstest.MapFS{
+		"workflow.pkl":           {Data: []byte("workflow")},
+		"resources/answer.pkl":   {Data: []byte("answer")},
+		"resources/fetch.pkl":    {Data: []byte("fetch")},
+		"resources/fetch2.pkl":   {Data: []byte("fetch")},
+		"resources/notes/readme": {Data: []byte("ignored")},
+	}
+	g, err := graph.Load(ctx, l, fsys)
+	if err != nil {
+		t.Fatalf("Failed to load graph: %v", err)
+	}
+	if g.AgentID != "myAgent" || g.Target != "answer" || g.Node("answer").File != "resources/answer.pkl" {
+		t.Errorf("Unexpected graph %+v", g.Nodes())
+	}
+	dups := g.Duplicates()
+	if len(dups) != 1 || !reflect.DeepEqual(dups[0].Files, []string{"resources/fetch.pkl", "resources/fetch2.pkl"}) {
+		t.Errorf("Expected fetch duplicated in two files, got %v", dups)
+	}
+
+	delete(fsys, "workflow.pkl")
+	if _, err := graph.Load(ctx, l, fsys); err == nil {
+		t.Error("Expected error for a directory without workflow.pkl")
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kdeps/schema/assets"
+)
+
+// TestImportGraph tests the import graph of the embedded schema
+func TestImportGraph(t *testing.T) {
+	graph, err := assets.ImportGraph()
+	if err != nil {
+		t.Fatalf("Failed to build import graph: %v", err)
+	}
+
+	t.Run("Modules", func(t *testing.T) {
+		files, _ := assets.ListPKLFiles()
+		if len(graph.Modules()) != len(files) {
+			t.Errorf("Expected %d modules, got %d", len(files), len(graph.Modules()))
+		}
+	})
+
+	t.Run("ImportKinds", func(t *testing.T) {
+		var sawAlias, sawExtends bool
+		for _, imp := range graph.Imports("Exec.pkl") {
+			if imp.URI == "Utils.pkl" && imp.Clause == "extends" {
+				sawExtends = true
+			}
+		}
+		for _, imp := range graph.Imports("Resource.pkl") {
+			if imp.Alias == "utils" && imp.Kind == assets.ImportLocal {
+				sawAlias = true
+			}
+		}
+		if !sawExtends {
+			t.Error("Exec.pkl should extend Utils.pkl")
+		}
+		if !sawAlias {
+			t.Error("Resource.pkl should have an aliased local import")
+		}
+
+		stdlib := graph.ImportsOfKind(assets.ImportStdlib)
+		if !contains(stdlib, "pkl:json") {
+			t.Errorf("Expected pkl:json in stdlib imports, got %v", stdlib)
+		}
+		packages := graph.ImportsOfKind(assets.ImportPackage)
+		if !contains(packages, "package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.10.0#/go.pkl") {
+			t.Errorf("Expected pkl-go package import, got %v", packages)
+		}
+	})
+
+	t.Run("Resolved", func(t *testing.T) {
+		if unresolved := graph.Unresolved(); len(unresolved) > 0 {
+			t.Errorf("Expected every local import to resolve, got %+v", unresolved)
+		}
+	})
+
+	t.Run("TopologicalOrder", func(t *testing.T) {
+		order, err := graph.TopologicalOrder()
+		if err != nil {
+			t.Fatalf("Embedded schema should be acyclic: %v", err)
+		}
+		position := make(map[string]int, len(order))
+		for i, m := range order {
+			position[m] = i
+		}
+		for _, m := range order {
+			for _, dep := range graph.Dependencies(m) {
+				if position[dep] >= position[m] {
+					t.Errorf("%s should come before %s", dep, m)
+				}
+			}
+		}
+	})
+
+	t.Run("Closure", func(t *testing.T) {
+		closure, err := graph.Closure("Resource.pkl")
+		if err != nil {
+			t.Fatalf("Failed to compute closure: %v", err)
+		}
+		if closure[len(closure)-1] != "Resource.pkl" {
+			t.Errorf("Entry module should come last, got %v", closure)
+		}
+		if !contains(closure, "Utils.pkl") {
+			t.Errorf("Closure should include Utils.pkl, got %v", closure)
+		}
+		if _, err := graph.Closure("Missing.pkl"); err == nil {
+			t.Error("Expected error for an unknown module")
+		}
+	})
+
+	t.Run("ExtractSubset", func(t *testing.T) {
+		dir, err := assets.ExtractPKLSubsetToDir(t.TempDir(), "Workflow.pkl")
+		if err != nil {
+			t.Fatalf("Failed to extract subset: %v", err)
+		}
+		closure, _ := graph.Closure("Workflow.pkl")
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != len(closure) {
+			t.Errorf("Expected %d files, got %d", len(closure), len(entries))
+		}
+		if _, err := os.Stat(filepath.Join(dir, "Workflow.pkl")); err != nil {
+			t.Errorf("Workflow.pkl should be extracted: %v", err)
+		}
+	})
+}
+
+// TestImportGraphCycles tests cycle detection on a synthetic schema
+func TestImportGraphCycles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A.pkl": &fstest.MapFile{Data: []byte("module A\nimport \"B.pkl\" as b\n")},
+		"B.pkl": &fstest.MapFile{Data: []byte("module B\nextends \"C.pkl\"\n")},
+		"C.pkl": &fstest.MapFile{Data: []byte("module C\nimport* \"A.pkl\"\n")},
+		"D.pkl": &fstest.MapFile{Data: []byte("module D\namends \"A.pkl\"\n// import \"E.pkl\"\nimport \"Missing.pkl\"\n")},
+		"E.pkl": &fstest.MapFile{Data: []byte("module E\nimport \"F.pkl\"\n")},
+		"F.pkl": &fstest.MapFile{Data: []byte("module F\n")},
+	}
+	graph, err := assets.BuildImportGraph(fsys)
+	if err != nil {
+		t.Fatalf("Failed to build import graph: %v", err)
+	}
+
+	cycles := graph.Cycles()
+	if len(cycles) != 1 {
+		t.Fatalf("Expected one cycle, got %v", cycles)
+	}
+	if len(cycles[0]) != 4 || cycles[0][0] != cycles[0][3] {
+		t.Errorf("Expected a closed three-module cycle, got %v", cycles[0])
+	}
+
+	_, err = graph.TopologicalOrder()
+	var cycleErr *assets.CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Errorf("Expected CycleError, got %v", err)
+	}
+
+	if deps := graph.Dependents("A.pkl"); len(deps) != 2 {
+		t.Errorf("Expected C.pkl and D.pkl to depend on A.pkl, got %v", deps)
+	}
+	unresolved := graph.Unresolved()
+	if len(unresolved) != 1 || unresolved[0].URI != "Missing.pkl" || unresolved[0].Line != 4 {
+		t.Errorf("Expected Missing.pkl to be unresolved, got %+v", unresolved)
+	}
+
+	closure, err := graph.Closure("D.pkl")
+	if err != nil || len(closure) != 4 {
+		t.Errorf("Expected closure of all four modules, got %v (%v)", closure, err)
+	}
+
+	// Cycles elsewhere in the graph do not affect the order of a closure.
+	closure, err = graph.Closure("E.pkl")
+	if err != nil || !reflect.DeepEqual(closure, []string{"F.pkl", "E.pkl"}) {
+		t.Errorf("Expected [F.pkl E.pkl], got %v (%v)", closure, err)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
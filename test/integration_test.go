@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/apple/pkl-go/pkl"
-	"github.com/kdeps/kdeps/pkg/pklres"
 )
 
 // TestIntegrationSuite runs all integration tests with comprehensive reporting
@@ -98,11 +97,9 @@ func TestIntegrationSuite(t *testing.T) {
 		defer os.Remove(tempDB.Name())
 		tempDB.Close()
 
-		pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-		if err != nil {
-			t.Fatalf("Failed to initialize pklres reader: %v", err)
-		}
+		pklresReader := NewTestPklresReader(t, tempDB.Name())
 
+		RequirePkl(t)
 		evaluator, err := NewTestEvaluator(&AgentResourceReader{}, pklresReader)
 		if err != nil {
 			t.Fatalf("Failed to create PKL evaluator: %v", err)
@@ -196,27 +193,24 @@ func testRealPklresReader(t *testing.T) error {
 	tempDB.Close()
 
 	// Initialize real pklres reader
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		return err
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
 
 	// Test set operation
-	setURI, _ := url.Parse("pklres:/real-test-id?op=set&type=exec&key=command&value=echo%20real%20test")
+	setURI, _ := url.Parse("pklres://?op=set&collection=real-test-id&key=command&value=echo%20real%20test")
 	_, err = pklresReader.Read(*setURI)
 	if err != nil {
 		return err
 	}
 
 	// Test get operation
-	getURI, _ := url.Parse("pklres:/real-test-id?op=get&type=exec&key=command")
+	getURI, _ := url.Parse("pklres://?op=get&collection=real-test-id&key=command")
 	result, err := pklresReader.Read(*getURI)
 	if err != nil {
 		return err
 	}
 
-	if result == nil {
-		return fmt.Errorf("expected non-nil result from real pklres reader")
+	if string(result) != "echo real test" {
+		return fmt.Errorf("expected %q from real pklres reader, got %q", "echo real test", result)
 	}
 
 	return nil
@@ -224,6 +218,7 @@ func testRealPklresReader(t *testing.T) error {
 
 // testPKLFileEvaluation tests PKL file evaluation with various file types
 func testPKLFileEvaluation(t *testing.T) error {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		return err
@@ -285,11 +280,9 @@ func testPKLResourceIntegration(t *testing.T) error {
 	defer os.Remove(tempDB.Name())
 	tempDB.Close()
 
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		return err
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
 
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, pklresReader)
 	if err != nil {
 		return err
@@ -310,6 +303,7 @@ func testPKLResourceIntegration(t *testing.T) error {
 
 // testPKLComplexWorkflows tests complex PKL workflows
 func testPKLComplexWorkflows(t *testing.T) error {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		return err
@@ -346,11 +340,9 @@ func testSchemaValidation(t *testing.T) error {
 	defer os.Remove(tempDB.Name())
 	tempDB.Close()
 
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		return err
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
 
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, pklresReader)
 	if err != nil {
 		return err
@@ -429,11 +421,9 @@ func testImportPathResolution(t *testing.T) error {
 	defer os.Remove(tempDB.Name())
 	tempDB.Close()
 
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		return err
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
 
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, pklresReader)
 	if err != nil {
 		return err
@@ -475,6 +465,7 @@ func testResourceReaderPerformance(t *testing.T) error {
 
 // testPKLEvaluationPerformance tests PKL evaluation performance
 func testPKLEvaluationPerformance(t *testing.T) error {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		return err
@@ -509,16 +500,13 @@ func testConcurrentOperations(t *testing.T) error {
 	defer os.Remove(tempDB.Name())
 	tempDB.Close()
 
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		return err
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
 
 	// Test concurrent set operations
 	done := make(chan error, 10)
 	for i := 0; i < 10; i++ {
 		go func(id int) {
-			uri, _ := url.Parse(fmt.Sprintf("pklres:/concurrent-test-%d?op=set&type=exec&key=command&value=echo%%20concurrent%%20%d", id, id))
+			uri, _ := url.Parse(fmt.Sprintf("pklres://?op=set&collection=concurrent-test-%d&key=command&value=echo%%20concurrent%%20%d", id, id))
 			_, err := pklresReader.Read(*uri)
 			done <- err
 		}(i)
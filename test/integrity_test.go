@@ -0,0 +1,94 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/kdeps/schema/assets"
+)
+
+// TestSchemaIntegrity tests the embedded manifest and tamper detection
+func TestSchemaIntegrity(t *testing.T) {
+	t.Run("ManifestCoversEmbeddedFiles", func(t *testing.T) {
+		manifest, err := assets.LoadManifest()
+		if err != nil {
+			t.Fatalf("Failed to load manifest: %v", err)
+		}
+		if manifest.SchemaVersion != assets.SchemaVersion {
+			t.Errorf("Expected schema version %s, got %s", assets.SchemaVersion, manifest.SchemaVersion)
+		}
+
+		files, _ := assets.ListPKLFiles()
+		if len(manifest.Files) != len(files) {
+			t.Errorf("Manifest lists %d files, %d are embedded", len(manifest.Files), len(files))
+		}
+
+		for _, name := range []string{"Agent.pkl", "Core.pkl", "Common.pkl", "PklResource.pkl", "Validation.pkl"} {
+			entry, ok := manifest.Lookup(name)
+			if !ok {
+				t.Errorf("Manifest should list %s", name)
+				continue
+			}
+			if entry.Module == "" || len(entry.SHA256) != 64 || entry.Size == 0 {
+				t.Errorf("Incomplete manifest entry for %s: %+v", name, entry)
+			}
+		}
+	})
+
+	t.Run("VerifyEmbedded", func(t *testing.T) {
+		if err := assets.VerifyIntegrity(); err != nil {
+			t.Errorf("Embedded schema should match the manifest: %v", err)
+		}
+	})
+
+	t.Run("DetectWorkspaceTampering", func(t *testing.T) {
+		workspace, err := assets.SetupPKLWorkspaceInTmpDir()
+		if err != nil {
+			t.Fatalf("Failed to set up workspace: %v", err)
+		}
+		defer workspace.Cleanup()
+
+		if err := workspace.VerifyIntegrity(); err != nil {
+			t.Fatalf("Fresh workspace should verify: %v", err)
+		}
+
+		if err := os.WriteFile(workspace.GetAbsolutePath("Workflow.pkl"), []byte("module tampered"), 0644); err != nil {
+			t.Fatalf("Failed to modify Workflow.pkl: %v", err)
+		}
+		if err := os.Remove(workspace.GetAbsolutePath("LLM.pkl")); err != nil {
+			t.Fatalf("Failed to remove LLM.pkl: %v", err)
+		}
+		if err := os.WriteFile(workspace.GetAbsolutePath("Extra.pkl"), []byte("module extra"), 0644); err != nil {
+			t.Fatalf("Failed to add Extra.pkl: %v", err)
+		}
+
+		err = workspace.VerifyIntegrity()
+		var integrityErr *assets.IntegrityError
+		if !errors.As(err, &integrityErr) {
+			t.Fatalf("Expected IntegrityError, got %v", err)
+		}
+		if len(integrityErr.Modified) != 1 || integrityErr.Modified[0] != "Workflow.pkl" {
+			t.Errorf("Expected Workflow.pkl to be modified, got %v", integrityErr.Modified)
+		}
+		if len(integrityErr.Missing) != 1 || integrityErr.Missing[0] != "LLM.pkl" {
+			t.Errorf("Expected LLM.pkl to be missing, got %v", integrityErr.Missing)
+		}
+		if len(integrityErr.Unexpected) != 1 || integrityErr.Unexpected[0] != "Extra.pkl" {
+			t.Errorf("Expected Extra.pkl to be unexpected, got %v", integrityErr.Unexpected)
+		}
+	})
+
+	t.Run("DetectDrift", func(t *testing.T) {
+		report, err := assets.DetectDrift("..")
+		if err != nil {
+			t.Fatalf("Failed to detect drift: %v", err)
+		}
+		if report.StaleManifest {
+			t.Error("assets/manifest.json is out of date, run `make manifest`")
+		}
+		if report.HasDrift() {
+			t.Logf("Schema drift detected:\n%s", report)
+		}
+	})
+}
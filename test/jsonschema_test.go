@@ -0,0 +1,121 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	apiserver "github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/jsonschema"
+)
+
+// TestJSONSchemaExport tests defaults, enums, patterns and required properties
+func TestJSONSchemaExport(t *testing.T) {
+	t.Run("Workflow", func(t *testing.T) {
+		s, err := jsonschema.For[workflow.WorkflowImpl]()
+		if err != nil {
+			t.Fatalf("Failed to generate schema: %v", err)
+		}
+		if s.Schema != jsonschema.Draft || s.Title != "Workflow" || s.AdditionalProperties != false {
+			t.Errorf("Unexpected schema header %q %q %v", s.Schema, s.Title, s.AdditionalProperties)
+		}
+		if !reflect.DeepEqual(s.Required, []string{"AgentID", "TargetActionID"}) {
+			t.Errorf("Unexpected required properties %v", s.Required)
+		}
+		if s.Properties["Version"].Default != "1.0.0" {
+			t.Errorf("Expected Version default 1.0.0, got %v", s.Properties["Version"].Default)
+		}
+
+		agentID := regexp.MustCompile(s.Properties["AgentID"].Pattern)
+		if !agentID.MatchString("myAgent") || agentID.MatchString("my-agent") {
+			t.Errorf("Unexpected AgentID pattern %s", agentID)
+		}
+		workflows := regexp.MustCompile(s.Properties["Workflows"].Items.Pattern)
+		if !workflows.MatchString("@agent/action:1.0.0") || workflows.MatchString("agent") {
+			t.Errorf("Unexpected Workflows pattern %s", workflows)
+		}
+
+		if _, ok := s.Defs["Project.Settings"]; !ok {
+			t.Errorf("Expected Project.Settings in $defs, got %v", s.Defs)
+		}
+	})
+
+	t.Run("APIServerSettings", func(t *testing.T) {
+		s, err := jsonschema.For[apiserver.APIServerSettings]()
+		if err != nil {
+			t.Fatalf("Failed to generate schema: %v", err)
+		}
+		port := s.Properties["PortNum"]
+		if port.Default != int64(3000) || port.Maximum == nil || *port.Maximum != 65535 {
+			t.Errorf("Unexpected PortNum schema %+v", port)
+		}
+		if !reflect.DeepEqual(port.Type, []string{"integer", "null"}) {
+			t.Errorf("Nullable PortNum should accept null, got %v", port.Type)
+		}
+		timeout := s.Properties["TimeoutDuration"]
+		if timeout.Default != "60.s" || !regexp.MustCompile(timeout.Pattern).MatchString("2.min") {
+			t.Errorf("Unexpected TimeoutDuration schema %+v", timeout)
+		}
+
+		// (?i:...) has no ECMA-262 equivalent and is expanded into character classes.
+		methods := s.Defs["APIServer.APIServerRoutes"].Properties["Methods"].Items.Pattern
+		re := regexp.MustCompile(methods)
+		if !re.MatchString("get") || !re.MatchString("OPTIONS") || re.MatchString("GETS") {
+			t.Errorf("Unexpected route method pattern %s", methods)
+		}
+	})
+
+	t.Run("Enums", func(t *testing.T) {
+		s, err := jsonschema.For[project.Settings]()
+		if err != nil {
+			t.Fatalf("Failed to generate schema: %v", err)
+		}
+		env := s.Properties["Environment"]
+		if !reflect.DeepEqual(env.Enum, []any{"dev", "prod", nil}) || env.Default != "dev" {
+			t.Errorf("Unexpected Environment schema %+v", env)
+		}
+		serverType := s.Defs["WebServer.WebServerRoutes"].Properties["ServerType"]
+		if !reflect.DeepEqual(serverType.Enum, []any{"static", "app", nil}) {
+			t.Errorf("Unexpected ServerType enum %v", serverType.Enum)
+		}
+		if s.Properties["AgentSettings"].AnyOf == nil {
+			t.Error("Nullable class properties should be an anyOf of the class and null")
+		}
+	})
+
+	t.Run("NotGenerated", func(t *testing.T) {
+		if _, err := jsonschema.For[struct{ Name string }](); err == nil {
+			t.Error("Expected error for a type without a PKL module")
+		}
+	})
+}
+
+// TestJSONSchemaFilesUpToDate tests that jsonschema/schemas matches the generator
+func TestJSONSchemaFilesUpToDate(t *testing.T) {
+	schemas, err := jsonschema.All()
+	if err != nil {
+		t.Fatalf("Failed to generate schemas: %v", err)
+	}
+	if len(schemas) != len(jsonschema.Roots) {
+		t.Errorf("Expected %d schemas, got %d", len(jsonschema.Roots), len(schemas))
+	}
+	for name, s := range schemas {
+		want, err := s.MarshalIndent()
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", name, err)
+		}
+		got, err := os.ReadFile(filepath.Join("..", "jsonschema", "schemas", jsonschema.Filename(name)))
+		if err != nil {
+			t.Errorf("Failed to read %s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date, run `make jsonschema`", jsonschema.Filename(name))
+		}
+	}
+}
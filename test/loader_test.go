@@ -0,0 +1,304 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/loader"
+)
+
+// sourceModule records the source text a fakeEvaluator was asked to evaluate.
+type sourceModule struct {
+	Source string
+}
+
+// fakeEvaluator resolves module URIs through the configured module readers and
+// returns the module text instead of evaluating it, or the prepared value of the
+// text if values is set.
+type fakeEvaluator struct {
+	pkl.Evaluator
+	options pkl.EvaluatorOptions
+	values  map[string]any
+	active  *int32
+	peak    *int32
+	closed  atomic.Bool
+
+	mu   sync.Mutex
+	uris []string
+}
+
+func (e *fakeEvaluator) EvaluateModule(_ context.Context, source *pkl.ModuleSource, out any) error {
+	n := atomic.AddInt32(e.active, 1)
+	defer atomic.AddInt32(e.active, -1)
+	for {
+		p := atomic.LoadInt32(e.peak)
+		if n <= p || atomic.CompareAndSwapInt32(e.peak, p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Text sources carry their contents under a repl:text URI.
+	text := source.Contents
+	if source.Uri != nil {
+		e.mu.Lock()
+		e.uris = append(e.uris, source.Uri.String())
+		e.mu.Unlock()
+	}
+	if text == "" && source.Uri != nil {
+		var err error
+		if text, err = e.read(*source.Uri); err != nil {
+			return err
+		}
+	}
+	if e.values == nil {
+		out.(*sourceModule).Source = text
+		return nil
+	}
+	v, ok := e.values[text]
+	if !ok {
+		return errors.New("unexpected module " + text)
+	}
+	reflect.ValueOf(out).Elem().Set(reflect.ValueOf(v).Elem())
+	return nil
+}
+
+func (e *fakeEvaluator) read(uri url.URL) (string, error) {
+	for _, reader := range e.options.ModuleReaders {
+		if reader.Scheme() == uri.Scheme {
+			return reader.Read(uri)
+		}
+	}
+	return "", errors.New("no reader for " + uri.String())
+}
+
+func (e *fakeEvaluator) Close() error {
+	e.closed.Store(true)
+	return nil
+}
+
+func (e *fakeEvaluator) Closed() bool {
+	return e.closed.Load()
+}
+
+// fakeEvaluatorFactory creates fakeEvaluators, which evaluate module text to
+// the pointers in values if it is set.
+type fakeEvaluatorFactory struct {
+	values map[string]any
+
+	mu         sync.Mutex
+	evaluators []*fakeEvaluator
+	active     int32
+	peak       int32
+}
+
+func (f *fakeEvaluatorFactory) New(_ context.Context, opts ...func(*pkl.EvaluatorOptions)) (pkl.Evaluator, error) {
+	ev := &fakeEvaluator{values: f.values, active: &f.active, peak: &f.peak}
+	for _, opt := range opts {
+		opt(&ev.options)
+	}
+	f.mu.Lock()
+	f.evaluators = append(f.evaluators, ev)
+	f.mu.Unlock()
+	return ev, nil
+}
+
+// TestLoaderPool tests evaluator reuse and the pool size limit
+func TestLoaderPool(t *testing.T) {
+	ctx := context.Background()
+	factory := &fakeEvaluatorFactory{}
+	l, err := loader.New(ctx, loader.WithPoolSize(2), loader.WithEvaluatorFunc(factory.New))
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+
+	t.Run("Reuse", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			if _, err := loader.LoadFromText[sourceModule](ctx, l, "value = 1"); err != nil {
+				t.Fatalf("Failed to load: %v", err)
+			}
+		}
+		if l.Size() != 1 {
+			t.Errorf("Sequential loads should reuse one evaluator, got %d", l.Size())
+		}
+	})
+
+	t.Run("Parallel", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := loader.LoadFromBytes[sourceModule](ctx, l, []byte("value = 1")); err != nil {
+					t.Errorf("Failed to load: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if l.Size() > 2 || atomic.LoadInt32(&factory.peak) > 2 {
+			t.Errorf("Pool should not exceed 2 evaluators, got %d (peak %d)", l.Size(), factory.peak)
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		schemes := map[string]bool{}
+		for _, reader := range factory.evaluators[0].options.ModuleReaders {
+			schemes[reader.Scheme()] = true
+		}
+		if !schemes["kdeps-schema"] || !schemes[loader.MountScheme] {
+			t.Errorf("Expected schema and mount readers, got %v", schemes)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		if err := l.Close(); err != nil {
+			t.Fatalf("Failed to close loader: %v", err)
+		}
+		for _, ev := range factory.evaluators {
+			if !ev.Closed() {
+				t.Error("Every evaluator should be closed")
+			}
+		}
+		if _, err := loader.LoadFromText[sourceModule](ctx, l, "value = 1"); !errors.Is(err, loader.ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	})
+}
+
+// TestLoaderFromFS tests loading modules from an in-memory file system
+func TestLoaderFromFS(t *testing.T) {
+	ctx := context.Background()
+	factory := &fakeEvaluatorFactory{}
+	l, err := loader.New(ctx, loader.WithEvaluatorFunc(factory.New))
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+	defer l.Close()
+
+	fsys := fstest.MapFS{
+		"workflow.pkl":          &fstest.MapFile{Data: []byte(`amends "kdeps-schema:/Workflow.pkl"`)},
+		"resources/fetch.pkl":   &fstest.MapFile{Data: []byte(`amends "kdeps-schema:/Resource.pkl"`)},
+		"resources/50% #1?.pkl": &fstest.MapFile{Data: []byte(`amends "kdeps-schema:/Resource.pkl"`)},
+	}
+	module, err := loader.LoadFromFS[sourceModule](ctx, l, fsys, "resources/fetch.pkl")
+	if err != nil {
+		t.Fatalf("Failed to load from FS: %v", err)
+	}
+	if !strings.Contains(module.Source, "Resource.pkl") {
+		t.Errorf("Unexpected module source %q", module.Source)
+	}
+
+	// Names are escaped in the mount URI.
+	module, err = loader.LoadFromFS[sourceModule](ctx, l, fsys, "resources/50% #1?.pkl")
+	if err != nil {
+		t.Fatalf("Failed to load a name with URI delimiters: %v", err)
+	}
+	if !strings.Contains(module.Source, "Resource.pkl") {
+		t.Errorf("Unexpected module source %q", module.Source)
+	}
+
+	if _, err := loader.LoadFromFS[sourceModule](ctx, l, fsys, "../escape.pkl"); err == nil {
+		t.Error("Expected error for an invalid path")
+	}
+
+	// A file system keeps its mount across loads, and others get their own.
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "resources"), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "resources", "fetch.pkl"), []byte(`amends "kdeps-schema:/Resource.pkl"`), 0o644); err != nil {
+		t.Fatalf("Failed to write module: %v", err)
+	}
+	other := fstest.MapFS{"resources/fetch.pkl": fsys["resources/fetch.pkl"]}
+	for _, f := range []fs.FS{fsys, other, fsys, os.DirFS(dir), os.DirFS(dir)} {
+		if _, err := loader.LoadFromFS[sourceModule](ctx, l, f, "resources/fetch.pkl"); err != nil {
+			t.Fatalf("Failed to load from FS: %v", err)
+		}
+	}
+	ev := factory.evaluators[0]
+	ev.mu.Lock()
+	uris := ev.uris
+	ev.mu.Unlock()
+	mount := func(uri string) string {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", uri, err)
+		}
+		id, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		return id
+	}
+	// uris: fetch, 50% #1?, fsys, other, fsys, DirFS, DirFS
+	if len(uris) != 7 {
+		t.Fatalf("Expected 7 loads, got %v", uris)
+	}
+	if mount(uris[0]) != mount(uris[1]) || mount(uris[1]) != mount(uris[2]) || mount(uris[2]) != mount(uris[4]) {
+		t.Errorf("Expected one mount for fsys, got %v", uris)
+	}
+	if mount(uris[3]) == mount(uris[2]) || mount(uris[5]) != mount(uris[6]) || mount(uris[5]) == mount(uris[3]) {
+		t.Errorf("Expected one mount per file system, got %v", uris)
+	}
+}
+
+// TestLoaderWaitsForEvaluator tests that a full pool honours context cancellation
+func TestLoaderWaitsForEvaluator(t *testing.T) {
+	factory := &fakeEvaluatorFactory{}
+	l, err := loader.New(context.Background(), loader.WithPoolSize(1), loader.WithEvaluatorFunc(factory.New))
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+	defer l.Close()
+
+	release := make(chan struct{})
+	go l.Do(context.Background(), func(pkl.Evaluator) error {
+		<-release
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := loader.LoadFromText[sourceModule](ctx, l, "value = 1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded while the pool is busy, got %v", err)
+	}
+	close(release)
+}
+
+// TestLoaderEvaluation tests loading generated types with a real evaluator
+func TestLoaderEvaluation(t *testing.T) {
+	ctx := context.Background()
+	l, err := loader.New(ctx, loader.WithPoolSize(1))
+	if err != nil {
+		t.Skipf("PKL evaluator not available: %v", err)
+	}
+	defer l.Close()
+
+	wf, err := loader.LoadFromText[workflow.WorkflowImpl](ctx, l, `
+amends "kdeps-schema:/Workflow.pkl"
+AgentID = "loaderTest"
+TargetActionID = "answer"
+Workflows {}
+Settings {}
+`)
+	if err != nil {
+		if strings.Contains(err.Error(), "package://") {
+			t.Skipf("Schema package dependencies not available offline: %v", err)
+		}
+		t.Fatalf("Failed to load workflow: %v", err)
+	}
+	if wf.GetAgentID() != "loaderTest" {
+		t.Errorf("Expected AgentID loaderTest, got %s", wf.GetAgentID())
+	}
+}
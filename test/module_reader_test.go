@@ -0,0 +1,130 @@
+package test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+// TestEmbeddedModuleReader tests the in-process kdeps-schema: module reader
+func TestEmbeddedModuleReader(t *testing.T) {
+	reader := assets.NewModuleReader()
+
+	t.Run("Properties", func(t *testing.T) {
+		if reader.Scheme() != assets.ModuleScheme {
+			t.Errorf("Expected scheme %s, got %s", assets.ModuleScheme, reader.Scheme())
+		}
+		if !reader.IsGlobbable() || !reader.HasHierarchicalUris() || !reader.IsLocal() {
+			t.Error("Embedded module reader should be globbable, hierarchical and local")
+		}
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		uri, err := url.Parse(assets.ModuleURI("Workflow.pkl"))
+		if err != nil {
+			t.Fatalf("Failed to parse module URI: %v", err)
+		}
+		content, err := reader.Read(*uri)
+		if err != nil {
+			t.Fatalf("Failed to read Workflow.pkl: %v", err)
+		}
+		expected, _ := assets.GetPKLFileAsString("Workflow.pkl")
+		if content != expected {
+			t.Error("Module reader content should match embedded Workflow.pkl")
+		}
+	})
+
+	t.Run("ReadMissing", func(t *testing.T) {
+		uri, _ := url.Parse("kdeps-schema:/DoesNotExist.pkl")
+		if _, err := reader.Read(*uri); err == nil {
+			t.Error("Reading a missing module should fail")
+		}
+	})
+
+	t.Run("ReadEscapingRoot", func(t *testing.T) {
+		uri, _ := url.Parse("kdeps-schema:/../assets/pkl_assets.go")
+		if _, err := reader.Read(*uri); err == nil {
+			t.Error("Reading outside the schema root should fail")
+		}
+	})
+
+	t.Run("ListElements", func(t *testing.T) {
+		uri, _ := url.Parse("kdeps-schema:/")
+		elements, err := reader.ListElements(*uri)
+		if err != nil {
+			t.Fatalf("Failed to list elements: %v", err)
+		}
+		files, _ := assets.ListPKLFiles()
+		if len(elements) != len(files) {
+			t.Errorf("Expected %d elements, got %d", len(files), len(elements))
+		}
+		for _, element := range elements {
+			if element.IsDirectory() {
+				t.Errorf("Unexpected directory %s in schema root", element.Name())
+			}
+		}
+	})
+
+	t.Run("CustomFS", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"nested/Module.pkl": &fstest.MapFile{Data: []byte("value = 1")},
+		}
+		custom := assets.NewFSModuleReader("custom", fsys)
+
+		uri, _ := url.Parse("custom:/nested/Module.pkl")
+		content, err := custom.Read(*uri)
+		if err != nil {
+			t.Fatalf("Failed to read from custom FS: %v", err)
+		}
+		if content != "value = 1" {
+			t.Errorf("Unexpected content: %s", content)
+		}
+
+		root, _ := url.Parse("custom:/")
+		elements, err := custom.ListElements(*root)
+		if err != nil {
+			t.Fatalf("Failed to list custom FS: %v", err)
+		}
+		if len(elements) != 1 || elements[0].Name() != "nested" || !elements[0].IsDirectory() {
+			t.Errorf("Expected a single nested directory, got %v", elements)
+		}
+	})
+}
+
+// TestEmbeddedModuleEvaluation evaluates a workflow that imports the schema through kdeps-schema:
+func TestEmbeddedModuleEvaluation(t *testing.T) {
+	ctx := context.Background()
+	evaluator, err := assets.NewEvaluator(ctx)
+	if err != nil {
+		t.Skipf("PKL evaluator not available: %v", err)
+	}
+	defer evaluator.Close()
+
+	source := pkl.TextSource(`
+amends "kdeps-schema:/Workflow.pkl"
+
+AgentID = "embeddedAgent"
+TargetActionID = "answer"
+Workflows {}
+Settings {}
+`)
+	wf, err := workflow.Load(ctx, evaluator, source)
+	if err != nil {
+		if strings.Contains(err.Error(), "package://") {
+			t.Skipf("Package dependencies not resolvable offline: %v", err)
+		}
+		t.Fatalf("Failed to evaluate workflow from embedded schema: %v", err)
+	}
+	if wf.GetAgentID() != "embeddedAgent" {
+		t.Errorf("Expected AgentID embeddedAgent, got %s", wf.GetAgentID())
+	}
+	if wf.GetVersion() != "1.0.0" {
+		t.Errorf("Expected default version 1.0.0, got %s", wf.GetVersion())
+	}
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kdeps/schema/assets"
+)
+
+func agentFixture() fstest.MapFS {
+	return fstest.MapFS{
+		"workflow.pkl": &fstest.MapFile{Data: []byte(`amends "Workflow.pkl"
+import "resources/fetch.pkl"
+`)},
+		"resources/fetch.pkl": &fstest.MapFile{Data: []byte(`amends "../Resource.pkl"
+import "pkl:json"
+import "helpers.pkl" as helpers
+import* "*.pkl"
+`)},
+		"resources/helpers.pkl": &fstest.MapFile{Data: []byte("value = 1\n")},
+		"data/input.json":       &fstest.MapFile{Data: []byte("{}")},
+	}
+}
+
+// TestOverlayWorkspace tests layering agent files over the embedded schema
+func TestOverlayWorkspace(t *testing.T) {
+	overlay, err := assets.NewOverlayWorkspace(assets.FSLayer("agent", agentFixture()))
+	if err != nil {
+		t.Fatalf("Failed to create overlay: %v", err)
+	}
+
+	t.Run("Files", func(t *testing.T) {
+		files := overlay.Files()
+		if len(files) != 4 || files[0] != "data/input.json" {
+			t.Errorf("Expected 4 sorted user files, got %v", files)
+		}
+		if len(overlay.Conflicts()) != 0 {
+			t.Errorf("Expected no conflicts, got %v", overlay.Conflicts())
+		}
+	})
+
+	t.Run("ResolveImport", func(t *testing.T) {
+		cases := map[string]string{
+			"Workflow.pkl":        "kdeps-schema:/Workflow.pkl",
+			"resources/fetch.pkl": "resources/fetch.pkl",
+			"pkl:json":            "pkl:json",
+		}
+		for uri, expected := range cases {
+			resolved, ok := overlay.ResolveImport("workflow.pkl", uri)
+			if !ok || resolved != expected {
+				t.Errorf("ResolveImport(%q): expected %s, got %s (%v)", uri, expected, resolved, ok)
+			}
+		}
+		if resolved, ok := overlay.ResolveImport("resources/fetch.pkl", "../Resource.pkl"); !ok || resolved != "kdeps-schema:/Resource.pkl" {
+			t.Errorf("Expected ../Resource.pkl to resolve to the schema, got %s", resolved)
+		}
+		if _, ok := overlay.ResolveImport("workflow.pkl", "Missing.pkl"); ok {
+			t.Error("Expected Missing.pkl to be unresolved")
+		}
+	})
+
+	t.Run("ReadModule", func(t *testing.T) {
+		content, err := overlay.ReadModule("resources/fetch.pkl")
+		if err != nil {
+			t.Fatalf("Failed to read module: %v", err)
+		}
+		for _, expected := range []string{`amends "kdeps-schema:/Resource.pkl"`, `import "pkl:json"`, `import "helpers.pkl" as helpers`, `import* "*.pkl"`} {
+			if !strings.Contains(content, expected) {
+				t.Errorf("Expected %q in rewritten module:\n%s", expected, content)
+			}
+		}
+	})
+
+	t.Run("ModuleReader", func(t *testing.T) {
+		reader := overlay.ModuleReader()
+		if reader.Scheme() != assets.OverlayScheme {
+			t.Errorf("Expected scheme %s, got %s", assets.OverlayScheme, reader.Scheme())
+		}
+		uri, _ := url.Parse(overlay.ModuleURI("workflow.pkl"))
+		content, err := reader.Read(*uri)
+		if err != nil {
+			t.Fatalf("Failed to read workflow.pkl: %v", err)
+		}
+		if !strings.HasPrefix(content, `amends "kdeps-schema:/Workflow.pkl"`) {
+			t.Errorf("Expected rewritten amends clause, got:\n%s", content)
+		}
+
+		root, _ := url.Parse("kdeps-overlay:/resources")
+		elements, err := reader.ListElements(*root)
+		if err != nil || len(elements) != 2 {
+			t.Errorf("Expected 2 elements in resources, got %v (%v)", elements, err)
+		}
+	})
+
+	t.Run("Materialize", func(t *testing.T) {
+		dir, err := overlay.Materialize(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to materialize overlay: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "resources", "fetch.pkl"))
+		if err != nil {
+			t.Fatalf("Failed to read materialized module: %v", err)
+		}
+		if !strings.Contains(string(data), `amends "../.kdeps-schema/Resource.pkl"`) {
+			t.Errorf("Expected import relative to the schema directory, got:\n%s", data)
+		}
+		if _, err := os.Stat(filepath.Join(dir, assets.OverlaySchemaDir, "Resource.pkl")); err != nil {
+			t.Errorf("Schema should be extracted: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "data", "input.json")); err != nil {
+			t.Errorf("Non-PKL files should be copied: %v", err)
+		}
+	})
+}
+
+// TestOverlayConflicts tests shadowing reports between layers and the schema
+func TestOverlayConflicts(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "workflow.pkl"), []byte("// local override\n"), 0644); err != nil {
+		t.Fatalf("Failed to write workflow.pkl: %v", err)
+	}
+
+	memory := fstest.MapFS{
+		"workflow.pkl":           &fstest.MapFile{Data: []byte("// in memory\n")},
+		"resources/Resource.pkl": &fstest.MapFile{Data: []byte("// shadows the schema\n")},
+	}
+	overlay, err := assets.NewOverlayWorkspace(assets.DirLayer(dir), assets.FSLayer("memory", memory))
+	if err != nil {
+		t.Fatalf("Failed to create overlay: %v", err)
+	}
+
+	conflicts := overlay.Conflicts()
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %v", conflicts)
+	}
+	for _, conflict := range conflicts {
+		switch conflict.Path {
+		case "workflow.pkl":
+			if conflict.Layer != dir || conflict.Shadows != "memory" {
+				t.Errorf("Expected %s to shadow memory, got %v", dir, conflict)
+			}
+		case "resources/Resource.pkl":
+			if conflict.Shadows != "schema" {
+				t.Errorf("Expected schema shadowing, got %v", conflict)
+			}
+		default:
+			t.Errorf("Unexpected conflict %v", conflict)
+		}
+	}
+
+	data, _ := overlay.ReadFile("workflow.pkl")
+	if string(data) != "// local override\n" {
+		t.Errorf("Expected the first layer to win, got %q", data)
+	}
+	if resolved, _ := overlay.ResolveImport("resources/a.pkl", "Resource.pkl"); resolved != "resources/Resource.pkl" {
+		t.Errorf("Expected the shadowing user file to win, got %s", resolved)
+	}
+}
@@ -0,0 +1,220 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/pklpackage"
+)
+
+// TestPklPackageBuilder tests building the core package from the embedded schema
+func TestPklPackageBuilder(t *testing.T) {
+	core, err := pklpackage.BuildCore()
+	if err != nil {
+		t.Fatalf("Failed to build core package: %v", err)
+	}
+
+	t.Run("Metadata", func(t *testing.T) {
+		expectedURI := assets.SchemaPackageURI + "@" + assets.SchemaVersion
+		if core.Metadata.PackageURI != expectedURI {
+			t.Errorf("Expected package URI %s, got %s", expectedURI, core.Metadata.PackageURI)
+		}
+		if core.Metadata.Name != "core" || core.Metadata.Version != assets.SchemaVersion {
+			t.Errorf("Unexpected name/version: %s@%s", core.Metadata.Name, core.Metadata.Version)
+		}
+		if !strings.HasSuffix(core.Metadata.PackageZipURL, "/core@"+assets.SchemaVersion+".zip") {
+			t.Errorf("Unexpected zip URL %s", core.Metadata.PackageZipURL)
+		}
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		zr, err := zip.NewReader(bytes.NewReader(core.Zip), int64(len(core.Zip)))
+		if err != nil {
+			t.Fatalf("Failed to open zip: %v", err)
+		}
+		files, _ := assets.ListPKLFiles()
+		if len(zr.File) != len(files) {
+			t.Errorf("Expected %d zip entries, got %d", len(files), len(zr.File))
+		}
+		for _, f := range zr.File {
+			if strings.Contains(f.Name, "/") {
+				t.Errorf("Expected files at the package root, got %s", f.Name)
+			}
+		}
+	})
+
+	t.Run("Reproducible", func(t *testing.T) {
+		again, err := pklpackage.BuildCore()
+		if err != nil {
+			t.Fatalf("Failed to rebuild core package: %v", err)
+		}
+		if again.Metadata.PackageZipChecksums != core.Metadata.PackageZipChecksums {
+			t.Error("Rebuilding the package should produce the same checksum")
+		}
+	})
+
+	t.Run("Exclude", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"src/go.pkl":               &fstest.MapFile{Data: []byte("module go\n")},
+			"src/internal/utils.pkl":   &fstest.MapFile{Data: []byte("module utils\n")},
+			"src/tests/go.pkl":         &fstest.MapFile{Data: []byte("module tests\n")},
+			"src/tests/fixtures/a.pkl": &fstest.MapFile{Data: []byte("module a\n")},
+		}
+		pkg, err := pklpackage.Build(fsys, "src", pklpackage.BuildOptions{
+			BaseURI: "package://example.com/pkg",
+			Version: "1.0.0",
+			Exclude: []string{"tests", "tests/**"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to build package: %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(pkg.Zip), int64(len(pkg.Zip)))
+		if err != nil {
+			t.Fatalf("Failed to open zip: %v", err)
+		}
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		if want := []string{"go.pkl", "internal/utils.pkl"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("Expected zip entries %v, got %v", want, names)
+		}
+	})
+
+	t.Run("WriteTo", func(t *testing.T) {
+		dir, err := core.WriteTo(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to write package: %v", err)
+		}
+		base := "core@" + assets.SchemaVersion
+		for _, name := range []string{base, base + ".sha256", base + ".zip", base + ".zip.sha256"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("Expected %s to be written: %v", name, err)
+			}
+		}
+		sum, _ := os.ReadFile(filepath.Join(dir, base+".zip.sha256"))
+		if string(sum) != core.Metadata.PackageZipChecksums.SHA256 {
+			t.Errorf("Zip checksum file should match metadata")
+		}
+	})
+
+	t.Run("ParseURI", func(t *testing.T) {
+		uri, err := pklpackage.ParseURI("package://pkg.pkl-lang.org/pkl-go/pkl.golang@0.10.0#/go.pkl")
+		if err != nil {
+			t.Fatalf("Failed to parse URI: %v", err)
+		}
+		if uri.Authority != "pkg.pkl-lang.org" || uri.Path != "/pkl-go/pkl.golang" || uri.Version != "0.10.0" || uri.Fragment != "/go.pkl" {
+			t.Errorf("Unexpected parse result %+v", uri)
+		}
+		if uri.Name() != "pkl.golang" || uri.CacheDir() != "package-2/pkg.pkl-lang.org/pkl-go/pkl.golang@0.10.0" {
+			t.Errorf("Unexpected name %s or cache dir %s", uri.Name(), uri.CacheDir())
+		}
+		for _, invalid := range []string{"https://example.com/a@1.0.0", "package://example.com/a", "package://example.com"} {
+			if _, err := pklpackage.ParseURI(invalid); err == nil {
+				t.Errorf("Expected error for %s", invalid)
+			}
+		}
+	})
+}
+
+// TestPklPackageServer tests serving packages from the local package server
+func TestPklPackageServer(t *testing.T) {
+	core, err := pklpackage.BuildCore()
+	if err != nil {
+		t.Fatalf("Failed to build core package: %v", err)
+	}
+	server := pklpackage.NewServer(core)
+	defer server.Close()
+	client := server.Client()
+
+	resp, err := client.Get(server.MetadataURL(core.URI))
+	if err != nil {
+		t.Fatalf("Failed to fetch metadata: %v", err)
+	}
+	var metadata pklpackage.Metadata
+	err = json.NewDecoder(resp.Body).Decode(&metadata)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode metadata: %v", err)
+	}
+	if !strings.HasPrefix(metadata.PackageZipURL, server.URL) {
+		t.Errorf("Served zip URL should point at the server, got %s", metadata.PackageZipURL)
+	}
+
+	resp, err = client.Get(metadata.PackageZipURL)
+	if err != nil {
+		t.Fatalf("Failed to fetch zip: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(data, core.Zip) {
+		t.Error("Served zip should match the built package")
+	}
+
+	resp, err = client.Get(server.URL + "/schema.kdeps.com/core@9.9.9")
+	if err != nil {
+		t.Fatalf("Failed to request unknown package: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 404 {
+		t.Errorf("Expected 404 for unknown package, got %d", resp.StatusCode)
+	}
+
+	rewrites := server.Rewrites()
+	if rewrites["https://schema.kdeps.com/"] != server.URL+"/schema.kdeps.com/" {
+		t.Errorf("Unexpected rewrites %v", rewrites)
+	}
+}
+
+// TestPklPackageOfflineCache tests writing packages in the PKL cache layout
+func TestPklPackageOfflineCache(t *testing.T) {
+	dir, err := pklpackage.SetupOfflineCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to set up offline cache: %v", err)
+	}
+
+	zipPath := filepath.Join(dir, "package-2", "schema.kdeps.com", "core@"+assets.SchemaVersion, "core@"+assets.SchemaVersion+".zip")
+	if _, err := os.Stat(zipPath); err != nil {
+		t.Fatalf("Core package should be cached: %v", err)
+	}
+
+	packages, err := pklpackage.ReadCache(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("Failed to read cache: %v", err)
+	}
+	vendored, err := pklpackage.Vendored()
+	if err != nil {
+		t.Fatalf("Failed to read vendored packages: %v", err)
+	}
+	if len(packages) != len(vendored)+1 {
+		t.Errorf("Expected core plus %d vendored packages, got %d", len(vendored), len(packages))
+	}
+	golang := filepath.Join(dir, "package-2", "pkg.pkl-lang.org", "pkl-go", "pkl.golang@0.10.0", "pkl.golang@0.10.0.zip")
+	if _, err := os.Stat(golang); err != nil {
+		t.Errorf("pkl.golang should be vendored: %v", err)
+	}
+
+	if err := os.WriteFile(zipPath, []byte("corrupt"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt zip: %v", err)
+	}
+	if _, err := pklpackage.ReadCache(os.DirFS(dir)); err == nil {
+		t.Error("Expected checksum mismatch for a corrupt zip")
+	}
+
+	missing, err := pklpackage.MissingVendored()
+	if err != nil {
+		t.Fatalf("Failed to list missing packages: %v", err)
+	}
+	if len(missing) > 0 {
+		t.Logf("Packages not vendored yet (run `make vendor-pkl-packages`): %v", missing)
+	}
+}
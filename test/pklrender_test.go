@@ -0,0 +1,165 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/llm"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/project/buildenv"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/pklrender"
+)
+
+func renderedResource() *resource.ResourceImpl {
+	model := "llama3.2"
+	prompt := "Say \"hi\""
+	env := map[string]string{"KEY": "v"}
+	skip := []any{true, "ready"}
+	return &resource.ResourceImpl{
+		ActionID: "answer",
+		Requires: &[]string{"fetch"},
+		Run: &resource.ResourceAction{
+			Chat: &llm.ResourceChat{
+				Model:           &model,
+				Prompt:          &prompt,
+				TimeoutDuration: &pkl.Duration{Value: 60, Unit: pkl.Second},
+			},
+			Exec: &exec.ResourceExec{
+				Env:     &env,
+				Command: "echo one\necho two",
+			},
+			Expr:          &pkl.Object{Elements: []any{"a", int64(1)}},
+			SkipCondition: &skip,
+		},
+	}
+}
+
+// TestPKLRender tests rendering generated types to PKL source
+func TestPKLRender(t *testing.T) {
+	t.Run("Resource", func(t *testing.T) {
+		out, err := pklrender.Render(renderedResource())
+		if err != nil {
+			t.Fatalf("Failed to render resource: %v", err)
+		}
+		source := string(out)
+		for _, want := range []string{
+			`amends "kdeps-schema:/Resource.pkl"`,
+			`ActionID = "answer"`,
+			"Run {",
+			"  Chat {",
+			`    Model = "llama3.2"`,
+			`    Prompt = "Say \"hi\""`,
+			"    TimeoutDuration = 60.s",
+			"    Env {",
+			`      ["KEY"] = "v"`,
+			`"""`,
+			"  Expr {",
+			"  SkipCondition {",
+			"    JSONResponse = null",
+		} {
+			if !strings.Contains(source, want) {
+				t.Errorf("Expected %q in rendered resource:\n%s", want, source)
+			}
+		}
+		// Nil properties are only rendered to override a declared default.
+		if strings.Contains(source, "Name =") {
+			t.Errorf("Nil properties without a default should be omitted:\n%s", source)
+		}
+	})
+
+	t.Run("Class", func(t *testing.T) {
+		env := buildenv.Prod
+		out, err := pklrender.Render(project.Settings{Environment: &env})
+		if err != nil {
+			t.Fatalf("Failed to render settings: %v", err)
+		}
+		source := string(out)
+		for _, want := range []string{
+			`import "kdeps-schema:/Project.pkl"`,
+			"output {",
+			"value = new Project.Settings {",
+			`Environment = "prod"`,
+		} {
+			if !strings.Contains(source, want) {
+				t.Errorf("Expected %q in rendered settings:\n%s", want, source)
+			}
+		}
+	})
+
+	t.Run("SchemaPackage", func(t *testing.T) {
+		out, err := pklrender.Render(renderedResource(), pklrender.WithSchemaPackage("package://schema.kdeps.com/core@1.0.0"))
+		if err != nil {
+			t.Fatalf("Failed to render resource: %v", err)
+		}
+		if !strings.HasPrefix(string(out), `amends "package://schema.kdeps.com/core@1.0.0#/Resource.pkl"`) {
+			t.Errorf("Unexpected amends line:\n%s", out)
+		}
+	})
+
+	t.Run("NotGenerated", func(t *testing.T) {
+		if _, err := pklrender.Render(struct{ Name string }{"x"}); err == nil {
+			t.Error("Expected error for a type without a PKL module")
+		}
+	})
+}
+
+// TestPKLRenderRoundTrip tests that rendered source loads back into an equal struct
+func TestPKLRenderRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	evaluator, err := assets.NewEvaluator(ctx)
+	if err != nil {
+		t.Skipf("PKL evaluator not available: %v", err)
+	}
+	defer evaluator.Close()
+
+	skipOffline := func(t *testing.T, err error) {
+		if strings.Contains(err.Error(), "package://") {
+			t.Skipf("Package dependencies not resolvable offline: %v", err)
+		}
+	}
+
+	t.Run("Resource", func(t *testing.T) {
+		want := renderedResource()
+		out, err := pklrender.Render(want)
+		if err != nil {
+			t.Fatalf("Failed to render resource: %v", err)
+		}
+		var got resource.ResourceImpl
+		if err := evaluator.EvaluateModule(ctx, pkl.TextSource(string(out)), &got); err != nil {
+			skipOffline(t, err)
+			t.Fatalf("Failed to load rendered resource: %v\n%s", err, out)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("Round trip mismatch:\n%s\ngot  %+v\nwant %+v", out, got, *want)
+		}
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		original := project.Settings{}
+		if err := evaluator.EvaluateOutputValue(ctx, pkl.TextSource(`
+import "kdeps-schema:/Project.pkl"
+output { value = new Project.Settings {} }
+`), &original); err != nil {
+			skipOffline(t, err)
+			t.Fatalf("Failed to evaluate default settings: %v", err)
+		}
+		out, err := pklrender.Render(original)
+		if err != nil {
+			t.Fatalf("Failed to render settings: %v", err)
+		}
+		var got project.Settings
+		if err := evaluator.EvaluateOutputValue(ctx, pkl.TextSource(string(out)), &got); err != nil {
+			skipOffline(t, err)
+			t.Fatalf("Failed to load rendered settings: %v\n%s", err, out)
+		}
+		if !reflect.DeepEqual(got, original) {
+			t.Errorf("Round trip mismatch:\n%s", out)
+		}
+	})
+}
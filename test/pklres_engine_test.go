@@ -0,0 +1,378 @@
+package test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	pklresource "github.com/kdeps/schema/gen/pkl_resource"
+	"github.com/kdeps/schema/pklres"
+)
+
+func engineKeys(res pklres.Result, field string) []string {
+	var keys []string
+	for _, row := range res.Rows {
+		s, _ := row.Data[field].(string)
+		keys = append(keys, s)
+	}
+	return keys
+}
+
+// TestPklresEngineSelect tests typed comparisons and indexed selection
+func TestPklresEngineSelect(t *testing.T) {
+	entries := map[string]string{
+		"a": `{"model": "llama3.2", "role": "user", "exitCode": 0, "count": 9, "timeout": "60.s", "tags": ["x", "y"]}`,
+		"b": `{"model": "llama3.2", "role": "assistant", "exitCode": 1, "count": 10, "timeout": "1.5.min"}`,
+		"c": `{"model": "mistral", "role": "user", "exitCode": "0", "count": "100", "timeout": "90s"}`,
+		"d": `plain text`,
+	}
+	indexed := pklres.NewTable("llm", entries, pklres.DefaultIndexes...)
+	scanned := pklres.NewTable("llm", entries)
+	if !indexed.Indexed("model") || scanned.Indexed("model") {
+		t.Fatal("Unexpected indexes")
+	}
+
+	cases := []struct {
+		name       string
+		conditions []pklres.Condition
+		want       []string
+	}{
+		{"Numeric", []pklres.Condition{{Field: "count", Operator: "gt", Value: 9}}, []string{"b", "c"}},
+		{"NumericString", []pklres.Condition{{Field: "count", Operator: "lt", Value: "10"}}, []string{"a"}},
+		{"Text", []pklres.Condition{{Field: "model", Operator: "gt", Value: "m"}}, []string{"c"}},
+		{"Duration", []pklres.Condition{{Field: "timeout", Operator: "gte", Value: "90.s"}}, []string{"b", "c"}},
+		{"DurationEq", []pklres.Condition{{Field: "timeout", Operator: "eq", Value: "1.min"}}, []string{"a"}},
+		{"IndexEq", []pklres.Condition{{Field: "exitCode", Operator: "eq", Value: 0}}, []string{"a", "c"}},
+		{"IndexIn", []pklres.Condition{{Field: "role", Operator: "in", Value: []any{"assistant", "system"}}}, []string{"b"}},
+		{"IndexAnd", []pklres.Condition{
+			{Field: "model", Operator: "eq", Value: "llama3.2"},
+			{Field: "role", Operator: "eq", Value: "user"},
+		}, []string{"a"}},
+		{"Contains", []pklres.Condition{{Field: "tags", Operator: "contains", Value: "y"}}, []string{"a"}},
+		{"Key", []pklres.Condition{{Field: "key", Operator: "eq", Value: "d"}}, []string{"d"}},
+		{"MissingField", []pklres.Condition{{Field: "model", Operator: "ne", Value: "x"}}, []string{"a", "b", "c"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, table := range []*pklres.Table{indexed, scanned} {
+				res, err := table.Select(tc.conditions...)
+				if err != nil {
+					t.Fatalf("Failed to select: %v", err)
+				}
+				if got := engineKeys(res, "key"); !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("Expected %v, got %v (indexed: %v)", tc.want, got, table.Indexed("model"))
+				}
+			}
+		})
+	}
+
+	for _, c := range []pklres.Condition{
+		{Field: "model", Operator: "like", Value: "x"},
+		{Field: "", Operator: "eq", Value: "x"},
+		{Field: "role", Operator: "in", Value: "user"},
+	} {
+		if _, err := indexed.Select(c); err == nil {
+			t.Errorf("Expected error for %+v", c)
+		}
+	}
+}
+
+// TestPklresEngineJoin tests hash joins and conversion of evaluated conditions
+func TestPklresEngineJoin(t *testing.T) {
+	users := pklres.NewTable("users", map[string]string{
+		"u1": `{"id": 1, "name": "Alice"}`,
+		"u2": `{"id": 2, "name": "Bob"}`,
+	}, "id")
+	orders := pklres.NewTable("orders", map[string]string{
+		"o1": `{"userId": "1", "amount": 10}`,
+		"o2": `{"userId": 1, "amount": 20}`,
+		"o3": `{"userId": 3, "amount": 30}`,
+	})
+
+	for _, tc := range []struct {
+		joinType string
+		want     []string
+	}{
+		{"inner", []string{"Alice", "Alice"}},
+		{"left", []string{"Alice", "Alice", "Bob"}},
+		{"right", []string{"Alice", "Alice", ""}},
+		{"full", []string{"Alice", "Alice", "Bob", ""}},
+	} {
+		res, err := pklres.Join(users, orders, pklresource.JoinCondition{LeftKey: "id", RightKey: "userId", JoinType: tc.joinType})
+		if err != nil {
+			t.Fatalf("Failed to join: %v", err)
+		}
+		if got := engineKeys(res, "users.name"); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Expected %s join %v, got %v", tc.joinType, tc.want, got)
+		}
+	}
+	if _, err := pklres.Join(users, orders, pklresource.JoinCondition{LeftKey: "id", RightKey: "userId", JoinType: "cross"}); err == nil {
+		t.Error("Expected error for an unsupported join type")
+	}
+
+	res := orders.Project(pklresource.ProjectionCondition{Exclude: []string{"value"}})
+	if !reflect.DeepEqual(res.Columns, []string{"amount", "key", "userId"}) {
+		t.Errorf("Unexpected columns %v", res.Columns)
+	}
+
+	c := pklres.NewCondition(pklresource.SelectionCondition{
+		Field:    "amount",
+		Operator: "in",
+		Value:    &pkl.Object{Elements: []any{int64(10), int64(30)}},
+	})
+	selected, err := orders.Select(c)
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	if got := engineKeys(selected, "key"); !reflect.DeepEqual(got, []string{"o1", "o3"}) {
+		t.Errorf("Unexpected selection %v", got)
+	}
+}
+
+func queryLeaf(field, operator string, value any) *pklresource.QueryCondition {
+	return &pklresource.QueryCondition{Operator: operator, Field: &field, Value: value}
+}
+
+func queryNode(operator string, conditions ...*pklresource.QueryCondition) *pklresource.QueryCondition {
+	return &pklresource.QueryCondition{Operator: operator, Conditions: conditions}
+}
+
+// TestPklresEngineQuery tests condition trees, ordering and pagination
+func TestPklresEngineQuery(t *testing.T) {
+	table := pklres.NewTable("http", map[string]string{
+		"r1": `{"url": "https://api.example.com/users", "status": 200, "timestamp": 100}`,
+		"r2": `{"url": "https://api.example.com/orders", "status": 500, "timestamp": 200}`,
+		"r3": `{"url": "http://cdn.example.com/logo.png", "status": 200, "timestamp": 300}`,
+		"r4": `{"url": "https://api.example.com/users/1", "status": 404, "timestamp": 400, "error": null}`,
+		"r5": `{"url": "https://other.org", "timestamp": 500, "error": "timeout"}`,
+	}, "status")
+
+	cases := []struct {
+		name  string
+		where *pklresource.QueryCondition
+		want  []string
+		query string
+	}{
+		{"Or", queryNode("or", queryLeaf("status", "eq", 500), queryLeaf("status", "eq", 404)), []string{"r2", "r4"},
+			"SELECT * FROM http WHERE status eq 500 OR status eq 404"},
+		{"Not", queryNode("not", queryLeaf("status", "eq", 200)), []string{"r2", "r4", "r5"},
+			"SELECT * FROM http WHERE NOT (status eq 200)"},
+		{"Nested", queryNode("and",
+			queryLeaf("url", "startsWith", "https://api."),
+			queryNode("or", queryLeaf("status", "gte", 400), queryLeaf("url", "regex", `/users$`)),
+		), []string{"r1", "r2", "r4"},
+			"SELECT * FROM http WHERE url startsWith https://api. AND (status gte 400 OR url regex /users$)"},
+		{"Between", queryLeaf("timestamp", "between", []any{200, 400}), []string{"r2", "r3", "r4"},
+			"SELECT * FROM http WHERE timestamp between [200,400]"},
+		{"IsNull", queryLeaf("error", "isNull", nil), []string{"r1", "r2", "r3", "r4"}, "SELECT * FROM http WHERE error isNull"},
+		{"IsNotNull", queryLeaf("error", "isNull", false), []string{"r5"}, "SELECT * FROM http WHERE error isNull false"},
+		{"IndexedAnd", queryNode("and", queryLeaf("status", "in", []any{200, 404}), queryLeaf("url", "regex", `^https`)), []string{"r1", "r4"},
+			"SELECT * FROM http WHERE status in [200,404] AND url regex ^https"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := table.Query(pklresource.Query{Where: tc.where})
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			if got := engineKeys(res, "key"); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+			if res.Query != tc.query {
+				t.Errorf("Expected query %q, got %q", tc.query, res.Query)
+			}
+		})
+	}
+
+	t.Run("OrderAndPages", func(t *testing.T) {
+		limit := 2
+		q := pklresource.Query{
+			OrderBy: []*pklresource.OrderBy{{Field: "status", Descending: true}, {Field: "timestamp"}},
+			Limit:   &limit,
+		}
+		var pages [][]string
+		for {
+			res, err := table.Query(q)
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			pages = append(pages, engineKeys(res, "key"))
+			if res.NextCursor == nil {
+				break
+			}
+			q.Cursor = res.NextCursor
+		}
+		want := [][]string{{"r2", "r4"}, {"r1", "r3"}, {"r5"}}
+		if !reflect.DeepEqual(pages, want) {
+			t.Errorf("Expected pages %v, got %v", want, pages)
+		}
+
+		q.Cursor, q.Offset = nil, 1
+		res, err := table.Query(q)
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if !reflect.DeepEqual(engineKeys(res, "key"), []string{"r4", "r1"}) {
+			t.Errorf("Unexpected page at offset 1 %v", engineKeys(res, "key"))
+		}
+		if res.Query != "SELECT * FROM http ORDER BY status DESC, timestamp LIMIT 2 OFFSET 1" {
+			t.Errorf("Unexpected query %q", res.Query)
+		}
+
+		q.OrderBy = q.OrderBy[:1]
+		q.Cursor = res.NextCursor
+		if _, err := table.Query(q); err == nil {
+			t.Error("Expected error for a cursor of another ordering")
+		}
+	})
+
+	for name, q := range map[string]pklresource.Query{
+		"NotArity":       {Where: queryNode("not")},
+		"LeafNoField":    {Where: &pklresource.QueryCondition{Operator: "eq", Value: 1}},
+		"NodeWithField":  {Where: &pklresource.QueryCondition{Operator: "or", Field: new(string)}},
+		"BadRegex":       {Where: queryLeaf("url", "regex", "(")},
+		"BadBetween":     {Where: queryLeaf("status", "between", []any{1})},
+		"BadIsNull":      {Where: queryLeaf("error", "isNull", "yes")},
+		"NegativeOffset": {Offset: -1},
+		"BadCursor":      {Cursor: new(string)},
+	} {
+		if _, err := table.Query(q); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func aggregation(operator, field string) *pklresource.AggregateCondition {
+	c := &pklresource.AggregateCondition{Operator: operator}
+	if field != "" {
+		c.Field = &field
+	}
+	return c
+}
+
+// TestPklresEngineAggregate tests grouping and aggregate functions
+func TestPklresEngineAggregate(t *testing.T) {
+	table := pklres.NewTable("http", map[string]string{
+		"r1": `{"url": "/users", "method": "GET", "status": 200, "latency": "100.ms"}`,
+		"r2": `{"url": "/users", "method": "POST", "status": 500, "latency": "300.ms"}`,
+		"r3": `{"url": "/orders", "method": "GET", "status": 200, "latency": "1.s", "size": 10}`,
+		"r4": `{"url": "/orders", "method": "GET", "status": "200", "size": 30}`,
+		"r5": `{"method": "GET", "status": 404, "size": 5}`,
+	}, "status")
+
+	t.Run("GroupBy", func(t *testing.T) {
+		res, err := table.Aggregate(pklres.Aggregate{
+			GroupBy:      []string{"url"},
+			Aggregations: []*pklresource.AggregateCondition{aggregation("count", ""), aggregation("avg", "latency"), aggregation("distinct", "method")},
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		want := []map[string]any{
+			{"url": "/orders", "count": 2, "avg_latency": "1s", "distinct_method": []any{"GET"}},
+			{"url": "/users", "count": 2, "avg_latency": "200ms", "distinct_method": []any{"GET", "POST"}},
+			{"url": nil, "count": 1, "avg_latency": nil, "distinct_method": []any{"GET"}},
+		}
+		if len(res.Rows) != len(want) {
+			t.Fatalf("Expected %d groups, got %v", len(want), res.Rows)
+		}
+		for i, row := range res.Rows {
+			if !reflect.DeepEqual(row.Data, want[i]) {
+				t.Errorf("Expected group %v, got %v", want[i], row.Data)
+			}
+		}
+		if !reflect.DeepEqual(res.Columns, []string{"url", "count", "avg_latency", "distinct_method"}) {
+			t.Errorf("Unexpected columns %v", res.Columns)
+		}
+		if res.Query != "SELECT url, count(*) AS count, avg(latency) AS avg_latency, distinct(method) AS distinct_method FROM http GROUP BY url" {
+			t.Errorf("Unexpected query %q", res.Query)
+		}
+	})
+
+	t.Run("Histogram", func(t *testing.T) {
+		res, err := table.Aggregate(pklres.Aggregate{
+			GroupBy:      []string{"status"},
+			Aggregations: []*pklresource.AggregateCondition{aggregation("count", "")},
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		got := map[string]any{}
+		for _, row := range res.Rows {
+			got[fmt.Sprint(row.Data["status"])] = row.Data["count"]
+		}
+		if want := map[string]any{"200": 3, "404": 1, "500": 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected histogram %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Where", func(t *testing.T) {
+		alias := "failed"
+		count := aggregation("count", "")
+		count.Alias = &alias
+		res, err := table.Aggregate(pklres.Aggregate{
+			Where:        queryLeaf("status", "ne", 200),
+			Aggregations: []*pklresource.AggregateCondition{count, aggregation("sum", "size"), aggregation("min", "status"), aggregation("max", "status")},
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		want := map[string]any{"failed": 2, "sum_size": float64(5), "min_status": float64(404), "max_status": float64(500)}
+		if len(res.Rows) != 1 || !reflect.DeepEqual(res.Rows[0].Data, want) {
+			t.Errorf("Expected %v, got %v", want, res.Rows)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		res, err := table.Aggregate(pklres.Aggregate{
+			Where:        queryLeaf("status", "eq", 302),
+			Aggregations: []*pklresource.AggregateCondition{aggregation("count", ""), aggregation("avg", "size")},
+		})
+		if err != nil {
+			t.Fatalf("Failed to aggregate: %v", err)
+		}
+		if want := map[string]any{"count": 0, "avg_size": nil}; len(res.Rows) != 1 || !reflect.DeepEqual(res.Rows[0].Data, want) {
+			t.Errorf("Expected %v, got %v", want, res.Rows)
+		}
+	})
+
+	mixed := pklres.NewTable("exec", map[string]string{"a": `{"took": "1.s"}`, "b": `{"took": 2}`})
+	if _, err := mixed.Aggregate(pklres.Aggregate{Aggregations: []*pklresource.AggregateCondition{aggregation("sum", "took")}}); err == nil {
+		t.Error("Expected error for a sum of numbers and durations")
+	}
+	for name, a := range map[string]pklres.Aggregate{
+		"NoAggregations":  {GroupBy: []string{"url"}},
+		"Unsupported":     {Aggregations: []*pklresource.AggregateCondition{aggregation("median", "size")}},
+		"SumWithoutField": {Aggregations: []*pklresource.AggregateCondition{aggregation("sum", "")}},
+		"SumOfText":       {Aggregations: []*pklresource.AggregateCondition{aggregation("sum", "method")}},
+		"DuplicateColumn": {GroupBy: []string{"count"}, Aggregations: []*pklresource.AggregateCondition{aggregation("count", "")}},
+		"BadWhere":        {Where: queryNode("not"), Aggregations: []*pklresource.AggregateCondition{aggregation("count", "")}},
+	} {
+		if _, err := table.Aggregate(a); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+// TestPklresParseDuration tests parsing PKL and Go durations
+func TestPklresParseDuration(t *testing.T) {
+	for s, want := range map[string]pkl.Duration{
+		"60.s":     {Value: 60, Unit: pkl.Second},
+		"-1.5.min": {Value: -1.5, Unit: pkl.Minute},
+		"2.d":      {Value: 2, Unit: pkl.Day},
+		"1m30s":    {Value: 90, Unit: pkl.Second},
+	} {
+		got, err := pklres.ParseDuration(s)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", s, err)
+		} else if got != want {
+			t.Errorf("Expected %v for %q, got %v", want, s, got)
+		}
+	}
+	for _, s := range []string{"", "60", "60.sec", "soon"} {
+		if _, err := pklres.ParseDuration(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"os"
 	"strings"
 	"testing"
-
-	"github.com/kdeps/kdeps/pkg/pklres"
 )
 
 // TestPklresIntegrationPKL loads PKL test cases from a PKL file and checks results using the real pklres reader
@@ -19,10 +17,9 @@ func TestPklresIntegrationPKL(t *testing.T) {
 	tempDB.Close()
 
 	// Initialize real pklres reader
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		t.Fatalf("Failed to initialize pklres reader: %v", err)
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
+
+	RequirePkl(t)
 
 	// Create evaluator with real resource readers
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, pklresReader)
@@ -9,7 +9,6 @@ import (
 	"testing"
 
 	"github.com/apple/pkl-go/pkl"
-	"github.com/kdeps/kdeps/pkg/pklres"
 )
 
 // Mock resource reader for agent:/ scheme
@@ -112,10 +111,9 @@ func TestPklresIntegration(t *testing.T) {
 	tempDB.Close()
 
 	// Initialize real pklres reader
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		t.Fatalf("Failed to initialize pklres reader: %v", err)
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
+
+	RequirePkl(t)
 
 	// Create evaluator with real resource readers
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, pklresReader)
@@ -148,6 +146,7 @@ func TestPklresIntegration(t *testing.T) {
 
 // TestPklresFunctions tests the pklres functions directly
 func TestPklresFunctions(t *testing.T) {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		t.Fatalf("Failed to create evaluator: %v", err)
@@ -198,6 +197,7 @@ func TestPklresFunctions(t *testing.T) {
 
 // TestResourceFunctions tests the resource accessor functions
 func TestResourceFunctions(t *testing.T) {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		t.Fatalf("Failed to create evaluator: %v", err)
@@ -248,6 +248,7 @@ func TestResourceFunctions(t *testing.T) {
 
 // TestDefaultValues tests default value handling
 func TestDefaultValues(t *testing.T) {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		t.Fatalf("Failed to create evaluator: %v", err)
@@ -298,6 +299,7 @@ func TestDefaultValues(t *testing.T) {
 
 // TestDataResourceIntegration tests Data resource functionality
 func TestDataResourceIntegration(t *testing.T) {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		t.Fatalf("Failed to create evaluator: %v", err)
@@ -348,6 +350,7 @@ func TestDataResourceIntegration(t *testing.T) {
 
 // Add test for error handling and null safety
 func TestErrorHandling(t *testing.T) {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		t.Fatalf("Failed to create evaluator: %v", err)
@@ -404,6 +407,7 @@ func TestErrorHandling(t *testing.T) {
 
 // TestAdditionalResourceFunctions tests additional resource methods
 func TestAdditionalResourceFunctions(t *testing.T) {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		t.Fatalf("Failed to create evaluator: %v", err)
@@ -463,6 +467,7 @@ func TestAdditionalResourceFunctions(t *testing.T) {
 
 // TestBasicPKLFunctionality tests basic PKL functionality
 func TestBasicPKLFunctionality(t *testing.T) {
+	RequirePkl(t)
 	evaluator, err := NewTestEvaluator(&AgentResourceReader{}, &PklresResourceReader{})
 	if err != nil {
 		t.Fatalf("Failed to create evaluator: %v", err)
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kdeps/schema/pklres"
+)
+
+// TestPklresSnapshot tests exporting, importing and restoring pklres graphs
+func TestPklresSnapshot(t *testing.T) {
+	store := pklres.NewMemoryStorage()
+	recorded := map[string]map[string]string{
+		"current": {"requestID": "req-1"},
+		"req-1": {
+			"method":  "POST",
+			"path":    "/api/v1/chat",
+			"headers": `{"Content-Type":"application/json"}`,
+			"params":  `{"q":"hello"}`,
+			"files":   `{"upload":{"filepath":"/tmp/a.txt","filetype":"text/plain"}}`,
+		},
+		"@myAgent/exec:1.0.0": {"stdout": "done\n", "exitCode": "0"},
+		"@myAgent/llm:1.0.0":  {"response": "Hi!", "model": "llama3.2"},
+	}
+	for name, entries := range recorded {
+		if err := store.SetMany("run1", name, entries); err != nil {
+			t.Fatalf("Failed to seed %s: %v", name, err)
+		}
+	}
+	_ = store.Set("run2", "other", "key", "not exported")
+
+	snap, err := pklres.TakeSnapshot(store, "run1")
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if snap.Version != pklres.SnapshotVersion || snap.GraphID != "run1" || snap.Time.IsZero() {
+		t.Errorf("Unexpected snapshot %+v", snap)
+	}
+	if !reflect.DeepEqual(snap.Collections, recorded) {
+		t.Errorf("Expected collections %v, got %v", recorded, snap.Collections)
+	}
+
+	formats := map[string]func(*pklres.Snapshot, *bytes.Buffer) error{
+		"NDJSON": func(s *pklres.Snapshot, b *bytes.Buffer) error { return s.WriteNDJSON(b) },
+		"Tar":    func(s *pklres.Snapshot, b *bytes.Buffer) error { return s.WriteTar(b) },
+	}
+	for name, write := range formats {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(snap, &buf); err != nil {
+				t.Fatalf("Failed to write snapshot: %v", err)
+			}
+			loaded, err := pklres.ReadSnapshot(&buf)
+			if err != nil {
+				t.Fatalf("Failed to read snapshot: %v", err)
+			}
+			if loaded.GraphID != "run1" || !loaded.Time.Equal(snap.Time) || !reflect.DeepEqual(loaded.Collections, recorded) {
+				t.Errorf("Unexpected snapshot after a round trip %+v", loaded)
+			}
+
+			fresh := pklres.NewMemoryStorage()
+			if err := loaded.Restore(fresh, "replay"); err != nil {
+				t.Fatalf("Failed to restore: %v", err)
+			}
+			r := pklres.NewReader(fresh, pklres.WithGraphID("replay"))
+			if got := readPklres(t, r, "op=get&collection=req-1&key=method"); got != "POST" {
+				t.Errorf("Expected the recorded method, got %q", got)
+			}
+			if got := readPklres(t, r, "op=get&collection=@myAgent/llm:1.0.0&key=response"); got != "Hi!" {
+				t.Errorf("Expected the recorded response, got %q", got)
+			}
+		})
+	}
+
+	t.Run("PointInTime", func(t *testing.T) {
+		_ = store.Set("run1", "@myAgent/exec:1.0.0", "exitCode", "1")
+		_ = store.Set("run1", "@myAgent/exec:1.0.0", "stderr", "boom")
+		_ = store.Set("run1", "late", "key", "value")
+		if err := snap.Restore(store, ""); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		after, err := pklres.TakeSnapshot(store, "run1")
+		if err != nil {
+			t.Fatalf("Failed to take snapshot: %v", err)
+		}
+		if !reflect.DeepEqual(after.Collections, recorded) {
+			t.Errorf("Expected the graph to be restored to the snapshot, got %v", after.Collections)
+		}
+		if v, ok, _ := store.Get("run2", "other", "key"); !ok || v != "not exported" {
+			t.Error("Expected other graphs to be kept")
+		}
+	})
+
+	for name, data := range map[string]string{
+		"Empty":         "",
+		"NoHeader":      `{"type":"entry","collection":"c","key":"k","value":"v"}`,
+		"Version":       `{"type":"header","version":99,"graphID":"g"}`,
+		"BadEntry":      "{\"type\":\"header\",\"version\":1,\"graphID\":\"g\"}\n{\"type\":\"other\"}",
+		"NotASnapshot":  "hello",
+		"TarNoManifest": strings.Repeat("\x00", 1024),
+	} {
+		if _, err := pklres.ReadSnapshot(strings.NewReader(data)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,711 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kdeps/schema/pklres"
+)
+
+// readPklres performs a pklres op and returns the result as text.
+func readPklres(t *testing.T, r *pklres.Reader, query string) string {
+	t.Helper()
+	uri, err := url.Parse("pklres://?" + query)
+	if err != nil {
+		t.Fatalf("Failed to parse URI: %v", err)
+	}
+	data, err := r.Read(*uri)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", query, err)
+	}
+	return string(data)
+}
+
+// readPklresResult performs a relational pklres op and decodes its result.
+func readPklresResult(t *testing.T, r *pklres.Reader, query string) pklres.Result {
+	t.Helper()
+	var res pklres.Result
+	if err := json.Unmarshal([]byte(readPklres(t, r, query)), &res); err != nil {
+		t.Fatalf("Failed to decode result of %s: %v", query, err)
+	}
+	return res
+}
+
+func setPklres(t *testing.T, r *pklres.Reader, collection, key, value string) {
+	t.Helper()
+	q := url.Values{"op": {"set"}, "collection": {collection}, "key": {key}, "value": {value}}
+	if got := readPklres(t, r, q.Encode()); got != value {
+		t.Fatalf("Expected set to return %q, got %q", value, got)
+	}
+}
+
+// TestPklresStoreOps tests the key-value ops of the pklres reader
+func TestPklresStoreOps(t *testing.T) {
+	r := pklres.NewReader(pklres.NewMemoryStorage(), pklres.WithGraphID("graph1"))
+	if r.Scheme() != "pklres" || r.GraphID() != "graph1" {
+		t.Fatalf("Unexpected reader %s %s", r.Scheme(), r.GraphID())
+	}
+
+	setPklres(t, r, "@myAgent/fetch:1.0.0", "b", `{"n": 1}`)
+	setPklres(t, r, "@myAgent/fetch:1.0.0", "a", "hello world & more")
+
+	if got := readPklres(t, r, "op=get&collection=@myAgent/fetch:1.0.0&key=a"); got != "hello world & more" {
+		t.Errorf("Unexpected value %q", got)
+	}
+	if got := readPklres(t, r, "op=get&collection=@myAgent/fetch:1.0.0&key=missing"); got != "" {
+		t.Errorf("Expected empty value for a missing key, got %q", got)
+	}
+	if got := readPklres(t, r, "op=list&collection=@myAgent/fetch:1.0.0"); got != `["a","b"]` {
+		t.Errorf("Unexpected keys %s", got)
+	}
+	if got := readPklres(t, r, "op=list&collection=other"); got != `[]` {
+		t.Errorf("Expected no keys, got %s", got)
+	}
+
+	other := pklres.NewReader(r.Storage(), pklres.WithGraphID("graph2"))
+	if got := readPklres(t, other, "op=get&collection=@myAgent/fetch:1.0.0&key=a"); got != "" {
+		t.Errorf("Graphs should not share values, got %q", got)
+	}
+
+	for _, query := range []string{"", "op=nope", "op=get&collection=x", "op=set&collection=x&key=y", "op=setCacheTTL&ttl=soon", "op=relationalSelect&collection=x"} {
+		uri, _ := url.Parse("pklres://?" + query)
+		if _, err := r.Read(*uri); err == nil {
+			t.Errorf("Expected error for %q", query)
+		}
+	}
+}
+
+// TestPklresStoreSetMany tests atomic batch writes
+func TestPklresStoreSetMany(t *testing.T) {
+	store := pklres.NewMemoryStorage()
+	r := pklres.NewReader(store)
+	setPklres(t, r, "exec", "stdout", "old")
+	project := url.Values{"op": {"relationalProject"}, "collection": {"exec"}, "condition": {`{}`}}.Encode()
+	readPklresResult(t, r, project)
+
+	values := `{"stdout": "done", "stderr": "", "exitCode": 0, "env": {"HOME": "/root"}}`
+	got := readPklres(t, r, url.Values{"op": {"setMany"}, "collection": {"exec"}, "values": {values}}.Encode())
+	want := map[string]string{"stdout": "done", "stderr": "", "exitCode": "0", "env": `{"HOME":"/root"}`}
+	var stored map[string]string
+	if err := json.Unmarshal([]byte(got), &stored); err != nil || !reflect.DeepEqual(stored, want) {
+		t.Errorf("Expected setMany to return %v, got %s", want, got)
+	}
+	if entries, _ := store.Entries(pklres.DefaultGraphID, "exec"); !reflect.DeepEqual(entries, want) {
+		t.Errorf("Unexpected entries %v", entries)
+	}
+	if res := readPklresResult(t, r, project); len(res.Rows) != 4 {
+		t.Errorf("Expected setMany to invalidate cached results, got %d rows", len(res.Rows))
+	}
+
+	for _, values := range []string{`["a"]`, `{"a": null}`, `not json`} {
+		uri, _ := url.Parse("pklres://?" + url.Values{"op": {"setMany"}, "collection": {"exec"}, "values": {values}}.Encode())
+		if _, err := r.Read(*uri); err == nil {
+			t.Errorf("Expected error for values %s", values)
+		}
+	}
+
+	t.Run("Atomic", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				n := strconv.Itoa(i)
+				_ = store.SetMany("g", "batch", map[string]string{"a": n, "b": n, "c": n})
+			}
+		}()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			entries, _ := store.Entries("g", "batch")
+			if len(entries) > 0 && (len(entries) != 3 || entries["a"] != entries["b"] || entries["b"] != entries["c"]) {
+				t.Fatalf("Observed a partial batch %v", entries)
+			}
+		}
+	})
+
+	t.Run("FileRollback", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "store")
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		fs, err := pklres.NewFileStorage(filepath.Join(dir, "pklres.json"))
+		if err != nil {
+			t.Fatalf("Failed to open storage: %v", err)
+		}
+		if err := fs.SetMany("g", "exec", map[string]string{"stdout": "ok", "exitCode": "0"}); err != nil {
+			t.Fatalf("Failed to set: %v", err)
+		}
+		reopened, err := pklres.NewFileStorage(filepath.Join(dir, "pklres.json"))
+		if err != nil {
+			t.Fatalf("Failed to reopen storage: %v", err)
+		}
+		if entries, _ := reopened.Entries("g", "exec"); len(entries) != 2 {
+			t.Errorf("Expected the batch to be persisted, got %v", entries)
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove directory: %v", err)
+		}
+		if err := fs.SetMany("g", "exec", map[string]string{"stdout": "new", "stderr": "boom"}); err == nil {
+			t.Fatal("Expected error writing to a removed directory")
+		}
+		if entries, _ := fs.Entries("g", "exec"); !reflect.DeepEqual(entries, map[string]string{"stdout": "ok", "exitCode": "0"}) {
+			t.Errorf("Expected a failed batch to be rolled back, got %v", entries)
+		}
+	})
+}
+
+// nextEvent receives an event or fails after a second.
+func nextEvent(t *testing.T, events <-chan pklres.Event) pklres.Event {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("Expected an event, the channel is closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	return pklres.Event{}
+}
+
+func eventValue(v *string) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return *v
+}
+
+// TestPklresStoreWatch tests change events and op=waitFor
+func TestPklresStoreWatch(t *testing.T) {
+	store := pklres.NewMemoryStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	events := store.Watch(ctx, "llm", "resp")
+
+	_ = store.Set("g1", "llm", "response", "hello")
+	_ = store.Set("g1", "llm", "prompt", "ignored")
+	_ = store.Set("g1", "exec", "response", "ignored")
+	_ = store.SetMany("g2", "llm", map[string]string{"response": "hi", "responseTime": "1.s"})
+	_ = store.Set("g1", "llm", "response", "bye")
+	_, _ = store.Delete("g1", "llm", "response")
+	_ = store.Clear("g2")
+
+	want := []string{
+		"set g1 response <nil> hello",
+		"set g2 response <nil> hi",
+		"set g2 responseTime <nil> 1.s",
+		"set g1 response hello bye",
+		"delete g1 response bye <nil>",
+		"clear g2 response hi <nil>",
+		"clear g2 responseTime 1.s <nil>",
+	}
+	for _, w := range want {
+		e := nextEvent(t, events)
+		got := strings.Join([]string{string(e.Type), e.GraphID, e.Key, eventValue(e.OldValue), eventValue(e.NewValue)}, " ")
+		if got != w || e.Collection != "llm" || e.Time.IsZero() {
+			t.Errorf("Expected event %q, got %q", w, got)
+		}
+	}
+	cancel()
+	for range events {
+	}
+
+	t.Run("FileStorage", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "store")
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		fs, err := pklres.NewFileStorage(filepath.Join(dir, "pklres.json"))
+		if err != nil {
+			t.Fatalf("Failed to open storage: %v", err)
+		}
+		events := fs.Watch(t.Context(), "", "")
+		_ = fs.Set("g", "exec", "stdout", "ok")
+		if e := nextEvent(t, events); e.Type != pklres.EventSet || e.Collection != "exec" {
+			t.Errorf("Unexpected event %+v", e)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove directory: %v", err)
+		}
+		if err := fs.Set("g", "exec", "stderr", "lost"); err == nil {
+			t.Fatal("Expected error writing to a removed directory")
+		}
+		select {
+		case e := <-events:
+			t.Errorf("Expected no event for a failed write, got %+v", e)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("WaitFor", func(t *testing.T) {
+		r := pklres.NewReader(store, pklres.WithGraphID("g3"))
+		setPklres(t, r, "exec", "stdout", "ready")
+		if got := readPklres(t, r, "op=waitFor&collection=exec&key=stdout&timeout=1"); got != "ready" {
+			t.Errorf("Expected the existing value, got %q", got)
+		}
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			_ = store.Set("other", "exec", "exitCode", "1")
+			_ = store.Set("g3", "exec", "exitCode", "0")
+		}()
+		if got := readPklres(t, r, "op=waitFor&collection=exec&key=exitCode&timeout=5.s"); got != "0" {
+			t.Errorf("Expected the value set later, got %q", got)
+		}
+
+		for _, query := range []string{
+			"op=waitFor&collection=exec&key=missing&timeout=10ms",
+			"op=waitFor&collection=exec&key=missing&timeout=soon",
+			"op=waitFor&collection=exec",
+		} {
+			uri, _ := url.Parse("pklres://?" + query)
+			if _, err := r.Read(*uri); err == nil {
+				t.Errorf("Expected error for %q", query)
+			}
+		}
+
+		unwatched := pklres.NewReader(struct{ pklres.Storage }{store})
+		uri, _ := url.Parse("pklres://?op=waitFor&collection=exec&key=stdout")
+		if _, err := unwatched.Read(*uri); err == nil {
+			t.Error("Expected error for a storage without Watch")
+		}
+	})
+}
+
+// TestPklresStoreRelational tests the select, project and join ops
+func TestPklresStoreRelational(t *testing.T) {
+	r := pklres.NewReader(pklres.NewMemoryStorage())
+	setPklres(t, r, "users", "user1", `{"id": "user1", "name": "Alice", "age": 25, "department": "engineering"}`)
+	setPklres(t, r, "users", "user2", `{"id": "user2", "name": "Bob", "age": 30, "department": "marketing"}`)
+	setPklres(t, r, "users", "user3", `{"id": "user3", "name": "Charlie", "age": 35, "department": "engineering"}`)
+	setPklres(t, r, "orders", "order1", `{"id": "order1", "userId": "user1", "amount": 100.5}`)
+	setPklres(t, r, "orders", "order2", `{"id": "order2", "userId": "user1", "amount": 75.25}`)
+	setPklres(t, r, "orders", "order3", `{"id": "order3", "userId": "user9", "amount": 10}`)
+
+	keys := func(res pklres.Result, field string) []string {
+		var got []string
+		for _, row := range res.Rows {
+			s, _ := row.Data[field].(string)
+			got = append(got, s)
+		}
+		return got
+	}
+	selectQuery := func(conditions string) pklres.Result {
+		return readPklresResult(t, r, url.Values{"op": {"relationalSelect"}, "collection": {"users"}, "conditions": {conditions}}.Encode())
+	}
+
+	t.Run("Select", func(t *testing.T) {
+		res := selectQuery(`[{"field": "department", "operator": "eq", "value": "engineering"}, {"field": "age", "operator": "gte", "value": 30}]`)
+		if !reflect.DeepEqual(keys(res, "key"), []string{"user3"}) {
+			t.Errorf("Unexpected selection %v", keys(res, "key"))
+		}
+		if !reflect.DeepEqual(res.Columns, []string{"age", "department", "id", "key", "name", "value"}) {
+			t.Errorf("Unexpected columns %v", res.Columns)
+		}
+		if res.Query != "SELECT * FROM users WHERE department eq engineering AND age gte 30" || res.TTL != "5m0s" {
+			t.Errorf("Unexpected query %q and ttl %q", res.Query, res.TTL)
+		}
+
+		cases := map[string][]string{
+			`[{"field": "name", "operator": "contains", "value": "li"}]`:    {"user1", "user3"},
+			`[{"field": "age", "operator": "in", "value": [25, 35]}]`:       {"user1", "user3"},
+			`[{"field": "department", "operator": "ne", "value": "sales"}]`: {"user1", "user2", "user3"},
+			`[{"field": "missing", "operator": "ne", "value": "x"}]`:        nil,
+			`[{"field": "age", "operator": "lt", "value": "30"}]`:           {"user1"},
+			`[]`: {"user1", "user2", "user3"},
+		}
+		for conditions, want := range cases {
+			if got := keys(selectQuery(conditions), "key"); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %v for %s, got %v", want, conditions, got)
+			}
+		}
+	})
+
+	t.Run("Project", func(t *testing.T) {
+		res := readPklresResult(t, r, url.Values{"op": {"relationalProject"}, "collection": {"users"}, "condition": {`{"columns": ["name", "age"], "exclude": []}`}}.Encode())
+		if len(res.Rows) != 3 || !reflect.DeepEqual(res.Columns, []string{"age", "name"}) || !reflect.DeepEqual(keys(res, "name"), []string{"Alice", "Bob", "Charlie"}) {
+			t.Errorf("Unexpected projection %+v", res)
+		}
+		res = readPklresResult(t, r, url.Values{"op": {"relationalProject"}, "collection": {"users"}, "condition": {`{"columns": [], "exclude": ["value", "id"]}`}}.Encode())
+		if !reflect.DeepEqual(res.Columns, []string{"age", "department", "key", "name"}) {
+			t.Errorf("Unexpected columns %v", res.Columns)
+		}
+	})
+
+	t.Run("Join", func(t *testing.T) {
+		join := func(joinType string) pklres.Result {
+			condition := `{"leftCollection": "users", "rightCollection": "orders", "leftKey": "id", "rightKey": "userId", "joinType": "` + joinType + `"}`
+			return readPklresResult(t, r, url.Values{"op": {"relationalJoin"}, "condition": {condition}}.Encode())
+		}
+		inner := join("inner")
+		if !reflect.DeepEqual(keys(inner, "orders.key"), []string{"order1", "order2"}) || !reflect.DeepEqual(keys(inner, "users.name"), []string{"Alice", "Alice"}) {
+			t.Errorf("Unexpected inner join %+v", inner.Rows)
+		}
+		if inner.Query != "SELECT * FROM users INNER JOIN orders ON users.id = orders.userId" {
+			t.Errorf("Unexpected query %q", inner.Query)
+		}
+		if n := len(join("left").Rows); n != 4 {
+			t.Errorf("Expected 4 rows from a left join, got %d", n)
+		}
+		if n := len(join("right").Rows); n != 3 {
+			t.Errorf("Expected 3 rows from a right join, got %d", n)
+		}
+		if n := len(join("full").Rows); n != 5 {
+			t.Errorf("Expected 5 rows from a full join, got %d", n)
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		query := `{"where": {"operator": "or", "conditions": [
+			{"operator": "eq", "field": "department", "value": "marketing"},
+			{"operator": "lt", "field": "age", "value": 30}
+		]}, "orderBy": [{"field": "age", "descending": true}], "limit": 1}`
+		res := readPklresResult(t, r, url.Values{"op": {"relationalQuery"}, "collection": {"users"}, "query": {query}}.Encode())
+		if !reflect.DeepEqual(keys(res, "key"), []string{"user2"}) || res.NextCursor == nil {
+			t.Fatalf("Unexpected first page %v", keys(res, "key"))
+		}
+		params, _ := json.Marshal(map[string]any{"collection": "users", "query": json.RawMessage(query[:len(query)-1] + `, "cursor": "` + *res.NextCursor + `"}`)})
+		res = readPklresResult(t, r, url.Values{"op": {"queryWithCache"}, "queryType": {"query"}, "params": {string(params)}}.Encode())
+		if !reflect.DeepEqual(keys(res, "key"), []string{"user1"}) || res.NextCursor != nil {
+			t.Errorf("Unexpected last page %v", keys(res, "key"))
+		}
+	})
+
+	t.Run("Aggregate", func(t *testing.T) {
+		aggregations := `[{"operator": "count"}, {"operator": "avg", "field": "age", "alias": "averageAge"}]`
+		res := readPklresResult(t, r, url.Values{"op": {"aggregate"}, "collection": {"users"}, "groupBy": {`["department"]`}, "aggregations": {aggregations}}.Encode())
+		want := []map[string]any{
+			{"department": "engineering", "count": float64(2), "averageAge": float64(30)},
+			{"department": "marketing", "count": float64(1), "averageAge": float64(30)},
+		}
+		if len(res.Rows) != 2 || !reflect.DeepEqual(res.Rows[0].Data, want[0]) || !reflect.DeepEqual(res.Rows[1].Data, want[1]) {
+			t.Errorf("Expected %v, got %+v", want, res.Rows)
+		}
+
+		where := `{"operator": "gt", "field": "age", "value": 25}`
+		res = readPklresResult(t, r, url.Values{"op": {"aggregate"}, "collection": {"users"}, "aggregations": {`[{"operator": "count"}]`}, "where": {where}}.Encode())
+		if len(res.Rows) != 1 || res.Rows[0].Data["count"] != float64(2) || res.Query != "SELECT count(*) AS count FROM users WHERE age gt 25" {
+			t.Errorf("Unexpected filtered aggregation %+v", res)
+		}
+
+		params := `{"collection": "orders", "groupBy": ["userId"], "aggregations": [{"operator": "sum", "field": "amount"}]}`
+		res = readPklresResult(t, r, url.Values{"op": {"queryWithCache"}, "queryType": {"aggregate"}, "params": {params}}.Encode())
+		if len(res.Rows) != 2 || res.Rows[0].Data["sum_amount"] != 175.75 || res.Rows[1].Data["sum_amount"] != float64(10) {
+			t.Errorf("Unexpected sums %+v", res.Rows)
+		}
+
+		uri, _ := url.Parse("pklres://?" + url.Values{"op": {"aggregate"}, "collection": {"users"}}.Encode())
+		if _, err := r.Read(*uri); err == nil {
+			t.Error("Expected error for op=aggregate without aggregations")
+		}
+	})
+
+	t.Run("QueryWithCache", func(t *testing.T) {
+		params := `{"collectionKey": "users", "conditions": [{"field": "department", "operator": "eq", "value": "marketing"}]}`
+		res := readPklresResult(t, r, url.Values{"op": {"queryWithCache"}, "queryType": {"select"}, "params": {params}}.Encode())
+		if !reflect.DeepEqual(keys(res, "key"), []string{"user2"}) {
+			t.Errorf("Unexpected selection %v", keys(res, "key"))
+		}
+		params = `{"collection": "users", "columns": ["name"]}`
+		res = readPklresResult(t, r, url.Values{"op": {"queryWithCache"}, "queryType": {"project"}, "params": {params}}.Encode())
+		if !reflect.DeepEqual(res.Columns, []string{"name"}) {
+			t.Errorf("Unexpected columns %v", res.Columns)
+		}
+	})
+}
+
+// TestPklresStoreCache tests caching of relational results
+func TestPklresStoreCache(t *testing.T) {
+	r := pklres.NewReader(pklres.NewMemoryStorage())
+	setPklres(t, r, "users", "user1", `{"name": "Alice"}`)
+
+	stats := func() pklres.CacheStats {
+		var s pklres.CacheStats
+		if err := json.Unmarshal([]byte(readPklres(t, r, "op=getCacheStats")), &s); err != nil {
+			t.Fatalf("Failed to decode stats: %v", err)
+		}
+		return s
+	}
+	project := url.Values{"op": {"relationalProject"}, "collection": {"users"}, "condition": {`{}`}}.Encode()
+
+	readPklresResult(t, r, project)
+	readPklresResult(t, r, project)
+	if s := stats(); s.Entries != 1 || s.Hits != 1 || s.Misses != 1 || s.TTL != "5m0s" {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	setPklres(t, r, "users", "user2", `{"name": "Bob"}`)
+	if res := readPklresResult(t, r, project); len(res.Rows) != 2 {
+		t.Errorf("Expected set to invalidate cached results, got %d rows", len(res.Rows))
+	}
+
+	if got := readPklres(t, r, "op=setCacheTTL&ttl=60"); got != "1m0s" {
+		t.Errorf("Unexpected TTL %q", got)
+	}
+	readPklres(t, r, "op=clearCache")
+	if s := stats(); s.Entries != 0 || s.TTL != "1m0s" {
+		t.Errorf("Expected an empty cache, got %+v", s)
+	}
+
+	readPklres(t, r, "op=setCacheTTL&ttl=0")
+	readPklresResult(t, r, project)
+	if s := stats(); s.Entries != 0 {
+		t.Errorf("Expected a TTL of 0 to disable caching, got %+v", s)
+	}
+}
+
+// unwatchedStorage hides the Watcher of a storage.
+type unwatchedStorage struct {
+	pklres.Storage
+}
+
+// TestPklresStoreTables tests that tables follow the cache of the reader for
+// writes made past it, and that readers over a Watcher invalidate on them
+func TestPklresStoreTables(t *testing.T) {
+	store := pklres.NewMemoryStorage()
+	r := pklres.NewReader(unwatchedStorage{store})
+	setPklres(t, r, "users", "user1", `{"name": "a"}`)
+
+	name := func(r *pklres.Reader) string {
+		res := readPklresResult(t, r, url.Values{"op": {"relationalSelect"}, "collection": {"users"}, "conditions": {`[]`}}.Encode())
+		if len(res.Rows) != 1 {
+			t.Fatalf("Expected 1 row, got %d", len(res.Rows))
+		}
+		s, _ := res.Rows[0].Data["name"].(string)
+		return s
+	}
+	set := func(value string) {
+		if err := store.Set(pklres.DefaultGraphID, "users", "user1", `{"name": "`+value+`"}`); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+
+	name(r)
+	set("b")
+	if got := name(r); got != "a" {
+		t.Errorf("Expected the cached table, got %q", got)
+	}
+	readPklres(t, r, "op=clearCache")
+	if got := name(r); got != "b" {
+		t.Errorf("Expected clearCache to drop tables, got %q", got)
+	}
+
+	readPklres(t, r, "op=setCacheTTL&ttl=0")
+	set("c")
+	if got := name(r); got != "c" {
+		t.Errorf("Expected a TTL of 0 to disable table caching, got %q", got)
+	}
+	set("d")
+	if got := name(r); got != "d" {
+		t.Errorf("Expected a TTL of 0 to disable table caching, got %q", got)
+	}
+
+	watching := pklres.NewReader(store)
+	defer watching.Close()
+	name(watching)
+	for _, value := range []string{"e", "f"} {
+		set(value)
+		deadline := time.Now().Add(5 * time.Second)
+		for name(watching) != value && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := name(watching); got != value {
+			t.Errorf("Expected a direct write to invalidate the table, got %q", got)
+		}
+	}
+	if err := store.Clear(pklres.DefaultGraphID); err != nil {
+		t.Fatalf("Failed to clear storage: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	rows := func() int {
+		return len(readPklresResult(t, watching, url.Values{"op": {"relationalSelect"}, "collection": {"users"}, "conditions": {`[]`}}.Encode()).Rows)
+	}
+	for rows() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := rows(); n != 0 {
+		t.Errorf("Expected Clear to invalidate the table, got %d rows", n)
+	}
+}
+
+// TestPklresCacheLRU tests the bounds, invalidation and statistics of the LRU cache
+func TestPklresCacheLRU(t *testing.T) {
+	key := func(params string) pklres.CacheKey {
+		return pklres.CacheKey{GraphID: "g1", Op: "select", Params: params}
+	}
+	get := func(c *pklres.LRUCache, k pklres.CacheKey) bool {
+		_, _, ok := c.Get(k)
+		return ok
+	}
+
+	t.Run("Entries", func(t *testing.T) {
+		c := pklres.NewLRUCache(time.Minute, 2, 0)
+		for _, p := range []string{"a", "b"} {
+			_, gen, _ := c.Get(key(p))
+			c.Put(key(p), gen, []byte("result"), time.Millisecond, "users")
+		}
+		get(c, key("a"))
+		_, gen, _ := c.Get(key("c"))
+		c.Put(key("c"), gen, []byte("result"), 3*time.Millisecond, "users")
+		if !get(c, key("a")) || get(c, key("b")) || !get(c, key("c")) {
+			t.Error("Expected the least recently used result to be evicted")
+		}
+		s := c.Stats()
+		if s.Entries != 2 || s.Evictions != 1 || s.MaxEntries != 2 {
+			t.Errorf("Unexpected stats %+v", s)
+		}
+		op := s.Ops["select"]
+		if op.Hits != 3 || op.Misses != 4 || op.MaxLatency != "3ms" || op.AvgLatency != "1.666666ms" {
+			t.Errorf("Unexpected op stats %+v", op)
+		}
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		c := pklres.NewLRUCache(time.Minute, 0, 20)
+		c.Put(key("a"), 0, []byte("0123456789"), 0)
+		c.Put(key("b"), 0, []byte("0123456789"), 0)
+		if get(c, key("a")) || !get(c, key("b")) {
+			t.Error("Expected the byte bound to evict the oldest result")
+		}
+		c.Put(key("c"), 0, make([]byte, 30), 0)
+		if get(c, key("c")) || !get(c, key("b")) {
+			t.Error("Expected a result larger than the bound not to be cached")
+		}
+		if s := c.Stats(); s.Bytes != int64(len("g1select")+1+10) {
+			t.Errorf("Unexpected bytes %d", s.Bytes)
+		}
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		c := pklres.NewLRUCache(time.Minute, 0, 0)
+		c.Put(key("users"), 0, []byte("1"), 0, "users")
+		c.Put(key("join"), 0, []byte("2"), 0, "users", "orders")
+		c.Put(pklres.CacheKey{GraphID: "g2", Op: "select", Params: "users"}, 0, []byte("3"), 0, "users")
+		_, stale, _ := c.Get(key("orders"))
+		c.Invalidate("g1", "orders")
+		c.Put(key("orders"), stale, []byte("4"), 0, "orders")
+		if !get(c, key("users")) || get(c, key("join")) || get(c, key("orders")) {
+			t.Error("Expected invalidation to drop the results reading the collection and stale results")
+		}
+		c.Clear("g1")
+		if get(c, key("users")) || !get(c, pklres.CacheKey{GraphID: "g2", Op: "select", Params: "users"}) {
+			t.Error("Expected clear to drop only the results of the graph")
+		}
+		if s := c.Stats(); s.Invalidations != 1 {
+			t.Errorf("Expected 1 invalidation, got %d", s.Invalidations)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		c := pklres.NewLRUCache(time.Minute, 0, 0)
+		c.Put(key("a"), 0, []byte("1"), 0, "users")
+		c.SetTTL(0)
+		if get(c, key("a")) {
+			t.Error("Expected a TTL of 0 to drop cached results")
+		}
+		c.Put(key("a"), 0, []byte("1"), 0, "users")
+		if get(c, key("a")) {
+			t.Error("Expected a TTL of 0 to disable caching")
+		}
+
+		c.SetTTL(time.Hour)
+		c.Put(key("b"), 0, []byte("2"), 0, "users")
+		c.SetTTL(time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		if get(c, key("b")) {
+			t.Error("Expected a shorter TTL to apply to cached results")
+		}
+		if s := c.Stats(); s.Entries != 0 || s.Expirations != 2 {
+			t.Errorf("Unexpected stats %+v", s)
+		}
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		cache := pklres.NewLRUCache(time.Minute, 0, 0)
+		r := pklres.NewReader(pklres.NewMemoryStorage(), pklres.WithCache(cache))
+		setPklres(t, r, "users", "user1", `{"age": 25}`)
+		setPklres(t, r, "users", "user2", `{"age": 30}`)
+
+		selectQuery := func(conditions string) int {
+			return len(readPklresResult(t, r, url.Values{"op": {"relationalSelect"}, "collection": {"users"}, "conditions": {conditions}}.Encode()).Rows)
+		}
+		selectQuery(`[{"field": "age", "operator": "gt", "value": 20}]`)
+		selectQuery(`[ { "value": 20, "operator": "gt", "field": "age" } ]`)
+		if s := cache.Stats(); s.Hits != 1 || s.Misses != 1 {
+			t.Errorf("Expected normalized params to share a result, got %+v", s)
+		}
+
+		if got := readPklres(t, r, "op=delete&collection=users&key=user1"); got != "true" {
+			t.Errorf("Expected delete to return true, got %q", got)
+		}
+		if got := readPklres(t, r, "op=delete&collection=users&key=user1"); got != "false" {
+			t.Errorf("Expected a second delete to return false, got %q", got)
+		}
+		if n := selectQuery(`[{"field": "age", "operator": "gt", "value": 20}]`); n != 1 {
+			t.Errorf("Expected delete to invalidate cached results, got %d rows", n)
+		}
+
+		var s pklres.CacheStats
+		if err := json.Unmarshal([]byte(readPklres(t, r, "op=getCacheStats")), &s); err != nil {
+			t.Fatalf("Failed to decode stats: %v", err)
+		}
+		if s.Invalidations != 1 || s.Ops["select"].Misses != 2 || s.MaxEntries != 0 {
+			t.Errorf("Unexpected stats %+v", s)
+		}
+	})
+}
+
+// TestPklresFileStorage tests persistence of the file storage
+func TestPklresFileStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pklres.json")
+	store, err := pklres.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	r := pklres.NewReader(store, pklres.WithGraphID("graph1"))
+	setPklres(t, r, "fetch", "status", "200")
+	setPklres(t, r, "fetch", "body", `{"ok": true}`)
+	if existed, err := store.Delete("graph1", "fetch", "status"); err != nil || !existed {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+
+	reopened, err := pklres.NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	entries, err := reopened.Entries("graph1", "fetch")
+	if err != nil {
+		t.Fatalf("Failed to read entries: %v", err)
+	}
+	if !reflect.DeepEqual(entries, map[string]string{"body": `{"ok": true}`}) {
+		t.Errorf("Unexpected entries after reopening %v", entries)
+	}
+	if names, _ := reopened.Collections("graph1"); !reflect.DeepEqual(names, []string{"fetch"}) {
+		t.Errorf("Unexpected collections %v", names)
+	}
+
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	if _, err := pklres.NewFileStorage(path); err != nil {
+		t.Errorf("Expected an empty file to open as an empty store: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if _, err := pklres.NewFileStorage(path); err == nil {
+		t.Error("Expected error for a corrupt store")
+	}
+}
@@ -0,0 +1,183 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kdeps/schema/assets"
+	"github.com/kdeps/schema/pklres"
+	"github.com/kdeps/schema/readeruri"
+)
+
+// readerOps are an op of every reader with params needing encoding
+var readerOps = map[string]readeruri.Op{
+	`pklres://?op=get&collection=%40a%2Ffetch%3A1.0.0&key=a%20b%26c`:         &readeruri.PklresGet{Collection: "@a/fetch:1.0.0", Key: "a b&c"},
+	`pklres://?op=set&collection=c&key=k&value=%3D%2B%3F`:                    &readeruri.PklresSet{Collection: "c", Key: "k", Value: "=+?"},
+	`pklres://?op=setMany&collection=c&values=%7B%22k%22%3A%22v%22%7D`:       &readeruri.PklresSetMany{Collection: "c", Values: `{"k":"v"}`},
+	`pklres://?op=list&collection=c`:                                         &readeruri.PklresList{Collection: "c"},
+	`pklres://?op=delete&collection=c&key=k`:                                 &readeruri.PklresDelete{Collection: "c", Key: "k"},
+	`pklres://?op=waitFor&collection=c&key=k`:                                &readeruri.PklresWaitFor{Collection: "c", Key: "k"},
+	`pklres://?op=waitFor&collection=c&key=k&timeout=30.s`:                   &readeruri.PklresWaitFor{Collection: "c", Key: "k", Timeout: "30.s"},
+	`pklres://?op=relationalSelect&collection=c&conditions=%5B%5D`:           &readeruri.PklresRelationalSelect{Collection: "c", Conditions: "[]"},
+	`pklres://?op=relationalProject&collection=c&condition=%7B%7D`:           &readeruri.PklresRelationalProject{Collection: "c", Condition: "{}"},
+	`pklres://?op=relationalJoin&condition=%7B%7D`:                           &readeruri.PklresRelationalJoin{Condition: "{}"},
+	`pklres://?op=relationalQuery&collection=c&query=%7B%7D`:                 &readeruri.PklresRelationalQuery{Collection: "c", Query: "{}"},
+	`pklres://?op=aggregate&collection=c&groupBy=%5B%5D&aggregations=%5B%5D`: &readeruri.PklresAggregate{Collection: "c", GroupBy: "[]", Aggregations: "[]"},
+	`pklres://?op=queryWithCache&queryType=select&params=%7B%7D`:             &readeruri.PklresQueryWithCache{QueryType: "select", Params: "{}"},
+	`pklres://?op=clearCache`:                                                &readeruri.PklresClearCache{},
+	`pklres://?op=setCacheTTL&ttl=60`:                                        &readeruri.PklresSetCacheTTL{TTL: "60"},
+	`pklres://?op=getCacheStats`:                                             &readeruri.PklresGetCacheStats{},
+	`session:/user%2F1`:                                                      &readeruri.SessionGet{ID: "user/1"},
+	`session:/s?op=set&value=hello%20world`:                                  &readeruri.SessionSet{ID: "s", Value: "hello world"},
+	`session:/s?op=delete`:                                                   &readeruri.SessionDelete{ID: "s"},
+	`session:/_?op=clear`:                                                    &readeruri.SessionClear{},
+	`memory:/m`:                                                              &readeruri.MemoryGet{ID: "m"},
+	`memory:/m?op=set&value=v`:                                               &readeruri.MemorySet{ID: "m", Value: "v"},
+	`memory:/m?op=delete`:                                                    &readeruri.MemoryDelete{ID: "m"},
+	`memory:/_?op=clear`:                                                     &readeruri.MemoryClear{},
+	`item:/_?op=current`:                                                     &readeruri.ItemCurrent{},
+	`item:/_?op=prev`:                                                        &readeruri.ItemPrev{},
+	`item:/_?op=next`:                                                        &readeruri.ItemNext{},
+	`item:/loop?op=values`:                                                   &readeruri.ItemValues{ID: "loop"},
+	`tool:/t`:                                                                &readeruri.ToolGet{ID: "t"},
+	`tool:/t?op=run&script=echo%20%241&params=a%20b`:                         &readeruri.ToolRun{ID: "t", Script: "echo $1", Params: "a b"},
+	`tool:/t?op=history`:                                                     &readeruri.ToolHistory{ID: "t"},
+	`agent:/fetch`:                                                           &readeruri.AgentResolve{ActionID: "fetch"},
+	`agent:/fetch?agent=a&version=1.0.0`:                                     &readeruri.AgentResolve{ActionID: "fetch", Agent: "a", Version: "1.0.0"},
+	`agent:/?op=list-installed`:                                              &readeruri.AgentListInstalled{},
+	`agent:/a?op=list&version=1.0.0`:                                         &readeruri.AgentList{Agent: "a", Version: "1.0.0"},
+}
+
+// TestReaderURIRoundTrip tests encoding and parsing the URIs of every reader op
+func TestReaderURIRoundTrip(t *testing.T) {
+	for want, op := range readerOps {
+		if got := op.Encode(); got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+			continue
+		}
+		u, err := url.Parse(want)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", want, err)
+		}
+		parsed, err := readeruri.Parse(*u)
+		if err != nil {
+			t.Errorf("Failed to parse op of %s: %v", want, err)
+			continue
+		}
+		if !reflect.DeepEqual(parsed, op) {
+			t.Errorf("Expected %+v from %s, got %+v", op, want, parsed)
+		}
+	}
+}
+
+// TestReaderURIStrict tests that malformed URIs are rejected
+func TestReaderURIStrict(t *testing.T) {
+	cases := map[string]error{
+		"pklres://?op=get&collection=c":                              readeruri.ErrMissingParam,
+		"pklres://?op=get&collection=c&key=k&x=1":                    readeruri.ErrUnknownParam,
+		"pklres://?op=get&collection=c&key=k&key=j":                  readeruri.ErrRepeatedParam,
+		"pklres://?op=drop&collection=c":                             readeruri.ErrUnknownOp,
+		"pklres://?collection=c&key=k":                               readeruri.ErrUnknownOp,
+		"agent:/a?op=list&agent=b":                                   readeruri.ErrConflictingParam,
+		"agent:/a?agent=a&op=list-installed":                         readeruri.ErrUnknownParam,
+		"files:/a":                                                   readeruri.ErrUnknownScheme,
+		"session:/?op=delete":                                        readeruri.ErrMissingParam,
+		"session:/s?op=set":                                          readeruri.ErrMissingParam,
+		"pklres://?op=list&collection=c&v=3":                         readeruri.ErrUnsupportedVersion,
+		"pklres://?op=delete&collection=c&key=k&v=1":                 readeruri.ErrUnsupportedVersion,
+		"pklres://?op=relationalQuery&collection=c&query=%7B%7D&v=1": readeruri.ErrUnsupportedVersion,
+	}
+	for uri, want := range cases {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", uri, err)
+		}
+		if _, err := readeruri.Parse(*u); !errors.Is(err, want) {
+			t.Errorf("Expected %v for %s, got %v", want, uri, err)
+		}
+	}
+
+	u, _ := url.Parse("pklres://?op=list&collection=c")
+	if err := new(readeruri.PklresGet).Decode(*u); !errors.Is(err, readeruri.ErrUnknownOp) {
+		t.Errorf("Expected %v decoding op=list as op=get, got %v", readeruri.ErrUnknownOp, err)
+	}
+	u, _ = url.Parse("pklres://?op=list&collection=c&v=1")
+	if _, err := readeruri.Parse(*u); err != nil {
+		t.Errorf("Expected version 1 URI to parse, got %v", err)
+	}
+}
+
+// TestReaderURIVersions tests version negotiation
+func TestReaderURIVersions(t *testing.T) {
+	if v, err := readeruri.Negotiate(1, 5); err != nil || v != readeruri.Version {
+		t.Errorf("Expected version %d, got %d (%v)", readeruri.Version, v, err)
+	}
+	if v, err := readeruri.Negotiate(1, 1); err != nil || v != 1 {
+		t.Errorf("Expected version 1, got %d (%v)", v, err)
+	}
+	if _, err := readeruri.Negotiate(readeruri.Version+1, readeruri.Version+2); !errors.Is(err, readeruri.ErrUnsupportedVersion) {
+		t.Errorf("Expected %v, got %v", readeruri.ErrUnsupportedVersion, err)
+	}
+
+	uri, err := readeruri.EncodeVersion(&readeruri.PklresList{Collection: "c"}, 1)
+	if err != nil || uri != "pklres://?op=list&collection=c&v=1" {
+		t.Errorf("Unexpected versioned URI %s (%v)", uri, err)
+	}
+	if uri, err := readeruri.EncodeVersion(&readeruri.ItemCurrent{}, 2); err != nil || uri != "item:/_?op=current&v=2" {
+		t.Errorf("Unexpected versioned URI %s (%v)", uri, err)
+	}
+	if uri, err := readeruri.EncodeVersion(&readeruri.SessionGet{ID: "s"}, 1); err != nil || uri != "session:/s?v=1" {
+		t.Errorf("Unexpected versioned URI %s (%v)", uri, err)
+	}
+	if _, err := readeruri.EncodeVersion(&readeruri.PklresSetMany{Collection: "c", Values: "{}"}, 1); !errors.Is(err, readeruri.ErrUnsupportedVersion) {
+		t.Errorf("Expected setMany to require version 2, got %v", err)
+	}
+}
+
+// TestReaderURIPKL tests that the embedded ReaderURI.pkl is generated from the Go definitions
+func TestReaderURIPKL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := readeruri.WritePKL(&buf); err != nil {
+		t.Fatalf("Failed to write PKL: %v", err)
+	}
+	embedded, err := assets.GetPKLFileAsString(readeruri.PKLModule)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", readeruri.PKLModule, err)
+	}
+	if embedded != buf.String() {
+		t.Errorf("%s is out of date, run make readeruri", readeruri.PKLModule)
+	}
+	for _, want := range []string{
+		`function pklresGet(collection: String, key: String): String =`,
+		`function pklresWaitFor(collection: String, key: String, timeout: String?): String =`,
+		`function agentListInstalled(): String =`,
+		`"session:/\(URI.encodeComponent(id))?op=set&value=\(URI.encodeComponent(value))"`,
+	} {
+		if !strings.Contains(embedded, want) {
+			t.Errorf("Expected %s to contain %s", readeruri.PKLModule, want)
+		}
+	}
+}
+
+// TestReaderURIPklres tests that the pklres reader accepts every pklres op
+func TestReaderURIPklres(t *testing.T) {
+	store := pklres.NewMemoryStorage()
+	_ = store.Set("graph", "c", "k", "v")
+	reader := pklres.NewReader(store, pklres.WithGraphID("graph"))
+	for uri, op := range readerOps {
+		if !strings.HasPrefix(uri, "pklres:") {
+			continue
+		}
+		if _, ok := op.(*readeruri.PklresDelete); ok {
+			continue // would remove the key op=waitFor returns
+		}
+		u, _ := url.Parse(op.Encode())
+		if _, err := reader.Read(*u); err != nil && (strings.Contains(err.Error(), "missing parameter") || strings.Contains(err.Error(), "unsupported op")) {
+			t.Errorf("Expected pklres to accept %s, got %v", uri, err)
+		}
+	}
+}
@@ -10,13 +10,10 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/kdeps/kdeps/pkg/agent"
-	"github.com/kdeps/kdeps/pkg/logging"
-	"github.com/kdeps/kdeps/pkg/pklres"
-	"github.com/spf13/afero"
+	"github.com/kdeps/schema/agents"
 )
 
-// TestRealAgentReader tests the actual agent resource reader from kdeps
+// TestRealAgentReader tests the agent resource reader of the schema
 func TestRealAgentReader(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "agent-test-*")
@@ -32,7 +29,7 @@ func TestRealAgentReader(t *testing.T) {
 	}
 
 	// Create a test agent with workflow.pkl
-	testAgentDir := filepath.Join(agentsDir, "test-agent", "1.0.0")
+	testAgentDir := filepath.Join(agentsDir, "test_agent", "1.0.0")
 	if err := os.MkdirAll(testAgentDir, 0755); err != nil {
 		t.Fatalf("Failed to create test agent directory: %v", err)
 	}
@@ -43,23 +40,19 @@ func TestRealAgentReader(t *testing.T) {
 
 module test.Workflow
 
-ActionID = "test-action"
+ActionID = "test_action"
 Description = "Test action for agent reader testing"
 `
 	if err := os.WriteFile(filepath.Join(testAgentDir, "workflow.pkl"), []byte(workflowContent), 0644); err != nil {
 		t.Fatalf("Failed to write workflow.pkl: %v", err)
 	}
 
-	// Create logger
-	logger := logging.NewTestLogger()
-
 	// Initialize the real agent reader
-	fs := afero.NewOsFs()
-	agentReader, err := agent.InitializeAgent(fs, tempDir, "test-agent", "1.0.0", logger)
+	registry, err := agents.LoadDir(agentsDir)
 	if err != nil {
 		t.Fatalf("Failed to initialize agent reader: %v", err)
 	}
-	defer agentReader.Close()
+	agentReader := agents.NewReader(registry, agents.WithWorkflow("test_agent", "1.0.0"))
 
 	testCases := []struct {
 		name     string
@@ -69,38 +62,32 @@ Description = "Test action for agent reader testing"
 	}{
 		{
 			name:     "Resolve local action ID",
-			uri:      "agent:/test-action",
-			expected: "@test-agent/test-action:1.0.0",
+			uri:      "agent:/test_action",
+			expected: "@test_agent/test_action:1.0.0",
 			contains: true,
 		},
 		{
 			name:     "Resolve with query parameters",
-			uri:      "agent:/test-action?agent=test-agent&version=1.0.0",
-			expected: "@test-agent/test-action:1.0.0",
+			uri:      "agent:/test_action?agent=test_agent&version=1.0.0",
+			expected: "@test_agent/test_action:1.0.0",
 			contains: true,
 		},
 		{
 			name:     "List installed agents",
 			uri:      "agent:/?op=list-installed",
-			expected: "test-agent",
+			expected: "test_agent",
 			contains: true,
 		},
 		{
 			name:     "List agent resources",
-			uri:      "agent:/test-agent?op=list&agent=test-agent&version=1.0.0",
-			expected: "copy_all_resources",
-			contains: true,
-		},
-		{
-			name:     "Resolve canonical agent ID",
-			uri:      "agent:/@test-agent:1.0.0",
-			expected: "@test-agent:1.0.0",
+			uri:      "agent:/test_agent?op=list&agent=test_agent&version=1.0.0",
+			expected: "@test_agent/test_action:1.0.0",
 			contains: true,
 		},
 		{
 			name:     "Resolve canonical action ID",
-			uri:      "agent:/@test-agent/test-action:1.0.0",
-			expected: "@test-agent/test-action:1.0.0",
+			uri:      "agent:/@test_agent/test_action:1.0.0",
+			expected: "@test_agent/test_action:1.0.0",
 			contains: true,
 		},
 	}
@@ -131,7 +118,7 @@ Description = "Test action for agent reader testing"
 	}
 }
 
-// TestRealPklresReader tests the actual pklres resource reader from kdeps
+// TestRealPklresReader tests the pklres resource reader of the schema
 func TestRealPklresReader(t *testing.T) {
 	// Create a temporary database file
 	tempDB, err := os.CreateTemp("", "pklres-test-*.db")
@@ -142,11 +129,7 @@ func TestRealPklresReader(t *testing.T) {
 	tempDB.Close()
 
 	// Initialize the real pklres reader
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		t.Fatalf("Failed to initialize pklres reader: %v", err)
-	}
-	// Note: pklresReader doesn't have a Close method, database will be cleaned up when temp file is removed
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
 
 	testCases := []struct {
 		name     string
@@ -156,51 +139,51 @@ func TestRealPklresReader(t *testing.T) {
 	}{
 		{
 			name:     "Set record",
-			uri:      "pklres:/test-id?op=set&type=exec&key=command&value=echo hello",
+			uri:      "pklres://?op=set&collection=test-id&key=command&value=echo%20hello",
 			expected: "echo hello",
 			contains: false,
 		},
 		{
 			name:     "Get record",
-			uri:      "pklres:/test-id?op=get&type=exec&key=command",
+			uri:      "pklres://?op=get&collection=test-id&key=command",
 			expected: "echo hello",
 			contains: false,
 		},
 		{
 			name:     "Get non-existent record",
-			uri:      "pklres:/nonexistent?op=get&type=exec&key=command",
+			uri:      "pklres://?op=get&collection=nonexistent&key=command",
 			expected: "",
 			contains: false,
 		},
 		{
-			name:     "Set record without key",
-			uri:      "pklres:/test-id2?op=set&type=python&value=print('hello')",
+			name:     "Set record in another collection",
+			uri:      "pklres://?op=set&collection=test-id2&key=script&value=print('hello')",
 			expected: "print('hello')",
 			contains: false,
 		},
 		{
-			name:     "Get record without key",
-			uri:      "pklres:/test-id2?op=get&type=python",
+			name:     "Get record in another collection",
+			uri:      "pklres://?op=get&collection=test-id2&key=script",
 			expected: "print('hello')",
 			contains: false,
 		},
 		{
 			name:     "List records",
-			uri:      "pklres:/?op=list&type=exec",
-			expected: "test-id",
+			uri:      "pklres://?op=list&collection=test-id",
+			expected: "command",
 			contains: true,
 		},
 		{
 			name:     "Delete specific key",
-			uri:      "pklres:/test-id?op=delete&type=exec&key=command",
-			expected: "Deleted 1 record(s)",
-			contains: true,
+			uri:      "pklres://?op=delete&collection=test-id&key=command",
+			expected: "true",
+			contains: false,
 		},
 		{
-			name:     "Clear records by type",
-			uri:      "pklres:/?op=clear&type=python",
-			expected: "Cleared 1 records",
-			contains: true,
+			name:     "Get deleted record",
+			uri:      "pklres://?op=get&collection=test-id&key=command",
+			expected: "",
+			contains: false,
 		},
 	}
 
@@ -246,7 +229,7 @@ func TestRealResourceReadersIntegration(t *testing.T) {
 	}
 
 	// Create a test agent with workflow.pkl
-	testAgentDir := filepath.Join(agentsDir, "test-agent", "1.0.0")
+	testAgentDir := filepath.Join(agentsDir, "test_agent", "1.0.0")
 	if err := os.MkdirAll(testAgentDir, 0755); err != nil {
 		t.Fatalf("Failed to create test agent directory: %v", err)
 	}
@@ -257,23 +240,19 @@ func TestRealResourceReadersIntegration(t *testing.T) {
 
 module test.Workflow
 
-ActionID = "test-action"
+ActionID = "test_action"
 Description = "Test action for integration testing"
 `
 	if err := os.WriteFile(filepath.Join(testAgentDir, "workflow.pkl"), []byte(workflowContent), 0644); err != nil {
 		t.Fatalf("Failed to write workflow.pkl: %v", err)
 	}
 
-	// Create logger
-	logger := logging.NewTestLogger()
-
 	// Initialize the real agent reader
-	fs := afero.NewOsFs()
-	agentReader, err := agent.InitializeAgent(fs, tempDir, "test-agent", "1.0.0", logger)
+	registry, err := agents.LoadDir(agentsDir)
 	if err != nil {
 		t.Fatalf("Failed to initialize agent reader: %v", err)
 	}
-	defer agentReader.Close()
+	agentReader := agents.NewReader(registry, agents.WithWorkflow("test_agent", "1.0.0"))
 
 	// Create temporary database for pklres reader
 	tempDB, err := os.CreateTemp("", "pklres-integration-*.db")
@@ -284,10 +263,9 @@ Description = "Test action for integration testing"
 	tempDB.Close()
 
 	// Initialize the real pklres reader
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		t.Fatalf("Failed to initialize pklres reader: %v", err)
-	}
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
+
+	RequirePkl(t)
 
 	// Create evaluator with real resource readers
 	evaluator, err := NewTestEvaluator(agentReader, pklresReader)
@@ -384,14 +362,11 @@ module test.Workflow
 	}
 
 	// Initialize agent reader
-	logger := logging.NewTestLogger()
-	fs := afero.NewOsFs()
-
-	agentReader, err := agent.InitializeAgent(fs, tempDir, "agent1", "1.0.0", logger)
+	registry, err := agents.LoadDir(agentsDir)
 	if err != nil {
 		t.Fatalf("Failed to initialize agent reader: %v", err)
 	}
-	defer agentReader.Close()
+	agentReader := agents.NewReader(registry, agents.WithWorkflow("agent1", "1.0.0"))
 
 	// Test latest version resolution
 	testCases := []struct {
@@ -399,21 +374,11 @@ module test.Workflow
 		uri      string
 		expected string
 	}{
-		{
-			name:     "Resolve agent1 without version (should get latest)",
-			uri:      "agent:/@agent1",
-			expected: "@agent1:2.0.0",
-		},
 		{
 			name:     "Resolve agent1 action without version (should get latest)",
 			uri:      "agent:/@agent1/action1",
 			expected: "@agent1/action1:2.0.0",
 		},
-		{
-			name:     "Resolve specific version",
-			uri:      "agent:/@agent1:1.0.0",
-			expected: "@agent1:1.0.0",
-		},
 		{
 			name:     "Resolve specific version action",
 			uri:      "agent:/@agent1/action1:1.0.0",
@@ -452,19 +417,19 @@ module test.Workflow
 			t.Fatalf("Failed to read from agent reader: %v", err)
 		}
 
-		var agents []agent.AgentInfo
-		if err := json.Unmarshal(result, &agents); err != nil {
+		var installed []agents.Agent
+		if err := json.Unmarshal(result, &installed); err != nil {
 			t.Fatalf("Failed to unmarshal agents: %v", err)
 		}
 
 		expectedCount := 3 // agent1:1.0.0, agent1:2.0.0, agent2:1.0.0
-		if len(agents) != expectedCount {
-			t.Errorf("Expected %d agents, got %d", expectedCount, len(agents))
+		if len(installed) != expectedCount {
+			t.Errorf("Expected %d agents, got %d", expectedCount, len(installed))
 		}
 
 		// Check that we have the expected agents
 		agentMap := make(map[string]bool)
-		for _, a := range agents {
+		for _, a := range installed {
 			agentMap[fmt.Sprintf("%s:%s", a.Name, a.Version)] = true
 		}
 
@@ -488,11 +453,7 @@ func TestPklresReaderConcurrency(t *testing.T) {
 	tempDB.Close()
 
 	// Initialize the real pklres reader
-	pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-	if err != nil {
-		t.Fatalf("Failed to initialize pklres reader: %v", err)
-	}
-	// Note: pklresReader doesn't have a Close method, database will be cleaned up when temp file is removed
+	pklresReader := NewTestPklresReader(t, tempDB.Name())
 
 	// Test concurrent set operations
 	t.Run("Concurrent set operations", func(t *testing.T) {
@@ -503,7 +464,7 @@ func TestPklresReaderConcurrency(t *testing.T) {
 			go func(id int) {
 				defer func() { done <- true }()
 
-				uri := fmt.Sprintf("pklres:/test-id-%d?op=set&type=exec&key=command&value=echo hello-%d", id, id)
+				uri := fmt.Sprintf("pklres://?op=set&collection=test-id-%d&key=command&value=echo%%20hello-%d", id, id)
 				parsedURL, err := url.Parse(uri)
 				if err != nil {
 					t.Errorf("Failed to parse URI: %v", err)
@@ -538,7 +499,7 @@ func TestPklresReaderConcurrency(t *testing.T) {
 			go func(id int) {
 				defer func() { done <- true }()
 
-				uri := fmt.Sprintf("pklres:/test-id-%d?op=get&type=exec&key=command", id)
+				uri := fmt.Sprintf("pklres://?op=get&collection=test-id-%d&key=command", id)
 				parsedURL, err := url.Parse(uri)
 				if err != nil {
 					t.Errorf("Failed to parse URI: %v", err)
@@ -576,14 +537,11 @@ func TestResourceReaderErrorHandling(t *testing.T) {
 		}
 		defer os.RemoveAll(tempDir)
 
-		logger := logging.NewTestLogger()
-		fs := afero.NewOsFs()
-
-		agentReader, err := agent.InitializeAgent(fs, tempDir, "", "", logger)
+		registry, err := agents.LoadDir(tempDir)
 		if err != nil {
 			t.Fatalf("Failed to initialize agent reader: %v", err)
 		}
-		defer agentReader.Close()
+		agentReader := agents.NewReader(registry)
 
 		// Test invalid URIs
 		invalidURIs := []string{
@@ -618,23 +576,20 @@ func TestResourceReaderErrorHandling(t *testing.T) {
 		defer os.Remove(tempDB.Name())
 		tempDB.Close()
 
-		pklresReader, err := pklres.InitializePklResource(tempDB.Name())
-		if err != nil {
-			t.Fatalf("Failed to initialize pklres reader: %v", err)
-		}
-		// Note: pklresReader doesn't have a Close method, database will be cleaned up when temp file is removed
+		pklresReader := NewTestPklresReader(t, tempDB.Name())
 
 		// Test invalid operations
 		invalidURIs := []string{
-			"pklres:/?op=invalid",               // Invalid operation
-			"pklres:/?op=set",                   // Missing required parameters
-			"pklres:/?op=set&type=exec",         // Missing id and value
-			"pklres:/?op=set&id=test&type=exec", // Missing value
-			"pklres:/?op=get",                   // Missing required parameters
-			"pklres:/?op=get&type=exec",         // Missing id
-			"pklres:/?op=delete",                // Missing required parameters
-			"pklres:/?op=clear",                 // Missing type
-			"pklres:/?op=list",                  // Missing type
+			"pklres://?op=invalid",                         // Invalid operation
+			"pklres://",                                    // Missing operation
+			"pklres://?op=set",                             // Missing required parameters
+			"pklres://?op=set&collection=test",             // Missing key and value
+			"pklres://?op=set&collection=test&key=command", // Missing value
+			"pklres://?op=get",                             // Missing required parameters
+			"pklres://?op=get&collection=test",             // Missing key
+			"pklres://?op=delete",                          // Missing required parameters
+			"pklres://?op=clear",                           // Unsupported operation
+			"pklres://?op=list",                            // Missing collection
 		}
 
 		for _, uri := range invalidURIs {
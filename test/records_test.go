@@ -0,0 +1,211 @@
+package test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/http"
+	"github.com/kdeps/schema/gen/llm"
+	"github.com/kdeps/schema/gen/python"
+	"github.com/kdeps/schema/pklres"
+	"github.com/kdeps/schema/records"
+)
+
+// TestRecordsDefaults tests that missing values take the defaults of the PKL resource functions
+func TestRecordsDefaults(t *testing.T) {
+	store := pklres.NewMemoryStorage()
+	_ = store.Set("run1", "@a/llm:1.0.0", "model", "null")
+
+	chat, err := records.ReadChat(store, "run1", "@a/llm:1.0.0")
+	if err != nil {
+		t.Fatalf("Failed to read chat: %v", err)
+	}
+	if *chat.Model != "llama3.2" || *chat.JSONResponse || *chat.TimeoutDuration != records.DefaultTimeout {
+		t.Errorf("Expected chat defaults, got model %q, jsonResponse %v, timeout %v", *chat.Model, *chat.JSONResponse, *chat.TimeoutDuration)
+	}
+	if chat.Prompt != nil || chat.Scenario != nil || chat.Timestamp != nil {
+		t.Errorf("Expected nil prompt, scenario and timestamp, got %+v", chat)
+	}
+
+	x, err := records.ReadExec(store, "run1", "@a/exec:1.0.0")
+	if err != nil {
+		t.Fatalf("Failed to read exec: %v", err)
+	}
+	if *x.ExitCode != 0 || x.TimeoutDuration.GoDuration().Seconds() != 60 || x.Env == nil || x.ItemValues == nil {
+		t.Errorf("Expected exec defaults, got %+v", x)
+	}
+
+	p, err := records.ReadPython(store, "run1", "@a/python:1.0.0")
+	if err != nil {
+		t.Fatalf("Failed to read python: %v", err)
+	}
+	if *p.ExitCode != 0 || p.PythonEnvironment != nil || *p.TimeoutDuration != records.DefaultTimeout {
+		t.Errorf("Expected python defaults, got %+v", p)
+	}
+
+	h, err := records.ReadHTTP(store, "run1", "@a/http:1.0.0")
+	if err != nil {
+		t.Fatalf("Failed to read http: %v", err)
+	}
+	if h.Method != "GET" || h.Response != nil || h.Headers == nil || *h.TimeoutDuration != records.DefaultTimeout {
+		t.Errorf("Expected http defaults, got %+v", h)
+	}
+}
+
+// TestRecordsDecode tests decoding values stored by the PKL modules
+func TestRecordsDecode(t *testing.T) {
+	chat, err := records.DecodeChat(map[string]string{
+		"model":            "mistral",
+		"prompt":           "Summarize",
+		"jsonResponse":     "true",
+		"jsonResponseKeys": `["summary","tags"]`,
+		"timeoutDuration":  "2.min",
+		"timestamp":        "1700000000.s",
+		"scenario":         `[{"Role":"system","Prompt":"Be brief"}]`,
+		"tools":            `[{"Name":"lookup","Script":"lookup.py","Parameters":{"q":{"Required":true,"Type":"string"}}}]`,
+		"itemValues":       `["a","b"]`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to decode chat: %v", err)
+	}
+	if *chat.Model != "mistral" || !*chat.JSONResponse || *chat.TimeoutDuration != (pkl.Duration{Value: 2, Unit: pkl.Minute}) {
+		t.Errorf("Unexpected chat %+v", chat)
+	}
+	if !reflect.DeepEqual(*chat.JSONResponseKeys, []string{"summary", "tags"}) || !reflect.DeepEqual(*chat.ItemValues, []string{"a", "b"}) {
+		t.Errorf("Expected keys and item values, got %v and %v", *chat.JSONResponseKeys, *chat.ItemValues)
+	}
+	if s := *chat.Scenario; len(s) != 1 || *s[0].Role != "system" || *s[0].Prompt != "Be brief" {
+		t.Errorf("Unexpected scenario %+v", s)
+	}
+	if tools := *chat.Tools; len(tools) != 1 || *tools[0].Name != "lookup" || *(*tools[0].Parameters)["q"].Type != "string" {
+		t.Errorf("Unexpected tools %+v", tools)
+	}
+
+	h, err := records.DecodeHTTP(map[string]string{
+		"method":   "POST",
+		"url":      "https://example.com",
+		"response": `{"Body":"ok","Headers":{"Content-Type":"text/plain"}}`,
+	})
+	if err != nil {
+		t.Fatalf("Failed to decode http: %v", err)
+	}
+	if h.Method != "POST" || *h.Response.Body != "ok" || (*h.Response.Headers)["Content-Type"] != "text/plain" {
+		t.Errorf("Unexpected http %+v", h)
+	}
+
+	x, err := records.DecodeExec(map[string]string{"exitCode": "2", "env": `{"HOME":"/root"}`, "timeoutDuration": "1m30s"})
+	if err != nil {
+		t.Fatalf("Failed to decode exec: %v", err)
+	}
+	if *x.ExitCode != 2 || (*x.Env)["HOME"] != "/root" || x.TimeoutDuration.GoDuration().Seconds() != 90 {
+		t.Errorf("Unexpected exec %+v", x)
+	}
+
+	invalid := map[string]map[string]string{
+		"exitCode":        {"exitCode": "one"},
+		"timeoutDuration": {"timeoutDuration": "soon"},
+		"env":             {"env": "HOME=/root"},
+	}
+	for key, entries := range invalid {
+		if _, err := records.DecodeExec(entries); err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected an error naming %s, got %v", key, err)
+		}
+	}
+	if _, err := records.DecodeChat(map[string]string{"scenario": "[{"}); err == nil {
+		t.Error("Expected an error for a malformed scenario")
+	}
+}
+
+// TestRecordsRoundTrip tests that written resources read back unchanged
+func TestRecordsRoundTrip(t *testing.T) {
+	store := pklres.NewMemoryStorage()
+	str := func(s string) *string { return &s }
+	code := 3
+	timeout := pkl.Duration{Value: 1.5, Unit: pkl.Second}
+	stamp := pkl.Duration{Value: 1700000000, Unit: pkl.Second}
+	yes := true
+
+	chat := &llm.ResourceChat{
+		Model:            str("llama3.2"),
+		Role:             str("user"),
+		Prompt:           str("Hi"),
+		Response:         str("Hello!"),
+		JSONResponse:     &yes,
+		JSONResponseKeys: &[]string{"greeting"},
+		TimeoutDuration:  &timeout,
+		Timestamp:        &stamp,
+		Scenario:         &[]*llm.MultiChat{{Role: str("system"), Prompt: str("Be kind")}},
+		Tools:            &[]*llm.Tool{{Name: str("echo"), Script: str("echo $1")}},
+		Files:            &[]string{"/tmp/a.png"},
+		ItemValues:       &[]string{},
+	}
+	x := &exec.ResourceExec{
+		Env:             &map[string]string{"A": "1"},
+		Command:         "echo hi",
+		Stdout:          str("hi\n"),
+		ExitCode:        &code,
+		ItemValues:      &[]string{"x"},
+		Timestamp:       &stamp,
+		TimeoutDuration: &timeout,
+	}
+	p := &python.ResourcePython{
+		Env:               &map[string]string{},
+		PythonEnvironment: str("venv"),
+		Script:            "print(1)",
+		Stderr:            str("warning"),
+		ExitCode:          &code,
+		File:              str("/tmp/out"),
+		ItemValues:        &[]string{},
+		TimeoutDuration:   &timeout,
+	}
+	h := &http.ResourceHTTPClient{
+		Method:          "PUT",
+		Url:             "https://example.com/items/1",
+		Data:            &[]string{`{"name":"item"}`},
+		Headers:         &map[string]string{"Accept": "application/json"},
+		Params:          &map[string]string{"v": "2"},
+		Response:        &http.ResponseBlock{Body: str("{}"), Headers: &map[string]string{"X-Id": "1"}},
+		ItemValues:      &[]string{},
+		TimeoutDuration: &timeout,
+	}
+
+	if err := records.WriteChat(store, "run1", "@a/llm:1.0.0", chat); err != nil {
+		t.Fatalf("Failed to write chat: %v", err)
+	}
+	if err := records.WriteExec(store, "run1", "@a/exec:1.0.0", x); err != nil {
+		t.Fatalf("Failed to write exec: %v", err)
+	}
+	if err := records.WritePython(store, "run1", "@a/python:1.0.0", p); err != nil {
+		t.Fatalf("Failed to write python: %v", err)
+	}
+	if err := records.WriteHTTP(store, "run1", "@a/http:1.0.0", h); err != nil {
+		t.Fatalf("Failed to write http: %v", err)
+	}
+
+	if v, _, _ := store.Get("run1", "@a/llm:1.0.0", "timeoutDuration"); v != "1.5.s" {
+		t.Errorf("Expected timeoutDuration 1.5.s, got %q", v)
+	}
+	if v, _, _ := store.Get("run1", "@a/llm:1.0.0", "scenario"); v != `[{"Role":"system","Prompt":"Be kind","Content":null,"Description":null}]` {
+		t.Errorf("Unexpected scenario %s", v)
+	}
+
+	gotChat, err := records.ReadChat(store, "run1", "@a/llm:1.0.0")
+	if err != nil || !reflect.DeepEqual(gotChat, chat) {
+		t.Errorf("Expected chat %+v, got %+v (%v)", chat, gotChat, err)
+	}
+	gotExec, err := records.ReadExec(store, "run1", "@a/exec:1.0.0")
+	if err != nil || !reflect.DeepEqual(gotExec, x) {
+		t.Errorf("Expected exec %+v, got %+v (%v)", x, gotExec, err)
+	}
+	gotPython, err := records.ReadPython(store, "run1", "@a/python:1.0.0")
+	if err != nil || !reflect.DeepEqual(gotPython, p) {
+		t.Errorf("Expected python %+v, got %+v (%v)", p, gotPython, err)
+	}
+	gotHTTP, err := records.ReadHTTP(store, "run1", "@a/http:1.0.0")
+	if err != nil || !reflect.DeepEqual(gotHTTP, h) {
+		t.Errorf("Expected http %+v, got %+v (%v)", h, gotHTTP, err)
+	}
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kdeps/schema/assets"
+)
+
+func schemaFixture(minPkl string) fstest.MapFS {
+	return fstest.MapFS{
+		"Workflow.pkl": &fstest.MapFile{Data: []byte(`@ModuleInfo { minPklVersion = "` + minPkl + `" }
+module org.kdeps.pkl.Workflow
+`)},
+		"Resource.pkl": &fstest.MapFile{Data: []byte(`@ModuleInfo { minPklVersion = "0.25.0" }
+module org.kdeps.pkl.Resource
+`)},
+	}
+}
+
+// TestSchemaRegistry tests version selection in the multi-schema registry
+func TestSchemaRegistry(t *testing.T) {
+	registry := assets.NewRegistry()
+	for version, minPkl := range map[string]string{
+		"0.4.3":        "0.25.3",
+		"0.4.5":        "0.28.2",
+		"v0.5.0":       "0.29.0",
+		"1.0.0-beta.1": "0.29.0",
+	} {
+		if _, err := registry.Register(version, schemaFixture(minPkl)); err != nil {
+			t.Fatalf("Failed to register %s: %v", version, err)
+		}
+	}
+
+	t.Run("Versions", func(t *testing.T) {
+		versions := registry.Versions()
+		expected := []string{"0.4.3", "0.4.5", "0.5.0", "1.0.0-beta.1"}
+		if len(versions) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, versions)
+		}
+		for i := range expected {
+			if versions[i] != expected[i] {
+				t.Errorf("Expected %v, got %v", expected, versions)
+			}
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		release, err := registry.Get("v0.4.5")
+		if err != nil {
+			t.Fatalf("Failed to get 0.4.5: %v", err)
+		}
+		if release.MinPklVersion != "0.28.2" {
+			t.Errorf("Expected minPklVersion 0.28.2, got %s", release.MinPklVersion)
+		}
+		if _, err := registry.Get("0.4.4"); !errors.Is(err, assets.ErrVersionNotFound) {
+			t.Errorf("Expected ErrVersionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		cases := map[string]string{
+			"":        "1.0.0-beta.1",
+			"^0.4":    "0.4.5",
+			"~0.4.3":  "0.4.5",
+			"0.4.x":   "0.4.5",
+			"<0.4.5":  "0.4.3",
+			">=0.5.0": "1.0.0-beta.1",
+			"0.5":     "0.5.0",
+		}
+		for constraint, expected := range cases {
+			release, err := registry.Resolve(constraint)
+			if err != nil {
+				t.Errorf("Resolve(%q) failed: %v", constraint, err)
+				continue
+			}
+			if release.Version != expected {
+				t.Errorf("Resolve(%q): expected %s, got %s", constraint, expected, release.Version)
+			}
+		}
+		if _, err := registry.Resolve("^2"); !errors.Is(err, assets.ErrVersionNotFound) {
+			t.Errorf("Expected ErrVersionNotFound for ^2, got %v", err)
+		}
+	})
+
+	t.Run("ForPklVersion", func(t *testing.T) {
+		release, err := registry.ForPklVersion("0.28.2")
+		if err != nil {
+			t.Fatalf("ForPklVersion failed: %v", err)
+		}
+		if release.Version != "0.4.5" {
+			t.Errorf("Expected 0.4.5 for PKL 0.28.2, got %s", release.Version)
+		}
+		if _, err := registry.ForPklVersion("0.20.0"); err == nil {
+			t.Error("Expected no release for PKL 0.20.0")
+		}
+	})
+
+	t.Run("ForPackageURI", func(t *testing.T) {
+		release, err := registry.ForPackageURI("package://schema.kdeps.com/core@0.4.3#/Workflow.pkl")
+		if err != nil {
+			t.Fatalf("ForPackageURI failed: %v", err)
+		}
+		if release.Version != "0.4.3" {
+			t.Errorf("Expected 0.4.3, got %s", release.Version)
+		}
+		if _, err := assets.PackageURIVersion("package://example.com/other@1.0.0#/A.pkl"); err == nil {
+			t.Error("Expected error for a non-schema package URI")
+		}
+	})
+
+	t.Run("Workspace", func(t *testing.T) {
+		release, _ := registry.Get("0.4.3")
+		files, err := release.ListPKLFiles()
+		if err != nil || len(files) != 2 {
+			t.Fatalf("Expected 2 files, got %v (%v)", files, err)
+		}
+		workspace, err := release.SetupPKLWorkspace("")
+		if err != nil {
+			t.Fatalf("Failed to set up workspace: %v", err)
+		}
+		defer workspace.Cleanup()
+		if _, err := os.Stat(workspace.GetAbsolutePath("Workflow.pkl")); err != nil {
+			t.Errorf("Workflow.pkl should be extracted: %v", err)
+		}
+	})
+}
+
+// TestDefaultSchemaRegistry tests that the embedded schema is registered
+func TestDefaultSchemaRegistry(t *testing.T) {
+	registry, err := assets.DefaultRegistry()
+	if err != nil {
+		t.Fatalf("Failed to load default registry: %v", err)
+	}
+	release, err := registry.Get(assets.SchemaVersion)
+	if err != nil {
+		t.Fatalf("Current schema version should be registered: %v", err)
+	}
+	files, err := release.ListPKLFiles()
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	embedded, _ := assets.ListPKLFiles()
+	if len(files) != len(embedded) {
+		t.Errorf("Expected %d files for current release, got %d", len(embedded), len(files))
+	}
+	if release.MinPklVersion == "" {
+		t.Error("Current release should declare a minPklVersion")
+	}
+
+	previous, err := registry.Resolve("^0.4")
+	if err != nil {
+		t.Fatalf("Previous schema release should be embedded: %v", err)
+	}
+	if previous.Version != "0.4.6" {
+		t.Errorf("Expected release 0.4.6 for ^0.4, got %s", previous.Version)
+	}
+	if _, err := previous.GetPKLFile("ReaderURI.pkl"); err == nil {
+		t.Error("Release 0.4.6 should not contain ReaderURI.pkl")
+	}
+}
@@ -2,8 +2,11 @@ package test
 
 import (
 	"context"
+	"os/exec"
+	"testing"
 
 	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/pklres"
 )
 
 // NewTestEvaluator returns a PKL evaluator with all modules/resources allowed and custom readers.
@@ -30,3 +33,24 @@ func NewTestEvaluator(readers ...pkl.ResourceReader) (pkl.Evaluator, error) {
 	}
 	return pkl.NewEvaluator(context.Background(), opts)
 }
+
+// NewTestPklresReader returns the pklres reader of the schema over a file
+// storage at path.
+func NewTestPklresReader(tb testing.TB, path string) *pklres.Reader {
+	tb.Helper()
+	store, err := pklres.NewFileStorage(path)
+	if err != nil {
+		tb.Fatalf("Failed to open pklres storage: %v", err)
+	}
+	return pklres.NewReader(store)
+}
+
+// RequirePkl skips tb when the pkl binary is not installed. Tests call it
+// before creating an evaluator, so that any other failure to create one fails
+// the test.
+func RequirePkl(tb testing.TB) {
+	tb.Helper()
+	if _, err := exec.LookPath("pkl"); err != nil {
+		tb.Skipf("pkl binary not available: %v", err)
+	}
+}
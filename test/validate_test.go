@@ -0,0 +1,204 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apple/pkl-go/pkl"
+	"github.com/kdeps/schema/assets"
+	apiserver "github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/docker"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/python"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/workflow"
+	"github.com/kdeps/schema/validate"
+)
+
+// parityCase is one input for a rule that exists both in PKL and in Go.
+type parityCase struct {
+	// rule is a PKL expression of a function taking the input, e.g. "validation.isValidEnvName".
+	rule   string
+	goRule func(string) error
+	inputs []string
+}
+
+func ptrRule(fn func(*string) error) func(string) error {
+	return func(s string) error { return fn(&s) }
+}
+
+var parityCases = []parityCase{
+	{"validation.isValidHttpMethod", ptrRule(validate.HTTPMethod), []string{"GET", "post", "Options", "FETCH", "GETX", ""}},
+	{"validation.isValidActionId", ptrRule(validate.ActionID), []string{"fetch", "@agent/action:1.0.0", "@agent/act-ion", "@agent", "bad id", "@a/b:c!"}},
+	{"validation.isValidEnvName", ptrRule(validate.EnvName), []string{"PATH", "_x1", "1BAD", "with-dash", ""}},
+	{"validation.isValidVersion", ptrRule(validate.Version), []string{"1.2.3", "1.2.*", "1.2.3-alpha+build", "v1.0", "1..2"}},
+	{"validation.isValidUrl", ptrRule(validate.URL), []string{"https://kdeps.com", "http://localhost:8080/x", "ftp://host", "https://"}},
+	{"validation.isValidIdentifier", ptrRule(validate.Identifier), []string{"agent-1", "a_b", "1agent", "-x"}},
+	{"validation.isValidFilePath", ptrRule(validate.FilePath), []string{"/tmp/file.txt", "/a/b/", "relative/path", "/bad path"}},
+	{"workflow.isValidName.apply", validate.WorkflowName, []string{"myAgent", "my_agent2", "my-agent", ""}},
+	{"workflow.isValidWorkflow.apply", validate.WorkflowReference, []string{"@example", "@example/action:1.0.0", "example", "@ex/a/b"}},
+	{"workflow.isValidAction.apply", validate.WorkflowAction, []string{"answer", "@agent/action:1.0.0", "@agent", "a b"}},
+	{"workflow.isValidVersion.apply", validate.WorkflowVersion, []string{"1.0.0", "2.1", "1.0.0-beta", "x"}},
+	{"resource.isValidActionID.apply", validate.ResourceActionID, []string{"fetchData", "@agent/fetch:2.0", "fetch data"}},
+	{"resource.isValidDependency.apply", validate.ResourceDependency, []string{"fetchData", "@agent/fetch", "@agent"}},
+	{"(new docker.DockerSettings {}).isValidParams.apply", validate.DockerParam, []string{"HTTP_PROXY", "9lives", "a-b"}},
+	{"(new exec.ResourceExec {}).isValidEnv.apply", validate.ExecEnv, []string{"HOME", "1HOME"}},
+	{"(new python.ResourcePython {}).isValidEnv.apply", validate.PythonEnv, []string{"PYTHONPATH", "PY-PATH"}},
+	{"(new apiserver.APIServerRoutes {}).isValidHTTPMethod.apply", validate.APIServerMethod, []string{"delete", "GETS", "TRACE"}},
+	{"(new apiserver.CORS {}).isValidHTTPMethod.apply", validate.CORSMethod, []string{"HEAD", "CONNECT"}},
+}
+
+// TestValidateRules tests the single-value validators and their PKL messages
+func TestValidateRules(t *testing.T) {
+	method := "FETCH"
+	err := validate.HTTPMethod(&method)
+	if err == nil || err.Error() != "Error: Invalid HTTP method. Expected: GET, POST, PUT, PATCH, DELETE, HEAD, or OPTIONS (case insensitive). Provided: FETCH" {
+		t.Errorf("Unexpected HTTP method error: %v", err)
+	}
+	if err := validate.HTTPMethod(nil); err == nil || !strings.HasSuffix(err.Error(), "Provided: null") {
+		t.Errorf("Expected null to be rejected, got %v", err)
+	}
+
+	blank := "   "
+	if err := validate.NotNullOrEmpty(&blank, "Name"); err == nil {
+		t.Error("Whitespace should count as empty")
+	}
+
+	long := strings.Repeat("é", 5)
+	if err := validate.Length(&long, "Name", 0, 4); err == nil || !strings.Contains(err.Error(), "Provided: 5 characters") {
+		t.Errorf("Expected length error counting characters, got %v", err)
+	}
+	if err := validate.Length(nil, "Name", 1, 4); err == nil || !strings.Contains(err.Error(), "minimum length 1") {
+		t.Errorf("Expected null length error, got %v", err)
+	}
+
+	port := 70000
+	if err := validate.Range(&port, "Port", 1, 65535); err == nil || !strings.Contains(err.Error(), "between 1 and 65535 (inclusive)") {
+		t.Errorf("Expected range error, got %v", err)
+	}
+
+	if err := validate.APIServerMethod("GETS"); err == nil {
+		t.Error("Method patterns should require a full match")
+	}
+}
+
+// TestValidateStructs tests field paths of the struct validators
+func TestValidateStructs(t *testing.T) {
+	t.Run("Workflow", func(t *testing.T) {
+		env := map[string]string{"GOOD": "1", "1BAD": "2"}
+		corsMethods := []string{"GET", "TRACE"}
+		wf := &workflow.WorkflowImpl{
+			AgentID:        "my-agent",
+			Version:        "1.0.0",
+			TargetActionID: "answer",
+			Workflows:      []string{"@ok/action", "notExternal"},
+			Settings: &project.Settings{
+				APIServer: &apiserver.APIServerSettings{
+					Routes: &[]*apiserver.APIServerRoutes{
+						{Path: "/api", Methods: []string{"GET", "FETCH"}},
+					},
+					CORS: &apiserver.CORS{AllowMethods: &corsMethods},
+				},
+				AgentSettings: &docker.DockerSettings{Env: &env},
+			},
+		}
+
+		errs := validate.Workflow(wf)
+		expected := []string{
+			"AgentID",
+			"Workflows[1]",
+			"Settings.APIServer.Routes[0].Methods[1]",
+			"Settings.APIServer.CORS.AllowMethods[1]",
+			`Settings.AgentSettings.Env["1BAD"]`,
+		}
+		paths := errs.Paths()
+		if len(paths) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, paths)
+		}
+		for i := range expected {
+			if paths[i] != expected[i] {
+				t.Errorf("Expected path %s, got %s", expected[i], paths[i])
+			}
+		}
+		if errs[0].Message != validate.WorkflowNameMessage || errs[0].Value != "my-agent" {
+			t.Errorf("Unexpected first error %+v", errs[0])
+		}
+
+		var fe *validate.FieldError
+		if err := errs.Err(); !errors.As(err, &fe) || fe.Path != "AgentID" {
+			t.Errorf("Expected errors.As to find the first FieldError, got %v", err)
+		}
+	})
+
+	t.Run("Resource", func(t *testing.T) {
+		requires := []string{"fetch", "bad dep"}
+		execEnv := map[string]string{"A-B": "x"}
+		pyEnv := map[string]string{"OK": "x"}
+		res := &resource.ResourceImpl{
+			ActionID: "@agent/answer:1.0.0",
+			Requires: &requires,
+			Run: &resource.ResourceAction{
+				Exec:   &exec.ResourceExec{Env: &execEnv},
+				Python: &python.ResourcePython{Env: &pyEnv},
+			},
+		}
+		errs := validate.Resource(res)
+		paths := errs.Paths()
+		if len(paths) != 2 || paths[0] != "Requires[1]" || paths[1] != `Run.Exec.Env["A-B"]` {
+			t.Errorf("Unexpected paths %v", paths)
+		}
+		if errs[1].Message != validate.ExecEnvMessage {
+			t.Errorf("Expected the Exec.pkl message, got %s", errs[1].Message)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		wf := &workflow.WorkflowImpl{AgentID: "agent", Version: "1.0.0", TargetActionID: "answer", Workflows: []string{}}
+		if err := validate.Workflow(wf).Err(); err != nil {
+			t.Errorf("Expected a valid workflow, got %v", err)
+		}
+	})
+}
+
+// TestValidateParity runs the same inputs through the PKL schema and the Go validators
+func TestValidateParity(t *testing.T) {
+	ctx := context.Background()
+	evaluator, err := assets.NewEvaluator(ctx)
+	if err != nil {
+		t.Skipf("PKL evaluator not available: %v", err)
+	}
+	defer evaluator.Close()
+
+	header := `
+import "kdeps-schema:/Validation.pkl" as validation
+import "kdeps-schema:/Workflow.pkl" as workflow
+import "kdeps-schema:/Resource.pkl" as resource
+import "kdeps-schema:/Docker.pkl" as docker
+import "kdeps-schema:/Exec.pkl" as exec
+import "kdeps-schema:/Python.pkl" as python
+import "kdeps-schema:/APIServer.pkl" as apiserver
+`
+	for _, pc := range parityCases {
+		for _, input := range pc.inputs {
+			source := pkl.TextSource(header + "result = " + pc.rule + `(#"` + input + `"#)`)
+			var ok bool
+			pklErr := evaluator.EvaluateExpression(ctx, source, "result", &ok)
+			if pklErr != nil && strings.Contains(pklErr.Error(), "package://") {
+				t.Skipf("Schema package dependencies not available offline: %v", pklErr)
+			}
+			goErr := pc.goRule(input)
+
+			switch {
+			case pklErr == nil && goErr != nil:
+				t.Errorf("%s(%q): PKL accepts, Go rejects with %v", pc.rule, input, goErr)
+			case pklErr != nil && goErr == nil:
+				t.Errorf("%s(%q): Go accepts, PKL rejects with %v", pc.rule, input, pklErr)
+			case pklErr != nil && !strings.Contains(pklErr.Error(), goErr.Error()):
+				t.Errorf("%s(%q): message mismatch\nPKL: %v\nGo:  %v", pc.rule, input, pklErr, goErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kdeps/schema/assets"
+)
+
+// TestSharedPKLWorkspace tests the shared, reference-counted workspace cache
+func TestSharedPKLWorkspace(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(assets.SchemaCacheEnv, root)
+	t.Cleanup(func() { makeWritable(root) })
+
+	first, err := assets.SetupSharedPKLWorkspace()
+	if err != nil {
+		t.Fatalf("Failed to set up shared workspace: %v", err)
+	}
+	second, err := assets.SetupSharedPKLWorkspace()
+	if err != nil {
+		t.Fatalf("Failed to set up second shared workspace: %v", err)
+	}
+
+	t.Run("SameDirectory", func(t *testing.T) {
+		if first.Directory != second.Directory {
+			t.Errorf("Expected shared directory, got %s and %s", first.Directory, second.Directory)
+		}
+		manifest, _ := assets.LoadManifest()
+		if filepath.Base(first.Directory) != manifest.Digest() {
+			t.Errorf("Expected directory keyed by %s, got %s", manifest.Digest(), first.Directory)
+		}
+		if !first.IsShared() || first.IsTemporary() {
+			t.Error("Shared workspace should be shared and not temporary")
+		}
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		if err := first.VerifyIntegrity(); err != nil {
+			t.Errorf("Shared workspace should verify: %v", err)
+		}
+		info, err := os.Stat(first.GetAbsolutePath("Workflow.pkl"))
+		if err != nil {
+			t.Fatalf("Failed to stat Workflow.pkl: %v", err)
+		}
+		if info.Mode().Perm()&0222 != 0 {
+			t.Errorf("Expected read-only file, got mode %v", info.Mode())
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		if err := first.Cleanup(); err != nil {
+			t.Fatalf("Failed to clean up: %v", err)
+		}
+		if err := first.Cleanup(); err != nil {
+			t.Fatalf("Repeated cleanup should be a no-op: %v", err)
+		}
+		if err := second.Cleanup(); err != nil {
+			t.Fatalf("Failed to clean up second workspace: %v", err)
+		}
+		if _, err := os.Stat(first.GetAbsolutePath("Workflow.pkl")); err != nil {
+			t.Errorf("Cached files should survive cleanup: %v", err)
+		}
+	})
+
+	t.Run("GarbageCollection", func(t *testing.T) {
+		stale := filepath.Join(root, "0000stale")
+		if err := os.MkdirAll(stale, 0755); err != nil {
+			t.Fatalf("Failed to create stale entry: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(stale, "Workflow.pkl"), []byte("module old"), 0444); err != nil {
+			t.Fatalf("Failed to write stale file: %v", err)
+		}
+		os.Chmod(stale, 0555)
+
+		removed, err := assets.GCSchemaCache()
+		if err != nil {
+			t.Fatalf("Failed to collect garbage: %v", err)
+		}
+		if len(removed) != 1 || removed[0] != "0000stale" {
+			t.Errorf("Expected stale entry to be removed, got %v", removed)
+		}
+		if _, err := os.Stat(stale); !os.IsNotExist(err) {
+			t.Errorf("Stale entry should be gone, got %v", err)
+		}
+		if _, err := os.Stat(first.Directory); err != nil {
+			t.Errorf("Current entry should be kept: %v", err)
+		}
+	})
+
+	t.Run("Reuse", func(t *testing.T) {
+		workspace, err := assets.SetupSharedPKLWorkspace()
+		if err != nil {
+			t.Fatalf("Failed to reuse shared workspace: %v", err)
+		}
+		defer workspace.Cleanup()
+		if workspace.Directory != first.Directory {
+			t.Errorf("Expected %s to be reused, got %s", first.Directory, workspace.Directory)
+		}
+	})
+}
+
+// makeWritable restores write permission so the test framework can remove dir.
+func makeWritable(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(path, 0755)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,199 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	apiserver "github.com/kdeps/schema/gen/api_server"
+	"github.com/kdeps/schema/gen/docker"
+	"github.com/kdeps/schema/gen/exec"
+	"github.com/kdeps/schema/gen/http"
+	"github.com/kdeps/schema/gen/project"
+	"github.com/kdeps/schema/gen/python"
+	"github.com/kdeps/schema/gen/resource"
+	"github.com/kdeps/schema/gen/workflow"
+)
+
+// collector accumulates field errors below a path prefix.
+type collector struct {
+	errs Errors
+}
+
+func (c *collector) check(path string, err error) {
+	if err == nil {
+		return
+	}
+	fe, ok := err.(*FieldError)
+	if !ok {
+		c.errs = append(c.errs, &FieldError{Path: path, Message: err.Error()})
+		return
+	}
+	withPath := *fe
+	withPath.Path = path
+	c.errs = append(c.errs, &withPath)
+}
+
+func (c *collector) list(path string, values []string, rule func(string) error) {
+	for i, v := range values {
+		c.check(fmt.Sprintf("%s[%d]", path, i), rule(v))
+	}
+}
+
+func (c *collector) optionalList(path string, values *[]string, rule func(string) error) {
+	if values != nil {
+		c.list(path, *values, rule)
+	}
+}
+
+// keys validates the keys of a mapping in sorted order.
+func keys[V any](c *collector, path string, m *map[string]V, rule func(string) error) {
+	if m == nil {
+		return
+	}
+	names := make([]string, 0, len(*m))
+	for k := range *m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		c.check(path+"["+strconv.Quote(k)+"]", rule(k))
+	}
+}
+
+func join(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	return prefix + "." + field
+}
+
+// Workflow validates a workflow against the constraints of Workflow.pkl and the
+// modules it references.
+func Workflow(w workflow.Workflow) Errors {
+	var c collector
+	c.check("AgentID", WorkflowName(w.GetAgentID()))
+	c.check("Version", WorkflowVersion(w.GetVersion()))
+	c.check("TargetActionID", WorkflowAction(w.GetTargetActionID()))
+	c.list("Workflows", w.GetWorkflows(), WorkflowReference)
+	c.settings("Settings", w.GetSettings())
+	return c.errs
+}
+
+// Settings validates project settings.
+func Settings(s *project.Settings) Errors {
+	var c collector
+	c.settings("", s)
+	return c.errs
+}
+
+func (c *collector) settings(path string, s *project.Settings) {
+	if s == nil {
+		return
+	}
+	c.apiServerSettings(join(path, "APIServer"), s.APIServer)
+	c.dockerSettings(join(path, "AgentSettings"), s.AgentSettings)
+}
+
+// APIServerSettings validates API server settings, including route and CORS methods.
+func APIServerSettings(s *apiserver.APIServerSettings) Errors {
+	var c collector
+	c.apiServerSettings("", s)
+	return c.errs
+}
+
+func (c *collector) apiServerSettings(path string, s *apiserver.APIServerSettings) {
+	if s == nil {
+		return
+	}
+	if s.Routes != nil {
+		for i, route := range *s.Routes {
+			if route != nil {
+				c.list(fmt.Sprintf("%s[%d].Methods", join(path, "Routes"), i), route.Methods, APIServerMethod)
+			}
+		}
+	}
+	if s.CORS != nil {
+		c.optionalList(join(path, "CORS.AllowMethods"), s.CORS.AllowMethods, CORSMethod)
+	}
+}
+
+// DockerSettings validates the Args and Env names of agent Docker settings.
+func DockerSettings(s *docker.DockerSettings) Errors {
+	var c collector
+	c.dockerSettings("", s)
+	return c.errs
+}
+
+func (c *collector) dockerSettings(path string, s *docker.DockerSettings) {
+	if s == nil {
+		return
+	}
+	keys(c, join(path, "Args"), s.Args, DockerParam)
+	keys(c, join(path, "Env"), s.Env, DockerParam)
+}
+
+// Resource validates a resource against the constraints of Resource.pkl and the
+// action modules it contains.
+func Resource(r resource.Resource) Errors {
+	var c collector
+	c.check("ActionID", ResourceActionID(r.GetActionID()))
+	c.optionalList("Requires", r.GetRequires(), ResourceDependency)
+	c.resourceAction("Run", r.GetRun())
+	return c.errs
+}
+
+// ResourceAction validates the actions of a resource's Run block.
+func ResourceAction(a *resource.ResourceAction) Errors {
+	var c collector
+	c.resourceAction("", a)
+	return c.errs
+}
+
+func (c *collector) resourceAction(path string, a *resource.ResourceAction) {
+	if a == nil {
+		return
+	}
+	c.resourceExec(join(path, "Exec"), a.Exec)
+	c.resourcePython(join(path, "Python"), a.Python)
+	c.resourceHTTPClient(join(path, "HTTPClient"), a.HTTPClient)
+}
+
+// ResourceExec validates the env names of an exec action.
+func ResourceExec(e *exec.ResourceExec) Errors {
+	var c collector
+	c.resourceExec("", e)
+	return c.errs
+}
+
+func (c *collector) resourceExec(path string, e *exec.ResourceExec) {
+	if e != nil {
+		keys(c, join(path, "Env"), e.Env, ExecEnv)
+	}
+}
+
+// ResourcePython validates the env names of a python action.
+func ResourcePython(p *python.ResourcePython) Errors {
+	var c collector
+	c.resourcePython("", p)
+	return c.errs
+}
+
+func (c *collector) resourcePython(path string, p *python.ResourcePython) {
+	if p != nil {
+		keys(c, join(path, "Env"), p.Env, PythonEnv)
+	}
+}
+
+// ResourceHTTPClient validates the method of an HTTP client action.
+func ResourceHTTPClient(h *http.ResourceHTTPClient) Errors {
+	var c collector
+	c.resourceHTTPClient("", h)
+	return c.errs
+}
+
+func (c *collector) resourceHTTPClient(path string, h *http.ResourceHTTPClient) {
+	if h != nil {
+		c.check(join(path, "Method"), HTTPMethod(&h.Method))
+	}
+}
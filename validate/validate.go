@@ -0,0 +1,298 @@
+// Package validate applies the constraints of the PKL schema to the generated Go
+// types without a PKL evaluator.
+//
+// The regular expressions and error messages mirror Validation.pkl and the
+// constraint lambdas of Workflow.pkl, Resource.pkl, Docker.pkl, Exec.pkl,
+// Python.pkl and APIServer.pkl, so a value rejected here is rejected by PKL with
+// the same message. Struct validators collect every violation with its field path:
+//
+//	if err := validate.Workflow(wf).Err(); err != nil {
+//	    var errs validate.Errors
+//	    errors.As(err, &errs)
+//	    for _, fe := range errs {
+//	        fmt.Printf("%s: %s\n", fe.Path, fe.Message)
+//	    }
+//	}
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is a single constraint violation.
+type FieldError struct {
+	// Path locates the field, e.g. "Settings.APIServer.Routes[0].Methods[1]".
+	// It is empty for errors returned by the single-value validators.
+	Path string
+
+	// Value is the rejected value, or "null".
+	Value string
+
+	// Message is the PKL error message.
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Errors collects the violations of a struct validator.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the individual field errors for errors.Is and errors.As.
+func (e Errors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, fe := range e {
+		errs = append(errs, fe)
+	}
+	return errs
+}
+
+// Err returns nil if there are no violations, or the Errors otherwise.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Paths returns the field paths of all violations.
+func (e Errors) Paths() []string {
+	paths := make([]string, 0, len(e))
+	for _, fe := range e {
+		paths = append(paths, fe.Path)
+	}
+	return paths
+}
+
+// Patterns from Validation.pkl. PKL's String.matches requires the whole string to
+// match, so every pattern is evaluated with fullMatch.
+var (
+	// HTTPMethodRegex is standardHttpMethodRegex.
+	HTTPMethodRegex = regexp.MustCompile(`^(?i:(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS))$`)
+
+	// ActionIDRegex is standardActionIdRegex, also used for Workflow and Resource actions.
+	ActionIDRegex = regexp.MustCompile(`^(\w+|@\w+(/[\w-]+)(:[\w.]+)?)$`)
+
+	// EnvNameRegex is standardEnvNameRegex, also used for Docker params and Exec/Python env names.
+	EnvNameRegex = regexp.MustCompile(`^[a-zA-Z_]\w*$`)
+
+	// VersionRegex is standardVersionRegex.
+	VersionRegex = regexp.MustCompile(`^(\d+\.)?(\d+\.)?(\*|\d+)(-[\w.-]+)?(\+[\w.-]+)?$`)
+
+	// URLRegex is standardUrlRegex.
+	URLRegex = regexp.MustCompile(`^https?://[\w.-]+(:\d+)?(/.*)?$`)
+
+	// IdentifierRegex is standardIdentifierRegex.
+	IdentifierRegex = regexp.MustCompile(`^[a-zA-Z][\w-]*$`)
+
+	// FilePathRegex is standardFilePathRegex.
+	FilePathRegex = regexp.MustCompile(`^(/[\w.-]+)+/?$`)
+)
+
+// Patterns from Workflow.pkl.
+var (
+	// WorkflowNameRegex is Workflow.nameStringRegex.
+	WorkflowNameRegex = regexp.MustCompile(`(^\w+$)`)
+
+	// WorkflowReferenceRegex is Workflow.workflowStringRegex.
+	WorkflowReferenceRegex = regexp.MustCompile(`^@[\w-]+(/[\w-]+)?(:[\w.]+)?$`)
+
+	// WorkflowVersionRegex is Workflow.versionStringRegex.
+	WorkflowVersionRegex = regexp.MustCompile(`^(\d+\.)?(\d+\.)?(\*|\d+)$`)
+)
+
+var anchoredRegexes sync.Map
+
+// fullMatch reports whether re matches all of s, like PKL's String.matches.
+func fullMatch(re *regexp.Regexp, s string) bool {
+	anchored, ok := anchoredRegexes.Load(re)
+	if !ok {
+		anchored, _ = anchoredRegexes.LoadOrStore(re, regexp.MustCompile(`^(?:`+re.String()+`)$`))
+	}
+	return anchored.(*regexp.Regexp).MatchString(s)
+}
+
+// FormatValidationError mirrors Common.formatValidationError.
+func FormatValidationError(fieldName, expectedFormat string, providedValue *string) string {
+	provided := "null"
+	if providedValue != nil {
+		provided = *providedValue
+	}
+	return fmt.Sprintf("Error: Invalid %s. Expected: %s. Provided: %s", fieldName, expectedFormat, provided)
+}
+
+func displayValue(value *string) string {
+	if value == nil {
+		return "null"
+	}
+	return *value
+}
+
+func matchOrError(re *regexp.Regexp, value *string, fieldName, expectedFormat string) error {
+	if value != nil && fullMatch(re, *value) {
+		return nil
+	}
+	return &FieldError{Value: displayValue(value), Message: FormatValidationError(fieldName, expectedFormat, value)}
+}
+
+// HTTPMethod mirrors Validation.isValidHttpMethod.
+func HTTPMethod(method *string) error {
+	return matchOrError(HTTPMethodRegex, method, "HTTP method", "GET, POST, PUT, PATCH, DELETE, HEAD, or OPTIONS (case insensitive)")
+}
+
+// ActionID mirrors Validation.isValidActionId.
+func ActionID(actionID *string) error {
+	return matchOrError(ActionIDRegex, actionID, "action ID", "alphanumeric string or @package/action:version format")
+}
+
+// EnvName mirrors Validation.isValidEnvName.
+func EnvName(envName *string) error {
+	return matchOrError(EnvNameRegex, envName, "environment variable name", "start with letter/underscore, contain only alphanumeric characters and underscores")
+}
+
+// Version mirrors Validation.isValidVersion.
+func Version(version *string) error {
+	return matchOrError(VersionRegex, version, "version", "semantic version format (e.g., 1.2.3, 1.2.*, 1.2.3-alpha+build)")
+}
+
+// URL mirrors Validation.isValidUrl.
+func URL(url *string) error {
+	return matchOrError(URLRegex, url, "URL", "valid HTTP or HTTPS URL format")
+}
+
+// Identifier mirrors Validation.isValidIdentifier.
+func Identifier(identifier *string) error {
+	return matchOrError(IdentifierRegex, identifier, "identifier", "start with letter, contain only alphanumeric characters and hyphens")
+}
+
+// FilePath mirrors Validation.isValidFilePath.
+func FilePath(filePath *string) error {
+	return matchOrError(FilePathRegex, filePath, "file path", "Unix-style absolute path (e.g., /path/to/file)")
+}
+
+// NotNullOrEmpty mirrors Validation.isNotNullOrEmpty. Whitespace-only strings are empty.
+func NotNullOrEmpty(value *string, fieldName string) error {
+	if value != nil && strings.TrimSpace(*value) != "" {
+		return nil
+	}
+	return &FieldError{Value: displayValue(value), Message: FormatValidationError(fieldName, "non-null and non-empty string", value)}
+}
+
+// Length mirrors Validation.isValidLength. Length is counted in characters.
+func Length(value *string, fieldName string, minLength, maxLength int) error {
+	if value == nil {
+		if minLength == 0 {
+			return nil
+		}
+		null := "null"
+		return &FieldError{Value: null, Message: FormatValidationError(fieldName, fmt.Sprintf("non-null string with minimum length %d", minLength), &null)}
+	}
+	length := utf8.RuneCountInString(*value)
+	if length >= minLength && length <= maxLength {
+		return nil
+	}
+	provided := fmt.Sprintf("%d characters", length)
+	return &FieldError{Value: *value, Message: FormatValidationError(fieldName+" length", fmt.Sprintf("between %d and %d characters", minLength, maxLength), &provided)}
+}
+
+// LengthWithDefaults mirrors Validation.isValidLengthWithDefaults (0 to 1000 characters).
+func LengthWithDefaults(value *string, fieldName string) error {
+	return Length(value, fieldName, 0, 1000)
+}
+
+// Range mirrors Validation.isValidRange.
+func Range(value *int, fieldName string, minValue, maxValue int) error {
+	if value == nil {
+		null := "null"
+		return &FieldError{Value: null, Message: FormatValidationError(fieldName, fmt.Sprintf("non-null integer between %d and %d", minValue, maxValue), &null)}
+	}
+	if *value >= minValue && *value <= maxValue {
+		return nil
+	}
+	provided := strconv.Itoa(*value)
+	return &FieldError{Value: provided, Message: FormatValidationError(fieldName, fmt.Sprintf("between %d and %d (inclusive)", minValue, maxValue), &provided)}
+}
+
+// Messages of the constraint lambdas declared in the schema modules.
+const (
+	WorkflowNameMessage      = "Error: Invalid name: The name contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers) and is not empty."
+	WorkflowReferenceMessage = "External workflows must start with `@`, followed by a package name, with an optional `/action` path segment and an optional `:version` (e.g., `@example`, `@example/action`, or `@example/action:1.0.0`)."
+	WorkflowActionMessage    = "Default action must be either a simple alphanumeric string or start with `@`, followed by `/action` and an optional `:version` (e.g., `@agent/action:1.0.0`)."
+	WorkflowVersionMessage   = "Error: Invalid version format. Expected format: major.minor.patch or major.minor."
+	ResourceActionIDMessage  = "Error: Invalid id name: The id contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers) and is not empty."
+	ResourceRequiresMessage  = "Action must be either a simple alphanumeric string or start with `@`, followed by `/action` and an optional `:version` (e.g., `@agent/action:1.0.0`)."
+	DockerParamsMessage      = "Error: Invalid params name: The params name contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers), does not start with a number, and is not empty."
+	ExecEnvMessage           = "Error: Invalid env name: The env name contains invalid characters. Please ensure it only includes alphanumeric characters (letters and numbers), does not start with a number, and is not empty."
+	PythonEnvMessage         = "Error: Invalid environment variable name. Ensure it includes only alphanumeric characters or underscores, starts with a letter or underscore, and is not empty."
+	APIServerMethodMessage   = "Error: Unsupported HTTP method. The provided HTTP method is not supported. Please use one of the following methods: GET, POST, PUT, PATCH, DELETE, OPTIONS, or HEAD."
+	CORSMethodMessage        = "Unsupported HTTP method. Use: GET, POST, PUT, PATCH, DELETE, OPTIONS, HEAD"
+	APIRequestMethodMessage  = "Error: Invalid HTTP method. The provided HTTP method is not supported. Please use one of the following methods: GET, POST, PUT, PATCH, DELETE, OPTIONS, or HEAD."
+)
+
+// apiServerMethodRegex is the method pattern of APIServer.pkl, CORS and APIServerRequest.pkl.
+var apiServerMethodRegex = regexp.MustCompile(`^(?i:(GET|POST|PUT|PATCH|OPTIONS|DELETE|HEAD))`)
+
+func lambda(re *regexp.Regexp, message string) func(string) error {
+	return func(s string) error {
+		if fullMatch(re, s) {
+			return nil
+		}
+		return &FieldError{Value: s, Message: message}
+	}
+}
+
+// Constraint lambdas of the schema modules, named after the PKL module and property.
+var (
+	// WorkflowName mirrors Workflow.isValidName (AgentID).
+	WorkflowName = lambda(WorkflowNameRegex, WorkflowNameMessage)
+
+	// WorkflowReference mirrors Workflow.isValidWorkflow (Workflows).
+	WorkflowReference = lambda(WorkflowReferenceRegex, WorkflowReferenceMessage)
+
+	// WorkflowAction mirrors Workflow.isValidAction (TargetActionID).
+	WorkflowAction = lambda(ActionIDRegex, WorkflowActionMessage)
+
+	// WorkflowVersion mirrors Workflow.isValidVersion (Version).
+	WorkflowVersion = lambda(WorkflowVersionRegex, WorkflowVersionMessage)
+
+	// ResourceActionID mirrors Resource.isValidActionID (ActionID).
+	ResourceActionID = lambda(ActionIDRegex, ResourceActionIDMessage)
+
+	// ResourceDependency mirrors Resource.isValidDependency (Requires).
+	ResourceDependency = lambda(ActionIDRegex, ResourceRequiresMessage)
+
+	// DockerParam mirrors DockerSettings.isValidParams (Args and Env keys).
+	DockerParam = lambda(EnvNameRegex, DockerParamsMessage)
+
+	// ExecEnv mirrors ResourceExec.isValidEnv (Env keys).
+	ExecEnv = lambda(EnvNameRegex, ExecEnvMessage)
+
+	// PythonEnv mirrors ResourcePython.isValidEnv (Env keys).
+	PythonEnv = lambda(EnvNameRegex, PythonEnvMessage)
+
+	// APIServerMethod mirrors APIServerRoutes.isValidHTTPMethod (Methods).
+	APIServerMethod = lambda(apiServerMethodRegex, APIServerMethodMessage)
+
+	// CORSMethod mirrors CORS.isValidHTTPMethod (AllowMethods).
+	CORSMethod = lambda(apiServerMethodRegex, CORSMethodMessage)
+
+	// APIRequestMethod mirrors APIServerRequest.isValidHTTPMethod.
+	APIRequestMethod = lambda(apiServerMethodRegex, APIRequestMethodMessage)
+)